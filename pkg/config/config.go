@@ -10,6 +10,34 @@ import (
 type YamcsPluginConfiguration struct {
 	Endpoints map[string]*YamcsEndpointConfiguration `json:"endpoints"`
 	Hosts     map[string]*YamcsHostConfiguration     `json:"hosts"`
+	Cache     *HistoryCacheConfiguration             `json:"cache,omitempty"`
+	Audit     *AuditConfiguration                    `json:"audit,omitempty"`
+}
+
+// AuditConfiguration sets where the operator actions taken through the
+// datasource are recorded, and for how long.
+type AuditConfiguration struct {
+	// Disabled turns the audit log off.
+	Disabled bool `json:"disabled"`
+	// Directory holds the audit files. Defaults to a directory named after the
+	// datasource in the Grafana data path.
+	Directory string `json:"directory,omitempty"`
+	// RetentionDays is how long entries are kept. Defaults to 90 days.
+	RetentionDays int `json:"retentionDays,omitempty"`
+	// ReaderRole is the least Grafana role allowed to query the audit log.
+	// Defaults to Editor.
+	ReaderRole string `json:"readerRole,omitempty"`
+}
+
+// HistoryCacheConfiguration tunes the in-memory cache of historical query results.
+type HistoryCacheConfiguration struct {
+	// Disabled turns the cache off; every query goes to Yamcs.
+	Disabled bool `json:"disabled"`
+	// MaxMemoryMB is the memory budget of the cache. Defaults to 64 MB.
+	MaxMemoryMB int `json:"maxMemoryMB"`
+	// SettleSeconds is how long after the fact archive data is considered final
+	// and may be cached. Defaults to 60 seconds.
+	SettleSeconds int `json:"settleSeconds"`
 }
 
 type YamcsEndpointConfiguration struct {
@@ -18,6 +46,60 @@ type YamcsEndpointConfiguration struct {
 	Host        string `json:"host"`
 	Instance    string `json:"instance"`
 	Processor   string `json:"processor"`
+
+	Derived []*DerivedParameterConfiguration `json:"derived,omitempty"`
+	// Commanding restricts who may send which commands through the endpoint.
+	// Without it every user who can reach the datasource may send any command,
+	// and only administrators may use the contingency issue options.
+	Commanding *CommandPolicyConfiguration `json:"commanding,omitempty"`
+}
+
+// CommandPolicyConfiguration is the command authorization policy of an endpoint.
+type CommandPolicyConfiguration struct {
+	// Rules are tried in order against the qualified name of a command; the
+	// first rule that matches decides.
+	Rules []*CommandRuleConfiguration `json:"rules"`
+	// DefaultDeny rejects the commands no rule matches. By default they are allowed.
+	DefaultDeny bool `json:"defaultDeny,omitempty"`
+	// ApprovalWindowSeconds is how long a command waits for its second user
+	// before it expires. Defaults to 300 seconds.
+	ApprovalWindowSeconds int `json:"approvalWindowSeconds,omitempty"`
+}
+
+// CommandRuleConfiguration grants or denies the commands matching a pattern.
+type CommandRuleConfiguration struct {
+	// Pattern matches qualified command names segment by segment: "*" matches
+	// within a segment and "**" any number of segments, so "/YSS/SIMULATOR/**"
+	// covers a whole space system. A pattern without a leading "/" matches the
+	// command name alone, e.g. "SWITCH_*".
+	Pattern string `json:"pattern"`
+	// Deny rejects the matching commands for everybody.
+	Deny bool `json:"deny,omitempty"`
+	// MinRole is the lowest Grafana organization role allowed to send the
+	// commands: "Viewer", "Editor" or "Admin".
+	MinRole string `json:"minRole,omitempty"`
+	// Teams, when set, requires the user to belong to one of these Grafana teams.
+	Teams []string `json:"teams,omitempty"`
+	// Confirm holds the commands until a second user confirms them.
+	Confirm bool `json:"confirm,omitempty"`
+	// Overrides allows the matching commands to be sent with contingency
+	// issue options: bypassed transmission constraints, verifier overrides,
+	// another TC stream or extra attributes.
+	Overrides bool `json:"overrides,omitempty"`
+}
+
+// DerivedParameterConfiguration defines a virtual parameter computed from the
+// parameters of an endpoint, such as "power = BUS_V * BUS_I".
+type DerivedParameterConfiguration struct {
+	// Name is the name queries use for the derived parameter.
+	Name string `json:"name"`
+	// Expression computes the value, see the expression package for its syntax.
+	Expression string `json:"expression"`
+	// Unit overrides the unit propagated from the inputs.
+	Unit string `json:"unit,omitempty"`
+	// Inputs maps the variables of the expression to Yamcs parameter names.
+	// Variables without an entry are taken as parameter names.
+	Inputs map[string]string `json:"inputs,omitempty"`
 }
 
 type YamcsHostConfiguration struct {
@@ -62,5 +144,8 @@ func ExtractConfig(source backend.DataSourceInstanceSettings) (*YamcsPluginConfi
 		}
 	}
 
+	// The token is only needed to check the team membership of command senders.
+	secure.GrafanaToken = source.DecryptedSecureJSONData[GrafanaTokenKey]
+
 	return configuration, secure, nil
 }
@@ -3,8 +3,13 @@ package config
 // YamcsSecureConfiguration holds secrets for hosts
 type YamcsSecureConfiguration struct {
 	Hosts map[string]*YamcsSecureHost
+	// GrafanaToken is a Grafana service account token used to read team members.
+	GrafanaToken string
 }
 
+// GrafanaTokenKey is the secure JSON field holding the Grafana service account token.
+const GrafanaTokenKey = "grafanaToken"
+
 // YamcsSecureHost stores the password for a Yamcs host.
 type YamcsSecureHost struct {
 	Password string
@@ -3,8 +3,11 @@ package config
 import (
 	"fmt"
 	"net"
+	"path"
 	"regexp"
 	"strings"
+
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/expression"
 )
 
 // Validate checks a single host configuration
@@ -46,6 +49,18 @@ func (e *YamcsEndpointConfiguration) Validate(y *YamcsPluginConfiguration) error
 	if strings.TrimSpace(e.Instance) == "" {
 		errs = append(errs, "instance is required")
 	}
+	for i, derived := range e.Derived {
+		if derived == nil || strings.TrimSpace(derived.Name) == "" {
+			errs = append(errs, fmt.Sprintf("derived parameter %d has no name", i))
+			continue
+		}
+		if _, err := expression.Parse(derived.Expression); err != nil {
+			errs = append(errs, fmt.Sprintf("derived parameter %s has an invalid expression: %v", derived.Name, err))
+		}
+	}
+	if e.Commanding != nil {
+		errs = append(errs, e.Commanding.validate()...)
+	}
 
 	if len(errs) > 0 {
 		return fmt.Errorf("invalid endpoint config: %s", strings.Join(errs, "; "))
@@ -53,6 +68,28 @@ func (e *YamcsEndpointConfiguration) Validate(y *YamcsPluginConfiguration) error
 	return nil
 }
 
+func (c *CommandPolicyConfiguration) validate() []string {
+	var errs []string
+	if c.ApprovalWindowSeconds < 0 {
+		errs = append(errs, "command approval window cannot be negative")
+	}
+	for i, rule := range c.Rules {
+		if rule == nil || strings.TrimSpace(rule.Pattern) == "" {
+			errs = append(errs, fmt.Sprintf("command rule %d has no pattern", i))
+			continue
+		}
+		if _, err := path.Match(rule.Pattern, ""); err != nil {
+			errs = append(errs, fmt.Sprintf("command rule %s has an invalid pattern", rule.Pattern))
+		}
+		switch rule.MinRole {
+		case "", "Viewer", "Editor", "Admin":
+		default:
+			errs = append(errs, fmt.Sprintf("command rule %s has an unknown role %q", rule.Pattern, rule.MinRole))
+		}
+	}
+	return errs
+}
+
 // ---------------------------------------------------------------------
 // Helper
 // ---------------------------------------------------------------------
@@ -13,6 +13,8 @@ import (
 	"github.com/jaops-space/grafana-yamcs-jaops/pkg/config"
 	"github.com/jaops-space/grafana-yamcs-jaops/pkg/source"
 	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/exception"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/policy"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/telemetry"
 )
 
 // NewDatasource creates a new Datasource instance. Each instance gets its own
@@ -37,7 +39,27 @@ func NewDatasource(ctx context.Context, settings backend.DataSourceInstanceSetti
 	datasource.CallResourceHandler = httpadapter.New(router)
 
 	// Always create querier (it will use Yamcs-only for endpoints without a database)
-	datasource.querier = source.New(cfg.Endpoints)
+	datasource.querier = source.New(cfg.Endpoints, cfg.Cache)
+	datasource.stacks = source.NewCommandStacks()
+
+	// Command policies are enforced by the resource handlers, whatever the panels allow.
+	datasource.policies = map[string]*policy.Policy{}
+	for endpointID, endpoint := range cfg.Endpoints {
+		datasource.policies[endpointID] = policy.New(endpoint.Commanding)
+	}
+	datasource.approvals = policy.NewApprovals()
+	datasource.teams, err = newTeamDirectory(secure.GrafanaToken)
+	if err != nil {
+		return nil, err
+	}
+	datasource.audit, err = openAuditLog(settings, cfg.Audit)
+	if err != nil {
+		return nil, exception.Wrap("Error opening the audit log", "AUDIT_LOG_ERROR", err)
+	}
+	datasource.auditReader, err = auditReaderRole(cfg.Audit)
+	if err != nil {
+		return nil, err
+	}
 
 	return &datasource, nil
 
@@ -45,7 +67,7 @@ func NewDatasource(ctx context.Context, settings backend.DataSourceInstanceSetti
 
 // SubscribeStream handles the initial data request when a user subscribes to a stream.
 // It fetches the historical data based on the query and returns it as the initial response.
-func (d *Datasource) SubscribeStream(_ context.Context, req *backend.SubscribeStreamRequest) (*backend.SubscribeStreamResponse, error) {
+func (d *Datasource) SubscribeStream(ctx context.Context, req *backend.SubscribeStreamRequest) (_ *backend.SubscribeStreamResponse, err error) {
 	var q PluginQuery
 
 	// Parse the query from the request payload
@@ -53,37 +75,64 @@ func (d *Datasource) SubscribeStream(_ context.Context, req *backend.SubscribeSt
 		return nil, err
 	}
 
+	ctx, span := telemetry.Start(ctx, "SubscribeStream", queryAttributes(q)...)
+	span.SetAttributes(telemetry.AttributeStreamPath.String(req.Path))
+	defer func() { telemetry.End(span, err) }()
+
 	// Retrieve the endpoint associated with the requested stream
 	endpoint, err := d.multiplexer.GetEndpoint(q.EndpointID)
 	if err != nil {
 		return nil, err
 	}
+	span.SetAttributes(endpointAttributes(endpoint)...)
 
 	// Create a Grafana data frame based on the requested query type
 	var frame *data.Frame
 	switch q.Type {
 	case Graph:
-		frame, err = DatasourceGraphFrame(d.querier, endpoint, q)
-	case SingleValue, Image:
-		frame, err = DatasourceSingleValueFrame(endpoint, q)
+		frame, err = DatasourceGraphFrame(ctx, d.querier, endpoint, q)
+	case SingleValue:
+		frame, err = DatasourceSingleValueFrame(ctx, endpoint, q)
+	case Image:
+		frame, err = DatasourceImageFrame(ctx, d.querier, endpoint, q)
 	case DiscreteValue:
-		frame, err = DatasourceDiscreteValueFrame(endpoint, q)
+		frame, err = DatasourceDiscreteValueFrame(ctx, d.querier, endpoint, q)
+	case StateTimeline:
+		frame, err = DatasourceStateTimelineFrame(ctx, d.querier, endpoint, q)
+	case StateSummary:
+		frame, err = DatasourceStateSummaryFrame(ctx, d.querier, endpoint, q)
+	case Statistics:
+		frame, err = DatasourceStatisticsFrame(ctx, d.querier, endpoint, q)
+	case Gaps:
+		frame, err = DatasourceGapsFrame(ctx, d.querier, endpoint, q)
+	case Completeness:
+		frame, err = DatasourceCompletenessFrame(ctx, d.querier, endpoint, q)
 	case Events:
-		frame, err = DatasourceEventsFrame(endpoint, q)
+		frame, err = DatasourceEventsFrame(ctx, d.querier, endpoint, q)
 	case Commanding:
-		frame, err = DatasourceCommandFrame(endpoint, q)
+		frame, err = DatasourceCommandFrame(ctx, endpoint, q)
 	case CommandHistory:
-		frame, err = DatasourceCommandHistoryFrame(endpoint, q)
+		frame, err = DatasourceCommandHistoryFrame(ctx, endpoint, q)
+	case CommandProgress:
+		frame, err = DatasourceCommandProgressFrame(ctx, endpoint, q)
+	case CommandStack:
+		frame, err = DatasourceCommandStackFrame(d.stacks, q)
+	case CommandQueues:
+		frame, err = DatasourceCommandQueuesFrame(ctx, endpoint, q)
+	case CommandSchedule:
+		frame, err = DatasourceScheduledCommandsFrame(ctx, endpoint, q)
+	case Audit:
+		frame, err = DatasourceAuditFrame(ctx, d.audit, d.auditReader, q)
 	case Alarms:
-		frame, err = DatasourceAlarmsFrame(endpoint, q)
+		frame, err = DatasourceAlarmsFrame(ctx, endpoint, q)
 	case Links:
-		frame, err = DatasourceLinksFrame(endpoint, q)
+		frame, err = DatasourceLinksFrame(ctx, endpoint, q)
 	case Demands, Subscriptions:
 		return &backend.SubscribeStreamResponse{
 			Status: backend.SubscribeStreamStatusOK,
 		}, nil
 	case Time:
-		frame, err = DatasourceTimeFrame(endpoint, q)
+		frame, err = DatasourceTimeFrame(ctx, endpoint, q)
 	default:
 		return nil, exception.New("Query type not identified", "QUERY_TYPE_NOT_FOUND")
 	}
@@ -123,7 +172,7 @@ func (d *Datasource) PublishStream(context.Context, *backend.PublishStreamReques
 // every single data point, it calculates an average (for numeric values) or the most
 // frequent value (for non-numeric values). This behavior ensures consistency with
 // how historical data is retrieved, making real-time and historical views seamless.
-func (d *Datasource) RunStream(ctx context.Context, req *backend.RunStreamRequest, sender *backend.StreamSender) error {
+func (d *Datasource) RunStream(ctx context.Context, req *backend.RunStreamRequest, sender *backend.StreamSender) (err error) {
 	var q PluginQuery
 
 	// Parse the query from the request payload
@@ -131,11 +180,16 @@ func (d *Datasource) RunStream(ctx context.Context, req *backend.RunStreamReques
 		return err
 	}
 
+	ctx, span := telemetry.Start(ctx, "RunStream", queryAttributes(q)...)
+	span.SetAttributes(telemetry.AttributeStreamPath.String(req.Path))
+	defer func() { telemetry.End(span, err) }()
+
 	// Retrieve the endpoint associated with the requested stream
 	endpoint, err := d.multiplexer.GetEndpoint(q.EndpointID)
 	if err != nil {
 		return err
 	}
+	span.SetAttributes(endpointAttributes(endpoint)...)
 	endpoint.RequestTime()
 
 	// Route the stream to the appropriate handler
@@ -150,6 +204,14 @@ func (d *Datasource) RunStream(ctx context.Context, req *backend.RunStreamReques
 		return RunSubscriptionStream(ctx, req, sender, endpoint, q)
 	case CommandHistory:
 		return RunCommandHistoryStream(ctx, req, sender, endpoint, q)
+	case CommandProgress:
+		return RunCommandProgressStream(ctx, req, sender, endpoint, q)
+	case CommandStack:
+		return RunCommandStackStream(ctx, req, sender, d.stacks, q)
+	case CommandQueues:
+		return RunCommandQueuesStream(ctx, req, sender, endpoint, q)
+	case CommandSchedule:
+		return RunScheduledCommandsStream(ctx, req, sender, endpoint, q)
 	case Alarms:
 		return RunAlarmsStream(ctx, req, sender, endpoint, q)
 	case Links:
@@ -163,5 +225,9 @@ func (d *Datasource) RunStream(ctx context.Context, req *backend.RunStreamReques
 
 // Dispose here tells plugin SDK that plugin wants to clean up resources when a new instance is created.
 func (d *Datasource) Dispose() {
+	d.stacks.Dispose()
 	d.multiplexer.Dispose()
+	if d.audit != nil {
+		d.audit.Close()
+	}
 }
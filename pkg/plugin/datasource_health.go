@@ -10,11 +10,14 @@ import (
 	"github.com/jaops-space/grafana-yamcs-jaops/pkg/config"
 	"github.com/jaops-space/grafana-yamcs-jaops/pkg/source"
 	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/exception"
+	corehttp "github.com/jaops-space/grafana-yamcs-jaops/pkg/yamcs/core/http"
 )
 
 type ItemStatus struct {
 	Status  string `json:"status"` // ok, warning, error
 	Message string `json:"message,omitempty"`
+	// Circuit is the state of the host's circuit breaker, reported for hosts only.
+	Circuit *corehttp.BreakerStatus `json:"circuit,omitempty"`
 }
 
 type HealthDetails struct {
@@ -138,6 +141,21 @@ func (d *Datasource) applyConnectivityChecks(
 		}
 	}
 
+	for hostID, hostConfig := range y.Hosts {
+		if hostConfig.Path == "" {
+			continue
+		}
+		status := details.Hosts[hostID]
+		circuit := hostBreaker(hostConfig).Status()
+		status.Circuit = &circuit
+		if circuit.State != corehttp.BreakerClosed && status.Status == "ok" {
+			status = warningStatus(fmt.Sprintf("Circuit breaker is %s after %d consecutive failures", circuit.State, circuit.ConsecutiveFailures))
+			status.Circuit = &circuit
+			details.WarningHosts = append(details.WarningHosts, hostDisplayName(hostID, hostConfig))
+		}
+		details.Hosts[hostID] = status
+	}
+
 	backend.Logger.Debug("Testing Endpoint Connectivity")
 
 	for endpointID, endpointConfig := range y.Endpoints {
@@ -263,6 +281,11 @@ func hostDisplayName(hostID string, host *config.YamcsHostConfiguration) string
 	return hostID
 }
 
+// hostBreaker returns the circuit breaker shared by every client of the configured host.
+func hostBreaker(host *config.YamcsHostConfiguration) *corehttp.CircuitBreaker {
+	return corehttp.BreakerFor(corehttp.BaseURL(host.Path, corehttp.TLS{Enabled: host.Tls}))
+}
+
 func endpointDisplayName(endpointID string, endpoint *config.YamcsEndpointConfiguration) string {
 	if endpoint.Name != "" {
 		return endpoint.Name
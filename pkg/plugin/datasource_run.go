@@ -6,6 +6,7 @@ import (
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/commanding"
 	"github.com/jaops-space/grafana-yamcs-jaops/pkg/source"
 	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/tools"
 )
@@ -52,9 +53,17 @@ func RunParameterStream(ctx context.Context,
 	q PluginQuery) error {
 
 	yamcs := endpoint.GetClient()
+	mode, err := q.ExpansionMode()
+	if err != nil {
+		return err
+	}
+
+	if _, err := derivedParameter(endpoint, &q); err != nil {
+		return err
+	}
 
 	backend.Logger.Debug("Requesting parameter stream", "parameter", q.Parameter, "path", req.Path)
-	err := endpoint.RequestNewParameterStream(q.Parameter, req.Path)
+	err = endpoint.RequestNewParameterStream(q.Parameter, req.Path)
 	if err != nil {
 		backend.Logger.Error("Error requesting parameter stream", "error", err)
 		return err
@@ -80,6 +89,10 @@ func RunParameterStream(ctx context.Context,
 		getMax = getMax || (getField == "max")
 	}
 
+	// The initial frame carried the thresholds of the context applying when the
+	// panel subscribed; they are sent again whenever another context applies.
+	alarmContext := activeAlarmContext(endpoint, q)
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -96,17 +109,57 @@ func RunParameterStream(ctx context.Context,
 				continue
 			}
 
+			if tools.IsBinaryValue(buffer[len(buffer)-1].GetEngValue(), aggregatePath) {
+				if keep := q.Binary.HistoryLength(); len(buffer) > keep {
+					buffer = buffer[len(buffer)-keep:]
+				}
+				frame := traceFrame(ctx, "tools.ConvertBufferToBinaryFrame", len(buffer), func() *data.Frame {
+					return tools.ConvertBufferToBinaryFrame(buffer, q.Parameter+aggregatePath, aggregatePath, q.Binary.RawImageFormat())
+				})
+				include := data.IncludeDataOnly
+				if frame.Meta != nil {
+					include = data.IncludeAll
+				}
+				sender.SendFrame(frame, include)
+				endpoint.ClearParameterStream(q.Parameter, req.Path)
+				continue
+			}
+
+			if mode != tools.ExpandNone {
+				// Members and array elements may come and go between ticks, so
+				// the schema is sent along with every expanded frame.
+				frame := traceFrame(ctx, "tools.ConvertBufferToExpandedFrame", len(buffer), func() *data.Frame {
+					return tools.ConvertBufferToExpandedFrame(buffer, q.Parameter, q.AggregatePath, mode, false)
+				})
+				SetExpandedFieldMetadata(endpoint, q.Parameter, q.AggregatePath, mode, frame)
+				sender.SendFrame(frame, data.IncludeAll)
+				endpoint.ClearParameterStream(q.Parameter, req.Path)
+				continue
+			}
+
 			average := len(buffer) > 3
 			var frame *data.Frame
 			if average {
-				frame = tools.ConvertBufferToAverageFrame(buffer, q.Parameter+aggregatePath, getMin, getMax, aggregatePath, false)
+				frame = traceFrame(ctx, "tools.ConvertBufferToAverageFrame", len(buffer), func() *data.Frame {
+					return tools.ConvertBufferToAverageFrame(buffer, q.Parameter+aggregatePath, getMin, getMax, aggregatePath, false)
+				})
 			} else {
-				frame = tools.ConvertBufferToFrame(buffer, q.Parameter+aggregatePath, getMin, getMax, aggregatePath, false)
+				frame = traceFrame(ctx, "tools.ConvertBufferToFrame", len(buffer), func() *data.Frame {
+					return tools.ConvertBufferToFrame(buffer, q.Parameter+aggregatePath, getMin, getMax, aggregatePath, false)
+				})
+			}
+
+			include := data.IncludeDataOnly
+			if current := activeAlarmContext(endpoint, q); current != alarmContext {
+				backend.Logger.Debug("Alarm context changed", "parameter", q.Parameter, "context", current)
+				alarmContext = current
+				SetFieldMetadata(endpoint, q.Parameter, q.AggregatePath, frame)
+				include = data.IncludeAll
 			}
 
 			sender.SendFrame(
 				frame,
-				data.IncludeDataOnly,
+				include,
 			)
 
 			endpoint.ClearParameterStream(q.Parameter, req.Path)
@@ -255,25 +308,31 @@ func RunCommandHistoryStream(
 ) error {
 
 	yamcs := endpoint.GetClient()
+	filter, err := q.History.Filter()
+	if err != nil {
+		return err
+	}
 
 	// Start listening for command history entries for this path
-	endpoint.RequestCommandHistoryStream(req.Path)
+	endpoint.RequestCommandHistoryStream(req.Path, filter)
 	signal := endpoint.GetCommandHistorySignal(req.Path)
 	defer endpoint.WithdrawCommandHistoryStreamRequest(req.Path)
 
+	stages := newCommandStageStream()
 	flush := func() {
-		buffer := endpoint.GetCommandHistoryStream(req.Path)
+		buffer := endpoint.DrainCommandHistoryStream(req.Path)
 		if len(buffer) == 0 {
 			return
 		}
 
 		frame := tools.ConvertCommandListToFrame(buffer)
+		if q.History.Stages() {
+			frame = stages.convert(buffer)
+		}
 		sender.SendFrame(
 			frame,
 			data.IncludeDataOnly,
 		)
-
-		endpoint.ClearCommandHistoryStream(req.Path)
 	}
 
 	for {
@@ -289,6 +348,47 @@ func RunCommandHistoryStream(
 	}
 }
 
+// commandStageStreamRetention is how long a command history stream laid out
+// by stage remembers a command that did not complete.
+const commandStageStreamRetention = 24 * time.Hour
+
+// commandStageStream turns live command history updates into command stage
+// rows. Updates only hold the attributes that changed, so the name and issue
+// time of each command are kept until it completes, and each update yields
+// the rows of the stages it reports.
+type commandStageStream struct {
+	commands map[string]*tools.CommandProgress
+}
+
+func newCommandStageStream() *commandStageStream {
+	return &commandStageStream{commands: map[string]*tools.CommandProgress{}}
+}
+
+func (s *commandStageStream) convert(entries []*commanding.CommandHistoryEntry) *data.Frame {
+	updates := make([]*tools.CommandProgress, 0, len(entries))
+	for _, entry := range entries {
+		command, ok := s.commands[entry.GetId()]
+		if !ok {
+			command = tools.NewCommandProgress(entry.GetId())
+			s.commands[entry.GetId()] = command
+		}
+		command.Update(entry)
+
+		update := tools.NewCommandProgress(entry.GetId())
+		update.Update(entry)
+		update.Command, update.Issued = command.Command, command.Issued
+		updates = append(updates, update)
+	}
+
+	expiry := time.Now().Add(-commandStageStreamRetention)
+	for id, command := range s.commands {
+		if command.Complete() || (!command.Issued.IsZero() && command.Issued.Before(expiry)) {
+			delete(s.commands, id)
+		}
+	}
+	return tools.ConvertCommandStagesToFrame(updates, nil)
+}
+
 func RunTimeStream(
 	ctx context.Context,
 	req *backend.RunStreamRequest,
@@ -393,6 +493,123 @@ func RunAlarmsStream(
 	}
 }
 
+// queueStreamInterval is how often command queue streams send their changes.
+// Operators wait on these to release commands, so it does not follow the
+// time range like the other streams.
+const queueStreamInterval = 500 * time.Millisecond
+
+// RunCommandQueuesStream sends the command queues of the endpoint processor
+// each time a queue or its pending commands change.
+func RunCommandQueuesStream(
+	ctx context.Context,
+	req *backend.RunStreamRequest,
+	sender *backend.StreamSender,
+	endpoint *source.YamcsEndpoint,
+	q PluginQuery,
+) error {
+	yamcs := endpoint.GetClient()
+
+	// Listen before listing the queues so that no update falls in between.
+	queues, err := endpoint.RequestQueuesStream(req.Path)
+	if err != nil {
+		return err
+	}
+	defer endpoint.WithdrawQueuesStreamRequest(req.Path)
+
+	list, err := yamcs.WithContext(ctx).ListQueues(endpoint.Instance, endpoint.Processor)
+	if err != nil {
+		return err
+	}
+	queues.Reset(list)
+	// The subscription already sent the listed state.
+	queues.Changed()
+
+	ticker := time.NewTicker(queueStreamInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if !yamcs.WebSocket.IsConnected() {
+				return backend.DownstreamErrorf("yamcs client disconnected")
+			}
+			current, changed := queues.Changed()
+			if !changed {
+				continue
+			}
+			if err := sender.SendFrame(tools.ConvertCommandQueuesToFrame(current), data.IncludeAll); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// scheduleResyncInterval is how often scheduled command streams list the
+// timeline again, to catch the items edited outside of Grafana.
+const scheduleResyncInterval = 30 * time.Second
+
+// RunScheduledCommandsStream sends the scheduled commands each time one is
+// scheduled, edited, starts or ends.
+func RunScheduledCommandsStream(
+	ctx context.Context,
+	req *backend.RunStreamRequest,
+	sender *backend.StreamSender,
+	endpoint *source.YamcsEndpoint,
+	q PluginQuery,
+) error {
+	yamcs := endpoint.GetClient()
+
+	commands, err := endpoint.RequestScheduledCommandsStream(req.Path)
+	if err != nil {
+		return err
+	}
+	defer endpoint.WithdrawScheduledCommandsStreamRequest(req.Path)
+
+	resync := func() error {
+		items, err := listScheduledCommands(ctx, endpoint, q)
+		if err != nil {
+			return err
+		}
+		commands.Reset(items)
+		return nil
+	}
+	if err := resync(); err != nil {
+		return err
+	}
+	// The subscription already sent the listed state.
+	commands.Changed()
+	synced := time.Now()
+
+	ticker := time.NewTicker(getStreamTickerInterval(q, time.Second))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if !yamcs.WebSocket.IsConnected() {
+				return backend.DownstreamErrorf("yamcs client disconnected")
+			}
+			if commands.Stale() || time.Since(synced) >= scheduleResyncInterval {
+				if err := resync(); err != nil {
+					return err
+				}
+				synced = time.Now()
+			}
+			current, changed := commands.Changed()
+			if !changed {
+				continue
+			}
+			if err := sender.SendFrame(tools.ConvertScheduledCommandsToFrame(current), data.IncludeAll); err != nil {
+				return err
+			}
+		}
+	}
+}
+
 func RunLinksStream(
 	ctx context.Context,
 	req *backend.RunStreamRequest,
@@ -439,3 +656,97 @@ func RunLinksStream(
 		}
 	}
 }
+
+func RunCommandProgressStream(
+	ctx context.Context,
+	req *backend.RunStreamRequest,
+	sender *backend.StreamSender,
+	endpoint *source.YamcsEndpoint,
+	q PluginQuery,
+) error {
+
+	yamcs := endpoint.GetClient()
+	timeout, err := q.Tracking.TimeoutDuration()
+	if err != nil {
+		return err
+	}
+
+	// Listen before reading the archive so that no update falls in between.
+	endpoint.RequestCommandHistoryStream(req.Path, nil)
+	signal := endpoint.GetCommandHistorySignal(req.Path)
+	defer endpoint.WithdrawCommandHistoryStreamRequest(req.Path)
+
+	progress, err := commandProgress(ctx, endpoint, q)
+	if err != nil {
+		return err
+	}
+	send := func(timedOut bool) error {
+		return sender.SendFrame(tools.ConvertCommandProgressToFrame(progress, timedOut), data.IncludeAll)
+	}
+	if progress.Complete() {
+		return send(false)
+	}
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline.C:
+			return send(true)
+		case <-signal:
+			if !yamcs.WebSocket.IsConnected() {
+				return backend.DownstreamErrorf("yamcs client disconnected")
+			}
+			updated := false
+			for _, entry := range endpoint.DrainCommandHistoryStream(req.Path) {
+				updated = progress.Update(entry) || updated
+			}
+			if !updated {
+				continue
+			}
+			if err := send(false); err != nil {
+				return err
+			}
+			if progress.Complete() {
+				return nil
+			}
+		}
+	}
+}
+
+func RunCommandStackStream(
+	ctx context.Context,
+	req *backend.RunStreamRequest,
+	sender *backend.StreamSender,
+	stacks *source.CommandStacks,
+	q PluginQuery,
+) error {
+
+	run, err := commandStackRun(stacks, q)
+	if err != nil {
+		return err
+	}
+	signal := run.Watch(req.Path)
+	defer run.Unwatch(req.Path)
+
+	send := func() error {
+		return sender.SendFrame(tools.ConvertStackStatusToFrame(run.Status()), data.IncludeAll)
+	}
+	if err := send(); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-signal:
+			if err := send(); err != nil {
+				return err
+			}
+		case <-run.Done():
+			return send()
+		}
+	}
+}
@@ -1,8 +1,9 @@
 package plugin
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
+	"net/http"
 	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
@@ -10,16 +11,20 @@ import (
 	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
+	yamcsprotobuf "github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf"
 	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/commanding"
-	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/events"
 	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/links"
 	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/pvalue"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/timeline"
 	"github.com/jaops-space/grafana-yamcs-jaops/pkg/source"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/audit"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/exception"
 	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/tools"
 	"github.com/jaops-space/grafana-yamcs-jaops/pkg/yamcs/client"
+	corehttp "github.com/jaops-space/grafana-yamcs-jaops/pkg/yamcs/core/http"
 )
 
-func DatasourceGraphFrame(querier *source.Querier, endpoint *source.YamcsEndpoint, q PluginQuery) (*data.Frame, error) {
+func DatasourceGraphFrame(ctx context.Context, querier *source.Querier, endpoint *source.YamcsEndpoint, q PluginQuery) (*data.Frame, error) {
 	backend.Logger.Debug("DatasourceGraphFrame called",
 		"endpoint", q.EndpointID,
 		"parameter", q.Parameter,
@@ -28,12 +33,22 @@ func DatasourceGraphFrame(querier *source.Querier, endpoint *source.YamcsEndpoin
 		"realtime", q.Realtime,
 		"querier", querier != nil)
 
-	yamcs := endpoint.GetClient()
-	yamcs.SetSamplePointCount(q.MaxPoints)
-
 	start := time.Unix(int64(q.From), 0)
 	end := time.Unix(int64(q.To), 0)
 
+	derived, err := derivedParameter(endpoint, &q)
+	if err != nil {
+		return nil, err
+	}
+
+	mode, err := q.ExpansionMode()
+	if err != nil {
+		return nil, err
+	}
+	if mode != tools.ExpandNone && derived == nil {
+		return datasourceExpandedGraphFrame(ctx, querier, endpoint, q, mode, start, end)
+	}
+
 	aggregatePath := ""
 
 	if len(q.AggregatePath) > 0 {
@@ -47,20 +62,23 @@ func DatasourceGraphFrame(querier *source.Querier, endpoint *source.YamcsEndpoin
 		"endTime", end,
 		"yamcsFilter", q.YamcsFilter)
 
-	// Include aggregatePath in the API call to get the correct value type (Position.X returns INTEGER instead of AGGREGATE)
-	samples, err := yamcs.GetParameterSamplesInProcessorByNames(endpoint.Instance.GetName(), endpoint.Processor.GetName(), q.Parameter+aggregatePath, start, end)
+	var samples []client.Sample
+	if derived != nil {
+		samples, err = querier.DerivedSamples(ctx, endpoint, derived, start, end, q.MaxPoints)
+	} else {
+		// Include aggregatePath in the API call to get the correct value type (Position.X returns INTEGER instead of AGGREGATE)
+		samples, err = querier.ParameterSamples(ctx, endpoint, q.Parameter+aggregatePath, start, end, q.MaxPoints)
+	}
 
 	if err != nil {
 		backend.Logger.Error("Error requesting parameter samples", "error", err)
 		return nil, err
 	}
 
-	pointCount := 0
-
 	backend.Logger.Debug("Received parameter samples",
 		"parameter", q.Parameter,
 		"aggregatePath", aggregatePath,
-		"pointCount", pointCount)
+		"pointCount", len(samples))
 
 	var getMin bool = false
 	var getMax bool = false
@@ -70,15 +88,46 @@ func DatasourceGraphFrame(querier *source.Querier, endpoint *source.YamcsEndpoin
 		getMax = getMax || (getField == "max")
 	}
 
-	frame := tools.ConvertSampleBufferToFrame(samples, q.Parameter+aggregatePath, getMin, getMax)
+	frame := traceFrame(ctx, "tools.ConvertSampleBufferToFrame", len(samples), func() *data.Frame {
+		return tools.ConvertSampleBufferToFrame(samples, q.Parameter+aggregatePath, getMin, getMax)
+	})
+
+	SetFieldMetadata(endpoint, q.Parameter, q.AggregatePath, frame)
+	return frame, nil
+}
 
-	SetUnitAndThresholds(endpoint, q.Parameter, frame)
+// datasourceExpandedGraphFrame builds the graph frame of an expanded query from
+// raw values, as samples only exist for numeric members.
+func datasourceExpandedGraphFrame(ctx context.Context, querier *source.Querier, endpoint *source.YamcsEndpoint, q PluginQuery, mode tools.ExpansionMode, start, end time.Time) (*data.Frame, error) {
+	values, err := querier.ParameterHistory(ctx, endpoint, q.Parameter, start, end, q.MaxPoints)
+	if err != nil {
+		backend.Logger.Error("Error requesting parameter history", "error", err)
+		return nil, err
+	}
+
+	frame := traceFrame(ctx, "tools.ConvertBufferToExpandedFrame", len(values), func() *data.Frame {
+		return tools.ConvertBufferToExpandedFrame(values, q.Parameter, q.AggregatePath, mode, false)
+	})
+	SetExpandedFieldMetadata(endpoint, q.Parameter, q.AggregatePath, mode, frame)
 	return frame, nil
 }
 
-func DatasourceSingleValueFrame(endpoint *source.YamcsEndpoint, q PluginQuery) (*data.Frame, error) {
+func DatasourceSingleValueFrame(ctx context.Context, endpoint *source.YamcsEndpoint, q PluginQuery) (*data.Frame, error) {
+
+	mode, err := q.ExpansionMode()
+	if err != nil {
+		return nil, err
+	}
+
+	yamcs := endpoint.GetClient().WithContext(ctx)
+	derived, err := derivedParameter(endpoint, &q)
+	if err != nil {
+		return nil, err
+	}
+	if derived != nil {
+		return derivedSingleValueFrame(ctx, endpoint, derived, q)
+	}
 
-	yamcs := endpoint.GetClient()
 	aggregatePath := ""
 	if len(q.AggregatePath) > 0 {
 		aggregatePath = "." + q.AggregatePath
@@ -93,15 +142,33 @@ func DatasourceSingleValueFrame(endpoint *source.YamcsEndpoint, q PluginQuery) (
 
 	buffer := []client.ParameterValue{lastValue}
 
-	frame := tools.ConvertBufferToFrame(buffer, q.Parameter+aggregatePath, false, false, aggregatePath, false)
-	SetUnitAndThresholds(endpoint, q.Parameter, frame)
+	if tools.IsBinaryValue(lastValue.GetEngValue(), aggregatePath) {
+		return tools.ConvertBufferToBinaryFrame(buffer, q.Parameter+aggregatePath, aggregatePath, q.Binary.RawImageFormat()), nil
+	}
+
+	if mode != tools.ExpandNone {
+		frame := traceFrame(ctx, "tools.ConvertBufferToExpandedFrame", len(buffer), func() *data.Frame {
+			return tools.ConvertBufferToExpandedFrame(buffer, q.Parameter, q.AggregatePath, mode, false)
+		})
+		SetExpandedFieldMetadata(endpoint, q.Parameter, q.AggregatePath, mode, frame)
+		return frame, nil
+	}
+
+	frame := traceFrame(ctx, "tools.ConvertBufferToFrame", len(buffer), func() *data.Frame {
+		return tools.ConvertBufferToFrame(buffer, q.Parameter+aggregatePath, false, false, aggregatePath, false)
+	})
+	SetFieldMetadata(endpoint, q.Parameter, q.AggregatePath, frame)
 	return frame, nil
 
 }
 
-func DatasourceDiscreteValueFrame(endpoint *source.YamcsEndpoint, q PluginQuery) (*data.Frame, error) {
-
-	yamcs := endpoint.GetClient()
+// DatasourceImageFrame returns the latest value of an image parameter, or its
+// last values within the query window when the query asks for a history.
+func DatasourceImageFrame(ctx context.Context, querier *source.Querier, endpoint *source.YamcsEndpoint, q PluginQuery) (*data.Frame, error) {
+	count := q.Binary.HistoryLength()
+	if count <= 1 {
+		return DatasourceSingleValueFrame(ctx, endpoint, q)
+	}
 
 	start, end := time.Unix(int64(q.From), 0), time.Unix(int64(q.To), 0)
 	aggregatePath := ""
@@ -109,72 +176,291 @@ func DatasourceDiscreteValueFrame(endpoint *source.YamcsEndpoint, q PluginQuery)
 		aggregatePath = "." + q.AggregatePath
 	}
 
-	minRange := fmt.Sprint(int(end.Sub(start).Milliseconds()) / q.MaxPoints)
+	values, err := querier.RecentParameterValues(ctx, endpoint, q.Parameter, start, end, count)
+	if err != nil {
+		return nil, err
+	}
 
-	ranges, err := yamcs.GetParameterRangesByQueryWithTimeByNames(
-		endpoint.Instance.GetName(),
-		q.Parameter,
-		map[string]string{
-			"minRange":  minRange,
-			"processor": endpoint.Processor.GetName(),
-		},
-		start,
-		end,
-	)
+	return traceFrame(ctx, "tools.ConvertBufferToBinaryFrame", len(values), func() *data.Frame {
+		return tools.ConvertBufferToBinaryFrame(values, q.Parameter+aggregatePath, aggregatePath, q.Binary.RawImageFormat())
+	}), nil
+}
+
+// derivedParameter returns the derived parameter read by a query: the one its
+// expression defines, named after its parameter, or the one configured for the
+// endpoint under its parameter name. It returns nil for Yamcs parameters.
+// Derived values are plain numbers, so the aggregate path of the query is reset.
+func derivedParameter(endpoint *source.YamcsEndpoint, q *PluginQuery) (*source.DerivedParameter, error) {
+	var derived *source.DerivedParameter
+	if q.Expression != "" {
+		if q.Parameter == "" {
+			q.Parameter = q.Expression
+		}
+		var err error
+		derived, err = endpoint.DefineDerivedParameter(q.Parameter, q.Expression, q.Unit)
+		if err != nil {
+			return nil, exception.Wrap("Invalid expression", "INVALID_QUERY", err)
+		}
+	} else {
+		derived = endpoint.DerivedParameter(q.Parameter)
+	}
+	if derived != nil {
+		q.AggregatePath = ""
+	}
+	return derived, nil
+}
+
+// derivedSingleValueFrame computes the current value of a derived parameter
+// from the current values of its inputs.
+func derivedSingleValueFrame(ctx context.Context, endpoint *source.YamcsEndpoint, derived *source.DerivedParameter, q PluginQuery) (*data.Frame, error) {
+	yamcs := endpoint.GetClient().WithContext(ctx)
+	values := map[string]float64{}
+	generationTime := time.Time{}
+	for _, input := range derived.Parameters() {
+		value, err := yamcs.GetParameterValueByName(endpoint.Instance, endpoint.Processor, input)
+		if err != nil {
+			return nil, err
+		}
+		if number, ok := tools.NumericValue(value.GetEngValue()); ok {
+			values[input] = number
+		}
+		if t := value.GetGenerationTime().AsTime(); t.After(generationTime) {
+			generationTime = t
+		}
+	}
+
+	result, err := derived.Evaluate(func(parameter string) (float64, bool) {
+		value, ok := values[parameter]
+		return value, ok
+	})
+	if err != nil {
+		return nil, exception.Wrap("Cannot compute "+derived.Name, "DERIVED_EVALUATION", err)
+	}
+
+	buffer := []client.ParameterValue{source.NewDerivedValue(result, generationTime)}
+	frame := tools.ConvertBufferToFrame(buffer, q.Parameter, false, false, "", false)
+	SetFieldMetadata(endpoint, q.Parameter, "", frame)
+	return frame, nil
+}
+
+func DatasourceDiscreteValueFrame(ctx context.Context, querier *source.Querier, endpoint *source.YamcsEndpoint, q PluginQuery) (*data.Frame, error) {
+
+	ranges, aggregatePath, err := stateRanges(ctx, querier, endpoint, q)
 
 	if err != nil {
 		return nil, err
 	}
 
-	frame := tools.ConvertRangesToFrame(ranges, q.Parameter+aggregatePath, aggregatePath)
-	SetUnitAndThresholds(endpoint, q.Parameter, frame)
+	frame := traceFrame(ctx, "tools.ConvertRangesToFrame", len(ranges.GetRange()), func() *data.Frame {
+		return tools.ConvertRangesToFrame(ranges, q.Parameter+aggregatePath, aggregatePath)
+	})
+	SetFieldMetadata(endpoint, q.Parameter, q.AggregatePath, frame)
 	return frame, nil
 
 }
 
-func DatasourceEventsFrame(endpoint *source.YamcsEndpoint, q PluginQuery) (*data.Frame, error) {
+// DatasourceStateTimelineFrame returns the states of a discrete parameter over
+// the query window, one row per range with its duration and sample count.
+func DatasourceStateTimelineFrame(ctx context.Context, querier *source.Querier, endpoint *source.YamcsEndpoint, q PluginQuery) (*data.Frame, error) {
+
+	ranges, aggregatePath, err := stateRanges(ctx, querier, endpoint, q)
+	if err != nil {
+		return nil, err
+	}
+
+	frame := traceFrame(ctx, "tools.ConvertRangesToStateTimelineFrame", len(ranges.GetRange()), func() *data.Frame {
+		return tools.ConvertRangesToStateTimelineFrame(ranges, q.Parameter+aggregatePath, aggregatePath)
+	})
+	frame.Meta = &data.FrameMeta{PreferredVisualization: data.VisTypeTable}
+	SetStateFieldMetadata(endpoint, q.Parameter, q.AggregatePath, frame)
+	return frame, nil
+}
+
+// DatasourceStateSummaryFrame returns the time a discrete parameter spent in
+// each of its states over the query window and its number of transitions.
+func DatasourceStateSummaryFrame(ctx context.Context, querier *source.Querier, endpoint *source.YamcsEndpoint, q PluginQuery) (*data.Frame, error) {
+
+	ranges, aggregatePath, err := stateRanges(ctx, querier, endpoint, q)
+	if err != nil {
+		return nil, err
+	}
 
-	yamcs := endpoint.GetClient()
 	start, end := time.Unix(int64(q.From), 0), time.Unix(int64(q.To), 0)
+	frame := traceFrame(ctx, "tools.ConvertRangesToStateSummaryFrame", len(ranges.GetRange()), func() *data.Frame {
+		return tools.ConvertRangesToStateSummaryFrame(ranges, q.Parameter+aggregatePath, aggregatePath, start, end)
+	})
+	SetStateFieldMetadata(endpoint, q.Parameter, q.AggregatePath, frame)
+	return frame, nil
+}
 
-	iterator := yamcs.ListEventsWithinTimeRange(endpoint.Instance, start, end)
-	events := []*events.Event{}
-	for iterator.HasNext() {
-		currentEvents, err := iterator.Next()
+// stateRanges returns the value ranges of a discrete query, and its aggregate
+// path as used in field names.
+func stateRanges(ctx context.Context, querier *source.Querier, endpoint *source.YamcsEndpoint, q PluginQuery) (*pvalue.Ranges, string, error) {
+	start, end := time.Unix(int64(q.From), 0), time.Unix(int64(q.To), 0)
+	aggregatePath := ""
+	if len(q.AggregatePath) > 0 {
+		aggregatePath = "." + q.AggregatePath
+	}
+
+	minRange := time.Duration(0)
+	if q.MaxPoints > 0 {
+		minRange = end.Sub(start) / time.Duration(q.MaxPoints)
+	}
+
+	ranges, err := querier.ParameterRanges(ctx, endpoint, q.Parameter, start, end, minRange)
+	return ranges, aggregatePath, err
+}
+
+// DatasourceStatisticsFrame computes statistics of a numeric parameter over the
+// query window, from sample buckets or, for exact statistics, from raw values.
+// They can be grouped into fixed intervals or by the state of another parameter.
+func DatasourceStatisticsFrame(ctx context.Context, querier *source.Querier, endpoint *source.YamcsEndpoint, q PluginQuery) (*data.Frame, error) {
+
+	start, end := time.Unix(int64(q.From), 0), time.Unix(int64(q.To), 0)
+	derived, err := derivedParameter(endpoint, &q)
+	if err != nil {
+		return nil, err
+	}
+	aggregatePath := ""
+	if len(q.AggregatePath) > 0 {
+		aggregatePath = "." + q.AggregatePath
+	}
+	config := q.Statistics
+	if config == nil {
+		config = &StatisticsConfig{}
+	}
+
+	// Derived parameters are computed over samples, so they have no raw
+	// values to compute exact statistics from.
+	if config.Exact && derived != nil {
+		return nil, exception.New("Exact statistics are not available for derived parameters", "INVALID_QUERY")
+	}
+
+	var observations []tools.Observation
+	if config.Exact {
+		// Exact statistics cover every value or none: a window too large to be
+		// read whole is an error, to be narrowed or computed from samples.
+		values, err := querier.AllParameterValues(ctx, endpoint, q.Parameter, start, end)
+		if err != nil {
+			return nil, err
+		}
+		observations = tools.ValuesToObservations(values, aggregatePath)
+	} else if derived != nil {
+		samples, err := querier.DerivedSamples(ctx, endpoint, derived, start, end, q.MaxPoints)
+		if err != nil {
+			return nil, err
+		}
+		observations = tools.SamplesToObservations(samples)
+	} else {
+		samples, err := querier.ParameterSamples(ctx, endpoint, q.Parameter+aggregatePath, start, end, q.MaxPoints)
 		if err != nil {
 			return nil, err
 		}
-		events = append(events, currentEvents...)
+		observations = tools.SamplesToObservations(samples)
 	}
-	frame := tools.ConvertEventsToFrame(events)
+
+	groups := []tools.StatisticsGroup{{Start: start, Observations: observations}}
+	groupField := ""
+	switch {
+	case config.Interval != "":
+		interval, err := time.ParseDuration(config.Interval)
+		if err != nil || interval <= 0 {
+			return nil, exception.Wrap("Invalid statistics interval "+config.Interval, "INVALID_QUERY", err)
+		}
+		groups, groupField = tools.GroupByInterval(observations, start, end, interval), "time"
+	case config.GroupBy != "":
+		ranges, err := querier.ParameterRanges(ctx, endpoint, config.GroupBy, start, end, 0)
+		if err != nil {
+			return nil, err
+		}
+		groups, groupField = tools.GroupByState(observations, ranges, ""), config.GroupBy
+	}
+
+	frame := traceFrame(ctx, "tools.ConvertStatisticsToFrame", len(observations), func() *data.Frame {
+		return tools.ConvertStatisticsToFrame(groups, q.Parameter+aggregatePath, groupField, config.Percentiles)
+	})
 	frame.Meta = &data.FrameMeta{PreferredVisualization: data.VisTypeTable}
+	SetStatisticsFieldMetadata(endpoint, q.Parameter, q.AggregatePath, frame)
 	return frame, nil
 }
 
-func SetUnitAndThresholds(endpoint *source.YamcsEndpoint, parameter string, frame *data.Frame) {
+// DatasourceGapsFrame lists the gaps in the data of the query window, one row
+// per gap, usable as region annotations.
+func DatasourceGapsFrame(ctx context.Context, querier *source.Querier, endpoint *source.YamcsEndpoint, q PluginQuery) (*data.Frame, error) {
+	series, _, err := gapSeries(ctx, querier, endpoint, q)
+	if err != nil {
+		return nil, err
+	}
+	frame := tools.ConvertGapsToFrame(series)
+	frame.Meta = &data.FrameMeta{PreferredVisualization: data.VisTypeTable}
+	return frame, nil
+}
 
-	parameterDemand := endpoint.GetParameterDemand(parameter)
+// DatasourceCompletenessFrame computes the share of time covered by data over
+// intervals of the query window.
+func DatasourceCompletenessFrame(ctx context.Context, querier *source.Querier, endpoint *source.YamcsEndpoint, q PluginQuery) (*data.Frame, error) {
+	series, interval, err := gapSeries(ctx, querier, endpoint, q)
+	if err != nil {
+		return nil, err
+	}
+	start, end := time.Unix(int64(q.From), 0), time.Unix(int64(q.To), 0)
+	return tools.ConvertCompletenessToFrame(series, start, end, interval), nil
+}
 
-	frame.Meta = &data.FrameMeta{PreferredVisualization: data.VisTypeGraph}
+// gapSeries finds the gaps of the queried parameter or archive index groups,
+// and returns them with the completeness interval of the query.
+func gapSeries(ctx context.Context, querier *source.Querier, endpoint *source.YamcsEndpoint, q PluginQuery) ([]tools.GapSeries, time.Duration, error) {
+	start, end := time.Unix(int64(q.From), 0), time.Unix(int64(q.To), 0)
+	config := q.Gaps
+	if config == nil {
+		config = &GapsConfig{}
+	}
+	minGap, maxGap, interval, err := config.Durations()
+	if err != nil {
+		return nil, 0, err
+	}
 
-	for _, field := range frame.Fields {
-		if field.Config == nil {
-			field.Config = &data.FieldConfig{}
-		}
-		field.Config.Unit = parameterDemand.Unit
-		field.Config.Thresholds = &data.ThresholdsConfig{
-			Mode:  data.ThresholdsModeAbsolute,
-			Steps: make([]data.Threshold, 0, len(parameterDemand.Thresholds)),
-		}
-		for _, t := range parameterDemand.Thresholds {
-			field.Config.Thresholds.Steps = append(field.Config.Thresholds.Steps, *t)
+	var index string
+	switch config.Source {
+	case "", "parameter":
+		gaps, err := querier.ParameterGaps(ctx, endpoint, q.Parameter, start, end, minGap, maxGap, q.MaxPoints)
+		if err != nil {
+			return nil, 0, err
 		}
+		return []tools.GapSeries{{Name: q.Parameter, Gaps: gaps}}, interval, nil
+	case "apid":
+		index = client.CompletenessIndex
+	case "packet":
+		index = client.PacketIndex
+	default:
+		return nil, 0, exception.New("Unknown gaps source "+config.Source, "INVALID_QUERY")
 	}
+
+	keep := func(id *yamcsprotobuf.NamedObjectId) bool {
+		return (config.Stream == "" || id.GetNamespace() == config.Stream) && (config.Name == "" || id.GetName() == config.Name)
+	}
+	series, err := querier.IndexGaps(ctx, endpoint, index, start, end, minGap, maxGap, keep)
+	return series, interval, err
 }
 
-func DatasourceCommandFrame(endpoint *source.YamcsEndpoint, q PluginQuery) (*data.Frame, error) {
+func DatasourceEventsFrame(ctx context.Context, querier *source.Querier, endpoint *source.YamcsEndpoint, q PluginQuery) (*data.Frame, error) {
+
+	start, end := time.Unix(int64(q.From), 0), time.Unix(int64(q.To), 0)
 
-	yamcs := endpoint.GetClient()
+	events, err := querier.Events(ctx, endpoint, start, end)
+	if err != nil {
+		return nil, err
+	}
+	frame := traceFrame(ctx, "tools.ConvertEventsToFrame", len(events), func() *data.Frame {
+		return tools.ConvertEventsToFrame(events)
+	})
+	frame.Meta = &data.FrameMeta{PreferredVisualization: data.VisTypeTable}
+	return frame, nil
+}
+
+func DatasourceCommandFrame(ctx context.Context, endpoint *source.YamcsEndpoint, q PluginQuery) (*data.Frame, error) {
+
+	yamcs := endpoint.GetClient().WithContext(ctx)
 	command, err := yamcs.GetCommandInfo(endpoint.Instance, q.Command)
 	if err != nil {
 		return nil, err
@@ -192,27 +478,119 @@ func DatasourceCommandFrame(endpoint *source.YamcsEndpoint, q PluginQuery) (*dat
 	), nil
 }
 
-func DatasourceCommandHistoryFrame(endpoint *source.YamcsEndpoint, q PluginQuery) (*data.Frame, error) {
+func DatasourceCommandHistoryFrame(ctx context.Context, endpoint *source.YamcsEndpoint, q PluginQuery) (*data.Frame, error) {
+
+	filter, err := q.History.Filter()
+	if err != nil {
+		return nil, err
+	}
 
-	yamcs := endpoint.GetClient()
+	yamcs := endpoint.GetClient().WithContext(ctx)
 	start, end := time.Unix(int64(q.From), 0), time.Unix(int64(q.To), 0)
 	iterator := yamcs.ListCommandsHistory(endpoint.Instance, start, end)
+	if filter != nil {
+		iterator.SetQuery(filter.ArchiveQuery())
+	}
 	commandList := make([]*commanding.CommandHistoryEntry, 0)
 	for iterator.HasNext() {
 		commands, err := iterator.Next()
 		if err != nil {
 			return nil, err
 		}
-		commandList = append(commandList, commands...)
+		for _, command := range commands {
+			if filter == nil {
+				commandList = append(commandList, command)
+			} else if kept, ok := filter.Apply(command); ok {
+				commandList = append(commandList, kept)
+			}
+		}
+	}
+
+	if q.History.Stages() {
+		now := time.Now()
+		frame := traceFrame(ctx, "tools.ConvertCommandStagesToFrame", len(commandList), func() *data.Frame {
+			return tools.ConvertCommandStagesToFrame(tools.CommandProgressFromHistory(commandList), &now)
+		})
+		return frame, nil
 	}
 
-	frame := tools.ConvertCommandListToFrame(commandList)
+	frame := traceFrame(ctx, "tools.ConvertCommandListToFrame", len(commandList), func() *data.Frame {
+		return tools.ConvertCommandListToFrame(commandList)
+	})
 	frame.Meta = &data.FrameMeta{PreferredVisualization: data.VisTypeTable}
 
 	return frame, nil
 }
 
-func DatasourceTimeFrame(endpoint *source.YamcsEndpoint, q PluginQuery) (*data.Frame, error) {
+func DatasourceCommandProgressFrame(ctx context.Context, endpoint *source.YamcsEndpoint, q PluginQuery) (*data.Frame, error) {
+	progress, err := commandProgress(ctx, endpoint, q)
+	if err != nil {
+		return nil, err
+	}
+	return tools.ConvertCommandProgressToFrame(progress, false), nil
+}
+
+// commandProgress returns the progress of the command followed by q, as far as
+// it is recorded in the archive. A command not archived yet has no stages.
+func commandProgress(ctx context.Context, endpoint *source.YamcsEndpoint, q PluginQuery) (*tools.CommandProgress, error) {
+	if q.Tracking == nil || q.Tracking.ID == "" {
+		return nil, exception.New("Missing command ID to track", "INVALID_QUERY")
+	}
+	progress := tools.NewCommandProgress(q.Tracking.ID)
+	entry, err := endpoint.GetClient().WithContext(ctx).GetCommand(endpoint.Instance.GetName(), q.Tracking.ID)
+	if yamcsErr, ok := corehttp.AsYamcsError(err); ok && yamcsErr.StatusCode == http.StatusNotFound {
+		return progress, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	progress.Update(entry)
+	return progress, nil
+}
+
+func DatasourceCommandStackFrame(stacks *source.CommandStacks, q PluginQuery) (*data.Frame, error) {
+	run, err := commandStackRun(stacks, q)
+	if err != nil {
+		return nil, err
+	}
+	return tools.ConvertStackStatusToFrame(run.Status()), nil
+}
+
+// commandStackRun returns the command stack run followed by q.
+func commandStackRun(stacks *source.CommandStacks, q PluginQuery) (*source.StackRun, error) {
+	if q.Stack == nil || q.Stack.Run == "" {
+		return nil, exception.New("Missing command stack run ID", "INVALID_QUERY")
+	}
+	run, ok := stacks.Get(q.Stack.Run)
+	if !ok || run.Endpoint != q.EndpointID {
+		return nil, exception.New("Command stack run "+q.Stack.Run+" not found", "STACK_NOT_FOUND")
+	}
+	return run, nil
+}
+
+// DatasourceAuditFrame lists the operator actions taken on the endpoint of q
+// during its time range. Only users with at least readerRole may read them, as
+// the entries hold the payloads of the actions.
+func DatasourceAuditFrame(ctx context.Context, log *audit.Log, readerRole string, q PluginQuery) (*data.Frame, error) {
+	if log == nil {
+		return nil, exception.New("The audit log is disabled for this datasource", "AUDIT_DISABLED")
+	}
+	if user := contextUser(ctx); !user.HasRole(readerRole) {
+		return nil, exception.New("The audit log can only be read by users with the "+readerRole+" role", "AUDIT_FORBIDDEN")
+	}
+	start, end := time.Unix(int64(q.From), 0), time.Unix(int64(q.To), 0)
+	entries, err := log.Query(start, end, func(entry audit.Entry) bool {
+		return entry.Endpoint == q.EndpointID && q.Audit.Keeps(entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+	frame := tools.ConvertAuditEntriesToFrame(entries)
+	frame.Meta = &data.FrameMeta{PreferredVisualization: data.VisTypeTable}
+	return frame, nil
+}
+
+func DatasourceTimeFrame(ctx context.Context, endpoint *source.YamcsEndpoint, q PluginQuery) (*data.Frame, error) {
 	currentTime, ok := endpoint.GetCurrentTimeIfFresh(15 * time.Second)
 	if !ok {
 		return data.NewFrame(
@@ -248,23 +626,77 @@ func telemetryPointsToSamples(points []source.TelemetryPoint) []client.Sample {
 	return samples
 }
 
-func DatasourceAlarmsFrame(endpoint *source.YamcsEndpoint, q PluginQuery) (*data.Frame, error) {
+func DatasourceAlarmsFrame(ctx context.Context, endpoint *source.YamcsEndpoint, q PluginQuery) (*data.Frame, error) {
 
-	yamcs := endpoint.GetClient()
+	yamcs := endpoint.GetClient().WithContext(ctx)
 	alarmList, err := yamcs.ListProcessorAlarms(endpoint.Instance, endpoint.Processor)
 	if err != nil {
 		return nil, err
 	}
 
-	frame := tools.ConvertAlarmListToFrame(alarmList)
+	frame := traceFrame(ctx, "tools.ConvertAlarmListToFrame", len(alarmList), func() *data.Frame {
+		return tools.ConvertAlarmListToFrame(alarmList)
+	})
 	frame.Meta = &data.FrameMeta{}
 	frame.Meta.PreferredVisualization = data.VisTypeTable
 	return frame, nil
 
 }
 
-func DatasourceLinksFrame(endpoint *source.YamcsEndpoint, q PluginQuery) (*data.Frame, error) {
-	yamcs := endpoint.GetClient()
+// DatasourceCommandQueuesFrame lists the command queues of the endpoint
+// processor with the commands pending in each.
+func DatasourceCommandQueuesFrame(ctx context.Context, endpoint *source.YamcsEndpoint, q PluginQuery) (*data.Frame, error) {
+	yamcs := endpoint.GetClient().WithContext(ctx)
+	queues, err := yamcs.ListQueues(endpoint.Instance, endpoint.Processor)
+	if err != nil {
+		return nil, err
+	}
+	return tools.ConvertCommandQueuesToFrame(queues), nil
+}
+
+// scheduleHorizon is how far past now scheduled command queries look, so that
+// upcoming commands are listed whatever the dashboard time range.
+const scheduleHorizon = 7 * 24 * time.Hour
+
+// DatasourceScheduledCommandsFrame lists the commands scheduled on the
+// timeline of the endpoint instance, executed ones within the query time
+// range and upcoming ones up to a week ahead.
+func DatasourceScheduledCommandsFrame(ctx context.Context, endpoint *source.YamcsEndpoint, q PluginQuery) (*data.Frame, error) {
+	items, err := listScheduledCommands(ctx, endpoint, q)
+	if err != nil {
+		return nil, err
+	}
+	commands := make([]tools.ScheduledCommand, 0, len(items))
+	for _, item := range items {
+		commands = append(commands, tools.ScheduledCommand{Item: item})
+	}
+	tools.SortScheduledCommands(commands)
+	return tools.ConvertScheduledCommandsToFrame(commands), nil
+}
+
+func listScheduledCommands(ctx context.Context, endpoint *source.YamcsEndpoint, q PluginQuery) ([]*timeline.TimelineItem, error) {
+	start, end := time.Unix(int64(q.From), 0), time.Unix(int64(q.To), 0)
+	if horizon := time.Now().Add(scheduleHorizon); end.Before(horizon) {
+		end = horizon
+	}
+	iterator := endpoint.GetClient().WithContext(ctx).ListTimelineItems(endpoint.Instance, start, end)
+	items := make([]*timeline.TimelineItem, 0)
+	for iterator.HasNext() {
+		page, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range page {
+			if tools.IsCommandActivity(item) {
+				items = append(items, item)
+			}
+		}
+	}
+	return items, nil
+}
+
+func DatasourceLinksFrame(ctx context.Context, endpoint *source.YamcsEndpoint, q PluginQuery) (*data.Frame, error) {
+	yamcs := endpoint.GetClient().WithContext(ctx)
 	list, err := yamcs.ListLinks(endpoint.Instance)
 	if err != nil {
 		return nil, err
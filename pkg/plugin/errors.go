@@ -0,0 +1,121 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/exception"
+	corehttp "github.com/jaops-space/grafana-yamcs-jaops/pkg/yamcs/core/http"
+)
+
+// ErrorResponse is the JSON body returned by every resource handler on failure.
+type ErrorResponse struct {
+	// Error is the human readable message.
+	Error string `json:"error"`
+	// Status is the HTTP status of the response, repeated for clients that only see the body.
+	Status int `json:"status"`
+	// Source is "downstream" when Yamcs or the request caused the failure, "plugin" otherwise.
+	Source backend.ErrorSource `json:"source"`
+	// Code is the plugin error code or the Yamcs exception type, when known.
+	Code string `json:"code,omitempty"`
+	// Type is the Yamcs exception type, e.g. "NotFoundException".
+	Type string `json:"type,omitempty"`
+}
+
+// writeErrorMessage writes a JSON error body for a failure detected by the handler itself,
+// such as a bad method or a missing field.
+func writeErrorMessage(w http.ResponseWriter, status int, message string) {
+	writeErrorResponse(w, ErrorResponse{
+		Error:  message,
+		Status: status,
+		Source: backend.ErrorSourceFromHTTPStatus(status),
+	})
+}
+
+// writeError writes a JSON error body for err. Yamcs errors keep their own status where it
+// is meaningful to the caller; fallbackStatus is used for everything that cannot be classified.
+func writeError(w http.ResponseWriter, fallbackStatus int, err error) {
+	writeErrorResponse(w, errorResponseFor(fallbackStatus, err))
+}
+
+func errorResponseFor(fallbackStatus int, err error) ErrorResponse {
+	response := ErrorResponse{
+		Error:  err.Error(),
+		Status: fallbackStatus,
+		Source: backend.ErrorSourcePlugin,
+	}
+
+	if yamcsErr, ok := corehttp.AsYamcsError(err); ok {
+		response.Error = yamcsErr.Message
+		if response.Error == "" {
+			response.Error = yamcsErr.Error()
+		}
+		response.Status = statusForYamcsError(yamcsErr.StatusCode)
+		response.Source = yamcsErr.Source()
+		response.Code = yamcsErr.Type
+		response.Type = yamcsErr.Type
+		return response
+	}
+
+	var openErr *corehttp.CircuitOpenError
+	if errors.As(err, &openErr) {
+		response.Status = http.StatusServiceUnavailable
+		response.Source = backend.ErrorSourceDownstream
+		response.Code = "CIRCUIT_OPEN"
+		return response
+	}
+
+	var pluginErr *exception.PluginException
+	if errors.As(err, &pluginErr) {
+		response.Error = pluginErr.Message
+		if pluginErr.Cause != nil {
+			response.Error += ": " + pluginErr.Cause.Error()
+		}
+		response.Code = pluginErr.Code
+		if pluginErr.Code == "ENDPOINT_CONFIG_NOT_FOUND" {
+			response.Status = http.StatusNotFound
+			response.Source = backend.ErrorSourceDownstream
+			return response
+		}
+	}
+
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		response.Status = http.StatusGatewayTimeout
+		response.Source = backend.ErrorSourceDownstream
+	case errors.As(err, &netErr):
+		response.Status = http.StatusBadGateway
+		response.Source = backend.ErrorSourceDownstream
+	case backend.IsDownstreamError(err):
+		response.Source = backend.ErrorSourceDownstream
+	}
+	return response
+}
+
+// statusForYamcsError maps the status Yamcs answered with to the status returned to Grafana.
+// Client errors are passed through since they describe the user's request. A 401 means the
+// credentials configured for the endpoint were rejected, which is not the Grafana user's fault.
+func statusForYamcsError(status int) int {
+	switch {
+	case status == http.StatusUnauthorized:
+		return http.StatusBadGateway
+	case status >= 400 && status < 500:
+		return status
+	case status == http.StatusServiceUnavailable, status == http.StatusGatewayTimeout:
+		return status
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+func writeErrorResponse(w http.ResponseWriter, response ErrorResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(response.Status)
+	json.NewEncoder(w).Encode(response)
+}
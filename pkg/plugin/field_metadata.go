@@ -0,0 +1,202 @@
+package plugin
+
+import (
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/mdb"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/source"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/tools"
+)
+
+// fieldContext is what the MDB says about the parameter behind a frame, as
+// resolved once for all of its fields.
+type fieldContext struct {
+	parameter     string // name the fields were built with, aggregate path included
+	aggregatePath string
+	demand        *source.ParameterDemand
+	memberType    *mdb.ParameterTypeInfo
+	activeContext int
+	alarmInfo     *mdb.AlarmInfo
+}
+
+// fieldDecorator fills one aspect of the configuration of a value field.
+type fieldDecorator func(field *data.Field, fc *fieldContext)
+
+// fieldDecorators is the metadata pipeline run on the fields of parameter frames.
+var fieldDecorators = []fieldDecorator{
+	decorateUnit,
+	decorateThresholds,
+	decorateMappings,
+	decorateLimits,
+	decorateDescription,
+}
+
+// SetFieldMetadata decorates the fields of frame with what the MDB defines for
+// parameter, or for its member at aggregatePath: unit, alarm thresholds, state
+// mappings, limit sets, description, display name, range, decimals and data
+// source. Context-dependent alarms are resolved against the latest values of
+// their context parameters.
+func SetFieldMetadata(endpoint *source.YamcsEndpoint, parameter string, aggregatePath string, frame *data.Frame) {
+
+	fc := newFieldContext(endpoint, parameter, aggregatePath)
+
+	frame.Meta = &data.FrameMeta{PreferredVisualization: data.VisTypeGraph}
+
+	for _, field := range frame.Fields {
+		if field.Config == nil {
+			field.Config = &data.FieldConfig{}
+		}
+		if field.Type() == data.FieldTypeTime || field.Type() == data.FieldTypeNullableTime {
+			decorateUnit(field, fc)
+			decorateThresholds(field, fc)
+			continue
+		}
+		for _, decorate := range fieldDecorators {
+			decorate(field, fc)
+		}
+	}
+}
+
+// SetExpandedFieldMetadata decorates the fields of a frame built by
+// tools.ConvertBufferToExpandedFrame. Each leaf field of a wide frame gets the
+// metadata of its own member, found from its name; the value field of a long
+// frame gets the metadata of the members designated by aggregatePath.
+func SetExpandedFieldMetadata(endpoint *source.YamcsEndpoint, parameter string, aggregatePath string, mode tools.ExpansionMode, frame *data.Frame) {
+
+	frame.Meta = &data.FrameMeta{PreferredVisualization: data.VisTypeGraph}
+	if mode == tools.ExpandLong {
+		frame.Meta.PreferredVisualization = data.VisTypeTable
+	}
+
+	for _, field := range frame.Fields {
+		if !strings.HasPrefix(field.Name, parameter) {
+			continue
+		}
+		if field.Config == nil {
+			field.Config = &data.FieldConfig{}
+		}
+		fc := newFieldContext(endpoint, parameter, strings.TrimPrefix(field.Name, parameter))
+		if mode == tools.ExpandLong {
+			fc = newFieldContext(endpoint, parameter, aggregatePath)
+		}
+		fc.parameter = field.Name
+		for _, decorate := range fieldDecorators {
+			decorate(field, fc)
+		}
+	}
+}
+
+// SetStateFieldMetadata decorates the state field of a state timeline or state
+// summary frame with the mappings and description of the parameter. The other
+// fields describe the ranges, not the parameter, and are left as they are.
+func SetStateFieldMetadata(endpoint *source.YamcsEndpoint, parameter string, aggregatePath string, frame *data.Frame) {
+
+	fc := newFieldContext(endpoint, parameter, aggregatePath)
+	for _, field := range frame.Fields {
+		if field.Name != fc.parameter {
+			continue
+		}
+		if field.Config == nil {
+			field.Config = &data.FieldConfig{}
+		}
+		decorateMappings(field, fc)
+		decorateDescription(field, fc)
+	}
+}
+
+// SetStatisticsFieldMetadata decorates the statistics of a parameter with its
+// unit and description, and all but the standard deviation with its thresholds.
+func SetStatisticsFieldMetadata(endpoint *source.YamcsEndpoint, parameter string, aggregatePath string, frame *data.Frame) {
+
+	fc := newFieldContext(endpoint, parameter, aggregatePath)
+	for _, field := range frame.Fields {
+		if !strings.Contains(field.Name, "("+fc.parameter+")") {
+			continue
+		}
+		if field.Config == nil {
+			field.Config = &data.FieldConfig{}
+		}
+		decorateUnit(field, fc)
+		if !strings.HasPrefix(field.Name, "stddev(") {
+			decorateThresholds(field, fc)
+		}
+		decorateDescription(field, fc)
+	}
+}
+
+// newFieldContext resolves the MDB definition of parameter, or of its member at
+// aggregatePath, and the alarm currently applying to it.
+func newFieldContext(endpoint *source.YamcsEndpoint, parameter string, aggregatePath string) *fieldContext {
+	parameterDemand := endpoint.GetParameterDemand(parameter)
+	fc := &fieldContext{
+		parameter:     parameter,
+		aggregatePath: strings.TrimPrefix(aggregatePath, "."),
+		demand:        parameterDemand,
+		memberType:    tools.ParameterTypeAt(parameterDemand.Type, aggregatePath),
+	}
+	if fc.aggregatePath != "" {
+		fc.parameter += "." + fc.aggregatePath
+	}
+	fc.activeContext, fc.alarmInfo = tools.ActiveAlarm(fc.memberType, endpoint.ContextValue)
+	return fc
+}
+
+// activeAlarmContext returns the index of the context alarm currently applying
+// to the queried parameter, -1 for its default alarm.
+func activeAlarmContext(endpoint *source.YamcsEndpoint, q PluginQuery) int {
+	parameterDemand := endpoint.GetParameterDemand(q.Parameter)
+	memberType := tools.ParameterTypeAt(parameterDemand.Type, q.AggregatePath)
+	activeContext, _ := tools.ActiveAlarm(memberType, endpoint.ContextValue)
+	return activeContext
+}
+
+func decorateUnit(field *data.Field, fc *fieldContext) {
+	field.Config.Unit = fc.demand.Unit
+	if unitSet := fc.memberType.GetUnitSet(); fc.memberType != fc.demand.Type && len(unitSet) > 0 {
+		field.Config.Unit = unitSet[0].GetUnit()
+	}
+}
+
+func decorateThresholds(field *data.Field, fc *fieldContext) {
+	thresholds := fc.demand.Thresholds
+	if fc.memberType != fc.demand.Type || fc.activeContext >= 0 {
+		thresholds = tools.ConvertAlarmInfoToThresholds(fc.alarmInfo)
+	}
+	field.Config.Thresholds = &data.ThresholdsConfig{
+		Mode:  data.ThresholdsModeAbsolute,
+		Steps: make([]data.Threshold, 0, len(thresholds)),
+	}
+	for _, t := range thresholds {
+		field.Config.Thresholds.Steps = append(field.Config.Thresholds.Steps, *t)
+	}
+}
+
+func decorateMappings(field *data.Field, fc *fieldContext) {
+	stateAlarm := tools.StateAlarmInfo(fc.memberType)
+	if fc.activeContext >= 0 {
+		stateAlarm = fc.alarmInfo
+	}
+	if mappings := tools.ConvertParameterTypeToMappings(fc.memberType, stateAlarm); len(mappings) > 0 {
+		field.Config.Mappings = tools.MergeValueMappings(field.Config.Mappings, mappings)
+	}
+}
+
+// decorateLimits attaches every limit set under the "alarmLimits" custom key, so
+// that panels can draw lower and upper bands.
+func decorateLimits(field *data.Field, fc *fieldContext) {
+	if limitSets := tools.ConvertParameterTypeToLimitSets(fc.memberType, fc.activeContext); len(limitSets) > 0 {
+		if field.Config.Custom == nil {
+			field.Config.Custom = map[string]interface{}{}
+		}
+		field.Config.Custom["alarmLimits"] = limitSets
+	}
+}
+
+func decorateDescription(field *data.Field, fc *fieldContext) {
+	if fc.demand.Info == nil {
+		return
+	}
+	metadata := tools.ConvertParameterInfoToMetadata(fc.demand.Info, fc.aggregatePath)
+	tools.ApplyMetadataToField(field, fc.parameter, metadata)
+}
@@ -7,6 +7,8 @@ import (
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/instancemgmt"
 	"github.com/jaops-space/grafana-yamcs-jaops/pkg/source"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/audit"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/policy"
 )
 
 // Make sure App implements required interfaces. This is important to do
@@ -26,6 +28,12 @@ type Datasource struct {
 	instancemgmt.InstanceDisposer
 	multiplexer *source.Multiplexer
 	querier     *source.Querier
+	stacks      *source.CommandStacks
+	policies    map[string]*policy.Policy
+	approvals   *policy.Approvals
+	teams       *teamDirectory
+	audit       *audit.Log
+	auditReader string // least role allowed to query the audit log
 
 	lastHealthDetails json.RawMessage
 	healthMutex       sync.RWMutex
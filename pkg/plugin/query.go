@@ -1,5 +1,16 @@
 package plugin
 
+import (
+	"path"
+	"strings"
+	"time"
+
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/source"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/audit"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/exception"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/tools"
+)
+
 type PluginQuery struct {
 	Type                PluginQueryType `json:"type"`
 	EndpointID          string          `json:"endpoint"`
@@ -13,11 +24,41 @@ type PluginQuery struct {
 	AggregatePath       string          `json:"aggregatePath"`
 	FrontendShiftedTime bool            `json:"frontendShiftedTime,omitempty"`
 
+	// Expand lays aggregates and arrays out as one field per member ("wide")
+	// or as member/value rows ("long").
+	Expand string `json:"expand,omitempty"`
+
+	// Expression defines a derived parameter, named after Parameter, computed
+	// from other parameters. Unit overrides the unit propagated from them.
+	Expression string `json:"expression,omitempty"`
+	Unit       string `json:"unit,omitempty"`
+
 	// user-chosen split time from Grafana
 	SplitAt int `json:"splitAt,omitempty"`
 
 	// YAMCS parameter filter configuration
 	YamcsFilter *YamcsFilterConfig `json:"yamcsFilter,omitempty"`
+
+	// Binary value decoding for image and single value queries
+	Binary *BinaryDecodingConfig `json:"binary,omitempty"`
+
+	// Statistics query configuration
+	Statistics *StatisticsConfig `json:"statistics,omitempty"`
+
+	// Gaps and completeness query configuration
+	Gaps *GapsConfig `json:"gaps,omitempty"`
+
+	// Command progress query configuration
+	Tracking *CommandTrackingConfig `json:"tracking,omitempty"`
+
+	// Command stack query configuration
+	Stack *CommandStackConfig `json:"stack,omitempty"`
+
+	// Audit query configuration
+	Audit *AuditQueryConfig `json:"audit,omitempty"`
+
+	// Command history query configuration
+	History *CommandHistoryConfig `json:"history,omitempty"`
 }
 
 // YamcsFilterConfig defines client-side YAMCS parameter filtering
@@ -28,19 +69,201 @@ type YamcsFilterConfig struct {
 	Value     string `json:"value"`     // Expected value for comparison
 }
 
+// BinaryDecodingConfig defines how binary parameter values are decoded
+type BinaryDecodingConfig struct {
+	Width       int    `json:"width,omitempty"`       // Width of raw sensor frames, in pixels
+	Height      int    `json:"height,omitempty"`      // Height of raw sensor frames, in pixels
+	PixelFormat string `json:"pixelFormat,omitempty"` // "gray8", "gray16", "rgb24" or "rgba32"
+	History     int    `json:"history,omitempty"`     // Number of latest values to return, 0 for the last one only
+}
+
+// RawImageFormat returns the format of raw sensor frames, or nil when the
+// query does not describe one.
+func (c *BinaryDecodingConfig) RawImageFormat() *tools.RawImageFormat {
+	if c == nil || c.PixelFormat == "" {
+		return nil
+	}
+	return &tools.RawImageFormat{Width: c.Width, Height: c.Height, PixelFormat: tools.PixelFormat(c.PixelFormat)}
+}
+
+// HistoryLength returns the number of values a binary query keeps.
+func (c *BinaryDecodingConfig) HistoryLength() int {
+	if c == nil || c.History < 1 {
+		return 1
+	}
+	return c.History
+}
+
+// StatisticsConfig defines the statistics computed by a statistics query
+type StatisticsConfig struct {
+	Exact       bool      `json:"exact,omitempty"`       // Compute from every raw value (at most 100000) rather than sample buckets
+	Percentiles []float64 `json:"percentiles,omitempty"` // Percentiles to compute, e.g. 95
+	Interval    string    `json:"interval,omitempty"`    // Group into fixed intervals, e.g. "1h"
+	GroupBy     string    `json:"groupBy,omitempty"`     // Group by the state of another parameter
+}
+
+// GapsConfig defines how gaps and completeness queries detect missing data
+type GapsConfig struct {
+	Source   string `json:"source,omitempty"`   // "parameter" (default), "apid" for the completeness index or "packet" for the packet index
+	MinGap   string `json:"minGap,omitempty"`   // Shortest gap reported, e.g. "30s"
+	MaxGap   string `json:"maxGap,omitempty"`   // Longest silence that is not a gap; the parameter expiration or the index default when empty
+	Interval string `json:"interval,omitempty"` // Completeness interval, e.g. "1h"; the whole time range when empty
+	Stream   string `json:"stream,omitempty"`   // Index groups to keep by namespace, e.g. a stream name; all when empty
+	Name     string `json:"name,omitempty"`     // Index groups to keep by name, e.g. an APID or packet name; all when empty
+}
+
+// Durations parses the minimum and maximum gaps and the completeness interval.
+func (c *GapsConfig) Durations() (minGap, maxGap, interval time.Duration, err error) {
+	if c == nil {
+		return 0, 0, 0, nil
+	}
+	for _, d := range []struct {
+		name  string
+		value string
+		into  *time.Duration
+	}{{"minGap", c.MinGap, &minGap}, {"maxGap", c.MaxGap, &maxGap}, {"interval", c.Interval, &interval}} {
+		if d.value == "" {
+			continue
+		}
+		if *d.into, err = time.ParseDuration(d.value); err != nil || *d.into < 0 {
+			return 0, 0, 0, exception.Wrap("Invalid gaps "+d.name+" "+d.value, "INVALID_QUERY", err)
+		}
+	}
+	return minGap, maxGap, interval, nil
+}
+
+// defaultTrackingTimeout is how long a command is followed when the query does
+// not set a timeout.
+const defaultTrackingTimeout = 2 * time.Minute
+
+// CommandTrackingConfig identifies the command followed by a command progress query
+type CommandTrackingConfig struct {
+	ID      string `json:"id"`                // Command ID returned by the command issue resource
+	Timeout string `json:"timeout,omitempty"` // Stop following the command after this long, e.g. "5m"; 2 minutes when empty
+}
+
+// TimeoutDuration parses the tracking timeout.
+func (c *CommandTrackingConfig) TimeoutDuration() (time.Duration, error) {
+	if c == nil || c.Timeout == "" {
+		return defaultTrackingTimeout, nil
+	}
+	timeout, err := time.ParseDuration(c.Timeout)
+	if err != nil || timeout <= 0 {
+		return 0, exception.Wrap("Invalid tracking timeout "+c.Timeout, "INVALID_QUERY", err)
+	}
+	return timeout, nil
+}
+
+// CommandStackConfig identifies the command stack run followed by a command stack query
+type CommandStackConfig struct {
+	Run string `json:"run"` // Run ID returned by the stack run resource
+}
+
+// CommandHistoryConfig sets the layout and filters of command history queries
+type CommandHistoryConfig struct {
+	Layout string   `json:"layout,omitempty"` // "commands" (default) for one row per command or "stages" for one row per command stage
+	Names  []string `json:"names,omitempty"`  // Command name patterns, as in command policies; all when empty
+	User   string   `json:"user,omitempty"`   // Yamcs user who issued the command; all when empty
+	Origin string   `json:"origin,omitempty"` // Origin of the command; all when empty
+	Queue  string   `json:"queue,omitempty"`  // Queue the command went through; all when empty
+	Status string   `json:"status,omitempty"` // "success", "failure" or "pending"; all when empty
+}
+
+// Filter returns the command history filter of the query, nil when the query
+// keeps every command.
+func (c *CommandHistoryConfig) Filter() (*source.CommandHistoryFilter, error) {
+	if c == nil || (len(c.Names) == 0 && c.User == "" && c.Origin == "" && c.Queue == "" && c.Status == "") {
+		return nil, nil
+	}
+	for _, pattern := range c.Names {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, exception.Wrap("Invalid command name pattern "+pattern, "INVALID_QUERY", err)
+		}
+	}
+	switch c.Status {
+	case "", source.CommandSucceeded, source.CommandFailed, source.CommandPending:
+	default:
+		return nil, exception.New("Invalid command status "+c.Status, "INVALID_QUERY")
+	}
+	return &source.CommandHistoryFilter{
+		Names:  c.Names,
+		User:   c.User,
+		Origin: c.Origin,
+		Queue:  c.Queue,
+		Status: c.Status,
+	}, nil
+}
+
+// Stages tells whether the command history is laid out one row per stage.
+func (c *CommandHistoryConfig) Stages() bool {
+	return c != nil && c.Layout == "stages"
+}
+
+// AuditQueryConfig filters the operator actions listed by an audit query
+type AuditQueryConfig struct {
+	Action  string `json:"action,omitempty"`  // Action or action family, e.g. "command.issue" or "alarm"; all when empty
+	User    string `json:"user,omitempty"`    // Grafana user who took the action; all when empty
+	Outcome string `json:"outcome,omitempty"` // "success", "failure", "denied" or "pending"; all when empty
+	// Overrides lists only the commands sent, or attempted, with contingency issue options
+	Overrides bool `json:"overrides,omitempty"`
+}
+
+// Keeps tells whether the audit query lists entry.
+func (c *AuditQueryConfig) Keeps(entry audit.Entry) bool {
+	if c == nil {
+		return true
+	}
+	if c.Action != "" && entry.Action != c.Action && !strings.HasPrefix(entry.Action, c.Action+".") {
+		return false
+	}
+	if c.User != "" && entry.User != c.User {
+		return false
+	}
+	if c.Overrides && len(entry.Overrides) == 0 {
+		return false
+	}
+	return c.Outcome == "" || string(entry.Outcome) == c.Outcome
+}
+
 type PluginQueryType string
 
 const (
-	Graph          PluginQueryType = "plot"
-	SingleValue    PluginQueryType = "single"
-	DiscreteValue  PluginQueryType = "discrete"
-	Events         PluginQueryType = "events"
-	Time           PluginQueryType = "time"
-	Image          PluginQueryType = "image"
-	Commanding     PluginQueryType = "commanding"
-	CommandHistory PluginQueryType = "command-history"
-	Alarms         PluginQueryType = "alarms"
-	Links          PluginQueryType = "links"
-	Demands        PluginQueryType = "demands"
-	Subscriptions  PluginQueryType = "subscriptions"
+	Graph           PluginQueryType = "plot"
+	SingleValue     PluginQueryType = "single"
+	DiscreteValue   PluginQueryType = "discrete"
+	StateTimeline   PluginQueryType = "state-timeline"
+	StateSummary    PluginQueryType = "state-summary"
+	Statistics      PluginQueryType = "statistics"
+	Gaps            PluginQueryType = "gaps"
+	Completeness    PluginQueryType = "completeness"
+	Events          PluginQueryType = "events"
+	Time            PluginQueryType = "time"
+	Image           PluginQueryType = "image"
+	Commanding      PluginQueryType = "commanding"
+	CommandHistory  PluginQueryType = "command-history"
+	CommandProgress PluginQueryType = "command-progress"
+	CommandStack    PluginQueryType = "command-stack"
+	CommandQueues   PluginQueryType = "command-queues"
+	CommandSchedule PluginQueryType = "scheduled-commands"
+	Audit           PluginQueryType = "audit"
+	Alarms          PluginQueryType = "alarms"
+	Links           PluginQueryType = "links"
+	Demands         PluginQueryType = "demands"
+	Subscriptions   PluginQueryType = "subscriptions"
 )
+
+// ExpansionMode returns how aggregate and array values of the query are laid
+// out. A wildcard in the aggregate path implies the wide layout.
+func (q *PluginQuery) ExpansionMode() (tools.ExpansionMode, error) {
+	switch mode := tools.ExpansionMode(q.Expand); mode {
+	case tools.ExpandNone:
+		if tools.HasPathWildcard(q.AggregatePath) {
+			return tools.ExpandWide, nil
+		}
+		return mode, nil
+	case tools.ExpandWide, tools.ExpandLong:
+		return mode, nil
+	default:
+		return "", exception.New("Invalid expansion mode "+q.Expand, "INVALID_QUERY")
+	}
+}
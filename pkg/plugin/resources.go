@@ -1,21 +1,27 @@
 package plugin
 
 import (
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/links"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/audit"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/tools"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/types"
+	corehttp "github.com/jaops-space/grafana-yamcs-jaops/pkg/yamcs/core/http"
 	"google.golang.org/protobuf/encoding/protojson"
 )
 
 // handleFetchSources handles incoming requests to check endpoint statuses.
 func (d *Datasource) handleFetchSources(w http.ResponseWriter, req *http.Request) {
 	if req.Method != http.MethodGet {
-		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		writeErrorMessage(w, http.StatusMethodNotAllowed, "Invalid request method")
 		return
 	}
 
@@ -31,6 +37,9 @@ func (d *Datasource) handleFetchSources(w http.ResponseWriter, req *http.Request
 		} else {
 			object["online"] = endpoint.GetClient().WebSocket.IsConnected()
 		}
+		if hostConfig, exists := d.multiplexer.Configuration.Hosts[endpointConfiguration.Host]; exists {
+			object["circuit"] = hostBreaker(hostConfig).Status()
+		}
 		response[endpointID] = object
 	}
 
@@ -42,7 +51,7 @@ func (d *Datasource) handleFetchSources(w http.ResponseWriter, req *http.Request
 func (d *Datasource) handleSearchParameters(w http.ResponseWriter, req *http.Request) {
 
 	if req.Method != http.MethodGet {
-		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		writeErrorMessage(w, http.StatusMethodNotAllowed, "Invalid request method")
 		return
 	}
 
@@ -52,14 +61,14 @@ func (d *Datasource) handleSearchParameters(w http.ResponseWriter, req *http.Req
 
 	endpoint, err := d.multiplexer.GetEndpoint(endpointID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, err)
 		return
 	}
 	client := endpoint.GetClient()
 	reqIterator := client.SearchParameters(endpoint.Instance, query)
 	results, err := reqIterator.Next()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, err)
 		return
 	}
 
@@ -73,6 +82,38 @@ func (d *Datasource) handleSearchParameters(w http.ResponseWriter, req *http.Req
 
 }
 
+// handleGetParameterInfo returns the presentation metadata of a parameter, or of
+// the member designated by the optional path query parameter.
+func (d *Datasource) handleGetParameterInfo(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeErrorMessage(w, http.StatusMethodNotAllowed, "Invalid request method")
+		return
+	}
+
+	vars := mux.Vars(req)
+	endpointID := vars["endpointID"]
+	parameterName := req.URL.Query().Get("name")
+	if parameterName == "" {
+		writeErrorMessage(w, http.StatusBadRequest, "missing required query parameter: name")
+		return
+	}
+
+	endpoint, err := d.multiplexer.GetEndpoint(endpointID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	metadata, err := endpoint.GetParameterMetadata(req.Context(), parameterName, req.URL.Query().Get("path"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(metadata)
+}
+
 type CommandInfoResult struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
@@ -80,7 +121,7 @@ type CommandInfoResult struct {
 
 func (d *Datasource) handleSearchCommands(w http.ResponseWriter, req *http.Request) {
 	if req.Method != http.MethodGet {
-		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		writeErrorMessage(w, http.StatusMethodNotAllowed, "Invalid request method")
 		return
 	}
 
@@ -90,14 +131,14 @@ func (d *Datasource) handleSearchCommands(w http.ResponseWriter, req *http.Reque
 
 	endpoint, err := d.multiplexer.GetEndpoint(endpointID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, err)
 		return
 	}
 	client := endpoint.GetClient()
 	reqIterator := client.SearchCommandInfo(endpoint.Instance, query)
 	results, err := reqIterator.Next()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, err)
 		return
 	}
 
@@ -123,12 +164,31 @@ func (d *Datasource) registerRoutes(mux *mux.Router) {
 	mux.HandleFunc("/fetch/endpoints", d.handleFetchSources)
 
 	mux.HandleFunc("/fetch/health-details", d.handleGetLastHealthDetails)
+	mux.HandleFunc("/fetch/cache", d.handleGetCacheStats)
 
 	mux.HandleFunc("/endpoint/{endpointID}/parameters", d.handleSearchParameters)
+	mux.HandleFunc("/endpoint/{endpointID}/parameter/info", d.handleGetParameterInfo)
 	mux.HandleFunc("/endpoint/{endpointID}/time", d.handleEndpointTime)
 	mux.HandleFunc("/endpoint/{endpointID}/commands", d.handleSearchCommands)
 	mux.HandleFunc("/endpoint/{endpointID}/command/info", d.handleGetCommandInfo)
+	mux.HandleFunc("/endpoint/{endpointID}/export/parameters", d.handleExportParameters)
+	mux.HandleFunc("/endpoint/{endpointID}/export/events", d.handleExportEvents)
+	mux.HandleFunc("/endpoint/{endpointID}/export/commands", d.handleExportCommands)
 	mux.HandleFunc("/endpoint/{endpointID}/command/issue", d.handleExecuteCommand)
+	mux.HandleFunc("/endpoint/{endpointID}/command/validate", d.handleValidateCommand)
+	mux.HandleFunc("/endpoint/{endpointID}/command/approvals", d.handleListCommandApprovals)
+	mux.HandleFunc("/endpoint/{endpointID}/command/approvals/{approvalID}/{action}", d.handleCommandApproval)
+	mux.HandleFunc("/endpoint/{endpointID}/stack/import", d.handleImportCommandStack)
+	mux.HandleFunc("/endpoint/{endpointID}/stack/run", d.handleRunCommandStack)
+	mux.HandleFunc("/endpoint/{endpointID}/stack/{runID}", d.handleGetCommandStack)
+	mux.HandleFunc("/endpoint/{endpointID}/stack/{runID}/{action}", d.handleControlCommandStack)
+	mux.HandleFunc("/endpoint/{endpointID}/schedule", d.handleListScheduledCommands)
+	mux.HandleFunc("/endpoint/{endpointID}/schedule/command", d.handleScheduleCommand)
+	mux.HandleFunc("/endpoint/{endpointID}/schedule/{itemID}", d.handleUpdateScheduledCommand)
+	mux.HandleFunc("/endpoint/{endpointID}/schedule/{itemID}/cancel", d.handleCancelScheduledCommand)
+	mux.HandleFunc("/endpoint/{endpointID}/queues", d.handleListQueues)
+	mux.HandleFunc("/endpoint/{endpointID}/queues/{queue}/entries/{commandID}/{action}", d.handleQueuedCommand)
+	mux.HandleFunc("/endpoint/{endpointID}/queues/{queue}/{action}", d.handleSetQueueState)
 	mux.HandleFunc("/endpoint/{endpointID}/alarm/acknowledge", d.handleAcknowledgeAlarm)
 	mux.HandleFunc("/endpoint/{endpointID}/alarm/clear", d.handleClearAlarm)
 	mux.HandleFunc("/endpoint/{endpointID}/alarm/shelve", d.handleShelveAlarm)
@@ -145,7 +205,7 @@ func (d *Datasource) registerRoutes(mux *mux.Router) {
 
 func (d *Datasource) handleEndpointTime(w http.ResponseWriter, req *http.Request) {
 	if req.Method != http.MethodGet {
-		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		writeErrorMessage(w, http.StatusMethodNotAllowed, "Invalid request method")
 		return
 	}
 
@@ -154,7 +214,7 @@ func (d *Datasource) handleEndpointTime(w http.ResponseWriter, req *http.Request
 
 	endpoint, err := d.multiplexer.GetEndpoint(endpointID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -166,7 +226,7 @@ func (d *Datasource) handleEndpointTime(w http.ResponseWriter, req *http.Request
 	maxTimeAge := 10 * time.Second
 
 	if currentTime.IsZero() || currentTimeUpdatedAt.IsZero() || time.Since(currentTimeUpdatedAt) > maxTimeAge {
-		http.Error(w, "processor time unavailable", http.StatusServiceUnavailable)
+		writeErrorMessage(w, http.StatusServiceUnavailable, "processor time unavailable")
 		return
 	}
 
@@ -185,14 +245,37 @@ func (d *Datasource) handleGetLastHealthDetails(w http.ResponseWriter, req *http
 		w.Header().Set("Content-Type", "application/json")
 		w.Write(d.lastHealthDetails)
 	} else {
-		http.Error(w, "No health details available", http.StatusNotFound)
+		writeErrorMessage(w, http.StatusNotFound, "No health details available")
 	}
 }
 
+// CacheStatsResult reports the activity of the historical query cache.
+type CacheStatsResult struct {
+	Enabled bool `json:"enabled"`
+	types.CacheStats
+}
+
+// handleGetCacheStats returns the hit, miss and memory counters of the historical query cache.
+func (d *Datasource) handleGetCacheStats(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeErrorMessage(w, http.StatusMethodNotAllowed, "Invalid request method")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(CacheStatsResult{
+		Enabled:    d.querier.History != nil,
+		CacheStats: d.querier.History.Stats(),
+	})
+}
+
 type CommandIssueBody struct {
 	Name      string         `json:"name"`
 	Arguments map[string]any `json:"arguments"`
 	Comment   string         `json:"comment"`
+	// Options are contingency issue options. They must be allowed by the
+	// command policy and justified by the comment.
+	Options *tools.CommandIssueOptions `json:"options,omitempty"`
 }
 
 const maxJSONBodyBytes int64 = 1 << 20
@@ -234,7 +317,7 @@ func decodeOptionalJSONBody(w http.ResponseWriter, req *http.Request, dst any) e
 
 func (d *Datasource) handleGetCommandInfo(w http.ResponseWriter, req *http.Request) {
 	if req.Method != http.MethodGet {
-		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		writeErrorMessage(w, http.StatusMethodNotAllowed, "Invalid request method")
 		return
 	}
 
@@ -242,26 +325,26 @@ func (d *Datasource) handleGetCommandInfo(w http.ResponseWriter, req *http.Reque
 	endpointID := vars["endpointID"]
 	commandName := req.URL.Query().Get("name")
 	if commandName == "" {
-		http.Error(w, "missing required query parameter: name", http.StatusBadRequest)
+		writeErrorMessage(w, http.StatusBadRequest, "missing required query parameter: name")
 		return
 	}
 
 	endpoint, err := d.multiplexer.GetEndpoint(endpointID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, err)
 		return
 	}
 
 	client := endpoint.GetClient()
 	commandInfo, err := client.GetCommandInfo(endpoint.Instance, commandName)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, err)
 		return
 	}
 
 	marshalled, err := protojson.Marshal(commandInfo)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -271,7 +354,7 @@ func (d *Datasource) handleGetCommandInfo(w http.ResponseWriter, req *http.Reque
 
 func (d *Datasource) handleExecuteCommand(w http.ResponseWriter, req *http.Request) {
 	if req.Method != http.MethodPost {
-		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		writeErrorMessage(w, http.StatusMethodNotAllowed, "Invalid request method")
 		return
 	}
 
@@ -281,24 +364,58 @@ func (d *Datasource) handleExecuteCommand(w http.ResponseWriter, req *http.Reque
 	body := &CommandIssueBody{}
 	err := decodeJSONBody(w, req, &body)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, err)
 		return
 	}
 
 	endpoint, err := d.multiplexer.GetEndpoint(endpointID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, err)
 		return
 	}
-	client := endpoint.GetClient()
-	response, err := client.IssueCommandWithComment(endpoint.Instance, endpoint.Processor, body.Name, body.Arguments, body.Comment)
+	overrides := body.Options.Overrides()
+	if len(overrides) > 0 && strings.TrimSpace(body.Comment) == "" {
+		writeErrorMessage(w, http.StatusBadRequest, "a comment justifying the issue options is required")
+		return
+	}
+	entry := audit.Entry{Endpoint: endpointID, Action: "command.issue", Target: body.Name, Overrides: overrides}
+	if len(overrides) > 0 {
+		if err := checkIssueOptions(req, endpoint, body.Name, body.Options); err != nil {
+			d.recordAction(req, entry, body, nil, err)
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	decision, err := d.authorizeCommand(req, endpoint, endpointID, body.Name, len(overrides) > 0)
+	if err != nil {
+		d.recordAction(req, entry, body, nil, err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !decision.Allowed {
+		entry.Outcome, entry.Error = audit.OutcomeDenied, decision.Reason
+		d.recordAction(req, entry, body, nil, nil)
+		writeErrorMessage(w, http.StatusForbidden, decision.Reason)
+		return
+	}
+	if decision.Confirm {
+		approval := d.approvals.Request(endpointID, body.Name, body.Arguments, body.Options, body.Comment, requestUser(req).Identity(), d.policies[endpointID].ApprovalWindow())
+		entry.Outcome = audit.OutcomePending
+		d.recordAction(req, entry, body, approval, nil)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(CommandApprovalResponse{Approval: approval})
+		return
+	}
+	response, err := issueCommand(endpoint, body.Name, body.Arguments, body.Options, body.Comment)
+	d.recordAction(req, entry, body, response, err)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, err)
 		return
 	}
 	marshalled, err := protojson.Marshal(response)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, err)
 		return
 	}
 	responseJSON := json.RawMessage(marshalled)
@@ -307,6 +424,82 @@ func (d *Datasource) handleExecuteCommand(w http.ResponseWriter, req *http.Reque
 	json.NewEncoder(w).Encode(responseJSON)
 }
 
+// CommandValidationResult is the response of the command validate endpoint.
+type CommandValidationResult struct {
+	// Valid is true when the arguments passed validation and the Yamcs dry-run.
+	Valid bool `json:"valid"`
+	// Arguments holds the given arguments completed with initial values.
+	Arguments map[string]any `json:"arguments"`
+	// Errors lists the faulty arguments, or the reason Yamcs rejected the command.
+	Errors []tools.ArgumentError `json:"errors"`
+	// Binary and UnprocessedBinary are the hexadecimal encodings of the command,
+	// after and before link post-processing, when the dry-run succeeded.
+	Binary            string `json:"binary,omitempty"`
+	UnprocessedBinary string `json:"unprocessedBinary,omitempty"`
+}
+
+func (d *Datasource) handleValidateCommand(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeErrorMessage(w, http.StatusMethodNotAllowed, "Invalid request method")
+		return
+	}
+
+	vars := mux.Vars(req)
+	endpointID := vars["endpointID"]
+
+	body := &CommandIssueBody{}
+	if err := decodeJSONBody(w, req, &body); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if body.Name == "" {
+		writeErrorMessage(w, http.StatusBadRequest, "missing required field: name")
+		return
+	}
+
+	endpoint, err := d.multiplexer.GetEndpoint(endpointID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	decision, err := d.authorizeCommand(req, endpoint, endpointID, body.Name, len(body.Options.Overrides()) > 0)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !decision.Allowed {
+		writeErrorMessage(w, http.StatusForbidden, decision.Reason)
+		return
+	}
+	client := endpoint.GetClient().WithContext(req.Context())
+	commandInfo, err := client.GetCommandInfo(endpoint.Instance, body.Name)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	result := CommandValidationResult{}
+	result.Arguments, result.Errors = tools.ValidateCommandArguments(commandInfo, body.Arguments)
+	if len(result.Errors) == 0 {
+		response, err := client.DryRunCommand(endpoint.Instance, endpoint.Processor, body.Name, result.Arguments, body.Comment)
+		yamcsErr, rejected := corehttp.AsYamcsError(err)
+		switch {
+		case err == nil:
+			result.Binary = hex.EncodeToString(response.GetBinary())
+			result.UnprocessedBinary = hex.EncodeToString(response.GetUnprocessedBinary())
+		case rejected && yamcsErr.StatusCode >= 400 && yamcsErr.StatusCode < 500 && yamcsErr.StatusCode != http.StatusUnauthorized:
+			result.Errors = append(result.Errors, tools.ArgumentError{Code: "REJECTED", Message: yamcsErr.Message})
+		default:
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	result.Valid = len(result.Errors) == 0
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
 // AlarmActionBody represents the request body for alarm actions.
 type AlarmActionBody struct {
 	Name           string `json:"name"`
@@ -317,7 +510,7 @@ type AlarmActionBody struct {
 
 func (d *Datasource) handleAcknowledgeAlarm(w http.ResponseWriter, req *http.Request) {
 	if req.Method != http.MethodPost {
-		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		writeErrorMessage(w, http.StatusMethodNotAllowed, "Invalid request method")
 		return
 	}
 
@@ -327,23 +520,24 @@ func (d *Datasource) handleAcknowledgeAlarm(w http.ResponseWriter, req *http.Req
 	var body AlarmActionBody
 	err := decodeJSONBody(w, req, &body)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, err)
 		return
 	}
 	if body.Name == "" {
-		http.Error(w, "missing required field: name", http.StatusBadRequest)
+		writeErrorMessage(w, http.StatusBadRequest, "missing required field: name")
 		return
 	}
 
 	endpoint, err := d.multiplexer.GetEndpoint(endpointID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, err)
 		return
 	}
 	client := endpoint.GetClient()
 	err = client.AcknowledgeAlarm(endpoint.Instance, endpoint.Processor, body.Name, body.SeqNum, body.Comment)
+	d.recordAction(req, audit.Entry{Endpoint: endpointID, Action: "alarm.acknowledge", Target: body.Name}, body, nil, err)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, err)
 		return
 	}
 
@@ -353,7 +547,7 @@ func (d *Datasource) handleAcknowledgeAlarm(w http.ResponseWriter, req *http.Req
 
 func (d *Datasource) handleClearAlarm(w http.ResponseWriter, req *http.Request) {
 	if req.Method != http.MethodPost {
-		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		writeErrorMessage(w, http.StatusMethodNotAllowed, "Invalid request method")
 		return
 	}
 
@@ -363,23 +557,24 @@ func (d *Datasource) handleClearAlarm(w http.ResponseWriter, req *http.Request)
 	body := AlarmActionBody{}
 	err := decodeJSONBody(w, req, &body)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, err)
 		return
 	}
 	if body.Name == "" {
-		http.Error(w, "missing required field: name", http.StatusBadRequest)
+		writeErrorMessage(w, http.StatusBadRequest, "missing required field: name")
 		return
 	}
 
 	endpoint, err := d.multiplexer.GetEndpoint(endpointID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, err)
 		return
 	}
 	client := endpoint.GetClient()
 	err = client.ClearAlarm(endpoint.Instance, endpoint.Processor, body.Name, body.SeqNum, body.Comment)
+	d.recordAction(req, audit.Entry{Endpoint: endpointID, Action: "alarm.clear", Target: body.Name}, body, nil, err)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, err)
 		return
 	}
 
@@ -389,7 +584,7 @@ func (d *Datasource) handleClearAlarm(w http.ResponseWriter, req *http.Request)
 
 func (d *Datasource) handleShelveAlarm(w http.ResponseWriter, req *http.Request) {
 	if req.Method != http.MethodPost {
-		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		writeErrorMessage(w, http.StatusMethodNotAllowed, "Invalid request method")
 		return
 	}
 
@@ -399,23 +594,24 @@ func (d *Datasource) handleShelveAlarm(w http.ResponseWriter, req *http.Request)
 	body := AlarmActionBody{}
 	err := decodeJSONBody(w, req, &body)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, err)
 		return
 	}
 	if body.Name == "" {
-		http.Error(w, "missing required field: name", http.StatusBadRequest)
+		writeErrorMessage(w, http.StatusBadRequest, "missing required field: name")
 		return
 	}
 
 	endpoint, err := d.multiplexer.GetEndpoint(endpointID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, err)
 		return
 	}
 	client := endpoint.GetClient()
 	err = client.ShelveAlarm(endpoint.Instance, endpoint.Processor, body.Name, body.SeqNum, body.Comment, body.ShelveDuration)
+	d.recordAction(req, audit.Entry{Endpoint: endpointID, Action: "alarm.shelve", Target: body.Name}, body, nil, err)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, err)
 		return
 	}
 
@@ -425,7 +621,7 @@ func (d *Datasource) handleShelveAlarm(w http.ResponseWriter, req *http.Request)
 
 func (d *Datasource) handleUnshelveAlarm(w http.ResponseWriter, req *http.Request) {
 	if req.Method != http.MethodPost {
-		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		writeErrorMessage(w, http.StatusMethodNotAllowed, "Invalid request method")
 		return
 	}
 
@@ -435,23 +631,24 @@ func (d *Datasource) handleUnshelveAlarm(w http.ResponseWriter, req *http.Reques
 	body := AlarmActionBody{}
 	err := decodeJSONBody(w, req, &body)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, err)
 		return
 	}
 	if body.Name == "" {
-		http.Error(w, "missing required field: name", http.StatusBadRequest)
+		writeErrorMessage(w, http.StatusBadRequest, "missing required field: name")
 		return
 	}
 
 	endpoint, err := d.multiplexer.GetEndpoint(endpointID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, err)
 		return
 	}
 	client := endpoint.GetClient()
 	err = client.UnshelveAlarm(endpoint.Instance, endpoint.Processor, body.Name, body.SeqNum)
+	d.recordAction(req, audit.Entry{Endpoint: endpointID, Action: "alarm.unshelve", Target: body.Name}, body, nil, err)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, err)
 		return
 	}
 
@@ -486,7 +683,7 @@ type ActionResult struct {
 // handleListLinks handles incoming requests to list all links for an endpoint.
 func (d *Datasource) handleListLinks(w http.ResponseWriter, req *http.Request) {
 	if req.Method != http.MethodGet {
-		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		writeErrorMessage(w, http.StatusMethodNotAllowed, "Invalid request method")
 		return
 	}
 
@@ -495,14 +692,14 @@ func (d *Datasource) handleListLinks(w http.ResponseWriter, req *http.Request) {
 
 	endpoint, err := d.multiplexer.GetEndpoint(endpointID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, err)
 		return
 	}
 
 	client := endpoint.GetClient()
 	links, err := client.ListLinks(endpoint.Instance)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, err)
 		return
 	}
 
@@ -518,7 +715,7 @@ func (d *Datasource) handleListLinks(w http.ResponseWriter, req *http.Request) {
 // handleGetLink handles incoming requests to get a specific link.
 func (d *Datasource) handleGetLink(w http.ResponseWriter, req *http.Request) {
 	if req.Method != http.MethodGet {
-		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		writeErrorMessage(w, http.StatusMethodNotAllowed, "Invalid request method")
 		return
 	}
 
@@ -528,14 +725,14 @@ func (d *Datasource) handleGetLink(w http.ResponseWriter, req *http.Request) {
 
 	endpoint, err := d.multiplexer.GetEndpoint(endpointID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, err)
 		return
 	}
 
 	client := endpoint.GetClient()
 	link, err := client.GetLink(endpoint.Instance, linkName)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, err)
 		return
 	}
 
@@ -548,7 +745,7 @@ func (d *Datasource) handleGetLink(w http.ResponseWriter, req *http.Request) {
 // handleEnableLink handles incoming requests to enable a link.
 func (d *Datasource) handleEnableLink(w http.ResponseWriter, req *http.Request) {
 	if req.Method != http.MethodPost {
-		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		writeErrorMessage(w, http.StatusMethodNotAllowed, "Invalid request method")
 		return
 	}
 
@@ -558,14 +755,15 @@ func (d *Datasource) handleEnableLink(w http.ResponseWriter, req *http.Request)
 
 	endpoint, err := d.multiplexer.GetEndpoint(endpointID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, err)
 		return
 	}
 
 	client := endpoint.GetClient()
 	link, err := client.EnableLink(endpoint.Instance, linkName)
+	d.recordAction(req, audit.Entry{Endpoint: endpointID, Action: "link.enable", Target: linkName}, nil, link, err)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, err)
 		return
 	}
 
@@ -578,7 +776,7 @@ func (d *Datasource) handleEnableLink(w http.ResponseWriter, req *http.Request)
 // handleDisableLink handles incoming requests to disable a link.
 func (d *Datasource) handleDisableLink(w http.ResponseWriter, req *http.Request) {
 	if req.Method != http.MethodPost {
-		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		writeErrorMessage(w, http.StatusMethodNotAllowed, "Invalid request method")
 		return
 	}
 
@@ -588,14 +786,15 @@ func (d *Datasource) handleDisableLink(w http.ResponseWriter, req *http.Request)
 
 	endpoint, err := d.multiplexer.GetEndpoint(endpointID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, err)
 		return
 	}
 
 	client := endpoint.GetClient()
 	link, err := client.DisableLink(endpoint.Instance, linkName)
+	d.recordAction(req, audit.Entry{Endpoint: endpointID, Action: "link.disable", Target: linkName}, nil, link, err)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, err)
 		return
 	}
 
@@ -608,7 +807,7 @@ func (d *Datasource) handleDisableLink(w http.ResponseWriter, req *http.Request)
 // handleResetLinkCounters handles incoming requests to reset link counters.
 func (d *Datasource) handleResetLinkCounters(w http.ResponseWriter, req *http.Request) {
 	if req.Method != http.MethodPost {
-		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		writeErrorMessage(w, http.StatusMethodNotAllowed, "Invalid request method")
 		return
 	}
 
@@ -618,14 +817,15 @@ func (d *Datasource) handleResetLinkCounters(w http.ResponseWriter, req *http.Re
 
 	endpoint, err := d.multiplexer.GetEndpoint(endpointID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, err)
 		return
 	}
 
 	client := endpoint.GetClient()
 	link, err := client.ResetLinkCounters(endpoint.Instance, linkName)
+	d.recordAction(req, audit.Entry{Endpoint: endpointID, Action: "link.reset", Target: linkName}, nil, link, err)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, err)
 		return
 	}
 
@@ -643,7 +843,7 @@ type LinkActionBody struct {
 // handleRunLinkAction handles incoming requests to run a link action.
 func (d *Datasource) handleRunLinkAction(w http.ResponseWriter, req *http.Request) {
 	if req.Method != http.MethodPost {
-		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		writeErrorMessage(w, http.StatusMethodNotAllowed, "Invalid request method")
 		return
 	}
 
@@ -654,7 +854,7 @@ func (d *Datasource) handleRunLinkAction(w http.ResponseWriter, req *http.Reques
 
 	endpoint, err := d.multiplexer.GetEndpoint(endpointID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -662,15 +862,16 @@ func (d *Datasource) handleRunLinkAction(w http.ResponseWriter, req *http.Reques
 	var body LinkActionBody
 	if req.Body != nil {
 		if err := decodeOptionalJSONBody(w, req, &body); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, err)
 			return
 		}
 	}
 
 	client := endpoint.GetClient()
 	response, err := client.RunLinkAction(endpoint.Instance, linkName, actionID, body.Message)
+	d.recordAction(req, audit.Entry{Endpoint: endpointID, Action: "link.action", Target: linkName + "/" + actionID}, body.Message, response, err)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, err)
 		return
 	}
 
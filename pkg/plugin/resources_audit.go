@@ -0,0 +1,101 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/config"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/audit"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/exception"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// openAuditLog opens the audit log of a datasource instance, or returns nil
+// when auditing is disabled. Without a configured directory the log goes to
+// the Grafana data path, in a directory named after the datasource.
+func openAuditLog(settings backend.DataSourceInstanceSettings, cfg *config.AuditConfiguration) (*audit.Log, error) {
+	if cfg == nil {
+		cfg = &config.AuditConfiguration{}
+	}
+	if cfg.Disabled {
+		return nil, nil
+	}
+	dir := cfg.Directory
+	if dir == "" {
+		dataPath := os.Getenv("GF_PATHS_DATA")
+		if dataPath == "" {
+			dataPath = os.TempDir()
+			backend.Logger.Warn("GF_PATHS_DATA is not set, the audit log is kept in the temporary directory", "path", dataPath)
+		}
+		dir = filepath.Join(dataPath, "jaops-yamcs-audit", settings.UID)
+	}
+	return audit.Open(dir, time.Duration(cfg.RetentionDays)*24*time.Hour)
+}
+
+// auditReaderRole returns the least Grafana role allowed to query the audit
+// log, Editor unless the configuration says otherwise.
+func auditReaderRole(cfg *config.AuditConfiguration) (string, error) {
+	if cfg == nil || cfg.ReaderRole == "" {
+		return "Editor", nil
+	}
+	switch cfg.ReaderRole {
+	case "Viewer", "Editor", "Admin":
+		return cfg.ReaderRole, nil
+	default:
+		return "", exception.New(fmt.Sprintf("Unknown audit log reader role %q", cfg.ReaderRole), "AUDIT_LOG_ERROR")
+	}
+}
+
+// recordAction adds an operator action to the audit log. The entry is
+// completed with the Grafana user of the request, the marshalled payload and
+// response, and the outcome told by err unless the entry already has one.
+// Failing to record is logged: the action has already reached Yamcs.
+func (d *Datasource) recordAction(req *http.Request, entry audit.Entry, payload, response any, err error) {
+	if d.audit == nil {
+		return
+	}
+	user := requestUser(req)
+	entry.User, entry.Role = user.Identity(), user.Role
+	entry.Payload = auditJSON(payload)
+	entry.Response = auditJSON(response)
+	if entry.Outcome == "" {
+		entry.Outcome = audit.OutcomeSuccess
+		if err != nil {
+			entry.Outcome = audit.OutcomeFailure
+		}
+	}
+	if err != nil && entry.Error == "" {
+		entry.Error = errorResponseFor(http.StatusInternalServerError, err).Error
+	}
+	if err := d.audit.Record(entry); err != nil {
+		backend.Logger.Error("could not record an operator action in the audit log", "action", entry.Action, "target", entry.Target, "error", err)
+	}
+}
+
+func auditJSON(value any) json.RawMessage {
+	if value == nil {
+		return nil
+	}
+	var (
+		encoded []byte
+		err     error
+	)
+	if message, ok := value.(proto.Message); ok {
+		if !message.ProtoReflect().IsValid() {
+			return nil
+		}
+		encoded, err = protojson.Marshal(message)
+	} else {
+		encoded, err = json.Marshal(value)
+	}
+	if err != nil || string(encoded) == "null" {
+		return nil
+	}
+	return encoded
+}
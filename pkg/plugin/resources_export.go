@@ -0,0 +1,203 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/source"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/export"
+)
+
+// exportChunkSize is the amount of response body buffered before it is sent to
+// Grafana as a chunk.
+const exportChunkSize = 1 << 20
+
+// chunkedWriter sends the response body in chunks as it is written, so that an
+// export never accumulates in memory.
+type chunkedWriter struct {
+	w       http.ResponseWriter
+	pending int
+	written int64
+}
+
+func (c *chunkedWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.pending += n
+	c.written += int64(n)
+	if c.pending >= exportChunkSize {
+		c.Flush()
+	}
+	return n, err
+}
+
+// Flush sends the pending body.
+func (c *chunkedWriter) Flush() {
+	if flusher, ok := c.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	c.pending = 0
+}
+
+// exportRequest holds the query parameters common to every export.
+type exportRequest struct {
+	endpoint   *source.YamcsEndpoint
+	start, end time.Time
+	format     export.Format
+}
+
+// parseExportRequest reads the endpoint, time range and format of an export,
+// writing an error response when they are invalid.
+func (d *Datasource) parseExportRequest(w http.ResponseWriter, req *http.Request) (*exportRequest, bool) {
+	if req.Method != http.MethodGet {
+		writeErrorMessage(w, http.StatusMethodNotAllowed, "Invalid request method")
+		return nil, false
+	}
+
+	query := req.URL.Query()
+	start, err := parseExportTime(query.Get("start"))
+	if err != nil {
+		writeErrorMessage(w, http.StatusBadRequest, "invalid start: "+err.Error())
+		return nil, false
+	}
+	end, err := parseExportTime(query.Get("end"))
+	if err != nil {
+		writeErrorMessage(w, http.StatusBadRequest, "invalid end: "+err.Error())
+		return nil, false
+	}
+	if !start.Before(end) {
+		writeErrorMessage(w, http.StatusBadRequest, "start must be before end")
+		return nil, false
+	}
+	format, err := export.ParseFormat(query.Get("format"))
+	if err != nil {
+		writeErrorMessage(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+
+	endpoint, err := d.multiplexer.GetEndpoint(mux.Vars(req)["endpointID"])
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return nil, false
+	}
+	return &exportRequest{endpoint: endpoint, start: start, end: end, format: format}, true
+}
+
+// parseExportTime parses a time given in RFC 3339 format or in milliseconds
+// since the epoch, as in Grafana time ranges.
+func parseExportTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, fmt.Errorf("missing time")
+	}
+	if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.UnixMilli(ms), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// stream sets the headers of an export named name and writes it with write.
+// Errors before the first byte get an error response; later ones can only
+// cut the response short.
+func (r *exportRequest) stream(ctx context.Context, w http.ResponseWriter, name string, write func(io.Writer) error) {
+	filename := fmt.Sprintf("%s-%s-%s.%s", name, r.start.UTC().Format("20060102T150405Z"), r.end.UTC().Format("20060102T150405Z"), r.format)
+	w.Header().Set("Content-Type", r.format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	body := &chunkedWriter{w: w}
+	err := write(body)
+	if err == nil {
+		body.Flush()
+		return
+	}
+	if ctx.Err() != nil {
+		backend.Logger.Debug("Export cancelled", "export", name)
+		return
+	}
+	if body.written == 0 {
+		w.Header().Del("Content-Disposition")
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	backend.Logger.Error("Export failed", "export", name, "bytes", body.written, "error", err)
+}
+
+// handleExportParameters streams the archived values of the parameters named
+// by the parameter query parameters. The align query parameter lays several
+// parameters out in columns, see source.ExportAlignment; interval sets the
+// row interval of the interval alignment.
+func (d *Datasource) handleExportParameters(w http.ResponseWriter, req *http.Request) {
+	r, ok := d.parseExportRequest(w, req)
+	if !ok {
+		return
+	}
+
+	query := req.URL.Query()
+	parameters := parameterExportNames(query["parameter"])
+	if len(parameters) == 0 {
+		writeErrorMessage(w, http.StatusBadRequest, "missing required query parameter: parameter")
+		return
+	}
+	options := source.ParameterExport{
+		Parameters: parameters,
+		Start:      r.start,
+		End:        r.end,
+		Alignment:  source.ExportAlignment(query.Get("align")),
+	}
+	if interval := query.Get("interval"); interval != "" {
+		duration, err := time.ParseDuration(interval)
+		if err != nil {
+			writeErrorMessage(w, http.StatusBadRequest, "invalid interval: "+err.Error())
+			return
+		}
+		options.Interval = duration
+	}
+
+	ctx := req.Context()
+	r.stream(ctx, w, "parameters", func(body io.Writer) error {
+		return r.endpoint.ExportParameterHistory(ctx, options, r.format, body)
+	})
+}
+
+// parameterExportNames returns the parameter names of an export request, each
+// value holding one name or several separated by commas.
+func parameterExportNames(values []string) []string {
+	names := []string{}
+	for _, value := range values {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// handleExportEvents streams the archived events of a time range.
+func (d *Datasource) handleExportEvents(w http.ResponseWriter, req *http.Request) {
+	r, ok := d.parseExportRequest(w, req)
+	if !ok {
+		return
+	}
+	ctx := req.Context()
+	r.stream(ctx, w, "events", func(body io.Writer) error {
+		return r.endpoint.ExportEvents(ctx, r.start, r.end, r.format, body)
+	})
+}
+
+// handleExportCommands streams the archived command history of a time range.
+func (d *Datasource) handleExportCommands(w http.ResponseWriter, req *http.Request) {
+	r, ok := d.parseExportRequest(w, req)
+	if !ok {
+		return
+	}
+	ctx := req.Context()
+	r.stream(ctx, w, "commands", func(body io.Writer) error {
+		return r.endpoint.ExportCommandHistory(ctx, r.start, r.end, r.format, body)
+	})
+}
@@ -0,0 +1,319 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/commanding"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/source"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/audit"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/policy"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/tools"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// teamMembersTTL is how long the members of a Grafana team are cached.
+const teamMembersTTL = time.Minute
+
+// CommandApprovalResponse is returned instead of the issued command when the
+// command waits for a second user to confirm it.
+type CommandApprovalResponse struct {
+	Approval policy.Approval `json:"approval"`
+}
+
+// requestUser returns the Grafana user who sent the resource request.
+func requestUser(req *http.Request) policy.User {
+	return contextUser(req.Context())
+}
+
+// contextUser returns the Grafana user of a request or stream context.
+func contextUser(ctx context.Context) policy.User {
+	user := backend.UserFromContext(ctx)
+	if user == nil {
+		return policy.User{}
+	}
+	return policy.User{Login: user.Login, Email: user.Email, Role: user.Role}
+}
+
+// authorizeCommand checks the command policy of the endpoint for the user of
+// the request, overrides telling whether contingency issue options are used.
+// The command is resolved to its qualified name first, so that an alias
+// cannot slip past the patterns.
+func (d *Datasource) authorizeCommand(req *http.Request, endpoint *source.YamcsEndpoint, endpointID, command string, overrides bool) (policy.Decision, error) {
+	commandPolicy := d.policies[endpointID]
+	if commandPolicy == nil {
+		return commandPolicy.Decide(command, requestUser(req), overrides, nil)
+	}
+	info, err := endpoint.GetClient().WithContext(req.Context()).GetCommandInfo(endpoint.Instance, command)
+	if err != nil {
+		return policy.Decision{}, fmt.Errorf("could not look up command %s: %w", command, err)
+	}
+	return commandPolicy.Decide(info.GetQualifiedName(), requestUser(req), overrides, d.teams.checker(req.Context()))
+}
+
+// handleListCommandApprovals lists the commands waiting for confirmation that
+// the user of the request may confirm, along with the ones they requested.
+func (d *Datasource) handleListCommandApprovals(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeErrorMessage(w, http.StatusMethodNotAllowed, "Invalid request method")
+		return
+	}
+
+	endpointID := mux.Vars(req)["endpointID"]
+	endpoint, err := d.multiplexer.GetEndpoint(endpointID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	user := requestUser(req)
+	approvals := []policy.Approval{}
+	for _, approval := range d.approvals.Pending(endpointID) {
+		if approval.RequestedBy != user.Identity() {
+			decision, err := d.authorizeCommand(req, endpoint, endpointID, approval.Command, len(approval.Options.Overrides()) > 0)
+			if err != nil {
+				backend.Logger.Debug("could not check who may confirm a pending command", "command", approval.Command, "error", err)
+				continue
+			}
+			if !decision.Allowed {
+				continue
+			}
+		}
+		approvals = append(approvals, approval)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(approvals)
+}
+
+// handleCommandApproval confirms or rejects a command waiting for a second
+// user. Confirming sends the command; the confirming user must be allowed to
+// send it and must not be the user who requested it.
+func (d *Datasource) handleCommandApproval(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeErrorMessage(w, http.StatusMethodNotAllowed, "Invalid request method")
+		return
+	}
+
+	vars := mux.Vars(req)
+	endpointID := vars["endpointID"]
+	approval, err := d.approvals.Get(endpointID, vars["approvalID"])
+	if err != nil {
+		writeApprovalError(w, err)
+		return
+	}
+
+	endpoint, err := d.multiplexer.GetEndpoint(endpointID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	user := requestUser(req)
+	overrides := approval.Options.Overrides()
+	action := vars["action"]
+	entry := audit.Entry{Endpoint: endpointID, Action: "command." + action, Target: approval.Command, Overrides: overrides}
+	decision, err := d.authorizeCommand(req, endpoint, endpointID, approval.Command, len(overrides) > 0)
+	if err != nil {
+		d.recordAction(req, entry, approval, nil, err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	switch action {
+	case "reject":
+		if !decision.Allowed && user.Identity() != approval.RequestedBy {
+			entry.Outcome, entry.Error = audit.OutcomeDenied, decision.Reason
+			d.recordAction(req, entry, approval, nil, nil)
+			writeErrorMessage(w, http.StatusForbidden, decision.Reason)
+			return
+		}
+		if _, err := d.approvals.Reject(endpointID, approval.ID); err != nil {
+			writeApprovalError(w, err)
+			return
+		}
+		d.recordAction(req, entry, approval, nil, nil)
+		w.WriteHeader(http.StatusNoContent)
+	case "confirm":
+		if !decision.Allowed {
+			entry.Outcome, entry.Error = audit.OutcomeDenied, decision.Reason
+			d.recordAction(req, entry, approval, nil, nil)
+			writeErrorMessage(w, http.StatusForbidden, decision.Reason)
+			return
+		}
+		if approval, err = d.approvals.Confirm(endpointID, approval.ID, user.Identity()); err != nil {
+			writeApprovalError(w, err)
+			return
+		}
+		comment := strings.TrimSpace(fmt.Sprintf("%s (requested by %s, confirmed by %s)", approval.Comment, approval.RequestedBy, user.Identity()))
+		response, err := issueCommand(endpoint, approval.Command, approval.Arguments, approval.Options, comment)
+		d.recordAction(req, entry, approval, response, err)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		marshalled, err := protojson.Marshal(response)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(json.RawMessage(marshalled))
+	default:
+		writeErrorMessage(w, http.StatusNotFound, "unknown command approval action: "+action)
+	}
+}
+
+// checkIssueOptions rejects the contingency issue options the command does not
+// offer, so that they are not passed on to Yamcs.
+func checkIssueOptions(req *http.Request, endpoint *source.YamcsEndpoint, command string, options *tools.CommandIssueOptions) error {
+	yamcs := endpoint.GetClient().WithContext(req.Context())
+	info, err := yamcs.GetCommandInfo(endpoint.Instance, command)
+	if err != nil {
+		return fmt.Errorf("could not look up command %s: %w", command, err)
+	}
+	var commandOptions []string
+	if len(options.Extra) > 0 {
+		serverInfo, err := yamcs.GetServerInfo()
+		if err != nil {
+			return fmt.Errorf("could not read the command options of the server: %w", err)
+		}
+		for _, option := range serverInfo.GetCommandOptions() {
+			commandOptions = append(commandOptions, option.GetId())
+		}
+	}
+	return options.Check(info, commandOptions)
+}
+
+// issueCommand sends a command, with its contingency issue options if any.
+func issueCommand(endpoint *source.YamcsEndpoint, command string, arguments map[string]any, options *tools.CommandIssueOptions, comment string) (*commanding.IssueCommandResponse, error) {
+	client := endpoint.GetClient()
+	if len(options.Overrides()) == 0 {
+		return client.IssueCommandWithComment(endpoint.Instance, endpoint.Processor, command, arguments, comment)
+	}
+	extra, err := options.ExtraValues()
+	if err != nil {
+		return nil, err
+	}
+	return client.IssueCommandWithOverrides(endpoint.Instance, endpoint.Processor, command, arguments, comment,
+		options.Stream, options.DisableTransmissionConstraints, options.DisableVerifiers, options.VerifierConfig(), extra)
+}
+
+func writeApprovalError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, policy.ErrApprovalExpired):
+		writeErrorMessage(w, http.StatusGone, err.Error())
+	case errors.Is(err, policy.ErrSelfApproval):
+		writeErrorMessage(w, http.StatusForbidden, err.Error())
+	default:
+		writeErrorMessage(w, http.StatusNotFound, err.Error())
+	}
+}
+
+// teamDirectory reads the members of Grafana teams through the Grafana HTTP
+// API, since plugins are only told the login and role of a user.
+type teamDirectory struct {
+	token  string
+	client *http.Client
+
+	mu      sync.Mutex
+	members map[string]cachedTeam
+}
+
+type cachedTeam struct {
+	logins  map[string]bool
+	fetched time.Time
+}
+
+func newTeamDirectory(token string) (*teamDirectory, error) {
+	client, err := httpclient.New(httpclient.Options{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP client: %w", err)
+	}
+	return &teamDirectory{token: token, client: client, members: map[string]cachedTeam{}}, nil
+}
+
+// checker returns the team checker used by the policies for one request.
+func (t *teamDirectory) checker(ctx context.Context) policy.TeamChecker {
+	return func(user policy.User, team string) (bool, error) {
+		members, err := t.teamMembers(ctx, team)
+		if err != nil {
+			return false, err
+		}
+		return members[user.Login] || members[user.Email], nil
+	}
+}
+
+func (t *teamDirectory) teamMembers(ctx context.Context, team string) (map[string]bool, error) {
+	t.mu.Lock()
+	cached, ok := t.members[team]
+	t.mu.Unlock()
+	if ok && time.Since(cached.fetched) < teamMembersTTL {
+		return cached.logins, nil
+	}
+
+	if t.token == "" {
+		return nil, errors.New("no Grafana service account token is configured")
+	}
+	appURL, err := backend.GrafanaConfigFromContext(ctx).AppURL()
+	if err != nil {
+		return nil, err
+	}
+	appURL = strings.TrimSuffix(appURL, "/")
+
+	var search struct {
+		Teams []struct {
+			ID   int64  `json:"id"`
+			Name string `json:"name"`
+		} `json:"teams"`
+	}
+	if err := t.get(ctx, appURL+"/api/teams/search?name="+url.QueryEscape(team), &search); err != nil {
+		return nil, err
+	}
+	logins := map[string]bool{}
+	for _, found := range search.Teams {
+		if found.Name != team {
+			continue
+		}
+		var members []struct {
+			Login string `json:"login"`
+			Email string `json:"email"`
+		}
+		if err := t.get(ctx, fmt.Sprintf("%s/api/teams/%d/members", appURL, found.ID), &members); err != nil {
+			return nil, err
+		}
+		for _, member := range members {
+			logins[member.Login] = member.Login != ""
+			logins[member.Email] = member.Email != ""
+		}
+	}
+
+	t.mu.Lock()
+	t.members[team] = cachedTeam{logins: logins, fetched: time.Now()}
+	t.mu.Unlock()
+	return logins, nil
+}
+
+func (t *teamDirectory) get(ctx context.Context, target string, dst any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+t.token)
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("grafana answered %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(dst)
+}
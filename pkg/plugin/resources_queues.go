@@ -0,0 +1,164 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/commanding"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/source"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/audit"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// handleListQueues lists the command queues of the endpoint processor with
+// the commands pending in each.
+func (d *Datasource) handleListQueues(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeErrorMessage(w, http.StatusMethodNotAllowed, "Invalid request method")
+		return
+	}
+
+	endpoint, err := d.multiplexer.GetEndpoint(mux.Vars(req)["endpointID"])
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	queues, err := endpoint.GetClient().WithContext(req.Context()).ListQueues(endpoint.Instance, endpoint.Processor)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	results := make([]json.RawMessage, 0, len(queues))
+	for _, queue := range queues {
+		marshalled, err := protojson.Marshal(queue)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		results = append(results, marshalled)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// handleSetQueueState enables, disables or blocks a command queue.
+func (d *Datasource) handleSetQueueState(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeErrorMessage(w, http.StatusMethodNotAllowed, "Invalid request method")
+		return
+	}
+
+	vars := mux.Vars(req)
+	endpointID, queueName, action := vars["endpointID"], vars["queue"], vars["action"]
+
+	endpoint, err := d.multiplexer.GetEndpoint(endpointID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	client := endpoint.GetClient().WithContext(req.Context())
+	var queue *commanding.CommandQueueInfo
+	switch action {
+	case "enable":
+		queue, err = client.EnableQueue(endpoint.Instance, endpoint.Processor, queueName)
+	case "disable":
+		queue, err = client.DisableQueue(endpoint.Instance, endpoint.Processor, queueName)
+	case "block":
+		queue, err = client.BlockQueue(endpoint.Instance, endpoint.Processor, queueName)
+	default:
+		writeErrorMessage(w, http.StatusNotFound, "unknown command queue action: "+action)
+		return
+	}
+	d.recordAction(req, audit.Entry{Endpoint: endpointID, Action: "queue." + action, Target: queueName}, nil, queue, err)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	marshalled, err := protojson.Marshal(queue)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(json.RawMessage(marshalled))
+}
+
+// handleQueuedCommand accepts or rejects a command waiting in a queue.
+// Accepting releases the command to Yamcs as it was requested, so the command
+// goes through the command policy of the endpoint first.
+func (d *Datasource) handleQueuedCommand(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeErrorMessage(w, http.StatusMethodNotAllowed, "Invalid request method")
+		return
+	}
+
+	vars := mux.Vars(req)
+	endpointID, queueName, commandID, action := vars["endpointID"], vars["queue"], vars["commandID"], vars["action"]
+
+	endpoint, err := d.multiplexer.GetEndpoint(endpointID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	client := endpoint.GetClient().WithContext(req.Context())
+	entry := audit.Entry{Endpoint: endpointID, Action: "queue." + action, Target: queueName + "/" + commandID}
+	switch action {
+	case "accept":
+		queued, err := queuedCommand(req, endpoint, queueName, commandID)
+		if err != nil {
+			d.recordAction(req, entry, nil, nil, err)
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		decision, err := d.authorizeCommand(req, endpoint, endpointID, queued.GetCommandName(), false)
+		if err != nil {
+			d.recordAction(req, entry, queued, nil, err)
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if !decision.Allowed || decision.Confirm {
+			if decision.Confirm {
+				decision.Reason = "command " + queued.GetCommandName() + " must be confirmed by a second user and cannot be released from a queue"
+			}
+			entry.Outcome, entry.Error = audit.OutcomeDenied, decision.Reason
+			d.recordAction(req, entry, queued, nil, nil)
+			writeErrorMessage(w, http.StatusForbidden, decision.Reason)
+			return
+		}
+		err = client.AcceptQueuedCommand(endpoint.Instance, endpoint.Processor, queueName, commandID)
+		d.recordAction(req, entry, queued, nil, err)
+	case "reject":
+		err = client.RejectQueuedCommand(endpoint.Instance, endpoint.Processor, queueName, commandID)
+		d.recordAction(req, entry, nil, nil, err)
+	default:
+		writeErrorMessage(w, http.StatusNotFound, "unknown queued command action: "+action)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// queuedCommand returns the command commandID waiting in queue.
+func queuedCommand(req *http.Request, endpoint *source.YamcsEndpoint, queue, commandID string) (*commanding.CommandQueueEntry, error) {
+	info, err := endpoint.GetClient().WithContext(req.Context()).GetQueue(endpoint.Instance, endpoint.Processor, queue)
+	if err != nil {
+		return nil, err
+	}
+	for _, queued := range info.GetEntries() {
+		if queued.GetId() == commandID {
+			return queued, nil
+		}
+	}
+	return nil, fmt.Errorf("command %s is not waiting in queue %s", commandID, queue)
+}
@@ -0,0 +1,367 @@
+package plugin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/timeline"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/source"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/audit"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/tools"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ScheduleCommandBody is a command to run at a given time, either absolute or
+// relative to another timeline item such as a pass.
+type ScheduleCommandBody struct {
+	Name      string         `json:"name"`
+	Arguments map[string]any `json:"arguments"`
+	Comment   string         `json:"comment"`
+	// Start is the absolute execution time.
+	Start *time.Time `json:"start,omitempty"`
+	// RelativeTo is the ID of the timeline item the command follows, e.g. an
+	// AOS event, and Offset the Go duration after its start, e.g. "5m".
+	RelativeTo string   `json:"relativeTo,omitempty"`
+	Offset     string   `json:"offset,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+}
+
+// timing returns the absolute or relative start of the command.
+func (b *ScheduleCommandBody) timing() (*timestamppb.Timestamp, *timeline.RelativeTime, error) {
+	switch {
+	case b.Start != nil && b.RelativeTo != "":
+		return nil, nil, errors.New("start and relativeTo cannot both be set")
+	case b.Start != nil:
+		if b.Start.Before(time.Now()) {
+			return nil, nil, errors.New("start must be in the future")
+		}
+		return timestamppb.New(*b.Start), nil, nil
+	case b.RelativeTo != "":
+		offset := time.Duration(0)
+		if b.Offset != "" {
+			parsed, err := time.ParseDuration(b.Offset)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid offset: %w", err)
+			}
+			offset = parsed
+		}
+		return nil, &timeline.RelativeTime{Relto: proto.String(b.RelativeTo), RelativeStart: durationpb.New(offset)}, nil
+	default:
+		return nil, nil, errors.New("missing required field: start or relativeTo")
+	}
+}
+
+// handleListScheduledCommands lists the commands scheduled on the timeline
+// between the start and stop query parameters, by default from a day ago to
+// a week ahead.
+func (d *Datasource) handleListScheduledCommands(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeErrorMessage(w, http.StatusMethodNotAllowed, "Invalid request method")
+		return
+	}
+
+	endpoint, err := d.multiplexer.GetEndpoint(mux.Vars(req)["endpointID"])
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	now := time.Now()
+	start, end := now.Add(-24*time.Hour), now.Add(scheduleHorizon)
+	for name, bound := range map[string]*time.Time{"start": &start, "stop": &end} {
+		if value := req.URL.Query().Get(name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				writeErrorMessage(w, http.StatusBadRequest, "invalid "+name+" time: "+value)
+				return
+			}
+			*bound = parsed
+		}
+	}
+
+	iterator := endpoint.GetClient().WithContext(req.Context()).ListTimelineItems(endpoint.Instance, start, end)
+	results := make([]json.RawMessage, 0)
+	for iterator.HasNext() {
+		items, err := iterator.Next()
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		for _, item := range items {
+			if !tools.IsCommandActivity(item) {
+				continue
+			}
+			marshalled, err := protojson.Marshal(item)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, err)
+				return
+			}
+			results = append(results, marshalled)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// handleScheduleCommand schedules a command on the timeline. The command is
+// authorized and its arguments validated now rather than when it runs.
+func (d *Datasource) handleScheduleCommand(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeErrorMessage(w, http.StatusMethodNotAllowed, "Invalid request method")
+		return
+	}
+
+	body := &ScheduleCommandBody{}
+	if err := decodeJSONBody(w, req, &body); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	endpointID := mux.Vars(req)["endpointID"]
+	endpoint, err := d.multiplexer.GetEndpoint(endpointID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	entry := audit.Entry{Endpoint: endpointID, Action: "schedule.create", Target: body.Name}
+	request, ok := d.scheduledCommandRequest(w, req, endpoint, endpointID, body, entry)
+	if !ok {
+		return
+	}
+	item, err := endpoint.GetClient().WithContext(req.Context()).CreateTimelineItem(endpoint.Instance, request)
+	d.recordAction(req, entry, body, item, err)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeTimelineItem(w, http.StatusCreated, item)
+}
+
+// handleUpdateScheduledCommand edits a command that has not run yet.
+// Rescheduling keeps the timeline item; changing the command, its arguments
+// or its comment replaces the item, since Yamcs cannot edit an activity
+// definition.
+func (d *Datasource) handleUpdateScheduledCommand(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPut {
+		writeErrorMessage(w, http.StatusMethodNotAllowed, "Invalid request method")
+		return
+	}
+
+	body := &ScheduleCommandBody{}
+	if err := decodeJSONBody(w, req, &body); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	vars := mux.Vars(req)
+	endpointID, itemID := vars["endpointID"], vars["itemID"]
+	endpoint, err := d.multiplexer.GetEndpoint(endpointID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	client := endpoint.GetClient().WithContext(req.Context())
+	item, err := client.GetTimelineItem(endpoint.Instance, itemID)
+	previous, ok := scheduledCommand(w, item, err)
+	if !ok {
+		return
+	}
+	if status := previous.Status(); status != "planned" {
+		writeErrorMessage(w, http.StatusConflict, "scheduled command "+itemID+" is already "+status)
+		return
+	}
+
+	entry := audit.Entry{Endpoint: endpointID, Action: "schedule.update", Target: itemID}
+	request, ok := d.scheduledCommandRequest(w, req, endpoint, endpointID, body, entry)
+	if !ok {
+		return
+	}
+
+	if proto.Equal(previous.Item.GetActivityDefinition(), request.GetActivityDefinition()) {
+		item, err = client.UpdateTimelineItem(endpoint.Instance, itemID, &timeline.UpdateItemRequest{
+			Name:         request.Name,
+			Start:        request.Start,
+			RelativeTime: request.RelativeTime,
+			Tags:         request.Tags,
+			ClearTags:    proto.Bool(len(request.Tags) == 0),
+			Properties:   request.Properties,
+		})
+	} else {
+		item, err = source.ReplaceScheduledCommand(client, endpoint.Instance, itemID, request)
+	}
+	d.recordAction(req, entry, body, item, err)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeTimelineItem(w, http.StatusOK, item)
+}
+
+// handleCancelScheduledCommand cancels a scheduled command: a planned command
+// will not run, a running one is stopped.
+func (d *Datasource) handleCancelScheduledCommand(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeErrorMessage(w, http.StatusMethodNotAllowed, "Invalid request method")
+		return
+	}
+
+	vars := mux.Vars(req)
+	endpointID, itemID := vars["endpointID"], vars["itemID"]
+	endpoint, err := d.multiplexer.GetEndpoint(endpointID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	client := endpoint.GetClient().WithContext(req.Context())
+	item, err := client.GetTimelineItem(endpoint.Instance, itemID)
+	scheduled, ok := scheduledCommand(w, item, err)
+	if !ok {
+		return
+	}
+
+	// Cancelling needs the same right as sending the command, without the
+	// confirmation: a cancelled command never reaches Yamcs.
+	entry := audit.Entry{Endpoint: endpointID, Action: "schedule.cancel", Target: itemID}
+	command, _ := scheduled.Command()
+	decision, err := d.authorizeCommand(req, endpoint, endpointID, command, false)
+	if err != nil {
+		d.recordAction(req, entry, nil, nil, err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !decision.Allowed {
+		entry.Outcome, entry.Error = audit.OutcomeDenied, decision.Reason
+		d.recordAction(req, entry, nil, nil, nil)
+		writeErrorMessage(w, http.StatusForbidden, decision.Reason)
+		return
+	}
+
+	var response proto.Message
+	switch status := scheduled.Status(); status {
+	case "planned":
+		response, err = client.UpdateTimelineItem(endpoint.Instance, itemID, &timeline.UpdateItemRequest{
+			Status:        timeline.ExecutionStatus_ABORTED.Enum(),
+			FailureReason: proto.String("cancelled by " + requestUser(req).Identity()),
+		})
+	case "running":
+		runs := scheduled.Item.GetRuns()
+		if len(runs) == 0 {
+			writeErrorMessage(w, http.StatusConflict, "scheduled command "+itemID+" has no run to cancel")
+			return
+		}
+		response, err = client.CancelActivity(endpoint.Instance, runs[len(runs)-1])
+	default:
+		writeErrorMessage(w, http.StatusConflict, "scheduled command "+itemID+" is already "+status)
+		return
+	}
+	d.recordAction(req, entry, nil, response, err)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	marshalled, err := protojson.Marshal(response)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(json.RawMessage(marshalled))
+}
+
+// scheduledCommandRequest checks a command to schedule and builds its
+// timeline item, writing an error response and recording refusals when it
+// cannot be scheduled.
+func (d *Datasource) scheduledCommandRequest(w http.ResponseWriter, req *http.Request, endpoint *source.YamcsEndpoint, endpointID string, body *ScheduleCommandBody, entry audit.Entry) (*timeline.CreateItemRequest, bool) {
+	if body.Name == "" {
+		writeErrorMessage(w, http.StatusBadRequest, "missing required field: name")
+		return nil, false
+	}
+	start, relative, err := body.timing()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return nil, false
+	}
+
+	decision, err := d.authorizeCommand(req, endpoint, endpointID, body.Name, false)
+	if err != nil {
+		d.recordAction(req, entry, body, nil, err)
+		writeError(w, http.StatusInternalServerError, err)
+		return nil, false
+	}
+	if !decision.Allowed || decision.Confirm {
+		if decision.Confirm {
+			decision.Reason = "command " + body.Name + " must be confirmed by a second user and cannot be scheduled"
+		}
+		entry.Outcome, entry.Error = audit.OutcomeDenied, decision.Reason
+		d.recordAction(req, entry, body, nil, nil)
+		writeErrorMessage(w, http.StatusForbidden, decision.Reason)
+		return nil, false
+	}
+
+	info, err := endpoint.GetClient().WithContext(req.Context()).GetCommandInfo(endpoint.Instance, body.Name)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return nil, false
+	}
+	arguments, argErrs := tools.ValidateCommandArguments(info, body.Arguments)
+	if len(argErrs) > 0 {
+		messages := make([]string, 0, len(argErrs))
+		for _, argErr := range argErrs {
+			messages = append(messages, argErr.Message)
+		}
+		writeErrorMessage(w, http.StatusBadRequest, "invalid command arguments: "+strings.Join(messages, "; "))
+		return nil, false
+	}
+
+	definition, err := tools.CommandActivity(info.GetQualifiedName(), arguments, endpoint.Processor.GetName(), body.Comment)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return nil, false
+	}
+	return &timeline.CreateItemRequest{
+		Name:               proto.String(info.GetName()),
+		Type:               timeline.TimelineItemType_ACTIVITY.Enum(),
+		Start:              start,
+		RelativeTime:       relative,
+		Tags:               body.Tags,
+		Description:        proto.String(body.Comment),
+		Properties:         map[string]string{tools.ScheduledByProperty: requestUser(req).Identity()},
+		ActivityDefinition: definition,
+	}, true
+}
+
+// scheduledCommand checks that a timeline item is a command activity, writing
+// an error response otherwise.
+func scheduledCommand(w http.ResponseWriter, item *timeline.TimelineItem, err error) (tools.ScheduledCommand, bool) {
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return tools.ScheduledCommand{}, false
+	}
+	if !tools.IsCommandActivity(item) {
+		writeErrorMessage(w, http.StatusNotFound, "timeline item "+item.GetId()+" is not a scheduled command")
+		return tools.ScheduledCommand{}, false
+	}
+	return tools.ScheduledCommand{Item: item}, true
+}
+
+func writeTimelineItem(w http.ResponseWriter, status int, item *timeline.TimelineItem) {
+	marshalled, err := protojson.Marshal(item)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(json.RawMessage(marshalled))
+}
@@ -0,0 +1,195 @@
+package plugin
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+
+	"github.com/gorilla/mux"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/commanding"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/source"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/audit"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/tools"
+)
+
+// defaultStackBucket is the bucket Yamcs Web saves command stacks in.
+const defaultStackBucket = "stacks"
+
+// handleImportCommandStack reads a command stack saved by Yamcs Web in a
+// bucket and returns it as steps, ready to be reviewed and run.
+func (d *Datasource) handleImportCommandStack(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeErrorMessage(w, http.StatusMethodNotAllowed, "Invalid request method")
+		return
+	}
+
+	query := req.URL.Query()
+	object := query.Get("object")
+	if object == "" {
+		writeErrorMessage(w, http.StatusBadRequest, "missing required query parameter: object")
+		return
+	}
+	bucket := query.Get("bucket")
+	if bucket == "" {
+		bucket = defaultStackBucket
+	}
+
+	endpoint, err := d.multiplexer.GetEndpoint(mux.Vars(req)["endpointID"])
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	content, err := endpoint.GetClient().WithContext(req.Context()).GetObject(bucket, object)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	stack, err := tools.ParseCommandStack(path.Base(object), content)
+	if err != nil {
+		writeErrorMessage(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stack)
+}
+
+// handleRunCommandStack starts running the command stack in the request body
+// and returns the status of the new run.
+func (d *Datasource) handleRunCommandStack(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeErrorMessage(w, http.StatusMethodNotAllowed, "Invalid request method")
+		return
+	}
+
+	stack := tools.CommandStack{}
+	if err := decodeJSONBody(w, req, &stack); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	endpointID := mux.Vars(req)["endpointID"]
+	endpoint, err := d.multiplexer.GetEndpoint(endpointID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	// Every command of the stack is checked before the first one is sent.
+	entry := audit.Entry{Endpoint: endpointID, Action: "stack.run", Target: stack.Name}
+	for _, step := range stack.Steps {
+		if step.Type != tools.StepCommand {
+			continue
+		}
+		decision, err := d.authorizeCommand(req, endpoint, endpointID, step.Command, false)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if !decision.Allowed || decision.Confirm {
+			if decision.Confirm {
+				decision.Reason = "command " + step.Command + " must be confirmed by a second user and cannot run in a stack"
+			}
+			entry.Outcome, entry.Error = audit.OutcomeDenied, decision.Reason
+			d.recordAction(req, entry, stack, nil, nil)
+			writeErrorMessage(w, http.StatusForbidden, decision.Reason)
+			return
+		}
+	}
+	run, err := d.stacks.Start(endpoint, stack, requestUser(req).Login, d.stackIssueRecorder(req, endpointID))
+	if err != nil {
+		d.recordAction(req, entry, stack, nil, err)
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	status := run.Status()
+	d.recordAction(req, entry, stack, status, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(status)
+}
+
+// stackIssuePayload is the audited payload of a command sent by a stack run.
+type stackIssuePayload struct {
+	Run string `json:"run"`
+	tools.StackStep
+}
+
+// stackIssueRecorder returns the function recording each command a stack run
+// sends as a command.issue action of the user who started the run.
+func (d *Datasource) stackIssueRecorder(req *http.Request, endpointID string) source.StackIssueFunc {
+	return func(run *source.StackRun, step tools.StackStep, response *commanding.IssueCommandResponse, err error) {
+		entry := audit.Entry{Endpoint: endpointID, Action: "command.issue", Target: step.Command}
+		d.recordAction(req, entry, stackIssuePayload{Run: run.ID, StackStep: step}, response, err)
+	}
+}
+
+// stackRun returns the run named in the request path, writing an error
+// response when the endpoint has no such run.
+func (d *Datasource) stackRun(w http.ResponseWriter, req *http.Request) (*source.StackRun, bool) {
+	vars := mux.Vars(req)
+	run, ok := d.stacks.Get(vars["runID"])
+	if !ok || run.Endpoint != vars["endpointID"] {
+		writeErrorMessage(w, http.StatusNotFound, "command stack run "+vars["runID"]+" not found")
+		return nil, false
+	}
+	return run, true
+}
+
+func (d *Datasource) handleGetCommandStack(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeErrorMessage(w, http.StatusMethodNotAllowed, "Invalid request method")
+		return
+	}
+	run, ok := d.stackRun(w, req)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(run.Status())
+}
+
+// handleControlCommandStack pauses, resumes or aborts a run, as given by the
+// action in the request path. Only the user who started the run may control
+// it.
+func (d *Datasource) handleControlCommandStack(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeErrorMessage(w, http.StatusMethodNotAllowed, "Invalid request method")
+		return
+	}
+	run, ok := d.stackRun(w, req)
+	if !ok {
+		return
+	}
+	action := mux.Vars(req)["action"]
+	entry := audit.Entry{Endpoint: run.Endpoint, Action: "stack." + action, Target: run.ID}
+	if requestUser(req).Login != run.StartedBy {
+		entry.Outcome, entry.Error = audit.OutcomeDenied, "command stack run "+run.ID+" was started by another user"
+		d.recordAction(req, entry, nil, nil, nil)
+		writeErrorMessage(w, http.StatusForbidden, entry.Error)
+		return
+	}
+
+	var err error
+	switch action {
+	case "pause":
+		err = run.Pause()
+	case "resume":
+		err = run.Resume()
+	case "abort":
+		err = run.Abort()
+	default:
+		writeErrorMessage(w, http.StatusNotFound, "unknown command stack action: "+action)
+		return
+	}
+	d.recordAction(req, entry, nil, nil, err)
+	if err != nil {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(run.Status())
+}
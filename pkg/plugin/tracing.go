@@ -0,0 +1,49 @@
+package plugin
+
+import (
+	"context"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/source"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// queryAttributes returns the span attributes describing a query.
+func queryAttributes(q PluginQuery) []attribute.KeyValue {
+	attributes := []attribute.KeyValue{
+		telemetry.AttributeEndpoint.String(q.EndpointID),
+		telemetry.AttributeQueryType.String(string(q.Type)),
+	}
+	if q.Parameter != "" {
+		attributes = append(attributes, telemetry.AttributeParameter.String(q.Parameter))
+	}
+	if q.MaxPoints > 0 {
+		attributes = append(attributes, telemetry.AttributeMaxPoints.Int(q.MaxPoints))
+	}
+	return attributes
+}
+
+// endpointAttributes returns the span attributes describing the instance and
+// processor an endpoint is bound to.
+func endpointAttributes(endpoint *source.YamcsEndpoint) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		telemetry.AttributeInstance.String(endpoint.Instance.GetName()),
+		telemetry.AttributeProcessor.String(endpoint.Processor.GetName()),
+	}
+}
+
+// traceFrame runs a tools.Convert* frame builder inside a child span of ctx and
+// records how many input points went in and how many rows came out.
+func traceFrame(ctx context.Context, name string, points int, build func() *data.Frame) *data.Frame {
+	_, span := telemetry.Start(ctx, name, telemetry.AttributePoints.Int(points))
+	defer span.End()
+
+	frame := build()
+	if frame != nil {
+		if rows, err := frame.RowLen(); err == nil {
+			span.SetAttributes(telemetry.AttributeRows.Int(rows))
+		}
+	}
+	return frame
+}
@@ -0,0 +1,242 @@
+package source
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/commanding"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/policy"
+	"google.golang.org/protobuf/proto"
+)
+
+// Completion states of a command, as selected by CommandHistoryFilter.Status.
+const (
+	CommandSucceeded = "success"
+	CommandFailed    = "failure"
+	CommandPending   = "pending"
+)
+
+// commandFilterRetention is how long a filter remembers a command to match
+// its later updates.
+const commandFilterRetention = 24 * time.Hour
+
+// CommandHistoryFilter selects command history entries. Empty fields match
+// every command.
+//
+// Live updates only hold the attributes that changed, so the filter remembers
+// the name, user, origin and queue of each command from its first entries,
+// and fills them into the updates it lets through. The "pending" status keeps
+// the updates of a command until it completes. The "success" and "failure"
+// statuses hold the updates back until the command completes, then give the
+// whole command at once, and its later updates as they come.
+type CommandHistoryFilter struct {
+	Names  []string // Command name patterns, as in command policies
+	User   string   // Yamcs user who issued the command
+	Origin string   // Origin of the command, such as the issuing host
+	Queue  string   // Queue the command went through
+	Status string   // CommandSucceeded, CommandFailed or CommandPending
+
+	mu        sync.Mutex
+	commands  map[string]*filteredCommand
+	lastPrune time.Time
+}
+
+// filteredCommand is what the filter knows about a command: the
+// identification of its first entry, and all its attributes when the filter
+// waits for its completion.
+type filteredCommand struct {
+	identity   *commanding.CommandHistoryEntry
+	attributes []*commanding.CommandHistoryAttribute
+	user       string
+	queue      string
+	completion string
+	seen       time.Time
+}
+
+// ArchiveQuery returns the part of the filter the Yamcs archive applies
+// itself: the queue, and the text of the name pattern before any wildcard
+// when there is a single pattern. Entries of the archive still have to be
+// checked with Keeps.
+func (f *CommandHistoryFilter) ArchiveQuery() map[string]string {
+	query := map[string]string{}
+	if f.Queue != "" {
+		query["queue"] = f.Queue
+	}
+	if len(f.Names) == 1 {
+		text := f.Names[0]
+		if wildcard := strings.IndexAny(text, "*?[\\"); wildcard >= 0 {
+			text = text[:wildcard]
+		}
+		if strings.Trim(text, "/") != "" {
+			query["q"] = text
+		}
+	}
+	return query
+}
+
+// Apply tells whether entry matches the filter and returns the entry to pass
+// on: entry filled with what is known of its command, or the whole command
+// when entry completes a command the filter waited for.
+func (f *CommandHistoryFilter) Apply(entry *commanding.CommandHistoryEntry) (*commanding.CommandHistoryEntry, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	command := f.update(entry)
+
+	if len(f.Names) > 0 && !matchesAny(f.Names, command.identity.GetCommandName()) {
+		return nil, false
+	}
+	if f.User != "" && command.user != f.User {
+		return nil, false
+	}
+	if f.Origin != "" && command.identity.GetOrigin() != f.Origin {
+		return nil, false
+	}
+	if f.Queue != "" && command.queue != f.Queue {
+		return nil, false
+	}
+	if f.Status != "" && command.completion != f.Status {
+		return nil, false
+	}
+	if f.waitsForCompletion() && hasAttribute(entry, "CommandComplete_Status") {
+		return command.merged(), true
+	}
+	return command.fill(entry), true
+}
+
+// waitsForCompletion tells whether the filter only lets commands through once
+// they completed.
+func (f *CommandHistoryFilter) waitsForCompletion() bool {
+	return f.Status == CommandSucceeded || f.Status == CommandFailed
+}
+
+// update merges entry into what the filter knows about its command.
+func (f *CommandHistoryFilter) update(entry *commanding.CommandHistoryEntry) *filteredCommand {
+	now := time.Now()
+	if f.commands == nil {
+		f.commands = map[string]*filteredCommand{}
+	}
+	if now.Sub(f.lastPrune) > time.Minute {
+		for id, command := range f.commands {
+			if now.Sub(command.seen) > commandFilterRetention {
+				delete(f.commands, id)
+			}
+		}
+		f.lastPrune = now
+	}
+
+	command, ok := f.commands[entry.GetId()]
+	if !ok {
+		command = &filteredCommand{identity: &commanding.CommandHistoryEntry{Id: entry.Id}, completion: CommandPending}
+		f.commands[entry.GetId()] = command
+	}
+	command.seen = now
+	identity := command.identity
+	if entry.CommandName != nil {
+		identity.CommandName = entry.CommandName
+	}
+	if entry.Origin != nil {
+		identity.Origin = entry.Origin
+	}
+	if entry.SequenceNumber != nil {
+		identity.SequenceNumber = entry.SequenceNumber
+	}
+	if entry.GenerationTime != nil {
+		identity.GenerationTime = entry.GenerationTime
+	}
+	if entry.CommandId != nil {
+		identity.CommandId = entry.CommandId
+	}
+	if len(entry.Assignments) > 0 {
+		identity.Assignments = entry.Assignments
+	}
+	for _, attribute := range entry.GetAttr() {
+		value := attribute.GetValue().GetStringValue()
+		switch attribute.GetName() {
+		case "username":
+			command.user = value
+		case "queue":
+			command.queue = value
+		case "CommandComplete_Status":
+			switch value {
+			case "OK":
+				command.completion = CommandSucceeded
+			case "NOK":
+				command.completion = CommandFailed
+			}
+		}
+		if f.waitsForCompletion() {
+			command.setAttribute(attribute)
+		}
+	}
+	return command
+}
+
+func (c *filteredCommand) setAttribute(attribute *commanding.CommandHistoryAttribute) {
+	for i, known := range c.attributes {
+		if known.GetName() == attribute.GetName() {
+			c.attributes[i] = attribute
+			return
+		}
+	}
+	c.attributes = append(c.attributes, attribute)
+}
+
+// merged returns the whole command, with the latest value of each attribute.
+func (c *filteredCommand) merged() *commanding.CommandHistoryEntry {
+	entry := proto.Clone(c.identity).(*commanding.CommandHistoryEntry)
+	entry.Attr = append([]*commanding.CommandHistoryAttribute(nil), c.attributes...)
+	return entry
+}
+
+// fill returns entry completed with the identification, user and queue of its
+// command when entry does not carry them.
+func (c *filteredCommand) fill(entry *commanding.CommandHistoryEntry) *commanding.CommandHistoryEntry {
+	filled := proto.Clone(entry).(*commanding.CommandHistoryEntry)
+	if filled.CommandName == nil {
+		filled.CommandName = c.identity.CommandName
+	}
+	if filled.Origin == nil {
+		filled.Origin = c.identity.Origin
+	}
+	if filled.SequenceNumber == nil {
+		filled.SequenceNumber = c.identity.SequenceNumber
+	}
+	if filled.GenerationTime == nil {
+		filled.GenerationTime = c.identity.GenerationTime
+	}
+	fillAttribute(filled, "username", c.user)
+	fillAttribute(filled, "queue", c.queue)
+	return filled
+}
+
+// fillAttribute adds the string attribute name to entry unless entry already
+// has it or value is unknown.
+func fillAttribute(entry *commanding.CommandHistoryEntry, name, value string) {
+	if value == "" || hasAttribute(entry, name) {
+		return
+	}
+	entry.Attr = append(entry.Attr, &commanding.CommandHistoryAttribute{
+		Name:  proto.String(name),
+		Value: &protobuf.Value{Type: protobuf.Value_STRING.Enum(), StringValue: proto.String(value)},
+	})
+}
+
+func hasAttribute(entry *commanding.CommandHistoryEntry, name string) bool {
+	for _, attribute := range entry.GetAttr() {
+		if attribute.GetName() == name {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if policy.Match(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package source
+
+import (
+	"testing"
+
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/commanding"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func commandEntry(id string, attributes map[string]string) *commanding.CommandHistoryEntry {
+	entry := &commanding.CommandHistoryEntry{Id: proto.String(id)}
+	for name, value := range attributes {
+		entry.Attr = append(entry.Attr, &commanding.CommandHistoryAttribute{
+			Name:  proto.String(name),
+			Value: &protobuf.Value{StringValue: proto.String(value)},
+		})
+	}
+	return entry
+}
+
+// keeps applies filter to entry and tells whether the entry is kept.
+func keeps(filter *CommandHistoryFilter, entry *commanding.CommandHistoryEntry) bool {
+	_, ok := filter.Apply(entry)
+	return ok
+}
+
+func attributes(entry *commanding.CommandHistoryEntry) map[string]string {
+	values := map[string]string{}
+	for _, attribute := range entry.GetAttr() {
+		values[attribute.GetName()] = attribute.GetValue().GetStringValue()
+	}
+	return values
+}
+
+func TestCommandHistoryFilter(t *testing.T) {
+	filter := &CommandHistoryFilter{Names: []string{"/YSS/SIMULATOR/*"}, User: "alice", Status: CommandFailed}
+	assert.Equal(t, map[string]string{"q": "/YSS/SIMULATOR/"}, filter.ArchiveQuery())
+
+	issued := commandEntry("cmd-1", map[string]string{"username": "alice", "queue": "default"})
+	issued.CommandName = proto.String("/YSS/SIMULATOR/SWITCH_VOLTAGE_ON")
+	issued.Origin = proto.String("ground")
+	assert.False(t, keeps(filter, issued))
+
+	other := commandEntry("cmd-2", map[string]string{"username": "bob", "CommandComplete_Status": "NOK"})
+	other.CommandName = proto.String("/YSS/SIMULATOR/SWITCH_VOLTAGE_OFF")
+	assert.False(t, keeps(filter, other))
+
+	pending := &CommandHistoryFilter{Origin: "ground", Queue: "default", Status: CommandPending}
+	assert.Equal(t, map[string]string{"queue": "default"}, pending.ArchiveQuery())
+	assert.True(t, keeps(pending, issued))
+	assert.False(t, keeps(pending, commandEntry("cmd-1", map[string]string{"CommandComplete_Status": "OK"})))
+}
+
+func TestCommandHistoryFilterStream(t *testing.T) {
+	issued := commandEntry("cmd-1", map[string]string{"username": "alice", "queue": "default"})
+	issued.CommandName = proto.String("/YSS/SIMULATOR/SWITCH_VOLTAGE_ON")
+	issued.Origin = proto.String("ground")
+	updates := []*commanding.CommandHistoryEntry{
+		issued,
+		commandEntry("cmd-1", map[string]string{"Acknowledge_Sent_Status": "OK"}),
+		commandEntry("cmd-1", map[string]string{"CommandComplete_Status": "NOK", "CommandComplete_Message": "timeout"}),
+		commandEntry("cmd-1", map[string]string{"Verifier_Complete_Message": "late"}),
+	}
+
+	// A failure filter holds the updates back until the command completes,
+	// then gives the whole command.
+	filter := &CommandHistoryFilter{Names: []string{"/YSS/SIMULATOR/*"}, User: "alice", Status: CommandFailed}
+	var kept []*commanding.CommandHistoryEntry
+	for _, update := range updates {
+		if entry, ok := filter.Apply(update); ok {
+			kept = append(kept, entry)
+		}
+	}
+	require.Len(t, kept, 2)
+	assert.Equal(t, "/YSS/SIMULATOR/SWITCH_VOLTAGE_ON", kept[0].GetCommandName())
+	assert.Equal(t, "ground", kept[0].GetOrigin())
+	assert.Equal(t, map[string]string{
+		"username":                "alice",
+		"queue":                   "default",
+		"Acknowledge_Sent_Status": "OK",
+		"CommandComplete_Status":  "NOK",
+		"CommandComplete_Message": "timeout",
+	}, attributes(kept[0]))
+
+	// Later updates come through with the identification of the command.
+	assert.Equal(t, "/YSS/SIMULATOR/SWITCH_VOLTAGE_ON", kept[1].GetCommandName())
+	assert.Equal(t, map[string]string{"Verifier_Complete_Message": "late", "username": "alice", "queue": "default"}, attributes(kept[1]))
+
+	// Without a status, every update comes through filled in.
+	filter = &CommandHistoryFilter{User: "alice"}
+	kept = kept[:0]
+	for _, update := range updates {
+		if entry, ok := filter.Apply(update); ok {
+			kept = append(kept, entry)
+		}
+	}
+	require.Len(t, kept, 4)
+	assert.Equal(t, "/YSS/SIMULATOR/SWITCH_VOLTAGE_ON", kept[1].GetCommandName())
+	assert.Equal(t, "alice", attributes(kept[1])["username"])
+	assert.Empty(t, updates[1].GetCommandName(), "the updates themselves are left untouched")
+}
@@ -0,0 +1,239 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	yamcsprotobuf "github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/pvalue"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/config"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/expression"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/tools"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/yamcs/client"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// DerivedParameter is a virtual parameter computed by an expression over other
+// parameters of an endpoint.
+type DerivedParameter struct {
+	Name       string
+	Expression *expression.Expression
+	// Unit is the configured unit, empty to propagate the units of the inputs.
+	Unit   string
+	inputs map[string]string
+}
+
+// NewDerivedParameter parses the definition of a derived parameter.
+func NewDerivedParameter(definition *config.DerivedParameterConfiguration) (*DerivedParameter, error) {
+	parsed, err := expression.Parse(definition.Expression)
+	if err != nil {
+		return nil, fmt.Errorf("derived parameter %s: %w", definition.Name, err)
+	}
+	return &DerivedParameter{
+		Name:       definition.Name,
+		Expression: parsed,
+		Unit:       definition.Unit,
+		inputs:     definition.Inputs,
+	}, nil
+}
+
+// parameter returns the Yamcs parameter a variable of the expression stands for.
+func (derived *DerivedParameter) parameter(variable string) string {
+	if parameter, ok := derived.inputs[variable]; ok {
+		return parameter
+	}
+	return variable
+}
+
+// Parameters returns the Yamcs parameters the derived parameter is computed from.
+func (derived *DerivedParameter) Parameters() []string {
+	parameters := make([]string, 0, len(derived.Expression.Variables()))
+	for _, variable := range derived.Expression.Variables() {
+		parameters = append(parameters, derived.parameter(variable))
+	}
+	return parameters
+}
+
+// Evaluate computes the derived value from the values of its input parameters.
+func (derived *DerivedParameter) Evaluate(values func(parameter string) (float64, bool)) (float64, error) {
+	return derived.Expression.Evaluate(func(variable string) (float64, bool) {
+		return values(derived.parameter(variable))
+	})
+}
+
+// unit returns the configured unit, or the unit propagated from the inputs.
+func (derived *DerivedParameter) unit(units func(parameter string) string) string {
+	if derived.Unit != "" {
+		return derived.Unit
+	}
+	return derived.Expression.Unit(func(variable string) string {
+		return units(derived.parameter(variable))
+	})
+}
+
+// DerivedParameter returns the derived parameter called name, as defined in the
+// endpoint configuration or by a query, or nil if there is none.
+func (ep *YamcsEndpoint) DerivedParameter(name string) *DerivedParameter {
+	ep.derivedMu.Lock()
+	defer ep.derivedMu.Unlock()
+
+	if derived, ok := ep.derived[name]; ok {
+		return derived
+	}
+	for _, definition := range ep.GetConfiguration().Derived {
+		if definition == nil || definition.Name != name {
+			continue
+		}
+		derived, err := NewDerivedParameter(definition)
+		if err != nil {
+			backend.Logger.Warn("Invalid derived parameter", "endpoint", ep.ID, "error", err)
+			return nil
+		}
+		ep.setDerived(derived)
+		return derived
+	}
+	return nil
+}
+
+// DefineDerivedParameter registers a derived parameter defined by a query. A
+// definition with the same name is replaced, along with the demand using it.
+func (ep *YamcsEndpoint) DefineDerivedParameter(name string, source string, unit string) (*DerivedParameter, error) {
+	ep.derivedMu.Lock()
+	existing, ok := ep.derived[name]
+	ep.derivedMu.Unlock()
+	if ok && existing.Expression.String() == source && existing.Unit == unit {
+		return existing, nil
+	}
+
+	derived, err := NewDerivedParameter(&config.DerivedParameterConfiguration{Name: name, Expression: source, Unit: unit})
+	if err != nil {
+		return nil, err
+	}
+	ep.derivedMu.Lock()
+	ep.setDerived(derived)
+	ep.derivedMu.Unlock()
+
+	if demand := ep.Parameters[name]; demand != nil {
+		demand.Derived = derived
+		demand.Unit = derived.unit(ep.parameterUnit)
+	}
+	return derived, nil
+}
+
+// setDerived records a derived parameter. The caller holds derivedMu.
+func (ep *YamcsEndpoint) setDerived(derived *DerivedParameter) {
+	if ep.derived == nil {
+		ep.derived = make(map[string]*DerivedParameter)
+	}
+	ep.derived[derived.Name] = derived
+}
+
+// parameterUnit returns the unit of an input parameter.
+func (ep *YamcsEndpoint) parameterUnit(parameter string) string {
+	return ep.GetParameterDemand(parameter).Unit
+}
+
+// streamInputs returns the parameters a stream of demand needs from Yamcs: a
+// derived parameter needs its inputs, any other parameter itself and the
+// parameters its context alarms depend on.
+func (ep *YamcsEndpoint) streamInputs(demand *ParameterDemand) []string {
+	if demand.Derived != nil {
+		return demand.Derived.Parameters()
+	}
+	return append([]string{demand.Name}, tools.ContextParameters(demand.Type)...)
+}
+
+// updateDerivedParameters recomputes the streamed derived parameters that use
+// parameter, which has just received value.
+func (ep *YamcsEndpoint) updateDerivedParameters(parameter string, value *pvalue.ParameterValue) {
+	for _, demand := range ep.Parameters {
+		if demand.Derived == nil || len(demand.Streams) == 0 {
+			continue
+		}
+		uses := false
+		for _, input := range demand.Derived.Parameters() {
+			uses = uses || input == parameter
+		}
+		if !uses {
+			continue
+		}
+
+		result, err := demand.Derived.Evaluate(ep.lastNumericValue)
+		if err != nil {
+			// Some inputs have not been received yet.
+			continue
+		}
+		derivedValue := NewDerivedValue(result, value.GetGenerationTime().AsTime())
+		demand.LastReceived = time.Now()
+		demand.LastValue = derivedValue
+		for _, streamDemand := range demand.Streams {
+			streamDemand.Buffer = append(streamDemand.Buffer, derivedValue)
+		}
+	}
+}
+
+// lastNumericValue returns the latest value received for parameter, if numeric.
+func (ep *YamcsEndpoint) lastNumericValue(parameter string) (float64, bool) {
+	return tools.NumericValue(ep.ContextValue(parameter))
+}
+
+// NewDerivedValue wraps a computed value into a parameter value.
+func NewDerivedValue(result float64, generationTime time.Time) *pvalue.ParameterValue {
+	return &pvalue.ParameterValue{
+		EngValue:          &yamcsprotobuf.Value{Type: yamcsprotobuf.Value_DOUBLE.Enum(), DoubleValue: &result},
+		GenerationTime:    timestamppb.New(generationTime),
+		AcquisitionTime:   timestamppb.Now(),
+		AcquisitionStatus: pvalue.AcquisitionStatus_ACQUIRED.Enum(),
+	}
+}
+
+// DerivedSamples computes count samples of a derived parameter between start
+// and end. The samples of its inputs share their bucket times, as they are
+// requested over the same window with the same count; each bucket is computed
+// from the input averages, and holds as many values as the sparsest input.
+func (q *Querier) DerivedSamples(ctx context.Context, endpoint *YamcsEndpoint, derived *DerivedParameter, start, end time.Time, count int) ([]client.Sample, error) {
+	inputs := derived.Parameters()
+	buckets := make([]map[int64]client.Sample, len(inputs))
+	var times []*timestamppb.Timestamp
+	for i, input := range inputs {
+		samples, err := q.ParameterSamples(ctx, endpoint, input, start, end, count)
+		if err != nil {
+			return nil, err
+		}
+		buckets[i] = make(map[int64]client.Sample, len(samples))
+		for _, sample := range samples {
+			buckets[i][sample.GetTime().AsTime().UnixNano()] = sample
+			if i == 0 {
+				times = append(times, sample.GetTime())
+			}
+		}
+	}
+
+	result := make([]client.Sample, 0, len(times))
+	for _, t := range times {
+		n := int32(math.MaxInt32)
+		averages := make(map[string]float64, len(inputs))
+		for i, input := range inputs {
+			sample := buckets[i][t.AsTime().UnixNano()]
+			n = min(n, sample.GetN())
+			averages[input] = sample.GetAvg()
+		}
+
+		derivedSample := &pvalue.TimeSeries_Sample{Time: t, N: new(int32)}
+		if n > 0 {
+			value, err := derived.Evaluate(func(parameter string) (float64, bool) {
+				average, ok := averages[parameter]
+				return average, ok
+			})
+			if err == nil && !math.IsNaN(value) && !math.IsInf(value, 0) {
+				derivedSample.Avg, derivedSample.Min, derivedSample.Max = &value, &value, &value
+				derivedSample.N = &n
+			}
+		}
+		result = append(result, derivedSample)
+	}
+	return result, nil
+}
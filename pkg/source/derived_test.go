@@ -0,0 +1,38 @@
+package source
+
+import (
+	"testing"
+
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDerivedParameterInputs(t *testing.T) {
+	derived, err := NewDerivedParameter(&config.DerivedParameterConfiguration{
+		Name:       "power",
+		Expression: "v * `/YSS/SIMULATOR/BUS_I`",
+		Inputs:     map[string]string{"v": "/YSS/SIMULATOR/BUS_V"},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"/YSS/SIMULATOR/BUS_V", "/YSS/SIMULATOR/BUS_I"}, derived.Parameters())
+
+	values := map[string]float64{"/YSS/SIMULATOR/BUS_V": 28, "/YSS/SIMULATOR/BUS_I": 1.5}
+	got, err := derived.Evaluate(func(parameter string) (float64, bool) {
+		value, ok := values[parameter]
+		return value, ok
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 42.0, got)
+
+	units := map[string]string{"/YSS/SIMULATOR/BUS_V": "V", "/YSS/SIMULATOR/BUS_I": "A"}
+	assert.Equal(t, "V·A", derived.unit(func(parameter string) string { return units[parameter] }))
+	derived.Unit = "W"
+	assert.Equal(t, "W", derived.unit(func(parameter string) string { return units[parameter] }))
+}
+
+func TestNewDerivedParameterInvalid(t *testing.T) {
+	_, err := NewDerivedParameter(&config.DerivedParameterConfiguration{Name: "broken", Expression: "a +"})
+	assert.ErrorContains(t, err, "derived parameter broken")
+}
@@ -2,6 +2,7 @@ package source
 
 import (
 	"fmt"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
@@ -9,10 +10,12 @@ import (
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/grafana/grafana-plugin-sdk-go/data"
+	yamcsprotobuf "github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf"
 	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/alarms"
 	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/commanding"
 	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/events"
 	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/links"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/mdb"
 	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/pvalue"
 	"github.com/jaops-space/grafana-yamcs-jaops/pkg/config"
 	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/tools"
@@ -25,6 +28,20 @@ type YamcsEndpoint struct {
 
 	mu sync.RWMutex // guards AlarmCache and GlobalAlarmStatus
 
+	infoMu        sync.Mutex // guards parameterInfo
+	parameterInfo map[string]*mdb.ParameterInfo
+
+	derivedMu sync.Mutex // guards derived
+	derived   map[string]*DerivedParameter
+
+	commandMu sync.Mutex // guards CommandHistory, CommandSignals and CommandFilters
+
+	queuesMu     sync.Mutex // guards queueStreams
+	queueStreams map[string]*tools.CommandQueues
+
+	scheduleMu      sync.Mutex // guards scheduleStreams
+	scheduleStreams map[string]*tools.ScheduledCommands
+
 	ID                string
 	Instance          client.Instance
 	Processor         client.Processor
@@ -32,6 +49,7 @@ type YamcsEndpoint struct {
 	Events            map[string][]*events.Event
 	CommandHistory    map[string][]*commanding.CommandHistoryEntry
 	CommandSignals    map[string]chan struct{}
+	CommandFilters    map[string]*CommandHistoryFilter
 	Alarms            map[string][]*alarms.AlarmData
 	AlarmSignals      map[string]chan struct{}
 	Links             map[string][]*links.LinkInfo
@@ -48,10 +66,17 @@ type ParameterDemand struct {
 	endpoint *YamcsEndpoint
 
 	LastReceived time.Time
+	LastValue    *pvalue.ParameterValue
 	Name         string
 	Unit         string
 	Thresholds   []*data.Threshold
-	Streams      map[string]*ParameterStreamDemand
+	// Info and Type are the MDB definition and type of the parameter, nil if
+	// the lookup failed.
+	Info *mdb.ParameterInfo
+	Type *mdb.ParameterTypeInfo
+	// Derived is the definition of a derived parameter, nil for Yamcs parameters.
+	Derived *DerivedParameter
+	Streams map[string]*ParameterStreamDemand
 }
 
 // ParameterStreamDemand represents a demand for a specific parameter stream.
@@ -156,27 +181,36 @@ func (ep *YamcsEndpoint) GetConfiguration() *config.YamcsEndpointConfiguration {
 func (ep *YamcsEndpoint) GetParameterDemand(parameter string) *ParameterDemand {
 
 	if ep.Parameters[parameter] == nil {
-		client := ep.GetClient()
-		unit := ""
-		thresholds := make([]*data.Threshold, 0)
-
-		paramInfo, err := client.GetParameter(ep.Instance, parameter)
-		if err == nil {
-			paramType := paramInfo.GetType()
-			unitSet := paramType.GetUnitSet()
-			thresholds = tools.ConvertAlarmInfoToThresholds(paramType.GetDefaultAlarm())
-			if len(unitSet) > 0 {
-				unit = unitSet[0].GetUnit()
+		if derived := ep.DerivedParameter(parameter); derived != nil {
+			demand := &ParameterDemand{
+				endpoint:   ep,
+				Name:       parameter,
+				Thresholds: make([]*data.Threshold, 0),
+				Derived:    derived,
+				Streams:    make(map[string]*ParameterStreamDemand),
 			}
+			ep.Parameters[parameter] = demand
+			demand.Unit = derived.unit(ep.parameterUnit)
+			return demand
 		}
 
-		ep.Parameters[parameter] = &ParameterDemand{
+		// Concurrent demands for the same parameter share this MDB lookup.
+		paramInfo, err := ep.GetParameterInfo(ep.GetClient().Context(), parameter)
+		if existing := ep.Parameters[parameter]; existing != nil {
+			// Another caller registered the demand while we were waiting.
+			return existing
+		}
+
+		demand := &ParameterDemand{
 			endpoint:   ep,
 			Name:       parameter,
-			Unit:       unit,
-			Thresholds: thresholds,
+			Thresholds: make([]*data.Threshold, 0),
 			Streams:    make(map[string]*ParameterStreamDemand),
 		}
+		if err == nil {
+			demand.applyInfo(paramInfo)
+		}
+		ep.Parameters[parameter] = demand
 	}
 	return ep.Parameters[parameter]
 }
@@ -194,9 +228,11 @@ func (ep *YamcsEndpoint) GetChannelParameterListener() func(parameter string, va
 			return
 		}
 
+		paramDemand.LastValue = value
 		for _, streamDemand := range streamDemands {
 			streamDemand.Buffer = append(streamDemand.Buffer, value)
 		}
+		ep.updateDerivedParameters(parameter, value)
 
 	}
 }
@@ -228,9 +264,14 @@ func (ep *YamcsEndpoint) RequestNewParameterStream(name string, path string) err
 		return err
 	}
 
-	if !subscription.Has(name) {
-		backend.Logger.Debug("Adding parameter to subscription", "parameter", name)
-		subscription.Add(name)
+	// Derived parameters are computed from their inputs, and context alarms are
+	// evaluated against the live values of their parameters.
+	for _, input := range ep.streamInputs(ep.Parameters[name]) {
+		ep.GetParameterDemand(input)
+		if !subscription.Has(input) {
+			backend.Logger.Debug("Adding parameter to subscription", "parameter", input, "for", name)
+			subscription.Add(input)
+		}
 	}
 	backend.Logger.Debug("Current subscriptions", "subscriptions")
 	for name := range subscription.ActiveSubscriptions {
@@ -268,7 +309,31 @@ func (ep *YamcsEndpoint) WithdrawParameterStreamRequest(name string, path string
 		if err != nil {
 			return err
 		}
-		subscription.Remove(name)
+		for _, parameter := range ep.streamInputs(ep.Parameters[name]) {
+			if !ep.isParameterInUse(parameter) {
+				subscription.Remove(parameter)
+			}
+		}
+	}
+	return nil
+}
+
+// isParameterInUse reports whether parameter is streamed, or is needed by a
+// streamed parameter as a context parameter or as the input of a derived one.
+func (ep *YamcsEndpoint) isParameterInUse(parameter string) bool {
+	for _, demand := range ep.Parameters {
+		if len(demand.Streams) > 0 && slices.Contains(ep.streamInputs(demand), parameter) {
+			return true
+		}
+	}
+	return false
+}
+
+// ContextValue returns the latest engineering value received for parameter, or
+// nil if it is not subscribed or nothing has been received yet.
+func (ep *YamcsEndpoint) ContextValue(parameter string) *yamcsprotobuf.Value {
+	if demand := ep.Parameters[parameter]; demand != nil && demand.LastValue != nil {
+		return demand.LastValue.GetEngValue()
 	}
 	return nil
 }
@@ -349,16 +414,24 @@ COMMAND HISTORY
 
 **/
 
-func (ep *YamcsEndpoint) RequestCommandHistoryStream(path string) {
+// RequestCommandHistoryStream starts buffering the live command history
+// entries of the endpoint processor for path. Only the entries kept by filter
+// are buffered; a nil filter keeps them all.
+func (ep *YamcsEndpoint) RequestCommandHistoryStream(path string, filter *CommandHistoryFilter) {
 	ep.GetCommandHistorySubscription()
+	ep.commandMu.Lock()
+	defer ep.commandMu.Unlock()
 	ep.CommandHistory[path] = make([]*commanding.CommandHistoryEntry, 0)
 	ep.CommandSignals[path] = make(chan struct{}, 1)
+	if filter != nil {
+		ep.CommandFilters[path] = filter
+	}
 }
 
 func (ep *YamcsEndpoint) GetCommandHistorySubscription() (*client.CommandHistorySubscription, error) {
 	client := ep.GetClient()
 	for _, subscription := range client.CommandHistorySubscriptions {
-		if subscription.Instance == ep.Instance.GetName() {
+		if subscription.Instance == ep.Instance.GetName() && subscription.Processor == ep.Processor.GetName() {
 			return subscription, nil
 		}
 	}
@@ -366,19 +439,59 @@ func (ep *YamcsEndpoint) GetCommandHistorySubscription() (*client.CommandHistory
 	if err != nil {
 		return nil, err
 	}
-	subscription.SetListener(ep.Multiplexer.GetCommandHistoryListener(ep.Instance))
+	subscription.SetListener(ep.Multiplexer.GetCommandHistoryListener(ep.Instance, ep.Processor))
 	return subscription, nil
 }
 
 func (ep *YamcsEndpoint) GetCommandHistoryStream(path string) []*commanding.CommandHistoryEntry {
+	ep.commandMu.Lock()
+	defer ep.commandMu.Unlock()
 	return ep.CommandHistory[path]
 }
 
 func (ep *YamcsEndpoint) ClearCommandHistoryStream(path string) {
+	ep.commandMu.Lock()
+	defer ep.commandMu.Unlock()
 	ep.CommandHistory[path] = make([]*commanding.CommandHistoryEntry, 0)
 }
 
+// DrainCommandHistoryStream returns the entries buffered for path and empties
+// the buffer in the same step, so that no entry arriving in between is lost.
+func (ep *YamcsEndpoint) DrainCommandHistoryStream(path string) []*commanding.CommandHistoryEntry {
+	ep.commandMu.Lock()
+	defer ep.commandMu.Unlock()
+	entries, ok := ep.CommandHistory[path]
+	if ok {
+		ep.CommandHistory[path] = make([]*commanding.CommandHistoryEntry, 0)
+	}
+	return entries
+}
+
+// appendCommandHistory buffers entry for every command history stream of the
+// endpoint whose filter keeps it, as the filter gives it, and wakes their
+// readers up.
+func (ep *YamcsEndpoint) appendCommandHistory(entry *commanding.CommandHistoryEntry) {
+	ep.commandMu.Lock()
+	defer ep.commandMu.Unlock()
+	for path := range ep.CommandHistory {
+		kept := entry
+		if filter, ok := ep.CommandFilters[path]; ok {
+			if kept, ok = filter.Apply(entry); !ok {
+				continue
+			}
+		}
+		ep.CommandHistory[path] = append(ep.CommandHistory[path], kept)
+		ep.notifyCommandHistoryStream(path)
+	}
+}
+
 func (ep *YamcsEndpoint) NotifyCommandHistoryStream(path string) {
+	ep.commandMu.Lock()
+	defer ep.commandMu.Unlock()
+	ep.notifyCommandHistoryStream(path)
+}
+
+func (ep *YamcsEndpoint) notifyCommandHistoryStream(path string) {
 	if signal, ok := ep.CommandSignals[path]; ok {
 		select {
 		case signal <- struct{}{}:
@@ -388,11 +501,16 @@ func (ep *YamcsEndpoint) NotifyCommandHistoryStream(path string) {
 }
 
 func (ep *YamcsEndpoint) GetCommandHistorySignal(path string) <-chan struct{} {
+	ep.commandMu.Lock()
+	defer ep.commandMu.Unlock()
 	return ep.CommandSignals[path]
 }
 
 func (ep *YamcsEndpoint) WithdrawCommandHistoryStreamRequest(path string) {
+	ep.commandMu.Lock()
+	defer ep.commandMu.Unlock()
 	delete(ep.CommandHistory, path)
+	delete(ep.CommandFilters, path)
 	if signal, ok := ep.CommandSignals[path]; ok {
 		close(signal)
 		delete(ep.CommandSignals, path)
@@ -400,7 +518,7 @@ func (ep *YamcsEndpoint) WithdrawCommandHistoryStreamRequest(path string) {
 	if len(ep.CommandHistory) == 0 {
 		client := ep.GetClient()
 		for _, subscription := range client.CommandHistorySubscriptions {
-			if subscription.Instance == ep.Instance.GetName() {
+			if subscription.Instance == ep.Instance.GetName() && subscription.Processor == ep.Processor.GetName() {
 				subscription.Halt()
 			}
 		}
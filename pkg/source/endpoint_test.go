@@ -0,0 +1,32 @@
+package source
+
+import (
+	"testing"
+
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/commanding"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestDrainCommandHistoryStream(t *testing.T) {
+	ep := &YamcsEndpoint{
+		CommandHistory: map[string][]*commanding.CommandHistoryEntry{"stack/1": {}},
+		CommandSignals: map[string]chan struct{}{"stack/1": make(chan struct{}, 1)},
+	}
+
+	ep.appendCommandHistory(&commanding.CommandHistoryEntry{Id: proto.String("cmd-1")})
+	ep.appendCommandHistory(&commanding.CommandHistoryEntry{Id: proto.String("cmd-2")})
+	assert.Len(t, ep.DrainCommandHistoryStream("stack/1"), 2)
+	assert.Len(t, ep.GetCommandHistorySignal("stack/1"), 1, "readers are woken up once")
+
+	ep.appendCommandHistory(&commanding.CommandHistoryEntry{Id: proto.String("cmd-3")})
+	entries := ep.DrainCommandHistoryStream("stack/1")
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "cmd-3", entries[0].GetId())
+	}
+	assert.Empty(t, ep.DrainCommandHistoryStream("stack/1"))
+
+	// Draining a withdrawn stream does not bring it back.
+	assert.Nil(t, ep.DrainCommandHistoryStream("stack/2"))
+	assert.NotContains(t, ep.CommandHistory, "stack/2")
+}
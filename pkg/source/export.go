@@ -0,0 +1,432 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/commanding"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/events"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/pvalue"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/export"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/tools"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/types"
+)
+
+// exportPageSize is the number of archive entries requested per page.
+const exportPageSize = 1000
+
+// exportQuery orders archive pages oldest first, so that exports can be
+// written as they are read.
+var exportQuery = map[string]string{
+	"order": "asc",
+	"limit": strconv.Itoa(exportPageSize),
+}
+
+// ExportAlignment selects how the values of the exported parameters are laid
+// out in rows.
+type ExportAlignment string
+
+const (
+	// AlignNone writes one row per value, naming its parameter.
+	AlignNone ExportAlignment = "none"
+	// AlignExact writes one row per generation time, with one column per
+	// parameter, empty when the parameter has no value at that time.
+	AlignExact ExportAlignment = "exact"
+	// AlignPrevious writes the same rows as AlignExact, each column holding
+	// the latest value of its parameter at that time.
+	AlignPrevious ExportAlignment = "previous"
+	// AlignInterval writes one row per interval, each column holding the
+	// latest value of its parameter at the end of the interval.
+	AlignInterval ExportAlignment = "interval"
+)
+
+// ParameterExport describes an export of parameter history.
+type ParameterExport struct {
+	Parameters []string
+	Start, End time.Time
+	Alignment  ExportAlignment
+	// Interval is the row interval of AlignInterval.
+	Interval time.Duration
+}
+
+// cursor reads a paginated archive listing one entry at a time, holding a
+// single page in memory.
+type cursor[T any] struct {
+	ctx      context.Context
+	iterator *types.PaginatedRequestIterator[[]T]
+	page     []T
+	index    int
+}
+
+func newCursor[T any](ctx context.Context, iterator *types.PaginatedRequestIterator[[]T]) *cursor[T] {
+	iterator.SetQuery(exportQuery)
+	return &cursor[T]{ctx: ctx, iterator: iterator}
+}
+
+// peek returns the next entry without consuming it, and false at the end.
+func (c *cursor[T]) peek() (T, bool, error) {
+	var zero T
+	for c.index >= len(c.page) {
+		if !c.iterator.HasNext() {
+			return zero, false, nil
+		}
+		// Stop as soon as the client goes away.
+		if err := c.ctx.Err(); err != nil {
+			return zero, false, err
+		}
+		page, err := c.iterator.Next()
+		if err != nil {
+			return zero, false, err
+		}
+		c.page, c.index = page, 0
+	}
+	return c.page[c.index], true, nil
+}
+
+// next returns and consumes the next entry, and false at the end.
+func (c *cursor[T]) next() (T, bool, error) {
+	entry, ok, err := c.peek()
+	if ok {
+		c.index++
+	}
+	return entry, ok, err
+}
+
+// valueCursor is a cursor over the history of one parameter.
+type valueCursor = cursor[*pvalue.ParameterValue]
+
+// peekTime returns the generation time of the next value of c.
+func peekTime(c *valueCursor) (time.Time, bool, error) {
+	value, ok, err := c.peek()
+	if !ok {
+		return time.Time{}, false, err
+	}
+	return value.GetGenerationTime().AsTime(), true, nil
+}
+
+// ExportParameterHistory writes the raw archived values of the requested
+// parameters to w, in the given format, reading them from Yamcs page by page.
+func (ep *YamcsEndpoint) ExportParameterHistory(ctx context.Context, parameters ParameterExport, format export.Format, w io.Writer) error {
+	switch parameters.Alignment {
+	case "", AlignNone, AlignExact, AlignPrevious:
+	case AlignInterval:
+		if parameters.Interval <= 0 {
+			return fmt.Errorf("interval alignment requires a positive interval")
+		}
+	default:
+		return fmt.Errorf("unknown alignment %q, expected none, exact, previous or interval", parameters.Alignment)
+	}
+	yamcs := ep.GetClient().WithContext(ctx)
+	cursors := make([]*valueCursor, len(parameters.Parameters))
+	for i, parameter := range parameters.Parameters {
+		cursors[i] = newCursor(ctx, yamcs.ListParameterHistoryByName(ep.Instance, parameter, parameters.Start, parameters.End))
+		// Read the first page before writing anything, so that an unknown
+		// parameter fails the export with a proper error response.
+		if _, _, err := cursors[i].peek(); err != nil {
+			return err
+		}
+	}
+
+	if parameters.Alignment == "" || parameters.Alignment == AlignNone {
+		return exportValues(parameters.Parameters, cursors, format, w)
+	}
+	return exportAlignedValues(parameters, cursors, format, w)
+}
+
+// exportValues writes one row per value, merging the cursors by time.
+func exportValues(parameters []string, cursors []*valueCursor, format export.Format, w io.Writer) error {
+	writer, err := export.NewWriter(format, w, []export.Column{
+		{Name: "time", Type: export.Time},
+		{Name: "parameter", Type: export.String},
+		{Name: "value", Type: export.String},
+		{Name: "number", Type: export.Number},
+		{Name: "rawValue", Type: export.String},
+		{Name: "acquisitionStatus", Type: export.String},
+		{Name: "monitoringResult", Type: export.String},
+	})
+	if err != nil {
+		return err
+	}
+
+	for {
+		earliest, earliestTime := -1, time.Time{}
+		for i, c := range cursors {
+			t, ok, err := peekTime(c)
+			if err != nil {
+				return err
+			}
+			if ok && (earliest < 0 || t.Before(earliestTime)) {
+				earliest, earliestTime = i, t
+			}
+		}
+		if earliest < 0 {
+			return writer.Close()
+		}
+
+		value, _, _ := cursors[earliest].next()
+		row := []any{earliestTime, parameters[earliest], exportText(value.GetEngValue()), nil, nil, nil, nil}
+		if number, ok := tools.NumericValue(value.GetEngValue()); ok {
+			row[3] = number
+		}
+		if value.RawValue != nil {
+			row[4] = exportText(value.GetRawValue())
+		}
+		if value.AcquisitionStatus != nil {
+			row[5] = value.GetAcquisitionStatus().String()
+		}
+		if value.MonitoringResult != nil {
+			row[6] = value.GetMonitoringResult().String()
+		}
+		if err := writer.WriteRow(row); err != nil {
+			return err
+		}
+	}
+}
+
+// exportAlignedValues writes one column per parameter, in rows aligned as
+// requested by parameters.Alignment.
+func exportAlignedValues(parameters ParameterExport, cursors []*valueCursor, format export.Format, w io.Writer) error {
+	// Parameters whose first value is numeric get a numeric column.
+	columns := []export.Column{{Name: "time", Type: export.Time}}
+	for i, parameter := range parameters.Parameters {
+		column := export.Column{Name: parameter, Type: export.String}
+		first, ok, err := cursors[i].peek()
+		if err != nil {
+			return err
+		}
+		if _, numeric := tools.NumericValue(first.GetEngValue()); ok && numeric {
+			column.Type = export.Number
+		}
+		columns = append(columns, column)
+	}
+	writer, err := export.NewWriter(format, w, columns)
+	if err != nil {
+		return err
+	}
+
+	latest := make([]any, len(cursors))
+	row := make([]any, len(columns))
+	// consume takes the values of cursor i up to limit, included or not, into latest.
+	consume := func(i int, limit time.Time, included bool) (bool, error) {
+		updated := false
+		for {
+			t, ok, err := peekTime(cursors[i])
+			if err != nil || !ok || t.After(limit) || (!included && t.Equal(limit)) {
+				return updated, err
+			}
+			value, _, _ := cursors[i].next()
+			latest[i] = cellValue(value.GetEngValue(), columns[i+1].Type)
+			updated = true
+		}
+	}
+
+	if parameters.Alignment == AlignInterval {
+		for start := parameters.Start; start.Before(parameters.End); start = start.Add(parameters.Interval) {
+			row[0] = start
+			for i := range cursors {
+				if _, err := consume(i, start.Add(parameters.Interval), false); err != nil {
+					return err
+				}
+				row[i+1] = latest[i]
+			}
+			if err := writer.WriteRow(row); err != nil {
+				return err
+			}
+		}
+		return writer.Close()
+	}
+
+	for {
+		earliest, found := time.Time{}, false
+		for _, c := range cursors {
+			t, ok, err := peekTime(c)
+			if err != nil {
+				return err
+			}
+			if ok && (!found || t.Before(earliest)) {
+				earliest, found = t, true
+			}
+		}
+		if !found {
+			return writer.Close()
+		}
+
+		row[0] = earliest
+		for i := range cursors {
+			updated, err := consume(i, earliest, true)
+			if err != nil {
+				return err
+			}
+			row[i+1] = latest[i]
+			if parameters.Alignment == AlignExact && !updated {
+				row[i+1] = nil
+			}
+		}
+		if err := writer.WriteRow(row); err != nil {
+			return err
+		}
+	}
+}
+
+// cellValue converts a value for a column of the given type.
+func cellValue(value *protobuf.Value, columnType export.ColumnType) any {
+	if columnType == export.Number {
+		if number, ok := tools.NumericValue(value); ok {
+			return number
+		}
+		return nil
+	}
+	return exportText(value)
+}
+
+// exportText formats a value for export, keeping the full precision of numbers.
+func exportText(value *protobuf.Value) string {
+	switch value.GetType() {
+	case protobuf.Value_FLOAT, protobuf.Value_DOUBLE:
+		number, _ := tools.NumericValue(value)
+		return strconv.FormatFloat(number, 'g', -1, 64)
+	case protobuf.Value_SINT64:
+		return strconv.FormatInt(value.GetSint64Value(), 10)
+	case protobuf.Value_UINT64:
+		return strconv.FormatUint(value.GetUint64Value(), 10)
+	case protobuf.Value_STRING, protobuf.Value_ENUMERATED:
+		return value.GetStringValue()
+	}
+	return tools.StringifyValue(value)
+}
+
+// ExportEvents writes the archived events between start and end to w.
+func (ep *YamcsEndpoint) ExportEvents(ctx context.Context, start, end time.Time, format export.Format, w io.Writer) error {
+	yamcs := ep.GetClient().WithContext(ctx)
+	c := newCursor(ctx, yamcs.ListEventsWithinTimeRange(ep.Instance, start, end))
+	if _, _, err := c.peek(); err != nil {
+		return err
+	}
+
+	writer, err := export.NewWriter(format, w, []export.Column{
+		{Name: "time", Type: export.Time},
+		{Name: "receptionTime", Type: export.Time},
+		{Name: "severity", Type: export.String},
+		{Name: "source", Type: export.String},
+		{Name: "type", Type: export.String},
+		{Name: "message", Type: export.String},
+		{Name: "seqNumber", Type: export.Integer},
+		{Name: "createdBy", Type: export.String},
+	})
+	if err != nil {
+		return err
+	}
+	for {
+		event, ok, err := c.next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return writer.Close()
+		}
+		if err := writer.WriteRow(eventRow(event)); err != nil {
+			return err
+		}
+	}
+}
+
+func eventRow(event *events.Event) []any {
+	return []any{
+		event.GetGenerationTime().AsTime(),
+		event.GetReceptionTime().AsTime(),
+		event.GetSeverity().String(),
+		event.GetSource(),
+		event.GetType(),
+		event.GetMessage(),
+		int64(event.GetSeqNumber()),
+		event.GetCreatedBy(),
+	}
+}
+
+// commandExportAttributes are the command history attributes exported as
+// columns, after the identification of the command.
+var commandExportAttributes = []string{
+	"username",
+	"comment",
+	"Acknowledge_Queued_Status",
+	"Acknowledge_Released_Status",
+	"Acknowledge_Sent_Status",
+	"CommandComplete_Status",
+	"CommandComplete_Message",
+}
+
+// ExportCommandHistory writes the archived command history between start and
+// end to w.
+func (ep *YamcsEndpoint) ExportCommandHistory(ctx context.Context, start, end time.Time, format export.Format, w io.Writer) error {
+	yamcs := ep.GetClient().WithContext(ctx)
+	c := newCursor(ctx, yamcs.ListCommandsHistory(ep.Instance, start, end))
+	if _, _, err := c.peek(); err != nil {
+		return err
+	}
+
+	columns := []export.Column{
+		{Name: "time", Type: export.Time},
+		{Name: "id", Type: export.String},
+		{Name: "command", Type: export.String},
+		{Name: "origin", Type: export.String},
+		{Name: "sequenceNumber", Type: export.Integer},
+		{Name: "arguments", Type: export.String},
+	}
+	for _, attribute := range commandExportAttributes {
+		columns = append(columns, export.Column{Name: attribute, Type: export.String})
+	}
+	writer, err := export.NewWriter(format, w, columns)
+	if err != nil {
+		return err
+	}
+	for {
+		command, ok, err := c.next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return writer.Close()
+		}
+		if err := writer.WriteRow(commandRow(command)); err != nil {
+			return err
+		}
+	}
+}
+
+func commandRow(command *commanding.CommandHistoryEntry) []any {
+	arguments := ""
+	for _, assignment := range command.GetAssignments() {
+		if !assignment.GetUserInput() {
+			continue
+		}
+		if arguments != "" {
+			arguments += "; "
+		}
+		arguments += assignment.GetName() + "=" + exportText(assignment.GetValue())
+	}
+	row := []any{
+		command.GetGenerationTime().AsTime(),
+		command.GetId(),
+		command.GetCommandName(),
+		command.GetOrigin(),
+		int64(command.GetSequenceNumber()),
+		arguments,
+	}
+
+	attributes := make(map[string]string, len(command.GetAttr()))
+	for _, attribute := range command.GetAttr() {
+		attributes[attribute.GetName()] = exportText(attribute.GetValue())
+	}
+	for _, name := range commandExportAttributes {
+		if value, ok := attributes[name]; ok {
+			row = append(row, value)
+		} else {
+			row = append(row, nil)
+		}
+	}
+	return row
+}
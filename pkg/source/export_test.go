@@ -0,0 +1,129 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	yamcsprotobuf "github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/pvalue"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/export"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/types"
+	corehttp "github.com/jaops-space/grafana-yamcs-jaops/pkg/yamcs/core/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var exportStart = time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// exportValue returns a double value generated seconds after exportStart.
+func exportValue(seconds int, value float64) *pvalue.ParameterValue {
+	return &pvalue.ParameterValue{
+		GenerationTime: timestamppb.New(exportStart.Add(time.Duration(seconds) * time.Second)),
+		EngValue:       &yamcsprotobuf.Value{Type: yamcsprotobuf.Value_DOUBLE.Enum(), DoubleValue: proto.Float64(value)},
+	}
+}
+
+// pagedCursor returns a cursor serving the given pages without Yamcs.
+func pagedCursor(t *testing.T, pages ...[]*pvalue.ParameterValue) *valueCursor {
+	manager, err := corehttp.NewHTTPManager("localhost:8090", corehttp.GetNoTLSConfiguration(), &corehttp.NoCredentials{}, "", false, false, nil)
+	require.NoError(t, err)
+	served := 0
+	iterator := types.NewPaginatedRequestIterator(manager, func(*corehttp.HTTPManager) ([]*pvalue.ParameterValue, string, error) {
+		page := pages[served]
+		served++
+		if served < len(pages) {
+			return page, "next", nil
+		}
+		return page, "", nil
+	})
+	return newCursor(context.Background(), iterator)
+}
+
+func TestExportValuesMergesByTime(t *testing.T) {
+	cursors := []*valueCursor{
+		pagedCursor(t, []*pvalue.ParameterValue{exportValue(0, 1), exportValue(2, 2)}, []*pvalue.ParameterValue{exportValue(4, 3)}),
+		pagedCursor(t, []*pvalue.ParameterValue{exportValue(1, 10.5), exportValue(3, 20)}),
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, exportValues([]string{"/a", "/b"}, cursors, export.CSV, &out))
+	assert.Equal(t, "time,parameter,value,number,rawValue,acquisitionStatus,monitoringResult\n"+
+		"2025-03-01T12:00:00Z,/a,1,1,,,\n"+
+		"2025-03-01T12:00:01Z,/b,10.5,10.5,,,\n"+
+		"2025-03-01T12:00:02Z,/a,2,2,,,\n"+
+		"2025-03-01T12:00:03Z,/b,20,20,,,\n"+
+		"2025-03-01T12:00:04Z,/a,3,3,,,\n", out.String())
+}
+
+func TestExportAlignedValues(t *testing.T) {
+	tests := []struct {
+		name      string
+		alignment ExportAlignment
+		interval  time.Duration
+		want      string
+	}{
+		{
+			name:      "exact",
+			alignment: AlignExact,
+			want: "time,/a,/b\n" +
+				"2025-03-01T12:00:00Z,1,\n" +
+				"2025-03-01T12:00:02Z,2,20\n" +
+				"2025-03-01T12:00:03Z,,30\n",
+		},
+		{
+			name:      "previous",
+			alignment: AlignPrevious,
+			want: "time,/a,/b\n" +
+				"2025-03-01T12:00:00Z,1,\n" +
+				"2025-03-01T12:00:02Z,2,20\n" +
+				"2025-03-01T12:00:03Z,2,30\n",
+		},
+		{
+			name:      "interval",
+			alignment: AlignInterval,
+			interval:  2 * time.Second,
+			want: "time,/a,/b\n" +
+				"2025-03-01T12:00:00Z,1,\n" +
+				"2025-03-01T12:00:02Z,2,30\n" +
+				"2025-03-01T12:00:04Z,2,30\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cursors := []*valueCursor{
+				pagedCursor(t, []*pvalue.ParameterValue{exportValue(0, 1)}, []*pvalue.ParameterValue{exportValue(2, 2)}),
+				pagedCursor(t, []*pvalue.ParameterValue{exportValue(2, 20), exportValue(3, 30)}),
+			}
+			parameters := ParameterExport{
+				Parameters: []string{"/a", "/b"},
+				Start:      exportStart,
+				End:        exportStart.Add(6 * time.Second),
+				Alignment:  tt.alignment,
+				Interval:   tt.interval,
+			}
+
+			var out bytes.Buffer
+			require.NoError(t, exportAlignedValues(parameters, cursors, export.CSV, &out))
+			assert.Equal(t, tt.want, out.String())
+		})
+	}
+}
+
+func TestCursorStopsWhenCancelled(t *testing.T) {
+	c := pagedCursor(t, []*pvalue.ParameterValue{exportValue(0, 1)}, []*pvalue.ParameterValue{exportValue(1, 2)})
+	ctx, cancel := context.WithCancel(context.Background())
+	c.ctx = ctx
+
+	_, ok, err := c.next()
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	cancel()
+	_, _, err = c.next()
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,128 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/archive"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/pvalue"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/tools"
+)
+
+// ParameterGaps returns the gaps of at least minGap in the history of
+// parameter between start and end. Values further apart than maxGap, or than
+// the expiration period of the parameter when maxGap is zero, leave a gap
+// between them. Gaps are found in the value ranges of the parameter and, when
+// the expiration is known, in runs of empty sample buckets among count.
+func (q *Querier) ParameterGaps(ctx context.Context, endpoint *YamcsEndpoint, parameter string, start, end time.Time, minGap, maxGap time.Duration, count int) ([]tools.Gap, error) {
+	ranges, err := q.parameterCoverage(ctx, endpoint, parameter, start, end, minGap, maxGap)
+	if err != nil {
+		return nil, err
+	}
+	gaps := tools.RangeGaps(ranges, start, end, minGap)
+
+	expiration := maxGap
+	if expiration == 0 {
+		expiration = endpoint.parameterExpiration(ctx, parameter)
+	}
+	if expiration > 0 {
+		samples, err := q.ParameterSamples(ctx, endpoint, parameter, start, end, count)
+		if err != nil {
+			return nil, err
+		}
+		gaps = tools.MergeGaps(gaps, tools.SampleGaps(samples, start, end, max(minGap, expiration)))
+	}
+	return gaps, nil
+}
+
+// parameterCoverage returns the value ranges of parameter between start and
+// end, split where consecutive values are further apart than maxGap, or than
+// the parameter expiration when maxGap is zero. Gaps shorter than minGap do
+// not split ranges. Unlike ParameterRanges, the window is not cached by block,
+// as joining blocks would hide the gaps at their boundaries.
+func (q *Querier) parameterCoverage(ctx context.Context, endpoint *YamcsEndpoint, parameter string, start, end time.Time, minGap, maxGap time.Duration) (*pvalue.Ranges, error) {
+	options := map[string]string{
+		"minGap":    fmt.Sprint(minGap.Milliseconds()),
+		"processor": endpoint.Processor.GetName(),
+	}
+	if maxGap > 0 {
+		options["maxGap"] = fmt.Sprint(maxGap.Milliseconds())
+	}
+	key := fmt.Sprintf("%s|coverage|%s|%v|%d|%d", endpoint.ID, parameter, options, start.UnixNano(), end.UnixNano())
+	return coalesced(ctx, q, key, func(ctx context.Context) (*pvalue.Ranges, error) {
+		yamcs := endpoint.GetClient().WithContext(ctx)
+		return yamcs.GetParameterRangesByQueryWithTimeByNames(endpoint.Instance.GetName(), parameter, options, start, end)
+	})
+}
+
+// parameterExpiration returns the expiration period the MDB sets on the values
+// of parameter, as carried by its current value, or zero when it is unknown.
+func (ep *YamcsEndpoint) parameterExpiration(ctx context.Context, parameter string) time.Duration {
+	value, err := ep.GetClient().WithContext(ctx).GetParameterValueByName(ep.Instance, ep.Processor, parameter)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(value.GetExpireMillis()) * time.Millisecond
+}
+
+// IndexGaps returns the gaps of at least minGap between start and end in the
+// groups of an archive index, client.CompletenessIndex or client.PacketIndex,
+// for which keep returns true. Index entries closer than mergeTime are
+// merged, which hides shorter gaps.
+func (q *Querier) IndexGaps(ctx context.Context, endpoint *YamcsEndpoint, index string, start, end time.Time, minGap, mergeTime time.Duration, keep func(id *protobuf.NamedObjectId) bool) ([]tools.GapSeries, error) {
+	key := fmt.Sprintf("%s|index|%s|%d|%d|%d", endpoint.ID, index, mergeTime.Milliseconds(), start.UnixNano(), end.UnixNano())
+	groups, err := coalesced(ctx, q, key, func(ctx context.Context) ([]*archive.IndexGroup, error) {
+		iterator := endpoint.GetClient().WithContext(ctx).ListArchiveIndex(endpoint.Instance, index, start, end, mergeTime)
+		result := []*archive.IndexGroup{}
+		for iterator.HasNext() {
+			page, err := iterator.Next()
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, page...)
+		}
+		return result, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// A group may span several pages.
+	order := []string{}
+	entries := map[string][]*archive.IndexEntry{}
+	for _, group := range groups {
+		if !keep(group.GetId()) {
+			continue
+		}
+		name := indexGroupName(group.GetId())
+		if _, seen := entries[name]; !seen {
+			order = append(order, name)
+		}
+		entries[name] = append(entries[name], group.GetEntry()...)
+	}
+	sort.Strings(order)
+
+	series := make([]tools.GapSeries, 0, len(order))
+	for _, name := range order {
+		list := entries[name]
+		sort.SliceStable(list, func(i, j int) bool { return list[i].GetStart() < list[j].GetStart() })
+		gaps, err := tools.IndexGaps(list, start, end, minGap)
+		if err != nil {
+			return nil, err
+		}
+		series = append(series, tools.GapSeries{Name: name, Gaps: gaps})
+	}
+	return series, nil
+}
+
+// indexGroupName names an index group after its namespace, such as a stream,
+// and its name, such as an APID or a packet name.
+func indexGroupName(id *protobuf.NamedObjectId) string {
+	if id.GetNamespace() == "" {
+		return id.GetName()
+	}
+	return id.GetNamespace() + "/" + id.GetName()
+}
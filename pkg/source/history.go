@@ -0,0 +1,542 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	yamcsprotobuf "github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/events"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/pvalue"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/config"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/exception"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/types"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/yamcs/client"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	defaultCacheMemoryMB  = 64
+	defaultSettleDuration = time.Minute
+
+	// blocksPerWindow is the number of aligned blocks a query window is split into.
+	// More blocks mean a smaller open edge to refetch but more requests on a miss.
+	blocksPerWindow = 8
+
+	// entryOverhead approximates the per-item bookkeeping of a cached slice on top
+	// of the protobuf wire size.
+	entryOverhead = 64
+
+	// maxHistoryValues bounds the raw values fetched for a single history query,
+	// as aggregate parameters cannot be downsampled by Yamcs.
+	maxHistoryValues = 100000
+)
+
+// blockDurations are the block sizes a window can be split into. Using a fixed
+// ladder keeps block boundaries stable while a relative time range slides.
+var blockDurations = []time.Duration{
+	time.Second, 2 * time.Second, 5 * time.Second, 10 * time.Second, 30 * time.Second,
+	time.Minute, 2 * time.Minute, 5 * time.Minute, 10 * time.Minute, 15 * time.Minute, 30 * time.Minute,
+	time.Hour, 2 * time.Hour, 3 * time.Hour, 6 * time.Hour, 12 * time.Hour,
+	24 * time.Hour, 2 * 24 * time.Hour, 7 * 24 * time.Hour, 14 * 24 * time.Hour, 28 * 24 * time.Hour,
+}
+
+// HistoryCache keeps the results of historical Yamcs queries for closed, aligned
+// time blocks, so that a dashboard refresh only refetches the open edge of its window.
+type HistoryCache struct {
+	entries *types.LRUCache[string, any]
+	settle  time.Duration
+	now     func() time.Time
+}
+
+// NewHistoryCache creates a cache from the plugin configuration. It returns nil,
+// which disables caching, when the configuration turns it off.
+func NewHistoryCache(cfg *config.HistoryCacheConfiguration) *HistoryCache {
+	memoryMB := defaultCacheMemoryMB
+	settle := defaultSettleDuration
+	if cfg != nil {
+		if cfg.Disabled {
+			return nil
+		}
+		if cfg.MaxMemoryMB > 0 {
+			memoryMB = cfg.MaxMemoryMB
+		}
+		if cfg.SettleSeconds > 0 {
+			settle = time.Duration(cfg.SettleSeconds) * time.Second
+		}
+	}
+	return &HistoryCache{
+		entries: types.NewLRUCache[string, any](int64(memoryMB) << 20),
+		settle:  settle,
+		now:     time.Now,
+	}
+}
+
+// Stats returns the hit, miss and memory counters of the cache.
+func (cache *HistoryCache) Stats() types.CacheStats {
+	if cache == nil {
+		return types.CacheStats{}
+	}
+	return cache.entries.Stats()
+}
+
+// InvalidateEndpoint drops every entry cached for the endpoint.
+func (cache *HistoryCache) InvalidateEndpoint(endpointID string) {
+	if cache == nil {
+		return
+	}
+	prefix := endpointID + "|"
+	cache.entries.RemoveIf(func(key string) bool {
+		return strings.HasPrefix(key, prefix)
+	})
+}
+
+// timeBlock is a half-open interval [Start, End) aligned on a multiple of its duration.
+type timeBlock struct {
+	Start time.Time
+	End   time.Time
+}
+
+// blockPlan describes how a query window is split into cacheable blocks.
+type blockPlan struct {
+	Duration time.Duration
+	// Closed are the blocks that ended before the settle horizon, oldest first.
+	Closed []timeBlock
+	// Open is the remainder of the window, always fetched from Yamcs. It is empty
+	// when the whole window is settled.
+	Open timeBlock
+}
+
+// blocks returns the blocks of the plan in the order cached returns them.
+func (p blockPlan) blocks() []timeBlock {
+	if p.Open.Start.IsZero() {
+		return p.Closed
+	}
+	return append(p.Closed[:len(p.Closed):len(p.Closed)], p.Open)
+}
+
+// planBlocks splits [start, end) into blocks aligned on the epoch. It returns false
+// when the window is too short to be worth caching.
+func planBlocks(start, end, settledBefore time.Time) (blockPlan, bool) {
+	window := end.Sub(start)
+	if window <= 0 {
+		return blockPlan{}, false
+	}
+
+	target := window / blocksPerWindow
+	duration := time.Duration(0)
+	for _, candidate := range blockDurations {
+		if candidate >= target {
+			duration = candidate
+			break
+		}
+	}
+	if duration == 0 || target < blockDurations[0] {
+		return blockPlan{}, false
+	}
+
+	plan := blockPlan{Duration: duration}
+	blockStart := start.Truncate(duration)
+	for blockStart.Before(end) {
+		blockEnd := blockStart.Add(duration)
+		if blockEnd.After(settledBefore) {
+			break
+		}
+		plan.Closed = append(plan.Closed, timeBlock{Start: blockStart, End: blockEnd})
+		blockStart = blockEnd
+	}
+	if blockStart.Before(end) {
+		plan.Open = timeBlock{Start: blockStart, End: end}
+	}
+	return plan, true
+}
+
+// blockCount scales the number of points requested for the whole window down to a block.
+func blockCount(count int, window time.Duration, block time.Duration) int {
+	if count <= 0 {
+		return 0
+	}
+	scaled := int((int64(count)*int64(block) + int64(window) - 1) / int64(window))
+	return max(scaled, 1)
+}
+
+// cached runs fetch for every block of the plan, serving closed blocks from the
+// cache when possible, and returns the per-block results in time order.
+// Concurrent fetches of the same block share a single upstream call.
+func cached[T any](ctx context.Context, q *Querier, plan blockPlan, key func(timeBlock) string, size func(T) int64, fetch func(context.Context, timeBlock) (T, error)) ([]T, error) {
+	results := make([]T, 0, len(plan.Closed)+1)
+	for _, block := range plan.Closed {
+		blockKey := key(block)
+		if value, hit := q.History.entries.Get(blockKey); hit {
+			results = append(results, value.(T))
+			continue
+		}
+		value, err := coalesced(ctx, q, blockKey, func(ctx context.Context) (T, error) {
+			value, err := fetch(ctx, block)
+			if err == nil {
+				q.History.entries.Put(blockKey, value, size(value))
+			}
+			return value, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, value)
+	}
+	if !plan.Open.Start.IsZero() {
+		value, err := coalesced(ctx, q, edgeKey(key, plan.Open), func(ctx context.Context) (T, error) {
+			return fetch(ctx, plan.Open)
+		})
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, value)
+	}
+	return results, nil
+}
+
+// coalesced runs fetch, sharing its result with identical concurrent calls. The
+// result may be handed to several callers and must not be modified.
+func coalesced[T any](ctx context.Context, q *Querier, key string, fetch func(context.Context) (T, error)) (T, error) {
+	value, err, _ := q.flights.Do(ctx, key, func(ctx context.Context) (any, error) {
+		return fetch(ctx)
+	})
+	if err != nil {
+		var zeroValue T
+		return zeroValue, err
+	}
+	return value.(T), nil
+}
+
+// edgeKey identifies a block whose end is not aligned, such as the open edge of a window.
+func edgeKey(key func(timeBlock) string, block timeBlock) string {
+	return fmt.Sprintf("%s|%d", key(block), block.End.UnixNano())
+}
+
+func messagesSize[M proto.Message](messages []M) int64 {
+	size := int64(0)
+	for _, message := range messages {
+		size += int64(proto.Size(message)) + entryOverhead
+	}
+	return size
+}
+
+// ParameterSamples returns count aggregated samples of parameter (including any
+// aggregate path) between start and end. Settled blocks of the window are served
+// from the history cache.
+func (q *Querier) ParameterSamples(ctx context.Context, endpoint *YamcsEndpoint, parameter string, start, end time.Time, count int) ([]client.Sample, error) {
+	plan, ok := q.plan(start, end)
+	perBlock := count
+	if ok {
+		perBlock = blockCount(count, end.Sub(start), plan.Duration)
+	}
+	key := func(block timeBlock) string {
+		return fmt.Sprintf("%s|samples|%s|%d|%d|%d", endpoint.ID, parameter, perBlock, plan.Duration, block.Start.UnixNano())
+	}
+	fetch := func(ctx context.Context, block timeBlock) ([]client.Sample, error) {
+		yamcs := endpoint.GetClient().WithContext(ctx)
+		if perBlock > 0 {
+			yamcs.SetSamplePointCount(perBlock)
+		}
+		return yamcs.GetParameterSamplesInProcessorByNames(endpoint.Instance.GetName(), endpoint.Processor.GetName(), parameter, block.Start, block.End)
+	}
+
+	if !ok {
+		window := timeBlock{Start: start, End: end}
+		return coalesced(ctx, q, edgeKey(key, window), func(ctx context.Context) ([]client.Sample, error) {
+			return fetch(ctx, window)
+		})
+	}
+
+	blocks, err := cached(ctx, q, plan, key, messagesSize[client.Sample], fetch)
+	if err != nil {
+		return nil, err
+	}
+
+	samples := make([]client.Sample, 0)
+	for _, block := range blocks {
+		for _, sample := range block {
+			t := sample.GetTime().AsTime()
+			if t.Before(start) || !t.Before(end) {
+				continue
+			}
+			samples = append(samples, sample)
+		}
+	}
+	return samples, nil
+}
+
+// ParameterHistory returns the raw values of parameter between start and end,
+// oldest first. Unlike samples, raw values keep whole aggregates and arrays; they
+// are thinned out to about count values when count is positive.
+func (q *Querier) ParameterHistory(ctx context.Context, endpoint *YamcsEndpoint, parameter string, start, end time.Time, count int) ([]*pvalue.ParameterValue, error) {
+	key := fmt.Sprintf("%s|history|%s|%d|%d|%d", endpoint.ID, parameter, count, start.UnixNano(), end.UnixNano())
+	return coalesced(ctx, q, key, func(ctx context.Context) ([]*pvalue.ParameterValue, error) {
+		values, err := latestParameterValues(ctx, endpoint, parameter, start, end, maxHistoryValues)
+		if err != nil {
+			return nil, err
+		}
+		if count <= 0 || len(values) <= count {
+			return values, nil
+		}
+		stride := (len(values) + count - 1) / count
+		thinned := make([]*pvalue.ParameterValue, 0, count)
+		for i := 0; i < len(values); i += stride {
+			thinned = append(thinned, values[i])
+		}
+		return thinned, nil
+	})
+}
+
+// AllParameterValues returns every raw value of parameter between start and
+// end, oldest first. It fails rather than return part of the window when the
+// window holds more than maxHistoryValues values.
+func (q *Querier) AllParameterValues(ctx context.Context, endpoint *YamcsEndpoint, parameter string, start, end time.Time) ([]*pvalue.ParameterValue, error) {
+	key := fmt.Sprintf("%s|all|%s|%d|%d", endpoint.ID, parameter, start.UnixNano(), end.UnixNano())
+	return coalesced(ctx, q, key, func(ctx context.Context) ([]*pvalue.ParameterValue, error) {
+		values, err := latestParameterValues(ctx, endpoint, parameter, start, end, maxHistoryValues+1)
+		if err != nil {
+			return nil, err
+		}
+		if len(values) > maxHistoryValues {
+			return nil, exception.New(fmt.Sprintf("More than %d values of %s in the time range", maxHistoryValues, parameter), "TOO_MANY_VALUES")
+		}
+		return values, nil
+	})
+}
+
+// RecentParameterValues returns the last count raw values of parameter between
+// start and end, oldest first.
+func (q *Querier) RecentParameterValues(ctx context.Context, endpoint *YamcsEndpoint, parameter string, start, end time.Time, count int) ([]*pvalue.ParameterValue, error) {
+	key := fmt.Sprintf("%s|recent|%s|%d|%d|%d", endpoint.ID, parameter, count, start.UnixNano(), end.UnixNano())
+	return coalesced(ctx, q, key, func(ctx context.Context) ([]*pvalue.ParameterValue, error) {
+		return latestParameterValues(ctx, endpoint, parameter, start, end, count)
+	})
+}
+
+// latestParameterValues pages through the archived values of parameter, newest
+// first, until limit values are read, and returns them oldest first.
+func latestParameterValues(ctx context.Context, endpoint *YamcsEndpoint, parameter string, start, end time.Time, limit int) ([]*pvalue.ParameterValue, error) {
+	yamcs := endpoint.GetClient().WithContext(ctx)
+	iterator := yamcs.ListParameterHistoryByName(endpoint.Instance, parameter, start, end)
+	values := []*pvalue.ParameterValue{}
+	for iterator.HasNext() && len(values) < limit {
+		page, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, page...)
+	}
+	if len(values) > limit {
+		values = values[:limit]
+	}
+
+	for i, j := 0, len(values)-1; i < j; i, j = i+1, j-1 {
+		values[i], values[j] = values[j], values[i]
+	}
+	return values, nil
+}
+
+// ParameterRanges returns the value ranges of parameter between start and end,
+// ignoring value changes shorter than minRange. Ranges cut at a block boundary
+// are joined back together.
+func (q *Querier) ParameterRanges(ctx context.Context, endpoint *YamcsEndpoint, parameter string, start, end time.Time, minRange time.Duration) (*pvalue.Ranges, error) {
+	plan, ok := q.plan(start, end)
+	key := func(block timeBlock) string {
+		return fmt.Sprintf("%s|ranges|%s|%d|%d|%d", endpoint.ID, parameter, minRange.Milliseconds(), plan.Duration, block.Start.UnixNano())
+	}
+	fetch := func(ctx context.Context, block timeBlock) ([]*pvalue.Ranges_Range, error) {
+		yamcs := endpoint.GetClient().WithContext(ctx)
+		ranges, err := yamcs.GetParameterRangesByQueryWithTimeByNames(
+			endpoint.Instance.GetName(),
+			parameter,
+			map[string]string{
+				"minRange":  fmt.Sprint(minRange.Milliseconds()),
+				"processor": endpoint.Processor.GetName(),
+			},
+			block.Start,
+			block.End,
+		)
+		if err != nil {
+			return nil, err
+		}
+		return ranges.GetRange(), nil
+	}
+
+	if !ok {
+		window := timeBlock{Start: start, End: end}
+		blockRanges, err := coalesced(ctx, q, edgeKey(key, window), func(ctx context.Context) ([]*pvalue.Ranges_Range, error) {
+			return fetch(ctx, window)
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &pvalue.Ranges{Range: blockRanges}, nil
+	}
+
+	blocks, err := cached(ctx, q, plan, key, messagesSize[*pvalue.Ranges_Range], fetch)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &pvalue.Ranges{}
+	boundaries := plan.blocks()
+	for i, block := range blocks {
+		boundary := boundaries[i].Start
+		for j, r := range block {
+			if r.GetStop().AsTime().Before(start) || !r.GetStart().AsTime().Before(end) {
+				continue
+			}
+			if j > 0 {
+				boundary = time.Time{}
+			}
+			result.Range = appendRange(result.Range, r, boundary)
+		}
+	}
+	return result, nil
+}
+
+// appendRange appends r to ranges, merging it into the last range when both hold
+// the same values and the last range was cut at boundary, the start of the block
+// r comes from. Yamcs ends a block's last range on its last sample and starts the
+// next block's first range on the following one, so the ranges are joined when
+// the hole between them is no longer than their sample spacing; a longer hole is
+// a real gap and both ranges are kept. A zero boundary never merges. Cached
+// ranges are never modified: the last range is cloned before being extended.
+func appendRange(ranges []*pvalue.Ranges_Range, r *pvalue.Ranges_Range, boundary time.Time) []*pvalue.Ranges_Range {
+	if len(ranges) == 0 {
+		return append(ranges, r)
+	}
+	last := ranges[len(ranges)-1]
+	if boundary.IsZero() || !sameRangeValues(last, r) || !cutAt(last, r, boundary) {
+		return append(ranges, r)
+	}
+
+	merged := proto.Clone(last).(*pvalue.Ranges_Range)
+	merged.Stop = r.GetStop()
+	merged.Count = proto.Int32(last.GetCount() + r.GetCount())
+	merged.Counts = nil
+	for i, value := range last.GetEngValues() {
+		merged.Counts = append(merged.Counts, rangeCount(last, i)+valueCount(r, value))
+	}
+	ranges[len(ranges)-1] = merged
+	return ranges
+}
+
+// cutAt tells whether a and b are the two halves of a range cut at boundary: a
+// stops before the boundary, b starts after it, and the hole between them is
+// not longer than the sample spacing of either.
+func cutAt(a, b *pvalue.Ranges_Range, boundary time.Time) bool {
+	stop, start := a.GetStop().AsTime(), b.GetStart().AsTime()
+	if stop.After(boundary) || start.Before(boundary) {
+		return false
+	}
+	hole := start.Sub(stop)
+	return hole <= sampleSpacing(a) || hole <= sampleSpacing(b)
+}
+
+// sampleSpacing returns the average time between the samples of r, or zero
+// when r holds a single sample.
+func sampleSpacing(r *pvalue.Ranges_Range) time.Duration {
+	if r.GetCount() < 2 {
+		return 0
+	}
+	return r.GetStop().AsTime().Sub(r.GetStart().AsTime()) / time.Duration(r.GetCount()-1)
+}
+
+// sameRangeValues tells whether a and b hold the same set of values, in any
+// order.
+func sameRangeValues(a, b *pvalue.Ranges_Range) bool {
+	if len(a.GetEngValues()) == 0 || len(a.GetEngValues()) != len(b.GetEngValues()) {
+		return false
+	}
+	for _, value := range a.GetEngValues() {
+		if valueIndex(b, value) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func valueIndex(r *pvalue.Ranges_Range, value *yamcsprotobuf.Value) int {
+	for i, v := range r.GetEngValues() {
+		if proto.Equal(v, value) {
+			return i
+		}
+	}
+	return -1
+}
+
+// rangeCount returns the number of samples of the i-th value of r. A range with
+// a single value may leave its counts out.
+func rangeCount(r *pvalue.Ranges_Range, i int) int32 {
+	if i < len(r.GetCounts()) {
+		return r.GetCounts()[i]
+	}
+	if len(r.GetEngValues()) == 1 {
+		return r.GetCount()
+	}
+	return 0
+}
+
+func valueCount(r *pvalue.Ranges_Range, value *yamcsprotobuf.Value) int32 {
+	if i := valueIndex(r, value); i >= 0 {
+		return rangeCount(r, i)
+	}
+	return 0
+}
+
+// Events returns the archived events between start and end.
+func (q *Querier) Events(ctx context.Context, endpoint *YamcsEndpoint, start, end time.Time) ([]*events.Event, error) {
+	plan, ok := q.plan(start, end)
+	key := func(block timeBlock) string {
+		return fmt.Sprintf("%s|events|%d|%d", endpoint.ID, plan.Duration, block.Start.UnixNano())
+	}
+	fetch := func(ctx context.Context, block timeBlock) ([]*events.Event, error) {
+		yamcs := endpoint.GetClient().WithContext(ctx)
+		iterator := yamcs.ListEventsWithinTimeRange(endpoint.Instance, block.Start, block.End)
+		result := []*events.Event{}
+		for iterator.HasNext() {
+			page, err := iterator.Next()
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, page...)
+		}
+		return result, nil
+	}
+
+	if !ok {
+		window := timeBlock{Start: start, End: end}
+		return coalesced(ctx, q, edgeKey(key, window), func(ctx context.Context) ([]*events.Event, error) {
+			return fetch(ctx, window)
+		})
+	}
+
+	blocks, err := cached(ctx, q, plan, key, messagesSize[*events.Event], fetch)
+	if err != nil {
+		return nil, err
+	}
+
+	// Yamcs lists events newest first: keep that order across blocks.
+	result := []*events.Event{}
+	for i := len(blocks) - 1; i >= 0; i-- {
+		for _, event := range blocks[i] {
+			t := event.GetGenerationTime().AsTime()
+			if t.Before(start) || !t.Before(end) {
+				continue
+			}
+			result = append(result, event)
+		}
+	}
+	return result, nil
+}
+
+// plan splits the window into blocks if the history cache is enabled.
+func (q *Querier) plan(start, end time.Time) (blockPlan, bool) {
+	if q.History == nil {
+		return blockPlan{}, false
+	}
+	return planBlocks(start, end, q.History.now().Add(-q.History.settle))
+}
@@ -0,0 +1,110 @@
+package source
+
+import (
+	"testing"
+	"time"
+
+	yamcsprotobuf "github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/pvalue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestPlanBlocks(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 7, 30, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		window       time.Duration
+		wantOK       bool
+		wantDuration time.Duration
+		wantClosed   int
+	}{
+		{name: "too short", window: 4 * time.Second, wantOK: false},
+		{name: "last hour", window: time.Hour, wantOK: true, wantDuration: 10 * time.Minute, wantClosed: 6},
+		{name: "last 6 hours", window: 6 * time.Hour, wantOK: true, wantDuration: time.Hour, wantClosed: 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := now.Add(-tt.window)
+			plan, ok := planBlocks(start, now, now.Add(-time.Minute))
+			require.Equal(t, tt.wantOK, ok)
+			if !ok {
+				return
+			}
+			assert.Equal(t, tt.wantDuration, plan.Duration)
+			assert.Len(t, plan.Closed, tt.wantClosed)
+
+			// Blocks are aligned, contiguous and end with the open edge.
+			assert.True(t, plan.Closed[0].Start.Equal(start.Truncate(tt.wantDuration)))
+			for i := 1; i < len(plan.Closed); i++ {
+				assert.True(t, plan.Closed[i].Start.Equal(plan.Closed[i-1].End))
+			}
+			assert.True(t, plan.Open.Start.Equal(plan.Closed[len(plan.Closed)-1].End))
+			assert.True(t, plan.Open.End.Equal(now))
+		})
+	}
+}
+
+func TestPlanBlocksStableWhileSliding(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 7, 30, 0, time.UTC)
+	first, _ := planBlocks(now.Add(-time.Hour), now, now.Add(-time.Minute))
+	later := now.Add(15 * time.Second)
+	second, _ := planBlocks(later.Add(-time.Hour), later, later.Add(-time.Minute))
+
+	assert.Equal(t, first.Closed, second.Closed)
+}
+
+func TestBlockCount(t *testing.T) {
+	assert.Equal(t, 125, blockCount(1000, 8*time.Hour, time.Hour))
+	assert.Equal(t, 1, blockCount(3, time.Hour, time.Second))
+	assert.Equal(t, 0, blockCount(0, time.Hour, time.Minute))
+}
+
+func TestAppendRangeMergesAcrossBlocks(t *testing.T) {
+	value := func(s string) *yamcsprotobuf.Value {
+		return &yamcsprotobuf.Value{Type: yamcsprotobuf.Value_STRING.Enum(), StringValue: proto.String(s)}
+	}
+	at := func(minutes int) *timestamppb.Timestamp {
+		return timestamppb.New(time.Date(2025, 3, 1, 12, minutes, 0, 0, time.UTC))
+	}
+
+	cachedRange := &pvalue.Ranges_Range{Start: at(0), Stop: at(9), Count: proto.Int32(5), EngValues: []*yamcsprotobuf.Value{value("ON")}, Counts: []int32{5}}
+	ranges := appendRange(nil, cachedRange, time.Time{})
+	ranges = appendRange(ranges, &pvalue.Ranges_Range{Start: at(10), Stop: at(12), Count: proto.Int32(2), EngValues: []*yamcsprotobuf.Value{value("ON")}, Counts: []int32{2}}, at(10).AsTime())
+	ranges = appendRange(ranges, &pvalue.Ranges_Range{Start: at(12), Stop: at(15), Count: proto.Int32(1), EngValues: []*yamcsprotobuf.Value{value("OFF")}, Counts: []int32{1}}, time.Time{})
+
+	require.Len(t, ranges, 2)
+	assert.Equal(t, int32(7), ranges[0].GetCount())
+	assert.Equal(t, []int32{7}, ranges[0].GetCounts())
+	assert.True(t, ranges[0].GetStop().AsTime().Equal(at(12).AsTime()))
+
+	// The cached range itself is left untouched.
+	assert.Equal(t, int32(5), cachedRange.GetCount())
+
+	// Counts are matched by value, whatever their order.
+	mixed := appendRange(nil, &pvalue.Ranges_Range{Start: at(0), Stop: at(9), Count: proto.Int32(10), EngValues: []*yamcsprotobuf.Value{value("ON"), value("OFF")}, Counts: []int32{6, 4}}, time.Time{})
+	mixed = appendRange(mixed, &pvalue.Ranges_Range{Start: at(10), Stop: at(12), Count: proto.Int32(3), EngValues: []*yamcsprotobuf.Value{value("OFF"), value("ON")}, Counts: []int32{1, 2}}, at(10).AsTime())
+	require.Len(t, mixed, 1)
+	assert.Equal(t, []int32{8, 5}, mixed[0].GetCounts())
+}
+
+func TestAppendRangeKeepsGaps(t *testing.T) {
+	on := []*yamcsprotobuf.Value{{Type: yamcsprotobuf.Value_STRING.Enum(), StringValue: proto.String("ON")}}
+	at := func(minutes int) *timestamppb.Timestamp {
+		return timestamppb.New(time.Date(2025, 3, 1, 12, minutes, 0, 0, time.UTC))
+	}
+
+	// The data stops well before the block boundary: the hole is a real gap.
+	ranges := appendRange(nil, &pvalue.Ranges_Range{Start: at(0), Stop: at(4), Count: proto.Int32(5), EngValues: on, Counts: []int32{5}}, time.Time{})
+	ranges = appendRange(ranges, &pvalue.Ranges_Range{Start: at(10), Stop: at(14), Count: proto.Int32(5), EngValues: on, Counts: []int32{5}}, at(10).AsTime())
+	assert.Len(t, ranges, 2)
+
+	// Ranges with the same value within a block are kept apart too.
+	ranges = appendRange(nil, &pvalue.Ranges_Range{Start: at(0), Stop: at(4), Count: proto.Int32(5), EngValues: on, Counts: []int32{5}}, time.Time{})
+	ranges = appendRange(ranges, &pvalue.Ranges_Range{Start: at(5), Stop: at(9), Count: proto.Int32(5), EngValues: on, Counts: []int32{5}}, time.Time{})
+	assert.Len(t, ranges, 2)
+}
@@ -0,0 +1,104 @@
+package source
+
+import (
+	"context"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/mdb"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/processing"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/tools"
+)
+
+// GetParameterInfo returns the MDB definition of a parameter. Definitions are
+// cached per endpoint until the MDB of its processor changes.
+func (ep *YamcsEndpoint) GetParameterInfo(ctx context.Context, parameter string) (*mdb.ParameterInfo, error) {
+	ep.infoMu.Lock()
+	info, cached := ep.parameterInfo[parameter]
+	ep.infoMu.Unlock()
+	if cached {
+		return info, nil
+	}
+
+	info, err := ep.GetClient().WithContext(ctx).GetParameter(ep.Instance, parameter)
+	if err != nil {
+		return nil, err
+	}
+
+	ep.infoMu.Lock()
+	if ep.parameterInfo == nil {
+		ep.parameterInfo = make(map[string]*mdb.ParameterInfo)
+	}
+	ep.parameterInfo[parameter] = info
+	ep.infoMu.Unlock()
+	return info, nil
+}
+
+// GetParameterMetadata returns the presentation metadata of a parameter, or of
+// its member at aggregatePath.
+func (ep *YamcsEndpoint) GetParameterMetadata(ctx context.Context, parameter string, aggregatePath string) (tools.ParameterMetadata, error) {
+	info, err := ep.GetParameterInfo(ctx, parameter)
+	if err != nil {
+		return tools.ParameterMetadata{}, err
+	}
+	return tools.ConvertParameterInfoToMetadata(info, aggregatePath), nil
+}
+
+// InvalidateParameterInfo drops the cached definitions of the given parameters,
+// or of every parameter if none is given, and refreshes the demands using them.
+func (ep *YamcsEndpoint) InvalidateParameterInfo(parameters ...string) {
+	ep.infoMu.Lock()
+	if len(parameters) == 0 {
+		for parameter := range ep.parameterInfo {
+			parameters = append(parameters, parameter)
+		}
+		ep.parameterInfo = nil
+	} else {
+		for _, parameter := range parameters {
+			delete(ep.parameterInfo, parameter)
+		}
+	}
+	ep.infoMu.Unlock()
+
+	for _, parameter := range parameters {
+		demand := ep.Parameters[parameter]
+		if demand == nil {
+			continue
+		}
+		info, err := ep.GetParameterInfo(ep.GetClient().Context(), parameter)
+		if err != nil {
+			backend.Logger.Warn("Failed to refresh parameter definition", "parameter", parameter, "error", err)
+			continue
+		}
+		demand.applyInfo(info)
+	}
+}
+
+// GetMdbChangeListener returns a function invalidating the parameter definitions
+// affected by an MDB override.
+func (ep *YamcsEndpoint) GetMdbChangeListener() func(change *processing.MdbOverrideInfo) {
+	return func(change *processing.MdbOverrideInfo) {
+		parameters := []string{}
+		if parameter := change.GetParameterOverride().GetParameter(); parameter != "" {
+			parameters = append(parameters, parameter)
+		}
+		backend.Logger.Debug("MDB changed", "endpoint", ep.ID, "type", change.GetType(), "parameters", parameters)
+		// Refreshing the definitions calls Yamcs: keep it off the WebSocket reader.
+		go ep.InvalidateParameterInfo(parameters...)
+	}
+}
+
+// applyInfo updates the demand with a parameter definition.
+func (demand *ParameterDemand) applyInfo(info *mdb.ParameterInfo) {
+	paramType := info.GetType()
+	demand.Info = info
+	demand.Type = paramType
+	demand.Thresholds = tools.ConvertAlarmInfoToThresholds(paramType.GetDefaultAlarm())
+	if demand.Thresholds == nil {
+		demand.Thresholds = make([]*data.Threshold, 0)
+	}
+	demand.Unit = ""
+	if unitSet := paramType.GetUnitSet(); len(unitSet) > 0 {
+		demand.Unit = unitSet[0].GetUnit()
+	}
+}
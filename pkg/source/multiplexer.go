@@ -10,6 +10,7 @@ import (
 	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/commanding"
 	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/events"
 	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/links"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/processing"
 	"github.com/jaops-space/grafana-yamcs-jaops/pkg/config"
 	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/exception"
 	"github.com/jaops-space/grafana-yamcs-jaops/pkg/yamcs/client"
@@ -84,6 +85,7 @@ func (mux *Multiplexer) GetEndpoint(endpointID string) (*YamcsEndpoint, error) {
 			Events:         make(map[string][]*events.Event),
 			CommandHistory: make(map[string][]*commanding.CommandHistoryEntry),
 			CommandSignals: make(map[string]chan struct{}),
+			CommandFilters: make(map[string]*CommandHistoryFilter),
 			Alarms:         make(map[string][]*alarms.AlarmData),
 			AlarmSignals:   make(map[string]chan struct{}),
 			Links:          make(map[string][]*links.LinkInfo),
@@ -99,6 +101,10 @@ func (mux *Multiplexer) GetEndpoint(endpointID string) (*YamcsEndpoint, error) {
 	if err := mux.ensureProcessorUpdatesSubscription(yamcsClient, endpoint); err != nil {
 		return nil, err
 	}
+	if err := mux.ensureMdbChangesSubscription(yamcsClient, endpoint); err != nil {
+		// Definitions are still served, they just go stale on MDB overrides.
+		backend.Logger.Warn("Failed to subscribe to MDB changes", "endpoint", endpointID, "error", err)
+	}
 
 	// subscribe once per (instance, processor)
 	subscriptionExists := false
@@ -144,6 +150,49 @@ func (mux *Multiplexer) ensureProcessorUpdatesSubscription(yamcsClient *client.Y
 	return nil
 }
 
+// ensureMdbChangesSubscription keeps the cached parameter definitions of the
+// endpoint in line with the MDB of its processor.
+func (mux *Multiplexer) ensureMdbChangesSubscription(yamcsClient *client.YamcsClient, endpoint *YamcsEndpoint) error {
+	for _, subscription := range yamcsClient.MdbChangeSubscriptions {
+		if subscription.Instance == endpoint.Instance.GetName() && subscription.Processor == endpoint.Processor.GetName() {
+			return nil
+		}
+	}
+
+	subscription, err := yamcsClient.CreateMdbChangeSubscription(endpoint.Instance, endpoint.Processor)
+	if err != nil {
+		return err
+	}
+	subscription.SetListener(func(change *processing.MdbOverrideInfo) {
+		for _, listening := range mux.endpointsOf(subscription.Instance, subscription.Processor) {
+			listening.GetMdbChangeListener()(change)
+		}
+	})
+
+	// A new subscription means a new connection: the MDB may have changed while
+	// the plugin was not listening.
+	for _, listening := range mux.Endpoints {
+		if listening.Instance.GetName() == subscription.Instance && listening.Processor.GetName() == subscription.Processor {
+			go listening.InvalidateParameterInfo()
+		}
+	}
+	return nil
+}
+
+// endpointsOf returns the endpoints bound to a processor.
+func (mux *Multiplexer) endpointsOf(instanceName string, processorName string) []*YamcsEndpoint {
+	mux.SyncMux.Lock()
+	defer mux.SyncMux.Unlock()
+
+	endpoints := []*YamcsEndpoint{}
+	for _, endpoint := range mux.Endpoints {
+		if endpoint.Instance.GetName() == instanceName && endpoint.Processor.GetName() == processorName {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	return endpoints
+}
+
 // GetProcessorListener updates processor snapshots and keeps endpoint processor references current.
 func (mux *Multiplexer) GetProcessorListener(instance client.Instance, processor client.Processor) func(update client.Processor) {
 	instanceName := instance.GetName()
@@ -208,15 +257,14 @@ func (mux *Multiplexer) GetEventListener(instance client.Instance) func(event *e
 	}
 }
 
-// GetCommandHistoryListener returns a function that listens for command history entries.
-func (mux *Multiplexer) GetCommandHistoryListener(instance client.Instance) func(entry *commanding.CommandHistoryEntry) {
+// GetCommandHistoryListener returns a function that listens for command history
+// entries of a processor, and buffers them for the streams of the endpoints on
+// that processor whose filter keeps them.
+func (mux *Multiplexer) GetCommandHistoryListener(instance client.Instance, processor client.Processor) func(entry *commanding.CommandHistoryEntry) {
 	return func(entry *commanding.CommandHistoryEntry) {
 		for _, dataSource := range mux.Endpoints {
-			if dataSource.Instance.GetName() == instance.GetName() {
-				for path := range dataSource.CommandHistory {
-					dataSource.CommandHistory[path] = append(dataSource.CommandHistory[path], entry)
-					dataSource.NotifyCommandHistoryStream(path)
-				}
+			if dataSource.Instance.GetName() == instance.GetName() && dataSource.Processor.GetName() == processor.GetName() {
+				dataSource.appendCommandHistory(entry)
 			}
 		}
 	}
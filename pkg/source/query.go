@@ -5,6 +5,7 @@ import (
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/jaops-space/grafana-yamcs-jaops/pkg/config"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/types"
 	"github.com/jaops-space/grafana-yamcs-jaops/pkg/yamcs/client"
 )
 
@@ -40,12 +41,20 @@ type TelemetryPoint struct {
 // Querier orchestrates queries across Yamcs live data.
 type Querier struct {
 	endpoints map[string]*config.YamcsEndpointConfiguration
+
+	// History caches settled historical query results; nil disables caching.
+	History *HistoryCache
+
+	// flights coalesces identical historical queries running concurrently.
+	flights *types.FlightGroup[any]
 }
 
 // New creates a new Querier instance.
-func New(endpoints map[string]*config.YamcsEndpointConfiguration) *Querier {
+func New(endpoints map[string]*config.YamcsEndpointConfiguration, cache *config.HistoryCacheConfiguration) *Querier {
 	return &Querier{
 		endpoints: endpoints,
+		History:   NewHistoryCache(cache),
+		flights:   types.NewFlightGroup[any](),
 	}
 }
 
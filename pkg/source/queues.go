@@ -0,0 +1,104 @@
+package source
+
+import (
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/commanding"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/tools"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/yamcs/client"
+)
+
+// queueTopics are the WebSocket topics command queue streams listen to.
+var queueTopics = []string{client.QueueStatisticsTopic, client.QueueEventsTopic}
+
+// RequestQueuesStream starts following the command queues of the endpoint
+// processor for the stream at path. The returned queues are empty until they
+// are reset from the queue list; updates received meanwhile are only kept for
+// queues already known.
+func (ep *YamcsEndpoint) RequestQueuesStream(path string) (*tools.CommandQueues, error) {
+	if err := ep.ensureQueueSubscriptions(); err != nil {
+		return nil, err
+	}
+	ep.queuesMu.Lock()
+	defer ep.queuesMu.Unlock()
+	if ep.queueStreams == nil {
+		ep.queueStreams = map[string]*tools.CommandQueues{}
+	}
+	queues := tools.NewCommandQueues()
+	ep.queueStreams[path] = queues
+	return queues, nil
+}
+
+func (ep *YamcsEndpoint) ensureQueueSubscriptions() error {
+	c := ep.GetClient()
+	for _, topic := range queueTopics {
+		if ep.queueSubscription(c, topic) != nil {
+			continue
+		}
+		subscription, err := c.CreateQueueSubscription(ep.Instance, ep.Processor, topic)
+		if err != nil {
+			return err
+		}
+		subscription.SetStatisticsListener(ep.Multiplexer.GetQueueStatisticsListener(ep.Instance, ep.Processor))
+		subscription.SetEventListener(ep.Multiplexer.GetQueueEventListener(ep.Instance, ep.Processor))
+	}
+	return nil
+}
+
+func (ep *YamcsEndpoint) queueSubscription(c *client.YamcsClient, topic string) *client.QueueSubscription {
+	for _, subscription := range c.QueueSubscriptions {
+		if subscription.Topic == topic && subscription.Instance == ep.Instance.GetName() && subscription.Processor == ep.Processor.GetName() {
+			return subscription
+		}
+	}
+	return nil
+}
+
+// WithdrawQueuesStreamRequest stops following the queues for the stream at
+// path, and cancels the subscriptions once no stream is left.
+func (ep *YamcsEndpoint) WithdrawQueuesStreamRequest(path string) {
+	ep.queuesMu.Lock()
+	delete(ep.queueStreams, path)
+	remaining := len(ep.queueStreams)
+	ep.queuesMu.Unlock()
+	if remaining > 0 {
+		return
+	}
+	c := ep.GetClient()
+	for _, topic := range queueTopics {
+		if subscription := ep.queueSubscription(c, topic); subscription != nil {
+			subscription.Halt()
+		}
+	}
+}
+
+// eachQueueStream calls apply on the queues of every stream of the endpoint.
+func (ep *YamcsEndpoint) eachQueueStream(apply func(queues *tools.CommandQueues)) {
+	ep.queuesMu.Lock()
+	defer ep.queuesMu.Unlock()
+	for _, queues := range ep.queueStreams {
+		apply(queues)
+	}
+}
+
+// GetQueueStatisticsListener returns a function that applies queue statistics
+// to the queue streams of the endpoints on a processor.
+func (mux *Multiplexer) GetQueueStatisticsListener(instance client.Instance, processor client.Processor) func(info *commanding.CommandQueueInfo) {
+	return func(info *commanding.CommandQueueInfo) {
+		for _, endpoint := range mux.Endpoints {
+			if endpoint.Instance.GetName() == instance.GetName() && endpoint.Processor.GetName() == processor.GetName() {
+				endpoint.eachQueueStream(func(queues *tools.CommandQueues) { queues.ApplyInfo(info) })
+			}
+		}
+	}
+}
+
+// GetQueueEventListener returns a function that applies queue events to the
+// queue streams of the endpoints on a processor.
+func (mux *Multiplexer) GetQueueEventListener(instance client.Instance, processor client.Processor) func(event *commanding.CommandQueueEvent) {
+	return func(event *commanding.CommandQueueEvent) {
+		for _, endpoint := range mux.Endpoints {
+			if endpoint.Instance.GetName() == instance.GetName() && endpoint.Processor.GetName() == processor.GetName() {
+				endpoint.eachQueueStream(func(queues *tools.CommandQueues) { queues.ApplyEvent(event) })
+			}
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package source
+
+import (
+	"fmt"
+
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/activities"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/timeline"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/tools"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/yamcs/client"
+)
+
+// RequestScheduledCommandsStream starts following the command activities of
+// the endpoint instance for the stream at path. The returned commands are
+// empty until they are reset from the timeline.
+func (ep *YamcsEndpoint) RequestScheduledCommandsStream(path string) (*tools.ScheduledCommands, error) {
+	if ep.activitySubscription(ep.GetClient()) == nil {
+		subscription, err := ep.GetClient().CreateActivitySubscription(ep.Instance)
+		if err != nil {
+			return nil, err
+		}
+		subscription.SetListener(ep.Multiplexer.GetActivityListener(ep.Instance))
+	}
+	ep.scheduleMu.Lock()
+	defer ep.scheduleMu.Unlock()
+	if ep.scheduleStreams == nil {
+		ep.scheduleStreams = map[string]*tools.ScheduledCommands{}
+	}
+	commands := tools.NewScheduledCommands()
+	ep.scheduleStreams[path] = commands
+	return commands, nil
+}
+
+func (ep *YamcsEndpoint) activitySubscription(c *client.YamcsClient) *client.ActivitySubscription {
+	for _, subscription := range c.ActivitySubscriptions {
+		if subscription.Instance == ep.Instance.GetName() {
+			return subscription
+		}
+	}
+	return nil
+}
+
+// WithdrawScheduledCommandsStreamRequest stops following the command
+// activities for the stream at path, and cancels the subscription once no
+// stream of the instance is left.
+func (ep *YamcsEndpoint) WithdrawScheduledCommandsStreamRequest(path string) {
+	ep.scheduleMu.Lock()
+	delete(ep.scheduleStreams, path)
+	ep.scheduleMu.Unlock()
+	for _, endpoint := range ep.Multiplexer.Endpoints {
+		if endpoint.GetClient() == ep.GetClient() && endpoint.Instance.GetName() == ep.Instance.GetName() && endpoint.hasScheduleStreams() {
+			return
+		}
+	}
+	if subscription := ep.activitySubscription(ep.GetClient()); subscription != nil {
+		subscription.Halt()
+	}
+}
+
+func (ep *YamcsEndpoint) hasScheduleStreams() bool {
+	ep.scheduleMu.Lock()
+	defer ep.scheduleMu.Unlock()
+	return len(ep.scheduleStreams) > 0
+}
+
+// GetActivityListener returns a function that applies activity updates to
+// the scheduled command streams of the endpoints on an instance.
+func (mux *Multiplexer) GetActivityListener(instance client.Instance) func(activity *activities.ActivityInfo) {
+	return func(activity *activities.ActivityInfo) {
+		for _, endpoint := range mux.Endpoints {
+			if endpoint.Instance.GetName() != instance.GetName() {
+				continue
+			}
+			endpoint.scheduleMu.Lock()
+			for _, commands := range endpoint.scheduleStreams {
+				commands.ApplyActivity(activity)
+			}
+			endpoint.scheduleMu.Unlock()
+		}
+	}
+}
+
+// timelineEditor creates and deletes timeline items, as the Yamcs client does.
+type timelineEditor interface {
+	CreateTimelineItem(instance client.Instance, request *timeline.CreateItemRequest) (*timeline.TimelineItem, error)
+	DeleteTimelineItem(instance client.Instance, id string) error
+}
+
+// ReplaceScheduledCommand replaces the timeline item id by a new item built
+// from request. The old item is deleted first, so that no failure can leave
+// both items scheduled and the command sent twice.
+func ReplaceScheduledCommand(editor timelineEditor, instance client.Instance, id string, request *timeline.CreateItemRequest) (*timeline.TimelineItem, error) {
+	if err := editor.DeleteTimelineItem(instance, id); err != nil {
+		return nil, fmt.Errorf("could not remove scheduled command %s: %w", id, err)
+	}
+	item, err := editor.CreateTimelineItem(instance, request)
+	if err != nil {
+		return nil, fmt.Errorf("scheduled command %s was removed but its replacement could not be scheduled: %w", id, err)
+	}
+	return item, nil
+}
@@ -0,0 +1,49 @@
+package source
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/timeline"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/yamcs/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+// fakeTimeline keeps timeline items by ID. Deleting fails when deleteErr is set.
+type fakeTimeline struct {
+	items     map[string]*timeline.TimelineItem
+	deleteErr error
+}
+
+func (f *fakeTimeline) CreateTimelineItem(instance client.Instance, request *timeline.CreateItemRequest) (*timeline.TimelineItem, error) {
+	item := &timeline.TimelineItem{Id: proto.String("new"), Name: request.Name}
+	f.items[item.GetId()] = item
+	return item, nil
+}
+
+func (f *fakeTimeline) DeleteTimelineItem(instance client.Instance, id string) error {
+	if f.deleteErr != nil {
+		return f.deleteErr
+	}
+	delete(f.items, id)
+	return nil
+}
+
+func TestReplaceScheduledCommand(t *testing.T) {
+	request := &timeline.CreateItemRequest{Name: proto.String("/YSS/HEATER_OFF")}
+
+	editor := &fakeTimeline{items: map[string]*timeline.TimelineItem{"old": {Id: proto.String("old")}}}
+	item, err := ReplaceScheduledCommand(editor, nil, "old", request)
+	require.NoError(t, err)
+	assert.Equal(t, "new", item.GetId())
+	assert.Len(t, editor.items, 1)
+
+	// When the old item cannot be removed, no replacement is scheduled.
+	editor = &fakeTimeline{items: map[string]*timeline.TimelineItem{"old": {Id: proto.String("old")}}, deleteErr: errors.New("unavailable")}
+	_, err = ReplaceScheduledCommand(editor, nil, "old", request)
+	assert.ErrorContains(t, err, "could not remove scheduled command old")
+	assert.Len(t, editor.items, 1)
+	assert.Contains(t, editor.items, "old")
+}
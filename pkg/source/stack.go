@@ -0,0 +1,479 @@
+package source
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/commanding"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/exception"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/tools"
+)
+
+const (
+	// defaultStageTimeout bounds verifier steps without a timeout.
+	defaultStageTimeout = time.Minute
+	// defaultConditionTimeout bounds condition steps without a timeout.
+	defaultConditionTimeout = time.Minute
+	// conditionPollInterval is how often condition steps read parameter values.
+	conditionPollInterval = time.Second
+	// stackRunRetention is how long finished runs can still be looked up.
+	stackRunRetention = time.Hour
+)
+
+// stackTarget is what a command stack run acts on.
+type stackTarget interface {
+	// issue sends a command and returns its ID.
+	issue(ctx context.Context, step tools.StackStep) (string, error)
+	// waitStage returns once the command reached stage, with an error when the
+	// stage or the command failed.
+	waitStage(ctx context.Context, commandID, stage string) error
+	// parameterValue returns the current engineering value of a parameter.
+	parameterValue(ctx context.Context, parameter string) (*protobuf.Value, error)
+	close()
+}
+
+// StackRun executes a command stack in the background, one step after the
+// other.
+type StackRun struct {
+	ID       string
+	Endpoint string
+	// StartedBy is the login of the Grafana user who started the run, the only
+	// one allowed to pause, resume or abort it.
+	StartedBy string
+
+	stack        tools.CommandStack
+	pollInterval time.Duration
+	cancel       context.CancelFunc
+	done         chan struct{}
+
+	mu       sync.Mutex
+	state    tools.StackRunState
+	current  int
+	steps    []tools.StackStepStatus
+	finished time.Time
+	// resumed is closed when a paused run is resumed, nil while not paused.
+	resumed  chan struct{}
+	watchers map[string]chan struct{}
+}
+
+func newStackRun(endpoint string, stack tools.CommandStack) *StackRun {
+	id := make([]byte, 8)
+	rand.Read(id)
+	steps := make([]tools.StackStepStatus, len(stack.Steps))
+	for i := range steps {
+		steps[i].State = tools.StepPending
+	}
+	return &StackRun{
+		ID:           hex.EncodeToString(id),
+		Endpoint:     endpoint,
+		stack:        stack,
+		pollInterval: conditionPollInterval,
+		done:         make(chan struct{}),
+		state:        tools.StackRunning,
+		steps:        steps,
+		watchers:     map[string]chan struct{}{},
+	}
+}
+
+// Status returns a snapshot of the progress of the run.
+func (r *StackRun) Status() tools.StackRunStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return tools.StackRunStatus{
+		ID:       r.ID,
+		Endpoint: r.Endpoint,
+		Stack:    r.stack,
+		State:    r.state,
+		Current:  r.current,
+		Steps:    append([]tools.StackStepStatus(nil), r.steps...),
+	}
+}
+
+// Done is closed once the run is over.
+func (r *StackRun) Done() <-chan struct{} {
+	return r.done
+}
+
+// Watch returns a channel signalled whenever the status changes, until
+// Unwatch is called with the same key.
+func (r *StackRun) Watch(key string) <-chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	signal := make(chan struct{}, 1)
+	r.watchers[key] = signal
+	return signal
+}
+
+// Unwatch stops signalling status changes to the watcher key.
+func (r *StackRun) Unwatch(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.watchers, key)
+}
+
+// notify signals the watchers; the caller must hold r.mu.
+func (r *StackRun) notify() {
+	for _, signal := range r.watchers {
+		select {
+		case signal <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Pause holds the run before its next step. The step running when Pause is
+// called still completes.
+func (r *StackRun) Pause() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.state != tools.StackRunning {
+		return exception.New("Cannot pause a "+string(r.state)+" command stack", "INVALID_STACK_STATE")
+	}
+	r.state = tools.StackPaused
+	r.resumed = make(chan struct{})
+	r.notify()
+	return nil
+}
+
+// Resume continues a paused run.
+func (r *StackRun) Resume() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.state != tools.StackPaused {
+		return exception.New("Cannot resume a "+string(r.state)+" command stack", "INVALID_STACK_STATE")
+	}
+	r.state = tools.StackRunning
+	close(r.resumed)
+	r.resumed = nil
+	r.notify()
+	return nil
+}
+
+// Abort stops the run, interrupting the current step.
+func (r *StackRun) Abort() error {
+	r.mu.Lock()
+	state := r.state
+	r.mu.Unlock()
+	if state.Finished() {
+		return exception.New("Cannot abort a "+string(state)+" command stack", "INVALID_STACK_STATE")
+	}
+	r.cancel()
+	return nil
+}
+
+// waitWhilePaused blocks while the run is paused.
+func (r *StackRun) waitWhilePaused(ctx context.Context) error {
+	r.mu.Lock()
+	resumed := r.resumed
+	r.mu.Unlock()
+	if resumed == nil {
+		return ctx.Err()
+	}
+	select {
+	case <-resumed:
+		return ctx.Err()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *StackRun) updateStep(index int, update func(step *tools.StackStepStatus)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	update(&r.steps[index])
+	r.notify()
+}
+
+func (r *StackRun) finish(state tools.StackRunState) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.state = state
+	r.finished = time.Now()
+	r.resumed = nil
+	r.notify()
+}
+
+// run executes the steps against target, stopping at the first failure unless
+// the stack continues on failure.
+func (r *StackRun) run(ctx context.Context, target stackTarget) {
+	defer close(r.done)
+	defer target.close()
+
+	failed := false
+	lastCommand := ""
+	for i, step := range r.stack.Steps {
+		if err := r.waitWhilePaused(ctx); err != nil {
+			r.finish(tools.StackAborted)
+			return
+		}
+		r.mu.Lock()
+		r.current = i
+		r.mu.Unlock()
+
+		started := time.Now()
+		r.updateStep(i, func(status *tools.StackStepStatus) {
+			status.State = tools.StepRunning
+			status.Started = &started
+		})
+
+		commandID, err := r.execute(ctx, target, step, lastCommand)
+		if commandID != "" {
+			lastCommand = commandID
+		}
+		finished := time.Now()
+		aborted := ctx.Err() != nil
+		r.updateStep(i, func(status *tools.StackStepStatus) {
+			status.Finished = &finished
+			status.CommandID = commandID
+			switch {
+			case aborted:
+				status.State = tools.StepAborted
+			case err != nil:
+				status.State = tools.StepFailed
+				status.Message = err.Error()
+			default:
+				status.State = tools.StepSucceeded
+			}
+		})
+
+		if aborted {
+			r.finish(tools.StackAborted)
+			return
+		}
+		if err != nil {
+			backend.Logger.Warn("Command stack step failed", "run", r.ID, "step", i+1, "error", err)
+			failed = true
+			if !r.stack.ContinueOnFailure {
+				r.finish(tools.StackFailed)
+				return
+			}
+		}
+	}
+	if failed {
+		r.finish(tools.StackFailed)
+	} else {
+		r.finish(tools.StackCompleted)
+	}
+}
+
+// execute runs one step and returns the ID of the command it issued, if any.
+func (r *StackRun) execute(ctx context.Context, target stackTarget, step tools.StackStep, lastCommand string) (string, error) {
+	switch step.Type {
+	case tools.StepCommand:
+		return target.issue(ctx, step)
+
+	case tools.StepWait:
+		timer := time.NewTimer(step.WaitDuration())
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			return "", nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+
+	case tools.StepVerifier:
+		if lastCommand == "" {
+			return "", fmt.Errorf("no command was issued before this step")
+		}
+		timeout := step.TimeoutDuration(defaultStageTimeout)
+		stepCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		err := target.waitStage(stepCtx, lastCommand, step.Stage)
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+			return "", fmt.Errorf("%s not reached after %s", step.Stage, timeout)
+		}
+		return "", err
+
+	case tools.StepCondition:
+		timeout := step.TimeoutDuration(defaultConditionTimeout)
+		deadline := time.NewTimer(timeout)
+		defer deadline.Stop()
+		ticker := time.NewTicker(r.pollInterval)
+		defer ticker.Stop()
+		for {
+			holds, err := conditionsHold(ctx, target, step.Conditions)
+			if err != nil || holds {
+				return "", err
+			}
+			select {
+			case <-ticker.C:
+			case <-deadline.C:
+				return "", fmt.Errorf("%s did not hold within %s", step.Describe(), timeout)
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+		}
+	}
+	return "", fmt.Errorf("unknown step type %q", step.Type)
+}
+
+func conditionsHold(ctx context.Context, target stackTarget, conditions []tools.StackCondition) (bool, error) {
+	for _, condition := range conditions {
+		value, err := target.parameterValue(ctx, condition.Parameter)
+		if err != nil {
+			return false, err
+		}
+		if !condition.Holds(value) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// StackIssueFunc is told of every command a stack run sends, with the Yamcs
+// response or the error.
+type StackIssueFunc func(run *StackRun, step tools.StackStep, response *commanding.IssueCommandResponse, err error)
+
+// endpointStackTarget runs command stacks on a Yamcs endpoint, following the
+// commands it issues through the command history subscription.
+type endpointStackTarget struct {
+	endpoint *YamcsEndpoint
+	path     string
+	signal   <-chan struct{}
+	progress map[string]*tools.CommandProgress
+	run      *StackRun
+	onIssue  StackIssueFunc
+}
+
+func newEndpointStackTarget(endpoint *YamcsEndpoint, run *StackRun, onIssue StackIssueFunc) *endpointStackTarget {
+	path := "stack/" + run.ID
+	// Listen before issuing anything so that no acknowledgment is missed.
+	endpoint.RequestCommandHistoryStream(path, nil)
+	return &endpointStackTarget{
+		endpoint: endpoint,
+		path:     path,
+		signal:   endpoint.GetCommandHistorySignal(path),
+		progress: map[string]*tools.CommandProgress{},
+		run:      run,
+		onIssue:  onIssue,
+	}
+}
+
+func (t *endpointStackTarget) issue(ctx context.Context, step tools.StackStep) (string, error) {
+	yamcs := t.endpoint.GetClient().WithContext(ctx)
+	response, err := yamcs.IssueCommandWithComment(t.endpoint.Instance, t.endpoint.Processor, step.Command, step.Arguments, step.Comment)
+	if t.onIssue != nil {
+		t.onIssue(t.run, step, response, err)
+	}
+	if err != nil {
+		return "", err
+	}
+	t.progress[response.GetId()] = tools.NewCommandProgress(response.GetId())
+	return response.GetId(), nil
+}
+
+func (t *endpointStackTarget) waitStage(ctx context.Context, commandID, stage string) error {
+	progress, ok := t.progress[commandID]
+	if !ok {
+		return fmt.Errorf("command %s was not issued by this stack", commandID)
+	}
+	for {
+		for _, entry := range t.endpoint.DrainCommandHistoryStream(t.path) {
+			if tracked, ok := t.progress[entry.GetId()]; ok {
+				tracked.Update(entry)
+			}
+		}
+
+		if reached, ok := progress.Stage(stage); ok && reached.Status != "" && reached.Status != "PENDING" {
+			if reached.Status == "OK" {
+				return nil
+			}
+			return fmt.Errorf("%s %s: %s", stage, reached.Status, reached.Message)
+		}
+		if progress.Complete() {
+			completion, _ := progress.Stage("CommandComplete")
+			return fmt.Errorf("command completed with %s before %s: %s", completion.Status, stage, completion.Message)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.signal:
+		}
+	}
+}
+
+func (t *endpointStackTarget) parameterValue(ctx context.Context, parameter string) (*protobuf.Value, error) {
+	value, err := t.endpoint.GetClient().WithContext(ctx).GetParameterValueByName(t.endpoint.Instance, t.endpoint.Processor, parameter)
+	if err != nil {
+		return nil, err
+	}
+	return value.GetEngValue(), nil
+}
+
+func (t *endpointStackTarget) close() {
+	t.endpoint.WithdrawCommandHistoryStreamRequest(t.path)
+}
+
+// CommandStacks keeps track of the command stack runs of a datasource.
+type CommandStacks struct {
+	mu   sync.Mutex
+	runs map[string]*StackRun
+}
+
+// NewCommandStacks creates an empty registry of command stack runs.
+func NewCommandStacks() *CommandStacks {
+	return &CommandStacks{runs: map[string]*StackRun{}}
+}
+
+// Start validates stack and runs it on endpoint in the background, on behalf
+// of the Grafana user startedBy. onIssue, when set, is called after each
+// command the run sends.
+func (s *CommandStacks) Start(endpoint *YamcsEndpoint, stack tools.CommandStack, startedBy string, onIssue StackIssueFunc) (*StackRun, error) {
+	if err := stack.Validate(); err != nil {
+		return nil, exception.Wrap("Invalid command stack", "INVALID_STACK", err)
+	}
+	run := newStackRun(endpoint.ID, stack)
+	run.StartedBy = startedBy
+	s.start(run, newEndpointStackTarget(endpoint, run, onIssue))
+	return run, nil
+}
+
+func (s *CommandStacks) start(run *StackRun, target stackTarget) {
+	ctx, cancel := context.WithCancel(context.Background())
+	run.cancel = cancel
+
+	s.mu.Lock()
+	for id, old := range s.runs {
+		old.mu.Lock()
+		expired := old.state.Finished() && time.Since(old.finished) > stackRunRetention
+		old.mu.Unlock()
+		if expired {
+			delete(s.runs, id)
+		}
+	}
+	s.runs[run.ID] = run
+	s.mu.Unlock()
+
+	go func() {
+		defer cancel()
+		run.run(ctx, target)
+	}()
+}
+
+// Get returns the run with the given ID.
+func (s *CommandStacks) Get(id string) (*StackRun, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	run, ok := s.runs[id]
+	return run, ok
+}
+
+// Dispose aborts every run still going.
+func (s *CommandStacks) Dispose() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, run := range s.runs {
+		if run.cancel != nil {
+			run.cancel()
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/tools"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+// fakeStackTarget records the commands issued by a run. Commands named in
+// failing are rejected, stages are reached once released is closed, and
+// parameter values are read from values.
+type fakeStackTarget struct {
+	mu       sync.Mutex
+	issued   []string
+	failing  map[string]bool
+	released chan struct{}
+	values   map[string]*protobuf.Value
+	closed   bool
+}
+
+func newFakeStackTarget() *fakeStackTarget {
+	released := make(chan struct{})
+	close(released)
+	return &fakeStackTarget{failing: map[string]bool{}, released: released, values: map[string]*protobuf.Value{}}
+}
+
+func (t *fakeStackTarget) issue(ctx context.Context, step tools.StackStep) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.failing[step.Command] {
+		return "", fmt.Errorf("%s rejected", step.Command)
+	}
+	t.issued = append(t.issued, step.Command)
+	return fmt.Sprintf("cmd-%d", len(t.issued)), nil
+}
+
+func (t *fakeStackTarget) waitStage(ctx context.Context, commandID, stage string) error {
+	select {
+	case <-t.released:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (t *fakeStackTarget) parameterValue(ctx context.Context, parameter string) (*protobuf.Value, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.values[parameter], nil
+}
+
+func (t *fakeStackTarget) close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closed = true
+}
+
+func (t *fakeStackTarget) commands() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]string(nil), t.issued...)
+}
+
+func startTestStack(t *testing.T, target stackTarget, stack tools.CommandStack) *StackRun {
+	t.Helper()
+	require.NoError(t, stack.Validate())
+	run := newStackRun("ep", stack)
+	run.pollInterval = time.Millisecond
+	NewCommandStacks().start(run, target)
+	return run
+}
+
+func waitStackDone(t *testing.T, run *StackRun) tools.StackRunStatus {
+	t.Helper()
+	select {
+	case <-run.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("command stack did not finish")
+	}
+	return run.Status()
+}
+
+func stepStates(status tools.StackRunStatus) []tools.StackStepState {
+	states := []tools.StackStepState{}
+	for _, step := range status.Steps {
+		states = append(states, step.State)
+	}
+	return states
+}
+
+func TestStackRunExecutesStepsInOrder(t *testing.T) {
+	target := newFakeStackTarget()
+	target.values["/YSS/mode"] = &protobuf.Value{Type: protobuf.Value_ENUMERATED.Enum(), StringValue: proto.String("NOMINAL")}
+	run := startTestStack(t, target, tools.CommandStack{Steps: []tools.StackStep{
+		{Type: tools.StepCommand, Command: "/YSS/ON"},
+		{Type: tools.StepVerifier, Stage: "Acknowledge_Sent"},
+		{Type: tools.StepWait, Duration: "1ms"},
+		{Type: tools.StepCondition, Conditions: []tools.StackCondition{{Parameter: "/YSS/mode", Operator: "eq", Value: "NOMINAL"}}},
+		{Type: tools.StepCommand, Command: "/YSS/OFF"},
+	}})
+
+	status := waitStackDone(t, run)
+	assert.Equal(t, tools.StackCompleted, status.State)
+	assert.Equal(t, []string{"/YSS/ON", "/YSS/OFF"}, target.commands())
+	assert.Equal(t, []tools.StackStepState{
+		tools.StepSucceeded, tools.StepSucceeded, tools.StepSucceeded, tools.StepSucceeded, tools.StepSucceeded,
+	}, stepStates(status))
+	assert.Equal(t, "cmd-1", status.Steps[0].CommandID)
+	assert.Equal(t, "cmd-2", status.Steps[4].CommandID)
+	assert.True(t, target.closed)
+}
+
+func TestStackRunStopsOnFailure(t *testing.T) {
+	target := newFakeStackTarget()
+	target.failing["/YSS/BAD"] = true
+	steps := []tools.StackStep{
+		{Type: tools.StepCommand, Command: "/YSS/BAD"},
+		{Type: tools.StepCommand, Command: "/YSS/GOOD"},
+	}
+
+	status := waitStackDone(t, startTestStack(t, target, tools.CommandStack{Steps: steps}))
+	assert.Equal(t, tools.StackFailed, status.State)
+	assert.Equal(t, []tools.StackStepState{tools.StepFailed, tools.StepPending}, stepStates(status))
+	assert.Equal(t, "/YSS/BAD rejected", status.Steps[0].Message)
+	assert.Empty(t, target.commands())
+
+	status = waitStackDone(t, startTestStack(t, target, tools.CommandStack{Steps: steps, ContinueOnFailure: true}))
+	assert.Equal(t, tools.StackFailed, status.State)
+	assert.Equal(t, []tools.StackStepState{tools.StepFailed, tools.StepSucceeded}, stepStates(status))
+	assert.Equal(t, []string{"/YSS/GOOD"}, target.commands())
+}
+
+func TestStackRunStepTimeouts(t *testing.T) {
+	target := newFakeStackTarget()
+	target.released = make(chan struct{})
+	status := waitStackDone(t, startTestStack(t, target, tools.CommandStack{ContinueOnFailure: true, Steps: []tools.StackStep{
+		{Type: tools.StepVerifier, Stage: "CommandComplete"},
+		{Type: tools.StepCommand, Command: "/YSS/ON"},
+		{Type: tools.StepVerifier, Stage: "CommandComplete", Timeout: "5ms"},
+		{Type: tools.StepCondition, Timeout: "5ms", Conditions: []tools.StackCondition{{Parameter: "/YSS/unknown", Operator: "eq", Value: "1"}}},
+	}}))
+
+	assert.Equal(t, "no command was issued before this step", status.Steps[0].Message)
+	assert.Equal(t, "CommandComplete not reached after 5ms", status.Steps[2].Message)
+	assert.Equal(t, "wait until /YSS/unknown == 1 did not hold within 5ms", status.Steps[3].Message)
+}
+
+func TestStackRunPauseResumeAbort(t *testing.T) {
+	target := newFakeStackTarget()
+	target.released = make(chan struct{})
+	run := startTestStack(t, target, tools.CommandStack{Steps: []tools.StackStep{
+		{Type: tools.StepCommand, Command: "/YSS/ON"},
+		{Type: tools.StepVerifier, Stage: "CommandComplete", Timeout: "1m"},
+		{Type: tools.StepCommand, Command: "/YSS/OFF"},
+		{Type: tools.StepWait, Duration: "1h"},
+	}})
+	signal := run.Watch("test")
+	defer run.Unwatch("test")
+
+	// Pause while the verifier waits: the next command is held back.
+	require.Eventually(t, func() bool { return run.Status().Steps[1].State == tools.StepRunning }, 5*time.Second, time.Millisecond)
+	require.NoError(t, run.Pause())
+	assert.Error(t, run.Pause())
+	close(target.released)
+	require.Eventually(t, func() bool { return run.Status().Steps[1].State == tools.StepSucceeded }, 5*time.Second, time.Millisecond)
+	assert.Equal(t, tools.StackPaused, run.Status().State)
+	assert.Equal(t, []string{"/YSS/ON"}, target.commands())
+	<-signal
+
+	require.NoError(t, run.Resume())
+	require.Eventually(t, func() bool { return run.Status().Steps[3].State == tools.StepRunning }, 5*time.Second, time.Millisecond)
+	assert.Equal(t, []string{"/YSS/ON", "/YSS/OFF"}, target.commands())
+
+	require.NoError(t, run.Abort())
+	status := waitStackDone(t, run)
+	assert.Equal(t, tools.StackAborted, status.State)
+	assert.Equal(t, tools.StepAborted, status.Steps[3].State)
+	assert.Error(t, run.Abort())
+	assert.Error(t, run.Resume())
+}
@@ -0,0 +1,224 @@
+// Package audit keeps a durable record of the operator actions taken through
+// the datasource: commands, alarm and link operations.
+//
+// Entries are appended as JSON lines to one file per UTC day, so that files
+// past the retention limit can be dropped whole and the others are never
+// rewritten.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultRetention is how long entries are kept when the configuration does
+// not say otherwise.
+const DefaultRetention = 90 * 24 * time.Hour
+
+const (
+	filePrefix = "audit-"
+	fileSuffix = ".jsonl"
+	dayLayout  = "2006-01-02"
+)
+
+// Outcome tells how an action ended.
+type Outcome string
+
+const (
+	OutcomeSuccess Outcome = "success"
+	OutcomeFailure Outcome = "failure"
+	OutcomeDenied  Outcome = "denied"
+	OutcomePending Outcome = "pending"
+)
+
+// Entry is one recorded action.
+type Entry struct {
+	Time     time.Time       `json:"time"`
+	User     string          `json:"user"`
+	Role     string          `json:"role,omitempty"`
+	Endpoint string          `json:"endpoint"`
+	Action   string          `json:"action"` // e.g. "command.issue" or "alarm.acknowledge"
+	Target   string          `json:"target"` // Command, alarm or link acted on
+	Payload  json.RawMessage `json:"payload,omitempty"`
+	Response json.RawMessage `json:"response,omitempty"`
+	Outcome  Outcome         `json:"outcome"`
+	Error    string          `json:"error,omitempty"`
+	// Overrides flags the checks bypassed by a command sent with contingency
+	// issue options, e.g. "disable-verifiers" or "stream:tc_backup".
+	Overrides []string `json:"overrides,omitempty"`
+}
+
+// Log is an append-only audit log stored in a directory.
+type Log struct {
+	dir       string
+	retention time.Duration
+	now       func() time.Time
+
+	mu   sync.Mutex
+	file *os.File
+	day  string
+}
+
+// Open opens the audit log stored in dir, creating the directory when needed,
+// and drops the files older than retention.
+func Open(dir string, retention time.Duration) (*Log, error) {
+	if retention <= 0 {
+		retention = DefaultRetention
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("could not create the audit directory: %w", err)
+	}
+	log := &Log{dir: dir, retention: retention, now: time.Now}
+	if err := log.prune(); err != nil {
+		return nil, err
+	}
+	return log, nil
+}
+
+// Record appends an entry to the log, stamping it with the current time when
+// it has none. The entry is synced to disk before Record returns.
+func (l *Log) Record(entry Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if entry.Time.IsZero() {
+		entry.Time = l.now()
+	}
+	entry.Time = entry.Time.UTC()
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if day := entry.Time.Format(dayLayout); day != l.day || l.file == nil {
+		if err := l.rotate(day); err != nil {
+			return err
+		}
+	}
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("could not write the audit log: %w", err)
+	}
+	return l.file.Sync()
+}
+
+// rotate switches to the file of day, dropping the files past retention.
+func (l *Log) rotate(day string) error {
+	if l.file != nil {
+		l.file.Close()
+		l.file = nil
+	}
+	file, err := os.OpenFile(filepath.Join(l.dir, filePrefix+day+fileSuffix), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o640)
+	if err != nil {
+		return fmt.Errorf("could not open the audit log: %w", err)
+	}
+	l.file, l.day = file, day
+	return l.prune()
+}
+
+// Query returns the entries recorded between from and to, oldest first, that
+// keep accepts. A nil keep accepts every entry.
+func (l *Log) Query(from, to time.Time, keep func(Entry) bool) ([]Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	days, err := l.days()
+	if err != nil {
+		return nil, err
+	}
+	first, last := from.UTC().Format(dayLayout), to.UTC().Format(dayLayout)
+	entries := []Entry{}
+	for _, day := range days {
+		if day < first || day > last {
+			continue
+		}
+		if entries, err = l.readDay(day, from, to, keep, entries); err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.Before(entries[j].Time) })
+	return entries, nil
+}
+
+func (l *Log) readDay(day string, from, to time.Time, keep func(Entry) bool, entries []Entry) ([]Entry, error) {
+	file, err := os.Open(filepath.Join(l.dir, filePrefix+day+fileSuffix))
+	if err != nil {
+		return nil, fmt.Errorf("could not read the audit log: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var entry Entry
+		// A line cut short by a crash is skipped rather than failing the query.
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if entry.Time.Before(from) || entry.Time.After(to) {
+			continue
+		}
+		if keep == nil || keep(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// days lists the days the log has a file for, in order.
+func (l *Log) days() ([]string, error) {
+	files, err := os.ReadDir(l.dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not list the audit log: %w", err)
+	}
+	days := []string{}
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasPrefix(name, filePrefix) || !strings.HasSuffix(name, fileSuffix) {
+			continue
+		}
+		day := strings.TrimSuffix(strings.TrimPrefix(name, filePrefix), fileSuffix)
+		if _, err := time.Parse(dayLayout, day); err == nil {
+			days = append(days, day)
+		}
+	}
+	sort.Strings(days)
+	return days, nil
+}
+
+// prune removes the files whose whole day is older than the retention limit.
+func (l *Log) prune() error {
+	days, err := l.days()
+	if err != nil {
+		return err
+	}
+	cutoff := l.now().Add(-l.retention)
+	for _, day := range days {
+		start, _ := time.Parse(dayLayout, day)
+		if !start.Add(24 * time.Hour).Before(cutoff) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(l.dir, filePrefix+day+fileSuffix)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("could not remove an expired audit file: %w", err)
+		}
+	}
+	return nil
+}
+
+// Close closes the file being written.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file, l.day = nil, ""
+	return err
+}
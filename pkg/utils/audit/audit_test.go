@@ -0,0 +1,60 @@
+package audit
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogRecordAndQuery(t *testing.T) {
+	dir := t.TempDir()
+	log, err := Open(dir, 0)
+	require.NoError(t, err)
+	defer log.Close()
+
+	day := time.Date(2026, 3, 1, 23, 59, 0, 0, time.UTC)
+	log.now = func() time.Time { return day }
+	require.NoError(t, log.Record(Entry{Time: day, User: "alice", Endpoint: "ep", Action: "command.issue", Target: "/YSS/ON",
+		Payload: json.RawMessage(`{"arguments":{}}`), Outcome: OutcomeSuccess}))
+	require.NoError(t, log.Record(Entry{Time: day.Add(2 * time.Minute), User: "bob", Endpoint: "ep", Action: "alarm.acknowledge",
+		Target: "/YSS/voltage", Outcome: OutcomeFailure, Error: "no such alarm"}))
+	require.NoError(t, log.Record(Entry{Time: day.Add(3 * time.Minute), User: "bob", Endpoint: "other", Action: "link.disable",
+		Target: "UDP_TM", Outcome: OutcomeSuccess}))
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	require.NoError(t, err)
+	assert.Len(t, files, 2)
+
+	entries, err := log.Query(day.Add(-time.Hour), day.Add(time.Hour), func(entry Entry) bool { return entry.Endpoint == "ep" })
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "alice", entries[0].User)
+	assert.JSONEq(t, `{"arguments":{}}`, string(entries[0].Payload))
+	assert.Equal(t, "no such alarm", entries[1].Error)
+
+	entries, err = log.Query(day.Add(time.Minute), day.Add(time.Hour), nil)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "alarm.acknowledge", entries[0].Action)
+}
+
+func TestLogRetention(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	for _, day := range []string{"2026-03-01", "2026-03-08", "2026-03-09"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "audit-"+day+".jsonl"), nil, 0o640))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0o640))
+
+	log := &Log{dir: dir, retention: 48 * time.Hour, now: func() time.Time { return now }}
+	require.NoError(t, log.prune())
+	days, err := log.days()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2026-03-08", "2026-03-09"}, days)
+	assert.FileExists(t, filepath.Join(dir, "notes.txt"))
+}
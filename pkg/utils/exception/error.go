@@ -27,6 +27,11 @@ func (e *PluginException) Error() string {
 	return errorMessage
 }
 
+// Unwrap returns the underlying cause so that errors.Is and errors.As can inspect it.
+func (e *PluginException) Unwrap() error {
+	return e.Cause
+}
+
 // New creates a new PluginException with the given message and error code.
 // It returns a pointer to the newly created error.
 func New(message string, code string) *PluginException {
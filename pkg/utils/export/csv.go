@@ -0,0 +1,54 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// csvWriter writes rows as CSV, with a header line naming the columns. Times
+// are written in RFC 3339 format, in UTC.
+type csvWriter struct {
+	writer  *csv.Writer
+	columns []Column
+	record  []string
+}
+
+func newCSVWriter(w io.Writer, columns []Column) (*csvWriter, error) {
+	writer := csv.NewWriter(w)
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = column.Name
+	}
+	if err := writer.Write(header); err != nil {
+		return nil, err
+	}
+	return &csvWriter{writer: writer, columns: columns, record: make([]string, len(columns))}, nil
+}
+
+func (c *csvWriter) WriteRow(row []any) error {
+	for i, value := range row {
+		switch v := value.(type) {
+		case nil:
+			c.record[i] = ""
+		case string:
+			c.record[i] = v
+		case float64:
+			c.record[i] = strconv.FormatFloat(v, 'g', -1, 64)
+		case int64:
+			c.record[i] = strconv.FormatInt(v, 10)
+		case time.Time:
+			c.record[i] = v.UTC().Format(time.RFC3339Nano)
+		default:
+			return fmt.Errorf("unsupported value %T in column %s", value, c.columns[i].Name)
+		}
+	}
+	return c.writer.Write(c.record)
+}
+
+func (c *csvWriter) Close() error {
+	c.writer.Flush()
+	return c.writer.Error()
+}
@@ -0,0 +1,76 @@
+// Package export writes tabular archive exports, row by row, as CSV or Parquet.
+// Writers hold at most one batch of rows in memory, so that exports of any size
+// can be streamed to the client as they are read from Yamcs.
+package export
+
+import (
+	"fmt"
+	"io"
+)
+
+// Format is an export file format.
+type Format string
+
+const (
+	CSV     Format = "csv"
+	Parquet Format = "parquet"
+)
+
+// ParseFormat returns the format called name, CSV when name is empty.
+func ParseFormat(name string) (Format, error) {
+	switch Format(name) {
+	case "", CSV:
+		return CSV, nil
+	case Parquet:
+		return Parquet, nil
+	}
+	return "", fmt.Errorf("unknown export format %q, expected csv or parquet", name)
+}
+
+// ContentType returns the MIME type of files in the format.
+func (format Format) ContentType() string {
+	if format == Parquet {
+		return "application/vnd.apache.parquet"
+	}
+	return "text/csv"
+}
+
+// ColumnType is the type of the values of a column.
+type ColumnType int
+
+const (
+	// String columns hold string values.
+	String ColumnType = iota
+	// Number columns hold float64 values.
+	Number
+	// Integer columns hold int64 values.
+	Integer
+	// Time columns hold time.Time values.
+	Time
+)
+
+// Column describes a column of an export.
+type Column struct {
+	Name string
+	Type ColumnType
+}
+
+// Writer writes the rows of an export. A row holds one value per column, of
+// the Go type of the column, or nil for an empty cell.
+type Writer interface {
+	WriteRow(row []any) error
+	// Close writes the buffered rows and the end of the file. It does not
+	// close the underlying writer.
+	Close() error
+}
+
+// NewWriter returns a writer of columns to w in the given format.
+func NewWriter(format Format, w io.Writer, columns []Column) (Writer, error) {
+	switch format {
+	case CSV:
+		return newCSVWriter(w, columns)
+	case Parquet:
+		return newParquetWriter(w, columns)
+	}
+	return nil, fmt.Errorf("unknown export format %q", format)
+}
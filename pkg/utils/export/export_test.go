@@ -0,0 +1,92 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet/file"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testColumns = []Column{
+	{Name: "time", Type: Time},
+	{Name: "name", Type: String},
+	{Name: "value", Type: Number},
+	{Name: "count", Type: Integer},
+}
+
+var testTime = time.Date(2025, 3, 1, 12, 0, 0, 500_000_000, time.UTC)
+
+func TestParseFormat(t *testing.T) {
+	format, err := ParseFormat("")
+	require.NoError(t, err)
+	assert.Equal(t, CSV, format)
+
+	format, err = ParseFormat("parquet")
+	require.NoError(t, err)
+	assert.Equal(t, Parquet, format)
+
+	_, err = ParseFormat("xlsx")
+	assert.ErrorContains(t, err, "unknown export format")
+}
+
+func TestCSVWriter(t *testing.T) {
+	var out bytes.Buffer
+	writer, err := NewWriter(CSV, &out, testColumns)
+	require.NoError(t, err)
+	require.NoError(t, writer.WriteRow([]any{testTime, "a, b", 1.25, int64(3)}))
+	require.NoError(t, writer.WriteRow([]any{testTime, nil, nil, nil}))
+	require.NoError(t, writer.Close())
+
+	assert.Equal(t, "time,name,value,count\n"+
+		"2025-03-01T12:00:00.5Z,\"a, b\",1.25,3\n"+
+		"2025-03-01T12:00:00.5Z,,,\n", out.String())
+}
+
+func TestWriterRejectsMismatchedValue(t *testing.T) {
+	for _, format := range []Format{CSV, Parquet} {
+		writer, err := NewWriter(format, &bytes.Buffer{}, testColumns)
+		require.NoError(t, err)
+		assert.Error(t, writer.WriteRow([]any{testTime, "a", []int{1}, int64(1)}), format)
+	}
+}
+
+func TestParquetWriter(t *testing.T) {
+	var out bytes.Buffer
+	writer, err := NewWriter(Parquet, &out, testColumns)
+	require.NoError(t, err)
+	// Enough rows for several row groups.
+	rows := parquetRowGroupLength + 10
+	for i := 0; i < rows; i++ {
+		row := []any{testTime.Add(time.Duration(i) * time.Second), "a", float64(i), int64(i)}
+		if i == 1 {
+			row[2] = nil
+		}
+		require.NoError(t, writer.WriteRow(row))
+	}
+	require.NoError(t, writer.Close())
+
+	reader, err := file.NewParquetReader(bytes.NewReader(out.Bytes()))
+	require.NoError(t, err)
+	defer reader.Close()
+	assert.Equal(t, 2, reader.NumRowGroups())
+	assert.Equal(t, int64(rows), reader.NumRows())
+
+	arrowReader, err := pqarrow.NewFileReader(reader, pqarrow.ArrowReadProperties{}, memory.DefaultAllocator)
+	require.NoError(t, err)
+	table, err := arrowReader.ReadTable(context.Background())
+	require.NoError(t, err)
+	defer table.Release()
+
+	values := table.Column(2).Data().Chunk(0).(*array.Float64)
+	assert.Equal(t, 0.0, values.Value(0))
+	assert.True(t, values.IsNull(1))
+	assert.Equal(t, 2.0, values.Value(2))
+	assert.Equal(t, "name", table.Schema().Field(1).Name)
+}
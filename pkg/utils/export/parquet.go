@@ -0,0 +1,126 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/compress"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+)
+
+// parquetRowGroupLength is the number of rows buffered before they are written
+// as a row group.
+const parquetRowGroupLength = 64 * 1024
+
+// parquetWriter writes rows as a Parquet file, one row group per batch of
+// rows. Every column is nullable; times are millisecond UTC timestamps.
+type parquetWriter struct {
+	writer  *pqarrow.FileWriter
+	builder *array.RecordBuilder
+	columns []Column
+	rows    int
+}
+
+func newParquetWriter(w io.Writer, columns []Column) (*parquetWriter, error) {
+	fields := make([]arrow.Field, len(columns))
+	for i, column := range columns {
+		fields[i] = arrow.Field{Name: column.Name, Type: arrowType(column.Type), Nullable: true}
+	}
+	schema := arrow.NewSchema(fields, nil)
+
+	props := parquet.NewWriterProperties(
+		parquet.WithCompression(compress.Codecs.Snappy),
+		parquet.WithMaxRowGroupLength(parquetRowGroupLength),
+	)
+	// Hide any Close method of w, which the file writer would call on Close.
+	writer, err := pqarrow.NewFileWriter(schema, struct{ io.Writer }{w}, props, pqarrow.DefaultWriterProps())
+	if err != nil {
+		return nil, err
+	}
+	return &parquetWriter{
+		writer:  writer,
+		builder: array.NewRecordBuilder(memory.DefaultAllocator, schema),
+		columns: columns,
+	}, nil
+}
+
+func arrowType(columnType ColumnType) arrow.DataType {
+	switch columnType {
+	case Number:
+		return arrow.PrimitiveTypes.Float64
+	case Integer:
+		return arrow.PrimitiveTypes.Int64
+	case Time:
+		return &arrow.TimestampType{Unit: arrow.Millisecond, TimeZone: "UTC"}
+	}
+	return arrow.BinaryTypes.String
+}
+
+func (p *parquetWriter) WriteRow(row []any) error {
+	for i, value := range row {
+		field := p.builder.Field(i)
+		if value == nil {
+			field.AppendNull()
+			continue
+		}
+		switch b := field.(type) {
+		case *array.StringBuilder:
+			v, ok := value.(string)
+			if !ok {
+				return p.unsupported(i, value)
+			}
+			b.Append(v)
+		case *array.Float64Builder:
+			v, ok := value.(float64)
+			if !ok {
+				return p.unsupported(i, value)
+			}
+			b.Append(v)
+		case *array.Int64Builder:
+			v, ok := value.(int64)
+			if !ok {
+				return p.unsupported(i, value)
+			}
+			b.Append(v)
+		case *array.TimestampBuilder:
+			v, ok := value.(time.Time)
+			if !ok {
+				return p.unsupported(i, value)
+			}
+			b.Append(arrow.Timestamp(v.UnixMilli()))
+		}
+	}
+	p.rows++
+	if p.rows >= parquetRowGroupLength {
+		return p.flush()
+	}
+	return nil
+}
+
+func (p *parquetWriter) unsupported(column int, value any) error {
+	return fmt.Errorf("unsupported value %T in column %s", value, p.columns[column].Name)
+}
+
+// flush writes the buffered rows as a row group.
+func (p *parquetWriter) flush() error {
+	if p.rows == 0 {
+		return nil
+	}
+	record := p.builder.NewRecordBatch()
+	defer record.Release()
+	p.rows = 0
+	return p.writer.Write(record)
+}
+
+func (p *parquetWriter) Close() error {
+	defer p.builder.Release()
+	if err := p.flush(); err != nil {
+		return err
+	}
+	return p.writer.Close()
+}
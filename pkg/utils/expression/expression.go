@@ -0,0 +1,301 @@
+// Package expression parses and evaluates the arithmetic expressions defining
+// derived parameters, such as "BUS_V * BUS_I" or
+// "`/YSS/SIMULATOR/TEMP_A` - `/YSS/SIMULATOR/TEMP_B`".
+//
+// Expressions work on numbers. They support the + - * / % ^ operators,
+// comparisons, the && || ! logical operators, which take any non-zero number
+// as true and return 1 or 0, the c ? a : b conditional and the functions of
+// the functions table. Variables are identifiers, possibly dotted to designate
+// an aggregate member, or any name between backquotes.
+package expression
+
+import (
+	"fmt"
+	"math"
+)
+
+// Expression is a parsed expression.
+type Expression struct {
+	source    string
+	root      node
+	variables []string
+}
+
+// Parse parses source into an expression.
+func Parse(source string) (*Expression, error) {
+	p := &parser{lexer: newLexer(source)}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	root, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if p.token.kind != tokenEOF {
+		return nil, p.errorf("unexpected %s", p.token)
+	}
+
+	e := &Expression{source: source, root: root}
+	seen := map[string]bool{}
+	walk(root, func(n node) {
+		if v, ok := n.(*variableNode); ok && !seen[v.name] {
+			seen[v.name] = true
+			e.variables = append(e.variables, v.name)
+		}
+	})
+	return e, nil
+}
+
+// String returns the source of the expression.
+func (e *Expression) String() string {
+	return e.source
+}
+
+// Variables returns the names of the variables of the expression, in order of
+// first appearance.
+func (e *Expression) Variables() []string {
+	return e.variables
+}
+
+// Evaluate computes the value of the expression. values returns the value of a
+// variable and false when it has none, which fails the evaluation.
+func (e *Expression) Evaluate(values func(name string) (float64, bool)) (float64, error) {
+	return e.root.eval(values)
+}
+
+// Unit derives the unit of the expression from the units of its variables:
+// sums keep the unit of their operands, products and quotients combine them.
+// It returns an empty string when the expression has no unit or when its
+// operands have incompatible units.
+func (e *Expression) Unit(units func(name string) string) string {
+	return e.root.unit(units)
+}
+
+// node is an element of the syntax tree.
+type node interface {
+	eval(values func(string) (float64, bool)) (float64, error)
+	unit(units func(string) string) string
+	children() []node
+}
+
+func walk(n node, visit func(node)) {
+	visit(n)
+	for _, child := range n.children() {
+		walk(child, visit)
+	}
+}
+
+type numberNode struct {
+	value float64
+}
+
+func (n *numberNode) eval(func(string) (float64, bool)) (float64, error) { return n.value, nil }
+func (n *numberNode) unit(func(string) string) string                    { return "" }
+func (n *numberNode) children() []node                                   { return nil }
+
+type variableNode struct {
+	name string
+}
+
+func (n *variableNode) eval(values func(string) (float64, bool)) (float64, error) {
+	value, ok := values(n.name)
+	if !ok {
+		return 0, fmt.Errorf("no value for %s", n.name)
+	}
+	return value, nil
+}
+
+func (n *variableNode) unit(units func(string) string) string { return units(n.name) }
+func (n *variableNode) children() []node                      { return nil }
+
+type unaryNode struct {
+	operator string
+	operand  node
+}
+
+func (n *unaryNode) eval(values func(string) (float64, bool)) (float64, error) {
+	x, err := n.operand.eval(values)
+	if err != nil {
+		return 0, err
+	}
+	switch n.operator {
+	case "-":
+		return -x, nil
+	case "!":
+		return boolean(x == 0), nil
+	}
+	return x, nil
+}
+
+func (n *unaryNode) unit(units func(string) string) string {
+	if n.operator == "!" {
+		return ""
+	}
+	return n.operand.unit(units)
+}
+
+func (n *unaryNode) children() []node { return []node{n.operand} }
+
+type binaryNode struct {
+	operator    string
+	left, right node
+}
+
+func (n *binaryNode) eval(values func(string) (float64, bool)) (float64, error) {
+	x, err := n.left.eval(values)
+	if err != nil {
+		return 0, err
+	}
+	// Logical operators short-circuit, so that a missing value on the side
+	// that does not matter does not fail the evaluation.
+	switch {
+	case n.operator == "&&" && x == 0:
+		return 0, nil
+	case n.operator == "||" && x != 0:
+		return 1, nil
+	}
+	y, err := n.right.eval(values)
+	if err != nil {
+		return 0, err
+	}
+
+	switch n.operator {
+	case "+":
+		return x + y, nil
+	case "-":
+		return x - y, nil
+	case "*":
+		return x * y, nil
+	case "/":
+		return x / y, nil
+	case "%":
+		return math.Mod(x, y), nil
+	case "^":
+		return math.Pow(x, y), nil
+	case "<":
+		return boolean(x < y), nil
+	case "<=":
+		return boolean(x <= y), nil
+	case ">":
+		return boolean(x > y), nil
+	case ">=":
+		return boolean(x >= y), nil
+	case "==":
+		return boolean(x == y), nil
+	case "!=":
+		return boolean(x != y), nil
+	case "&&", "||":
+		return boolean(y != 0), nil
+	}
+	return 0, fmt.Errorf("unknown operator %s", n.operator)
+}
+
+func (n *binaryNode) unit(units func(string) string) string {
+	left, right := n.left.unit(units), n.right.unit(units)
+	switch n.operator {
+	case "+", "-", "%":
+		return commonUnit(left, right)
+	case "*":
+		switch {
+		case left == "":
+			return right
+		case right == "":
+			return left
+		}
+		return left + "·" + right
+	case "/":
+		switch {
+		case left == right:
+			return ""
+		case right == "":
+			return left
+		case left == "":
+			return "1/" + right
+		}
+		return left + "/" + right
+	case "^":
+		if exponent, ok := n.right.(*numberNode); ok && left != "" {
+			return left + "^" + fmt.Sprint(exponent.value)
+		}
+	}
+	return ""
+}
+
+func (n *binaryNode) children() []node { return []node{n.left, n.right} }
+
+type conditionalNode struct {
+	condition, then, otherwise node
+}
+
+func (n *conditionalNode) eval(values func(string) (float64, bool)) (float64, error) {
+	c, err := n.condition.eval(values)
+	if err != nil {
+		return 0, err
+	}
+	if c != 0 {
+		return n.then.eval(values)
+	}
+	return n.otherwise.eval(values)
+}
+
+func (n *conditionalNode) unit(units func(string) string) string {
+	return commonUnit(n.then.unit(units), n.otherwise.unit(units))
+}
+
+func (n *conditionalNode) children() []node { return []node{n.condition, n.then, n.otherwise} }
+
+type callNode struct {
+	name      string
+	function  function
+	arguments []node
+}
+
+func (n *callNode) eval(values func(string) (float64, bool)) (float64, error) {
+	if n.name == "if" {
+		return (&conditionalNode{n.arguments[0], n.arguments[1], n.arguments[2]}).eval(values)
+	}
+	arguments := make([]float64, len(n.arguments))
+	for i, argument := range n.arguments {
+		value, err := argument.eval(values)
+		if err != nil {
+			return 0, err
+		}
+		arguments[i] = value
+	}
+	return n.function.apply(arguments), nil
+}
+
+func (n *callNode) unit(units func(string) string) string {
+	switch {
+	case n.name == "if":
+		return commonUnit(n.arguments[1].unit(units), n.arguments[2].unit(units))
+	case n.function.keepsUnit:
+		unit := n.arguments[0].unit(units)
+		for _, argument := range n.arguments[1:] {
+			unit = commonUnit(unit, argument.unit(units))
+		}
+		return unit
+	}
+	return ""
+}
+
+func (n *callNode) children() []node { return n.arguments }
+
+// commonUnit returns the unit of a sum of values with the given units. A
+// number without unit takes the unit of the other operand.
+func commonUnit(a, b string) string {
+	switch {
+	case a == "":
+		return b
+	case b == "" || a == b:
+		return a
+	}
+	return ""
+}
+
+func boolean(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,141 @@
+package expression
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluate(t *testing.T) {
+	values := map[string]float64{
+		"a":                     3,
+		"b":                     4,
+		"zero":                  0,
+		"Position.X":            2,
+		"/YSS/SIMULATOR/TEMP_A": 20.5,
+	}
+	lookup := func(name string) (float64, bool) {
+		value, ok := values[name]
+		return value, ok
+	}
+
+	tests := []struct {
+		name       string
+		expression string
+		want       float64
+	}{
+		{name: "precedence", expression: "1 + 2 * 3", want: 7},
+		{name: "parentheses", expression: "(1 + 2) * 3", want: 9},
+		{name: "left associative", expression: "10 - 4 - 3", want: 3},
+		{name: "right associative power", expression: "2 ^ 3 ^ 2", want: 512},
+		{name: "unary minus and power", expression: "-2 ^ 2", want: -4},
+		{name: "modulo", expression: "7 % 4", want: 3},
+		{name: "exponent notation", expression: "1.5e3 + .5", want: 1500.5},
+		{name: "variables", expression: "a * b", want: 12},
+		{name: "dotted variable", expression: "Position.X + 1", want: 3},
+		{name: "quoted variable", expression: "`/YSS/SIMULATOR/TEMP_A` - 0.5", want: 20},
+		{name: "functions", expression: "hypot(a, b) + abs(-1)", want: 6},
+		{name: "variadic function", expression: "max(a, b, 1) - min(a, b)", want: 1},
+		{name: "clamp", expression: "clamp(a * 10, 0, 25)", want: 25},
+		{name: "comparison", expression: "a < b", want: 1},
+		{name: "logical", expression: "a > 1 && !zero", want: 1},
+		{name: "conditional", expression: "a > b ? a : b", want: 4},
+		{name: "nested conditional", expression: "zero ? 1 : a == 3 ? 2 : 3", want: 2},
+		{name: "short circuit skips missing", expression: "zero && missing", want: 0},
+		{name: "lazy if skips missing", expression: "if(a, b, missing)", want: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := Parse(tt.expression)
+			require.NoError(t, err)
+			got, err := e.Evaluate(lookup)
+			require.NoError(t, err)
+			assert.InDelta(t, tt.want, got, 1e-9)
+		})
+	}
+}
+
+func TestEvaluateMissingValue(t *testing.T) {
+	e, err := Parse("a + missing")
+	require.NoError(t, err)
+	_, err = e.Evaluate(func(name string) (float64, bool) { return 1, name == "a" })
+	assert.ErrorContains(t, err, "missing")
+}
+
+func TestEvaluateDivisionByZero(t *testing.T) {
+	e, err := Parse("1 / x")
+	require.NoError(t, err)
+	got, err := e.Evaluate(func(string) (float64, bool) { return 0, true })
+	require.NoError(t, err)
+	assert.True(t, math.IsInf(got, 1))
+}
+
+func TestVariables(t *testing.T) {
+	e, err := Parse("b * a + sqrt(b) + `x y`")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b", "a", "x y"}, e.Variables())
+	assert.Equal(t, "b * a + sqrt(b) + `x y`", e.String())
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		want       string
+	}{
+		{name: "empty", expression: "", want: "unexpected end of expression"},
+		{name: "trailing operator", expression: "a +", want: "unexpected end of expression"},
+		{name: "unbalanced parenthesis", expression: "(a + b", want: `expected ")"`},
+		{name: "extra token", expression: "a b", want: `unexpected "b"`},
+		{name: "unknown function", expression: "foo(a)", want: "unknown function foo"},
+		{name: "wrong arity", expression: "atan2(a)", want: "atan2 expects 2 arguments"},
+		{name: "no arguments", expression: "max()", want: "max expects arguments"},
+		{name: "unterminated name", expression: "`abc", want: "unterminated name"},
+		{name: "unexpected character", expression: "a $ b", want: "unexpected character"},
+		{name: "incomplete conditional", expression: "a ? b", want: `expected ":"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.expression)
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+}
+
+func TestUnit(t *testing.T) {
+	units := map[string]string{"V": "V", "I": "A", "V2": "V", "T": "degC"}
+	lookup := func(name string) string { return units[name] }
+
+	tests := []struct {
+		expression string
+		want       string
+	}{
+		{expression: "V * I", want: "V·A"},
+		{expression: "V + V2", want: "V"},
+		{expression: "V + 1", want: "V"},
+		{expression: "V + I", want: ""},
+		{expression: "V / V2", want: ""},
+		{expression: "V / I", want: "V/A"},
+		{expression: "V / 2", want: "V"},
+		{expression: "1 / I", want: "1/A"},
+		{expression: "V ^ 2", want: "V^2"},
+		{expression: "-T", want: "degC"},
+		{expression: "abs(T)", want: "degC"},
+		{expression: "sqrt(V)", want: ""},
+		{expression: "V > 3", want: ""},
+		{expression: "T > 0 ? T : 0", want: "degC"},
+		{expression: "max(V, V2)", want: "V"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			e, err := Parse(tt.expression)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, e.Unit(lookup))
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package expression
+
+import "math"
+
+// function is a function callable from expressions.
+type function struct {
+	// arity is the number of arguments, -1 for one or more.
+	arity int
+	// keepsUnit is set for functions whose result has the unit of their arguments.
+	keepsUnit bool
+	apply     func(arguments []float64) float64
+}
+
+func unary(f func(float64) float64, keepsUnit bool) function {
+	return function{arity: 1, keepsUnit: keepsUnit, apply: func(a []float64) float64 { return f(a[0]) }}
+}
+
+func binary(f func(float64, float64) float64, keepsUnit bool) function {
+	return function{arity: 2, keepsUnit: keepsUnit, apply: func(a []float64) float64 { return f(a[0], a[1]) }}
+}
+
+// functions are the functions expressions may call.
+var functions = map[string]function{
+	"abs":   unary(math.Abs, true),
+	"ceil":  unary(math.Ceil, true),
+	"floor": unary(math.Floor, true),
+	"round": unary(math.Round, true),
+	"sqrt":  unary(math.Sqrt, false),
+	"exp":   unary(math.Exp, false),
+	"log":   unary(math.Log, false),
+	"log10": unary(math.Log10, false),
+	"log2":  unary(math.Log2, false),
+	"sin":   unary(math.Sin, false),
+	"cos":   unary(math.Cos, false),
+	"tan":   unary(math.Tan, false),
+	"asin":  unary(math.Asin, false),
+	"acos":  unary(math.Acos, false),
+	"atan":  unary(math.Atan, false),
+	"atan2": binary(math.Atan2, false),
+	"pow":   binary(math.Pow, false),
+	"hypot": binary(math.Hypot, true),
+	"min": {arity: -1, keepsUnit: true, apply: func(a []float64) float64 {
+		result := a[0]
+		for _, x := range a[1:] {
+			result = math.Min(result, x)
+		}
+		return result
+	}},
+	"max": {arity: -1, keepsUnit: true, apply: func(a []float64) float64 {
+		result := a[0]
+		for _, x := range a[1:] {
+			result = math.Max(result, x)
+		}
+		return result
+	}},
+	"clamp": {arity: 3, keepsUnit: true, apply: func(a []float64) float64 {
+		return math.Max(a[1], math.Min(a[2], a[0]))
+	}},
+	// if(c, a, b) is evaluated lazily, as c ? a : b.
+	"if": {arity: 3},
+}
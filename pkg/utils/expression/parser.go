@@ -0,0 +1,292 @@
+package expression
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenName
+	tokenOperator
+)
+
+type token struct {
+	kind     tokenKind
+	text     string
+	position int
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of expression"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// operators are the operator tokens, longest first.
+var operators = []string{"<=", ">=", "==", "!=", "&&", "||", "+", "-", "*", "/", "%", "^", "<", ">", "!", "?", ":", "(", ")", ","}
+
+type lexer struct {
+	source   string
+	position int
+}
+
+func newLexer(source string) *lexer {
+	return &lexer{source: source}
+}
+
+func (l *lexer) next() (token, error) {
+	for l.position < len(l.source) && unicode.IsSpace(rune(l.source[l.position])) {
+		l.position++
+	}
+	start := l.position
+	if start == len(l.source) {
+		return token{kind: tokenEOF, position: start}, nil
+	}
+
+	c := l.source[start]
+	switch {
+	case c == '`':
+		end := strings.IndexByte(l.source[start+1:], '`')
+		if end < 0 {
+			return token{}, fmt.Errorf("unterminated name at %d", start)
+		}
+		l.position = start + end + 2
+		return token{kind: tokenName, text: l.source[start+1 : start+1+end], position: start}, nil
+	case isDigit(c) || (c == '.' && start+1 < len(l.source) && isDigit(l.source[start+1])):
+		for l.position < len(l.source) && (isDigit(l.source[l.position]) || l.source[l.position] == '.') {
+			l.position++
+		}
+		// Exponent, as in 1e-3.
+		if l.position < len(l.source) && (l.source[l.position] == 'e' || l.source[l.position] == 'E') {
+			l.position++
+			if l.position < len(l.source) && (l.source[l.position] == '+' || l.source[l.position] == '-') {
+				l.position++
+			}
+			for l.position < len(l.source) && isDigit(l.source[l.position]) {
+				l.position++
+			}
+		}
+		return token{kind: tokenNumber, text: l.source[start:l.position], position: start}, nil
+	case isNameStart(c):
+		for l.position < len(l.source) && (isNameStart(l.source[l.position]) || isDigit(l.source[l.position]) || l.source[l.position] == '.') {
+			l.position++
+		}
+		return token{kind: tokenName, text: l.source[start:l.position], position: start}, nil
+	}
+
+	for _, operator := range operators {
+		if strings.HasPrefix(l.source[start:], operator) {
+			l.position += len(operator)
+			return token{kind: tokenOperator, text: operator, position: start}, nil
+		}
+	}
+	return token{}, fmt.Errorf("unexpected character %q at %d", c, start)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// parser is a recursive descent parser, one method per precedence level.
+type parser struct {
+	lexer *lexer
+	token token
+}
+
+func (p *parser) advance() error {
+	t, err := p.lexer.next()
+	if err != nil {
+		return err
+	}
+	p.token = t
+	return nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf(format+" at %d", append(args, p.token.position)...)
+}
+
+// accept consumes the current token if it is one of the given operators.
+func (p *parser) accept(operators ...string) (string, bool, error) {
+	if p.token.kind != tokenOperator {
+		return "", false, nil
+	}
+	for _, operator := range operators {
+		if p.token.text == operator {
+			return operator, true, p.advance()
+		}
+	}
+	return "", false, nil
+}
+
+func (p *parser) expect(operator string) error {
+	if _, ok, err := p.accept(operator); err != nil || ok {
+		return err
+	}
+	return p.errorf("expected %q, found %s", operator, p.token)
+}
+
+func (p *parser) parseExpression() (node, error) {
+	condition, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok, err := p.accept("?"); err != nil || !ok {
+		return condition, err
+	}
+	then, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	otherwise, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	return &conditionalNode{condition, then, otherwise}, nil
+}
+
+// precedences lists the left-associative binary operators, loosest first.
+var precedences = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!=", "<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *parser) parseBinary(level int) (node, error) {
+	if level == len(precedences) {
+		return p.parseUnary()
+	}
+	left, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		operator, ok, err := p.accept(precedences[level]...)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{operator, left, right}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	operator, ok, err := p.accept("-", "+", "!")
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{operator, operand}, nil
+	}
+	return p.parsePower()
+}
+
+// parsePower parses the right-associative ^ operator, which binds tighter than
+// a unary minus on its left: -2^2 is -4.
+func (p *parser) parsePower() (node, error) {
+	base, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok, err := p.accept("^"); err != nil || !ok {
+		return base, err
+	}
+	exponent, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	return &binaryNode{"^", base, exponent}, nil
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.token
+	switch t.kind {
+	case tokenNumber:
+		value, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, p.errorf("invalid number %q", t.text)
+		}
+		return &numberNode{value}, p.advance()
+	case tokenName:
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if _, ok, err := p.accept("("); err != nil || !ok {
+			return &variableNode{t.text}, err
+		}
+		return p.parseCall(t)
+	case tokenOperator:
+		if t.text == "(" {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			inner, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			return inner, p.expect(")")
+		}
+	}
+	return nil, p.errorf("unexpected %s", t)
+}
+
+// parseCall parses the arguments of a call to name, whose "(" was consumed.
+func (p *parser) parseCall(name token) (node, error) {
+	f, exists := functions[name.text]
+	if !exists {
+		return nil, fmt.Errorf("unknown function %s at %d", name.text, name.position)
+	}
+	call := &callNode{name: name.text, function: f}
+	if _, ok, err := p.accept(")"); err != nil || ok {
+		if err == nil {
+			err = fmt.Errorf("%s expects arguments at %d", name.text, name.position)
+		}
+		return nil, err
+	}
+	for {
+		argument, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		call.arguments = append(call.arguments, argument)
+		if _, ok, err := p.accept(","); err != nil || !ok {
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	if f.arity >= 0 && len(call.arguments) != f.arity {
+		return nil, fmt.Errorf("%s expects %d arguments, got %d at %d", name.text, f.arity, len(call.arguments), name.position)
+	}
+	return call, nil
+}
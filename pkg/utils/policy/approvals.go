@@ -0,0 +1,154 @@
+package policy
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/tools"
+)
+
+var (
+	// ErrApprovalNotFound is returned for an unknown or already handled approval.
+	ErrApprovalNotFound = errors.New("no pending command with this id")
+	// ErrApprovalExpired is returned when the approval window has passed.
+	ErrApprovalExpired = errors.New("the command was not confirmed in time")
+	// ErrSelfApproval is returned when the requester tries to confirm their own command.
+	ErrSelfApproval = errors.New("a command must be confirmed by another user")
+)
+
+// Approval is a command held until a second user confirms it.
+type Approval struct {
+	ID          string                     `json:"id"`
+	Endpoint    string                     `json:"endpoint"`
+	Command     string                     `json:"command"`
+	Arguments   map[string]any             `json:"arguments"`
+	Options     *tools.CommandIssueOptions `json:"options,omitempty"`
+	Comment     string                     `json:"comment,omitempty"`
+	RequestedBy string                     `json:"requestedBy"`
+	Requested   time.Time                  `json:"requested"`
+	Expires     time.Time                  `json:"expires"`
+}
+
+// Approvals keeps the commands waiting for their confirmation. Expired
+// commands are dropped as the store is used.
+type Approvals struct {
+	mu      sync.Mutex
+	pending map[string]*Approval
+	now     func() time.Time
+}
+
+// NewApprovals creates an empty store.
+func NewApprovals() *Approvals {
+	return &Approvals{pending: map[string]*Approval{}, now: time.Now}
+}
+
+// Request holds a command, with its issue options if any, until it is
+// confirmed, for at most window.
+func (a *Approvals) Request(endpoint, command string, arguments map[string]any, options *tools.CommandIssueOptions, comment, requestedBy string, window time.Duration) Approval {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.expire()
+
+	now := a.now()
+	approval := &Approval{
+		ID:          newApprovalID(),
+		Endpoint:    endpoint,
+		Command:     command,
+		Arguments:   arguments,
+		Options:     options,
+		Comment:     comment,
+		RequestedBy: requestedBy,
+		Requested:   now,
+		Expires:     now.Add(window),
+	}
+	a.pending[approval.ID] = approval
+	return *approval
+}
+
+// newApprovalID returns a random approval ID, so that a pending command can
+// only be confirmed by users who were shown it.
+func newApprovalID() string {
+	id := make([]byte, 16)
+	rand.Read(id) // never fails, see crypto/rand.Read
+	return hex.EncodeToString(id)
+}
+
+// Pending lists the commands of an endpoint waiting for confirmation, oldest first.
+func (a *Approvals) Pending(endpoint string) []Approval {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.expire()
+
+	approvals := []Approval{}
+	for _, approval := range a.pending {
+		if approval.Endpoint == endpoint {
+			approvals = append(approvals, *approval)
+		}
+	}
+	sort.Slice(approvals, func(i, j int) bool { return approvals[i].Requested.Before(approvals[j].Requested) })
+	return approvals
+}
+
+// Get returns a pending command without taking it.
+func (a *Approvals) Get(endpoint, id string) (Approval, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	approval, err := a.lookup(endpoint, id)
+	if err != nil {
+		return Approval{}, err
+	}
+	return *approval, nil
+}
+
+// Confirm takes a pending command on behalf of confirmedBy, who must not be
+// the user who requested it. The command is removed from the store.
+func (a *Approvals) Confirm(endpoint, id, confirmedBy string) (Approval, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	approval, err := a.lookup(endpoint, id)
+	if err != nil {
+		return Approval{}, err
+	}
+	if confirmedBy == "" || confirmedBy == approval.RequestedBy {
+		return Approval{}, ErrSelfApproval
+	}
+	delete(a.pending, id)
+	return *approval, nil
+}
+
+// Reject drops a pending command.
+func (a *Approvals) Reject(endpoint, id string) (Approval, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	approval, err := a.lookup(endpoint, id)
+	if err != nil {
+		return Approval{}, err
+	}
+	delete(a.pending, id)
+	return *approval, nil
+}
+
+func (a *Approvals) lookup(endpoint, id string) (*Approval, error) {
+	approval, ok := a.pending[id]
+	if !ok || approval.Endpoint != endpoint {
+		return nil, ErrApprovalNotFound
+	}
+	if !a.now().Before(approval.Expires) {
+		delete(a.pending, id)
+		return nil, ErrApprovalExpired
+	}
+	return approval, nil
+}
+
+func (a *Approvals) expire() {
+	now := a.now()
+	for id, approval := range a.pending {
+		if !now.Before(approval.Expires) {
+			delete(a.pending, id)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+// Package policy decides which users may send which commands through an
+// endpoint, following the command rules of its configuration.
+package policy
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/config"
+)
+
+// DefaultApprovalWindow is how long a command waits for its confirmation when
+// the policy does not say otherwise.
+const DefaultApprovalWindow = 5 * time.Minute
+
+// roleRanks orders the Grafana organization roles.
+var roleRanks = map[string]int{
+	"Viewer": 1,
+	"Editor": 2,
+	"Admin":  3,
+}
+
+// User is the Grafana user sending or confirming a command.
+type User struct {
+	Login string
+	Email string
+	Role  string
+}
+
+// Identity names the user in approvals, preferring the login.
+func (u User) Identity() string {
+	if u.Login != "" {
+		return u.Login
+	}
+	return u.Email
+}
+
+// HasRole tells whether the user's role is at least role.
+func (u User) HasRole(role string) bool {
+	return roleRanks[u.Role] >= roleRanks[role]
+}
+
+// TeamChecker tells whether a user belongs to a Grafana team.
+type TeamChecker func(user User, team string) (bool, error)
+
+// Decision is the outcome of checking a command against a policy.
+type Decision struct {
+	// Allowed is true when the user may send the command.
+	Allowed bool
+	// Confirm is true when the command must be confirmed by a second user.
+	Confirm bool
+	// Reason explains why the command was refused.
+	Reason string
+}
+
+// Policy is the compiled command policy of an endpoint. A nil policy allows
+// every command.
+type Policy struct {
+	rules       []*config.CommandRuleConfiguration
+	defaultDeny bool
+	window      time.Duration
+}
+
+// New compiles the policy of an endpoint configuration, returning nil when
+// the endpoint has none.
+func New(cfg *config.CommandPolicyConfiguration) *Policy {
+	if cfg == nil {
+		return nil
+	}
+	policy := &Policy{defaultDeny: cfg.DefaultDeny, window: DefaultApprovalWindow}
+	for _, rule := range cfg.Rules {
+		if rule != nil && rule.Pattern != "" {
+			policy.rules = append(policy.rules, rule)
+		}
+	}
+	if cfg.ApprovalWindowSeconds > 0 {
+		policy.window = time.Duration(cfg.ApprovalWindowSeconds) * time.Second
+	}
+	return policy
+}
+
+// ApprovalWindow is how long a command may wait for its confirmation.
+func (p *Policy) ApprovalWindow() time.Duration {
+	if p == nil {
+		return DefaultApprovalWindow
+	}
+	return p.window
+}
+
+// Decide checks whether user may send the command with the given qualified
+// name, with contingency issue options when overrides is true. Team
+// membership is only looked up for rules that require it.
+//
+// Issue options must be granted explicitly by the matching rule. Without a
+// policy they are reserved to administrators.
+func (p *Policy) Decide(command string, user User, overrides bool, inTeam TeamChecker) (Decision, error) {
+	if p == nil {
+		if overrides && !user.HasRole("Admin") {
+			return Decision{Reason: fmt.Sprintf("command %s may only be sent with issue options by an administrator", command)}, nil
+		}
+		return Decision{Allowed: true}, nil
+	}
+	for _, rule := range p.rules {
+		if !Match(rule.Pattern, command) {
+			continue
+		}
+		if rule.Deny {
+			return Decision{Reason: fmt.Sprintf("command %s is not allowed on this endpoint", command)}, nil
+		}
+		if rule.MinRole != "" && !user.HasRole(rule.MinRole) {
+			return Decision{Reason: fmt.Sprintf("command %s requires the %s role", command, rule.MinRole)}, nil
+		}
+		if len(rule.Teams) > 0 {
+			member, err := memberOfAny(user, rule.Teams, inTeam)
+			if err != nil {
+				return Decision{}, err
+			}
+			if !member {
+				return Decision{Reason: fmt.Sprintf("command %s requires membership of team %s", command, strings.Join(rule.Teams, " or "))}, nil
+			}
+		}
+		if overrides && !rule.Overrides {
+			return Decision{Reason: fmt.Sprintf("command %s may not be sent with issue options", command)}, nil
+		}
+		return Decision{Allowed: true, Confirm: rule.Confirm}, nil
+	}
+	if p.defaultDeny {
+		return Decision{Reason: fmt.Sprintf("command %s is not allowed on this endpoint", command)}, nil
+	}
+	if overrides {
+		return Decision{Reason: fmt.Sprintf("command %s may not be sent with issue options", command)}, nil
+	}
+	return Decision{Allowed: true}, nil
+}
+
+func memberOfAny(user User, teams []string, inTeam TeamChecker) (bool, error) {
+	if inTeam == nil || user.Identity() == "" {
+		return false, nil
+	}
+	for _, team := range teams {
+		member, err := inTeam(user, team)
+		if err != nil {
+			return false, fmt.Errorf("could not check the members of team %s: %w", team, err)
+		}
+		if member {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Match tells whether a qualified command name matches pattern. Patterns are
+// matched segment by segment with path.Match, "**" standing for any number of
+// segments. A pattern without a leading "/" is matched against the last
+// segment of the name only.
+func Match(pattern, command string) bool {
+	if !strings.HasPrefix(pattern, "/") {
+		matched, _ := path.Match(pattern, path.Base(command))
+		return matched
+	}
+	return matchSegments(strings.Split(pattern[1:], "/"), strings.Split(strings.TrimPrefix(command, "/"), "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for skip := 0; skip <= len(name); skip++ {
+				if matchSegments(pattern[1:], name[skip:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if matched, _ := path.Match(pattern[0], name[0]); !matched {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
@@ -0,0 +1,119 @@
+package policy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatch(t *testing.T) {
+	for _, test := range []struct {
+		pattern, command string
+		match            bool
+	}{
+		{"/YSS/SIMULATOR/**", "/YSS/SIMULATOR/SWITCH_VOLTAGE_ON", true},
+		{"/YSS/SIMULATOR/**", "/YSS/SIMULATOR/POWER/RESET", true},
+		{"/YSS/SIMULATOR/**", "/YSS/OTHER/RESET", false},
+		{"/YSS/*/RESET", "/YSS/SIMULATOR/RESET", true},
+		{"/YSS/*/RESET", "/YSS/SIMULATOR/POWER/RESET", false},
+		{"/**/RESET", "/YSS/SIMULATOR/POWER/RESET", true},
+		{"SWITCH_*", "/YSS/SIMULATOR/SWITCH_VOLTAGE_ON", true},
+		{"SWITCH_*", "/YSS/SIMULATOR/DUMP", false},
+		{"/YSS/SIMULATOR/DUMP", "/YSS/SIMULATOR/DUMP", true},
+	} {
+		assert.Equal(t, test.match, Match(test.pattern, test.command), "%s on %s", test.pattern, test.command)
+	}
+}
+
+func TestPolicyDecide(t *testing.T) {
+	policy := New(&config.CommandPolicyConfiguration{
+		DefaultDeny: true,
+		Rules: []*config.CommandRuleConfiguration{
+			{Pattern: "/YSS/SIMULATOR/SELF_DESTRUCT", Deny: true},
+			{Pattern: "/YSS/SIMULATOR/POWER/**", MinRole: "Editor", Teams: []string{"flight", "power"}, Confirm: true},
+			{Pattern: "/YSS/SIMULATOR/RECOVER", MinRole: "Editor", Overrides: true},
+			{Pattern: "/YSS/SIMULATOR/*", MinRole: "Viewer"},
+		},
+	})
+	teams := map[string][]string{"flight": {"alice"}}
+	inTeam := func(user User, team string) (bool, error) {
+		for _, member := range teams[team] {
+			if member == user.Identity() {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	alice := User{Login: "alice", Role: "Editor"}
+	bob := User{Login: "bob", Role: "Admin"}
+	viewer := User{Login: "alice", Role: "Viewer"}
+
+	for _, test := range []struct {
+		command   string
+		user      User
+		overrides bool
+		decision  Decision
+	}{
+		{"/YSS/SIMULATOR/DUMP", viewer, false, Decision{Allowed: true}},
+		{"/YSS/SIMULATOR/SELF_DESTRUCT", bob, false, Decision{Reason: "command /YSS/SIMULATOR/SELF_DESTRUCT is not allowed on this endpoint"}},
+		{"/YSS/SIMULATOR/POWER/OFF", alice, false, Decision{Allowed: true, Confirm: true}},
+		{"/YSS/SIMULATOR/POWER/OFF", viewer, false, Decision{Reason: "command /YSS/SIMULATOR/POWER/OFF requires the Editor role"}},
+		{"/YSS/SIMULATOR/POWER/OFF", bob, false, Decision{Reason: "command /YSS/SIMULATOR/POWER/OFF requires membership of team flight or power"}},
+		{"/OTHER/DUMP", bob, false, Decision{Reason: "command /OTHER/DUMP is not allowed on this endpoint"}},
+		{"/YSS/SIMULATOR/DUMP", User{}, false, Decision{Reason: "command /YSS/SIMULATOR/DUMP requires the Viewer role"}},
+		{"/YSS/SIMULATOR/RECOVER", alice, true, Decision{Allowed: true}},
+		{"/YSS/SIMULATOR/DUMP", bob, true, Decision{Reason: "command /YSS/SIMULATOR/DUMP may not be sent with issue options"}},
+	} {
+		decision, err := policy.Decide(test.command, test.user, test.overrides, inTeam)
+		require.NoError(t, err)
+		assert.Equal(t, test.decision, decision, "%s by %+v", test.command, test.user)
+	}
+
+	decision, err := (*Policy)(nil).Decide("/ANYTHING", User{}, false, nil)
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed)
+	decision, err = (*Policy)(nil).Decide("/ANYTHING", alice, true, nil)
+	require.NoError(t, err)
+	assert.False(t, decision.Allowed)
+	decision, err = (*Policy)(nil).Decide("/ANYTHING", bob, true, nil)
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed)
+}
+
+func TestApprovals(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	approvals := NewApprovals()
+	approvals.now = func() time.Time { return now }
+
+	first := approvals.Request("ep", "/YSS/OFF", map[string]any{"n": 1}, nil, "", "alice", time.Minute)
+	now = now.Add(time.Second)
+	second := approvals.Request("ep", "/YSS/ON", nil, nil, "", "alice", time.Minute)
+	approvals.Request("other", "/YSS/ON", nil, nil, "", "alice", time.Minute)
+	assert.Equal(t, []Approval{first, second}, approvals.Pending("ep"))
+
+	_, err := approvals.Confirm("ep", first.ID, "alice")
+	assert.ErrorIs(t, err, ErrSelfApproval)
+	_, err = approvals.Confirm("other", first.ID, "bob")
+	assert.ErrorIs(t, err, ErrApprovalNotFound)
+	confirmed, err := approvals.Confirm("ep", first.ID, "bob")
+	require.NoError(t, err)
+	assert.Equal(t, first, confirmed)
+	_, err = approvals.Confirm("ep", first.ID, "bob")
+	assert.ErrorIs(t, err, ErrApprovalNotFound)
+
+	now = now.Add(time.Minute)
+	_, err = approvals.Confirm("ep", second.ID, "bob")
+	assert.ErrorIs(t, err, ErrApprovalExpired)
+	assert.Empty(t, approvals.Pending("ep"))
+}
+
+func TestApprovalIDs(t *testing.T) {
+	approvals := NewApprovals()
+	first := approvals.Request("ep", "/YSS/ON", nil, nil, "", "alice", time.Minute)
+	second := approvals.Request("ep", "/YSS/ON", nil, nil, "", "alice", time.Minute)
+	assert.Regexp(t, `^[0-9a-f]{32}$`, first.ID)
+	assert.NotEqual(t, first.ID, second.ID)
+}
@@ -0,0 +1,51 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Attribute keys shared by every span emitted by the plugin.
+const (
+	AttributeEndpoint   = attribute.Key("yamcs.endpoint")
+	AttributeInstance   = attribute.Key("yamcs.instance")
+	AttributeProcessor  = attribute.Key("yamcs.processor")
+	AttributeParameter  = attribute.Key("yamcs.parameter")
+	AttributeQueryType  = attribute.Key("yamcs.query.type")
+	AttributeMaxPoints  = attribute.Key("yamcs.query.max_points")
+	AttributePoints     = attribute.Key("yamcs.points")
+	AttributeRows       = attribute.Key("yamcs.frame.rows")
+	AttributePage       = attribute.Key("yamcs.page")
+	AttributeHasNext    = attribute.Key("yamcs.page.has_next")
+	AttributeStreamPath = attribute.Key("yamcs.stream.path")
+	AttributeMessage    = attribute.Key("yamcs.ws.message_type")
+	AttributeCall       = attribute.Key("yamcs.ws.call")
+
+	AttributeHTTPMethod = attribute.Key("http.request.method")
+	AttributeHTTPPath   = attribute.Key("url.path")
+	AttributeHTTPStatus = attribute.Key("http.response.status_code")
+	AttributeAttempt    = attribute.Key("http.request.resend_count")
+	AttributeBackoff    = attribute.Key("yamcs.http.backoff")
+)
+
+// Start opens a span named name as a child of whatever span ctx carries,
+// using the tracer configured by the Grafana plugin SDK.
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return tracing.DefaultTracer().Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// End records err on the span, if any, and ends it. Cancellation is how
+// streams normally terminate, so it is not recorded as an error.
+func End(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, context.Canceled) {
+		tracing.Error(span, err)
+	}
+	span.End()
+}
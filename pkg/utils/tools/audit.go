@@ -0,0 +1,58 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/audit"
+)
+
+// ConvertAuditEntriesToFrame lays audit entries out as a table, one row per
+// action. The time and text fields let the frame be used as annotations.
+func ConvertAuditEntriesToFrame(entries []audit.Entry) *data.Frame {
+	times := make([]time.Time, 0, len(entries))
+	users := make([]string, 0, len(entries))
+	actions := make([]string, 0, len(entries))
+	targets := make([]string, 0, len(entries))
+	outcomes := make([]string, 0, len(entries))
+	errs := make([]string, 0, len(entries))
+	overrides := make([]string, 0, len(entries))
+	payloads := make([]string, 0, len(entries))
+	responses := make([]string, 0, len(entries))
+	texts := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		times = append(times, entry.Time)
+		users = append(users, entry.User)
+		actions = append(actions, entry.Action)
+		targets = append(targets, entry.Target)
+		outcomes = append(outcomes, string(entry.Outcome))
+		errs = append(errs, entry.Error)
+		overrides = append(overrides, strings.Join(entry.Overrides, ", "))
+		payloads = append(payloads, string(entry.Payload))
+		responses = append(responses, string(entry.Response))
+
+		text := fmt.Sprintf("%s: %s %s (%s)", entry.User, entry.Action, entry.Target, entry.Outcome)
+		if len(entry.Overrides) > 0 {
+			text += " [" + strings.Join(entry.Overrides, ", ") + "]"
+		}
+		if entry.Error != "" {
+			text += ": " + entry.Error
+		}
+		texts = append(texts, text)
+	}
+
+	return data.NewFrame("audit",
+		data.NewField("time", nil, times),
+		data.NewField("user", nil, users),
+		data.NewField("action", nil, actions),
+		data.NewField("target", nil, targets),
+		data.NewField("outcome", nil, outcomes),
+		data.NewField("error", nil, errs),
+		data.NewField("overrides", nil, overrides),
+		data.NewField("payload", nil, payloads),
+		data.NewField("response", nil, responses),
+		data.NewField("text", nil, texts),
+	)
+}
@@ -0,0 +1,33 @@
+package tools
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/audit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertAuditEntriesToFrame(t *testing.T) {
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	frame := ConvertAuditEntriesToFrame([]audit.Entry{
+		{Time: at, User: "alice", Action: "command.issue", Target: "/YSS/ON", Payload: json.RawMessage(`{"comment":"go"}`), Outcome: audit.OutcomeSuccess},
+		{Time: at.Add(time.Minute), User: "bob", Action: "command.issue", Target: "/YSS/OFF", Outcome: audit.OutcomeDenied, Error: "command /YSS/OFF requires the Editor role"},
+		{Time: at.Add(2 * time.Minute), User: "carol", Action: "command.issue", Target: "/YSS/RECOVER", Outcome: audit.OutcomeSuccess,
+			Overrides: []string{"disable-verifiers", "stream:tc_backup"}},
+	})
+
+	require.Equal(t, 3, frame.Rows())
+	field, _ := frame.FieldByName("text")
+	assert.Equal(t, "alice: command.issue /YSS/ON (success)", field.At(0))
+	assert.Equal(t, "bob: command.issue /YSS/OFF (denied): command /YSS/OFF requires the Editor role", field.At(1))
+	assert.Equal(t, "carol: command.issue /YSS/RECOVER (success) [disable-verifiers, stream:tc_backup]", field.At(2))
+	field, _ = frame.FieldByName("overrides")
+	assert.Equal(t, "", field.At(0))
+	assert.Equal(t, "disable-verifiers, stream:tc_backup", field.At(2))
+	field, _ = frame.FieldByName("payload")
+	assert.Equal(t, `{"comment":"go"}`, field.At(0))
+	assert.Equal(t, "", field.At(1))
+}
@@ -0,0 +1,179 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"time"
+	"unicode/utf8"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/pvalue"
+)
+
+// PixelFormat is the layout of one pixel in a raw sensor frame.
+type PixelFormat string
+
+const (
+	PixelGray8  PixelFormat = "gray8"
+	PixelGray16 PixelFormat = "gray16" // big endian
+	PixelRGB24  PixelFormat = "rgb24"
+	PixelRGBA32 PixelFormat = "rgba32"
+)
+
+// bytesPerPixel returns the size of a pixel, or 0 for an unknown format.
+func (format PixelFormat) bytesPerPixel() int {
+	switch format {
+	case PixelGray8:
+		return 1
+	case PixelGray16:
+		return 2
+	case PixelRGB24:
+		return 3
+	case PixelRGBA32:
+		return 4
+	}
+	return 0
+}
+
+// RawImageFormat describes the raw sensor frames a binary parameter holds.
+type RawImageFormat struct {
+	Width       int
+	Height      int
+	PixelFormat PixelFormat
+}
+
+const (
+	MimePNG    = "image/png"
+	MimeJPEG   = "image/jpeg"
+	MimeGIF    = "image/gif"
+	MimeJSON   = "application/json"
+	MimeText   = "text/plain"
+	MimeBinary = "application/octet-stream"
+)
+
+// DetectMimeType identifies the content of a binary value: PNG, JPEG and GIF
+// images by their magic bytes, then JSON documents and text.
+func DetectMimeType(payload []byte) string {
+	switch {
+	case bytes.HasPrefix(payload, []byte("\x89PNG\r\n\x1a\n")):
+		return MimePNG
+	case bytes.HasPrefix(payload, []byte{0xFF, 0xD8, 0xFF}):
+		return MimeJPEG
+	case bytes.HasPrefix(payload, []byte("GIF87a")), bytes.HasPrefix(payload, []byte("GIF89a")):
+		return MimeGIF
+	}
+	trimmed := bytes.TrimSpace(payload)
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid(trimmed) {
+		return MimeJSON
+	}
+	if len(payload) > 0 && utf8.Valid(payload) && bytes.IndexFunc(payload, isControl) < 0 {
+		return MimeText
+	}
+	return MimeBinary
+}
+
+func isControl(r rune) bool {
+	return r < 0x20 && r != '\n' && r != '\r' && r != '\t'
+}
+
+// DecodeBinary turns a binary value into a data URL. Encoded images are passed
+// through with their mime type; other payloads are taken as raw frames of the
+// given format, if any, and encoded to PNG. Anything else is returned as is.
+func DecodeBinary(payload []byte, raw *RawImageFormat) (string, error) {
+	mimeType := DetectMimeType(payload)
+	if raw != nil && (mimeType == MimeBinary || mimeType == MimeText) {
+		encoded, err := EncodeRawImage(payload, *raw)
+		if err != nil {
+			return dataURL(MimeBinary, payload), err
+		}
+		return dataURL(MimePNG, encoded), nil
+	}
+	return dataURL(mimeType, payload), nil
+}
+
+func dataURL(mimeType string, payload []byte) string {
+	return "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(payload)
+}
+
+// EncodeRawImage encodes a raw sensor frame into PNG.
+func EncodeRawImage(payload []byte, format RawImageFormat) ([]byte, error) {
+	size := format.PixelFormat.bytesPerPixel()
+	if size == 0 {
+		return nil, fmt.Errorf("unknown pixel format %q", format.PixelFormat)
+	}
+	if format.Width <= 0 || format.Height <= 0 {
+		return nil, fmt.Errorf("invalid frame size %dx%d", format.Width, format.Height)
+	}
+	if expected := format.Width * format.Height * size; len(payload) != expected {
+		return nil, fmt.Errorf("frame holds %d bytes, %dx%d %s needs %d", len(payload), format.Width, format.Height, format.PixelFormat, expected)
+	}
+
+	bounds := image.Rect(0, 0, format.Width, format.Height)
+	var img image.Image
+	switch format.PixelFormat {
+	case PixelGray8:
+		img = &image.Gray{Pix: payload, Stride: format.Width, Rect: bounds}
+	case PixelGray16:
+		img = &image.Gray16{Pix: payload, Stride: 2 * format.Width, Rect: bounds}
+	case PixelRGB24:
+		rgba := image.NewNRGBA(bounds)
+		for i := 0; i < format.Width*format.Height; i++ {
+			rgba.SetNRGBA(i%format.Width, i/format.Width, color.NRGBA{R: payload[3*i], G: payload[3*i+1], B: payload[3*i+2], A: 0xFF})
+		}
+		img = rgba
+	case PixelRGBA32:
+		img = &image.NRGBA{Pix: payload, Stride: 4 * format.Width, Rect: bounds}
+	}
+
+	buffer := &bytes.Buffer{}
+	if err := png.Encode(buffer, img); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// IsBinaryValue reports whether the member of value at aggregatePath is binary.
+func IsBinaryValue(value *protobuf.Value, aggregatePath string) bool {
+	return memberValue(value, aggregatePath).GetType() == protobuf.Value_BINARY
+}
+
+// memberValue returns the member of value at aggregatePath, or value itself
+// when the path is empty.
+func memberValue(value *protobuf.Value, aggregatePath string) *protobuf.Value {
+	if aggregatePath == "" {
+		return value
+	}
+	return aggregateExtractFromPath(value, aggregatePath)
+}
+
+// ConvertBufferToBinaryFrame converts the binary values of a buffer into a frame
+// of data URLs, as decoded by DecodeBinary. Values that cannot be decoded are
+// kept as octet streams and reported in the frame notices.
+func ConvertBufferToBinaryFrame(buffer []*pvalue.ParameterValue, parameter string, aggregatePath string, raw *RawImageFormat) *data.Frame {
+	times := make([]time.Time, 0, len(buffer))
+	urls := make([]string, 0, len(buffer))
+	notices := []data.Notice{}
+	for _, item := range buffer {
+		url, err := DecodeBinary(memberValue(item.GetEngValue(), aggregatePath).GetBinaryValue(), raw)
+		if err != nil {
+			notices = append(notices, data.Notice{Severity: data.NoticeSeverityWarning, Text: err.Error()})
+		}
+		times = append(times, item.GetGenerationTime().AsTime())
+		urls = append(urls, url)
+	}
+
+	frame := data.NewFrame("response",
+		data.NewField("time", nil, times),
+		data.NewField(parameter, nil, urls),
+	)
+	if len(notices) > 0 {
+		frame.Meta = &data.FrameMeta{Notices: notices}
+	}
+	return frame
+}
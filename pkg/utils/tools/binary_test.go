@@ -0,0 +1,79 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image/png"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/pvalue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestDetectMimeType(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload []byte
+		want    string
+	}{
+		{name: "PNG", payload: []byte("\x89PNG\r\n\x1a\n\x00\x00"), want: MimePNG},
+		{name: "JPEG", payload: []byte{0xFF, 0xD8, 0xFF, 0xE0}, want: MimeJPEG},
+		{name: "GIF", payload: []byte("GIF89a..."), want: MimeGIF},
+		{name: "JSON", payload: []byte(` {"mode": "SAFE"}`), want: MimeJSON},
+		{name: "Text", payload: []byte("hello\n"), want: MimeText},
+		{name: "Raw", payload: []byte{0x00, 0x01, 0x02}, want: MimeBinary},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, DetectMimeType(tt.payload))
+		})
+	}
+}
+
+func TestDecodeBinaryRawFrame(t *testing.T) {
+	raw := &RawImageFormat{Width: 2, Height: 2, PixelFormat: PixelGray8}
+
+	url, err := DecodeBinary([]byte{0x00, 0x40, 0x80, 0xFF}, raw)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(url, "data:image/png;base64,"))
+	encoded, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(url, "data:image/png;base64,"))
+	require.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(encoded))
+	require.NoError(t, err)
+	assert.Equal(t, 2, img.Bounds().Dx())
+	r, _, _, _ := img.At(1, 1).RGBA()
+	assert.Equal(t, uint32(0xFFFF), r)
+
+	url, err = DecodeBinary([]byte{0x00, 0x01, 0x02}, raw)
+	assert.Error(t, err)
+	assert.True(t, strings.HasPrefix(url, "data:application/octet-stream;base64,"))
+}
+
+func TestConvertBufferToBinaryFrame(t *testing.T) {
+	start := time.Unix(1700000000, 0).UTC()
+	buffer := []*pvalue.ParameterValue{{
+		GenerationTime: timestamppb.New(start),
+		EngValue: &protobuf.Value{
+			Type: protobuf.Value_AGGREGATE.Enum(),
+			AggregateValue: &protobuf.AggregateValue{
+				Name:  []string{"image"},
+				Value: []*protobuf.Value{{Type: protobuf.Value_BINARY.Enum(), BinaryValue: []byte{0xFF, 0xD8, 0xFF}}},
+			},
+		},
+	}}
+
+	assert.True(t, IsBinaryValue(buffer[0].GetEngValue(), ".image"))
+	assert.False(t, IsBinaryValue(buffer[0].GetEngValue(), ""))
+
+	frame := ConvertBufferToBinaryFrame(buffer, "/YSS/Camera.image", ".image", nil)
+	require.Len(t, frame.Fields, 2)
+	assert.Equal(t, start, frame.Fields[0].At(0))
+	assert.Equal(t, "data:image/jpeg;base64,/9j/", frame.Fields[1].At(0))
+	assert.Nil(t, frame.Meta)
+}
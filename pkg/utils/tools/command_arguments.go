@@ -0,0 +1,320 @@
+package tools
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/mdb"
+)
+
+// ArgumentError describes why the value given to a command argument would be
+// rejected.
+type ArgumentError struct {
+	// Argument is the path of the faulty value, such as "mode", "config.gain"
+	// or "table[3]", or empty when the error is not tied to an argument.
+	Argument string `json:"argument,omitempty"`
+	// Code classifies the error: MISSING, UNKNOWN, TYPE, RANGE, ENUM, LENGTH
+	// or REJECTED when Yamcs refused the command.
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e ArgumentError) Error() string {
+	if e.Argument == "" {
+		return e.Message
+	}
+	return e.Argument + ": " + e.Message
+}
+
+// CommandArguments returns the arguments a user may give to a command,
+// including those inherited from its base commands, in the order they are
+// defined. Arguments assigned by the command or one of its ancestors are left
+// out.
+func CommandArguments(info *mdb.CommandInfo) []*mdb.ArgumentInfo {
+	chain := []*mdb.CommandInfo{}
+	for c := info; c != nil; c = c.GetBaseCommand() {
+		chain = append(chain, c)
+	}
+	assigned := map[string]bool{}
+	for _, c := range chain {
+		for _, assignment := range c.GetArgumentAssignment() {
+			assigned[assignment.GetName()] = true
+		}
+	}
+
+	arguments := []*mdb.ArgumentInfo{}
+	seen := map[string]bool{}
+	for i := len(chain) - 1; i >= 0; i-- {
+		for _, argument := range chain[i].GetArgument() {
+			name := argument.GetName()
+			if assigned[name] || seen[name] {
+				continue
+			}
+			seen[name] = true
+			arguments = append(arguments, argument)
+		}
+	}
+	return arguments
+}
+
+// ValidateCommandArguments checks args against the argument types of a
+// command: numeric ranges, enumeration labels, string and binary lengths,
+// array dimensions and aggregate members. It returns the arguments with
+// missing values and aggregate members filled in from their initial values,
+// along with one error per faulty value.
+func ValidateCommandArguments(info *mdb.CommandInfo, args map[string]any) (map[string]any, []ArgumentError) {
+	completed := map[string]any{}
+	errs := []ArgumentError{}
+
+	known := map[string]bool{}
+	for _, argument := range CommandArguments(info) {
+		name := argument.GetName()
+		known[name] = true
+		value, ok := args[name]
+		if !ok || value == nil {
+			if argument.InitialValue == nil {
+				errs = append(errs, ArgumentError{Argument: name, Code: "MISSING", Message: "no value given and no initial value defined"})
+				continue
+			}
+			value = initialArgumentValue(argument.GetType(), argument.GetInitialValue())
+		}
+		checked, argErrs := validateArgumentValue(name, argument.GetType(), value)
+		completed[name] = checked
+		errs = append(errs, argErrs...)
+	}
+
+	unknown := []string{}
+	for name := range args {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		errs = append(errs, ArgumentError{Argument: name, Code: "UNKNOWN", Message: "the command has no such argument"})
+	}
+	return completed, errs
+}
+
+// initialArgumentValue decodes the initial value of an argument, which the MDB
+// gives as a string. Aggregate and array initial values are JSON documents;
+// other values are kept as strings, as Yamcs accepts them for every type.
+func initialArgumentValue(argType *mdb.ArgumentTypeInfo, initial string) any {
+	switch strings.ToLower(argType.GetEngType()) {
+	case "aggregate", "array":
+		var value any
+		if err := json.Unmarshal([]byte(initial), &value); err == nil {
+			return value
+		}
+	}
+	return initial
+}
+
+// validateArgumentValue checks value against argType and returns it with
+// aggregate members completed, along with the errors found at path or below.
+func validateArgumentValue(path string, argType *mdb.ArgumentTypeInfo, value any) (any, []ArgumentError) {
+	fail := func(code, format string, args ...any) (any, []ArgumentError) {
+		return value, []ArgumentError{{Argument: path, Code: code, Message: fmt.Sprintf(format, args...)}}
+	}
+
+	switch engType := strings.ToLower(argType.GetEngType()); engType {
+	case "integer":
+		number, ok := argumentNumber(value)
+		if !ok || number != math.Trunc(number) {
+			return fail("TYPE", "%v is not an integer", value)
+		}
+		if argType.Signed != nil && !argType.GetSigned() && number < 0 {
+			return fail("RANGE", "%v is negative but the argument is unsigned", value)
+		}
+		return validateArgumentRange(path, argType, value, number)
+
+	case "float":
+		number, ok := argumentNumber(value)
+		if !ok {
+			return fail("TYPE", "%v is not a number", value)
+		}
+		return validateArgumentRange(path, argType, value, number)
+
+	case "boolean":
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			switch v {
+			case "true", argType.GetOneStringValue():
+				return v, nil
+			case "false", argType.GetZeroStringValue():
+				return v, nil
+			}
+		}
+		return fail("TYPE", "%v is not a boolean", value)
+
+	case "enumeration":
+		label, ok := value.(string)
+		labels := make([]string, 0, len(argType.GetEnumValue()))
+		for _, enum := range argType.GetEnumValue() {
+			if ok && enum.GetLabel() == label {
+				return value, nil
+			}
+			labels = append(labels, enum.GetLabel())
+		}
+		return fail("ENUM", "%v is not one of %s", value, strings.Join(labels, ", "))
+
+	case "string":
+		text, ok := value.(string)
+		if !ok {
+			return fail("TYPE", "%v is not a string", value)
+		}
+		length := utf8.RuneCountInString(text)
+		if argType.MinChars != nil && length < int(argType.GetMinChars()) {
+			return fail("LENGTH", "%d characters given, at least %d expected", length, argType.GetMinChars())
+		}
+		if argType.MaxChars != nil && length > int(argType.GetMaxChars()) {
+			return fail("LENGTH", "%d characters given, at most %d expected", length, argType.GetMaxChars())
+		}
+		return value, nil
+
+	case "binary":
+		text, ok := value.(string)
+		if !ok {
+			return fail("TYPE", "%v is not a hexadecimal string", value)
+		}
+		decoded, err := hex.DecodeString(text)
+		if err != nil {
+			return fail("TYPE", "%q is not a hexadecimal string", text)
+		}
+		if argType.MinBytes != nil && len(decoded) < int(argType.GetMinBytes()) {
+			return fail("LENGTH", "%d bytes given, at least %d expected", len(decoded), argType.GetMinBytes())
+		}
+		if argType.MaxBytes != nil && len(decoded) > int(argType.GetMaxBytes()) {
+			return fail("LENGTH", "%d bytes given, at most %d expected", len(decoded), argType.GetMaxBytes())
+		}
+		return value, nil
+
+	case "time":
+		text, ok := value.(string)
+		if !ok {
+			return fail("TYPE", "%v is not a time", value)
+		}
+		if _, err := time.Parse(time.RFC3339Nano, text); err != nil {
+			return fail("TYPE", "%q is not an RFC 3339 time", text)
+		}
+		return value, nil
+
+	case "aggregate":
+		members, ok := value.(map[string]any)
+		if !ok {
+			return fail("TYPE", "%v is not an aggregate", value)
+		}
+		return validateAggregate(path, argType, members)
+
+	case "array":
+		return validateArray(path, argType.GetDimensions(), argType.GetElementType(), value)
+	}
+
+	// Unknown types are left for Yamcs to check.
+	return value, nil
+}
+
+// validateArgumentRange checks that number, parsed from value, lies within the
+// range of argType.
+func validateArgumentRange(path string, argType *mdb.ArgumentTypeInfo, value any, number float64) (any, []ArgumentError) {
+	if (argType.RangeMin != nil && number < argType.GetRangeMin()) || (argType.RangeMax != nil && number > argType.GetRangeMax()) {
+		message := fmt.Sprintf("%v is out of range [%s, %s]", value, formatBound(argType.RangeMin, "-inf"), formatBound(argType.RangeMax, "+inf"))
+		return value, []ArgumentError{{Argument: path, Code: "RANGE", Message: message}}
+	}
+	return value, nil
+}
+
+func formatBound(bound *float64, unbounded string) string {
+	if bound == nil {
+		return unbounded
+	}
+	return strconv.FormatFloat(*bound, 'g', -1, 64)
+}
+
+// validateAggregate checks every member of an aggregate value, filling missing
+// members from their initial values.
+func validateAggregate(path string, argType *mdb.ArgumentTypeInfo, members map[string]any) (any, []ArgumentError) {
+	completed := map[string]any{}
+	errs := []ArgumentError{}
+	known := map[string]bool{}
+	for _, member := range argType.GetMember() {
+		name := member.GetName()
+		memberPath := path + "." + name
+		known[name] = true
+		value, ok := members[name]
+		if !ok || value == nil {
+			if member.InitialValue == nil {
+				errs = append(errs, ArgumentError{Argument: memberPath, Code: "MISSING", Message: "no value given and no initial value defined"})
+				continue
+			}
+			value = initialArgumentValue(member.GetType(), member.GetInitialValue())
+		}
+		checked, memberErrs := validateArgumentValue(memberPath, member.GetType(), value)
+		completed[name] = checked
+		errs = append(errs, memberErrs...)
+	}
+
+	unknown := []string{}
+	for name := range members {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		errs = append(errs, ArgumentError{Argument: path + "." + name, Code: "UNKNOWN", Message: "the aggregate has no such member"})
+	}
+	return completed, errs
+}
+
+// validateArray checks the length of value along each dimension of fixed size
+// and every element against elementType. Dimensions sized by a parameter or
+// another argument are left for Yamcs to check.
+func validateArray(path string, dimensions []*mdb.ArgumentDimensionInfo, elementType *mdb.ArgumentTypeInfo, value any) (any, []ArgumentError) {
+	if len(dimensions) == 0 {
+		return validateArgumentValue(path, elementType, value)
+	}
+	elements, ok := value.([]any)
+	if !ok {
+		return value, []ArgumentError{{Argument: path, Code: "TYPE", Message: fmt.Sprintf("%v is not an array", value)}}
+	}
+	if dimension := dimensions[0]; dimension.FixedValue != nil && int64(len(elements)) != dimension.GetFixedValue() {
+		message := fmt.Sprintf("%d elements given, %d expected", len(elements), dimension.GetFixedValue())
+		return value, []ArgumentError{{Argument: path, Code: "LENGTH", Message: message}}
+	}
+
+	checked := make([]any, len(elements))
+	errs := []ArgumentError{}
+	for i, element := range elements {
+		var elementErrs []ArgumentError
+		checked[i], elementErrs = validateArray(fmt.Sprintf("%s[%d]", path, i), dimensions[1:], elementType, element)
+		errs = append(errs, elementErrs...)
+	}
+	return checked, errs
+}
+
+// argumentNumber reads a numeric argument value, given either as a JSON number
+// or as a decimal string.
+func argumentNumber(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case string:
+		number, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return number, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,117 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/mdb"
+	"github.com/stretchr/testify/assert"
+)
+
+func testCommandInfo() *mdb.CommandInfo {
+	base := &mdb.CommandInfo{
+		Name: pointer("Base"),
+		Argument: []*mdb.ArgumentInfo{
+			{Name: pointer("apid"), Type: &mdb.ArgumentTypeInfo{EngType: pointer("integer")}},
+			{Name: pointer("priority"), InitialValue: pointer("2"), Type: &mdb.ArgumentTypeInfo{
+				EngType: pointer("integer"), Signed: pointer(false), RangeMin: pointer(0.0), RangeMax: pointer(3.0),
+			}},
+		},
+	}
+	return &mdb.CommandInfo{
+		Name:               pointer("SetMode"),
+		BaseCommand:        base,
+		ArgumentAssignment: []*mdb.ArgumentAssignmentInfo{{Name: pointer("apid"), Value: pointer("100")}},
+		Argument: []*mdb.ArgumentInfo{
+			{Name: pointer("mode"), Type: &mdb.ArgumentTypeInfo{
+				EngType:   pointer("enumeration"),
+				EnumValue: []*mdb.EnumValue{{Value: pointer(int64(0)), Label: pointer("SAFE")}, {Value: pointer(int64(1)), Label: pointer("NOMINAL")}},
+			}},
+			{Name: pointer("gain"), Type: &mdb.ArgumentTypeInfo{EngType: pointer("float"), RangeMin: pointer(-1.5)}},
+			{Name: pointer("label"), InitialValue: pointer("none"), Type: &mdb.ArgumentTypeInfo{EngType: pointer("string"), MaxChars: pointer(int32(8))}},
+			{Name: pointer("key"), InitialValue: pointer("00ff"), Type: &mdb.ArgumentTypeInfo{EngType: pointer("binary"), MinBytes: pointer(int32(2))}},
+			{Name: pointer("table"), InitialValue: pointer("[1, 2, 3]"), Type: &mdb.ArgumentTypeInfo{
+				EngType:     pointer("array"),
+				Dimensions:  []*mdb.ArgumentDimensionInfo{{FixedValue: pointer(int64(3))}},
+				ElementType: &mdb.ArgumentTypeInfo{EngType: pointer("integer"), RangeMax: pointer(10.0)},
+			}},
+			{Name: pointer("config"), Type: &mdb.ArgumentTypeInfo{
+				EngType: pointer("aggregate"),
+				Member: []*mdb.ArgumentMemberInfo{
+					{Name: pointer("enabled"), Type: &mdb.ArgumentTypeInfo{EngType: pointer("boolean"), OneStringValue: pointer("ON"), ZeroStringValue: pointer("OFF")}},
+					{Name: pointer("delay"), InitialValue: pointer("5"), Type: &mdb.ArgumentTypeInfo{EngType: pointer("integer")}},
+				},
+			}},
+		},
+	}
+}
+
+func TestCommandArgumentsSkipsAssignedArguments(t *testing.T) {
+	names := []string{}
+	for _, argument := range CommandArguments(testCommandInfo()) {
+		names = append(names, argument.GetName())
+	}
+	assert.Equal(t, []string{"priority", "mode", "gain", "label", "key", "table", "config"}, names)
+}
+
+func TestValidateCommandArgumentsFillsInitialValues(t *testing.T) {
+	completed, errs := ValidateCommandArguments(testCommandInfo(), map[string]any{
+		"mode":   "NOMINAL",
+		"gain":   "0.5",
+		"config": map[string]any{"enabled": "ON"},
+	})
+	assert.Empty(t, errs)
+	assert.Equal(t, map[string]any{
+		"priority": "2",
+		"mode":     "NOMINAL",
+		"gain":     "0.5",
+		"label":    "none",
+		"key":      "00ff",
+		"table":    []any{float64(1), float64(2), float64(3)},
+		"config":   map[string]any{"enabled": "ON", "delay": "5"},
+	}, completed)
+}
+
+func TestValidateCommandArgumentsReportsEachFault(t *testing.T) {
+	_, errs := ValidateCommandArguments(testCommandInfo(), map[string]any{
+		"priority": float64(-1),
+		"mode":     "SAEF",
+		"gain":     -2.0,
+		"label":    "much too long",
+		"key":      "0g",
+		"table":    []any{float64(1), float64(20), 2.5},
+		"config":   map[string]any{"enabled": "maybe", "extra": 1},
+		"apid":     float64(200),
+	})
+
+	byArgument := map[string]string{}
+	for _, err := range errs {
+		byArgument[err.Argument] = err.Code
+	}
+	assert.Equal(t, map[string]string{
+		"priority":       "RANGE",
+		"mode":           "ENUM",
+		"gain":           "RANGE",
+		"label":          "LENGTH",
+		"key":            "TYPE",
+		"table[1]":       "RANGE",
+		"table[2]":       "TYPE",
+		"config.enabled": "TYPE",
+		"config.extra":   "UNKNOWN",
+		"apid":           "UNKNOWN",
+	}, byArgument)
+	assert.Contains(t, errs, ArgumentError{Argument: "mode", Code: "ENUM", Message: "SAEF is not one of SAFE, NOMINAL"})
+	assert.Contains(t, errs, ArgumentError{Argument: "gain", Code: "RANGE", Message: "-2 is out of range [-1.5, +inf]"})
+}
+
+func TestValidateCommandArgumentsRequiresValues(t *testing.T) {
+	_, errs := ValidateCommandArguments(testCommandInfo(), map[string]any{
+		"gain":   1.0,
+		"table":  []any{float64(1)},
+		"config": map[string]any{},
+	})
+	assert.Equal(t, []ArgumentError{
+		{Argument: "mode", Code: "MISSING", Message: "no value given and no initial value defined"},
+		{Argument: "table", Code: "LENGTH", Message: "1 elements given, 3 expected"},
+		{Argument: "config.enabled", Code: "MISSING", Message: "no value given and no initial value defined"},
+	}, errs)
+}
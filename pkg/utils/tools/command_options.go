@@ -0,0 +1,160 @@
+package tools
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/commanding"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/mdb"
+	"google.golang.org/protobuf/proto"
+)
+
+// CommandIssueOptions are the issue options reserved to contingency
+// operations: they bypass the checks Yamcs normally makes on a command or
+// send it somewhere else than the default stream.
+type CommandIssueOptions struct {
+	// DisableTransmissionConstraints releases the command without waiting for
+	// its transmission constraints.
+	DisableTransmissionConstraints bool `json:"disableTransmissionConstraints,omitempty"`
+	// DisableVerifiers skips every verifier of the command.
+	DisableVerifiers bool `json:"disableVerifiers,omitempty"`
+	// Verifiers overrides individual verifiers, by stage name.
+	Verifiers map[string]VerifierOverride `json:"verifiers,omitempty"`
+	// Stream is the TC stream to send the command to, e.g. a backup stream.
+	Stream string `json:"stream,omitempty"`
+	// Extra holds additional command attributes, as strings, booleans or numbers.
+	Extra map[string]any `json:"extra,omitempty"`
+}
+
+// VerifierOverride disables a verifier or changes its check window, in
+// milliseconds relative to the release of the command.
+type VerifierOverride struct {
+	Disable             bool   `json:"disable,omitempty"`
+	TimeToStartChecking *int64 `json:"timeToStartChecking,omitempty"`
+	TimeToStopChecking  *int64 `json:"timeToStopChecking,omitempty"`
+}
+
+// Overrides lists the options in use, as recorded in the audit log. It is
+// empty when the options change nothing.
+func (o *CommandIssueOptions) Overrides() []string {
+	if o == nil {
+		return nil
+	}
+	var overrides []string
+	if o.DisableTransmissionConstraints {
+		overrides = append(overrides, "disable-transmission-constraints")
+	}
+	if o.DisableVerifiers {
+		overrides = append(overrides, "disable-verifiers")
+	}
+	stages := make([]string, 0, len(o.Verifiers))
+	for stage := range o.Verifiers {
+		stages = append(stages, stage)
+	}
+	sort.Strings(stages)
+	for _, stage := range stages {
+		if o.Verifiers[stage].Disable {
+			overrides = append(overrides, "disable-verifier:"+stage)
+		} else {
+			overrides = append(overrides, "verifier-window:"+stage)
+		}
+	}
+	if o.Stream != "" {
+		overrides = append(overrides, "stream:"+o.Stream)
+	}
+	if len(o.Extra) > 0 {
+		overrides = append(overrides, "extra")
+	}
+	return overrides
+}
+
+// Check rejects the options that do not apply to command: verifiers or
+// transmission constraints it does not have, and extra attributes that are not
+// among the command options of the server.
+func (o *CommandIssueOptions) Check(command *mdb.CommandInfo, commandOptions []string) error {
+	if o == nil {
+		return nil
+	}
+	stages := map[string]bool{}
+	constrained := false
+	for info := command; info != nil; info = info.GetBaseCommand() {
+		for _, verifier := range info.GetVerifier() {
+			stages[verifier.GetStage()] = true
+		}
+		constrained = constrained || len(info.GetConstraint()) > 0
+	}
+
+	var unknown []string
+	if o.DisableTransmissionConstraints && !constrained {
+		unknown = append(unknown, "disableTransmissionConstraints (the command has no transmission constraints)")
+	}
+	if o.DisableVerifiers && len(stages) == 0 {
+		unknown = append(unknown, "disableVerifiers (the command has no verifiers)")
+	}
+	for stage := range o.Verifiers {
+		if !stages[stage] {
+			unknown = append(unknown, "verifier "+stage)
+		}
+	}
+	for name := range o.Extra {
+		if !slices.Contains(commandOptions, name) {
+			unknown = append(unknown, "extra attribute "+name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("issue options not offered by %s: %s", command.GetQualifiedName(), strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// VerifierConfig converts the verifier overrides for the issue request.
+func (o *CommandIssueOptions) VerifierConfig() map[string]*commanding.VerifierConfig {
+	if o == nil || len(o.Verifiers) == 0 {
+		return nil
+	}
+	config := make(map[string]*commanding.VerifierConfig, len(o.Verifiers))
+	for stage, override := range o.Verifiers {
+		verifier := &commanding.VerifierConfig{Disable: proto.Bool(override.Disable)}
+		if override.TimeToStartChecking != nil || override.TimeToStopChecking != nil {
+			verifier.CheckWindow = &commanding.VerifierConfig_CheckWindow{
+				TimeToStartChecking: override.TimeToStartChecking,
+				TimeToStopChecking:  override.TimeToStopChecking,
+			}
+		}
+		config[stage] = verifier
+	}
+	return config
+}
+
+// ExtraValues converts the extra attributes for the issue request. Whole
+// numbers become signed integers, other numbers doubles.
+func (o *CommandIssueOptions) ExtraValues() (map[string]*protobuf.Value, error) {
+	if o == nil || len(o.Extra) == 0 {
+		return nil, nil
+	}
+	values := make(map[string]*protobuf.Value, len(o.Extra))
+	for name, raw := range o.Extra {
+		value := &protobuf.Value{}
+		switch v := raw.(type) {
+		case string:
+			value.Type, value.StringValue = protobuf.Value_STRING.Enum(), proto.String(v)
+		case bool:
+			value.Type, value.BooleanValue = protobuf.Value_BOOLEAN.Enum(), proto.Bool(v)
+		case float64:
+			if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+				value.Type, value.Sint64Value = protobuf.Value_SINT64.Enum(), proto.Int64(int64(v))
+			} else {
+				value.Type, value.DoubleValue = protobuf.Value_DOUBLE.Enum(), proto.Float64(v)
+			}
+		default:
+			return nil, fmt.Errorf("extra attribute %s must be a string, a boolean or a number", name)
+		}
+		values[name] = value
+	}
+	return values, nil
+}
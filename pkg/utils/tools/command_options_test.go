@@ -0,0 +1,81 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/mdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandIssueOptions(t *testing.T) {
+	var none *CommandIssueOptions
+	assert.Empty(t, none.Overrides())
+	assert.Empty(t, (&CommandIssueOptions{}).Overrides())
+
+	options := &CommandIssueOptions{
+		DisableTransmissionConstraints: true,
+		Verifiers: map[string]VerifierOverride{
+			"Complete":   {TimeToStopChecking: pointer(int64(60000))},
+			"Acceptance": {Disable: true},
+		},
+		Stream: "tc_backup",
+		Extra:  map[string]any{"priority": 3.0, "gain": 0.5, "note": "contingency", "urgent": true},
+	}
+	assert.Equal(t, []string{
+		"disable-transmission-constraints",
+		"disable-verifier:Acceptance",
+		"verifier-window:Complete",
+		"stream:tc_backup",
+		"extra",
+	}, options.Overrides())
+
+	config := options.VerifierConfig()
+	require.Len(t, config, 2)
+	assert.True(t, config["Acceptance"].GetDisable())
+	assert.Nil(t, config["Acceptance"].GetCheckWindow())
+	assert.False(t, config["Complete"].GetDisable())
+	assert.Equal(t, int64(60000), config["Complete"].GetCheckWindow().GetTimeToStopChecking())
+
+	extra, err := options.ExtraValues()
+	require.NoError(t, err)
+	assert.Equal(t, protobuf.Value_SINT64, extra["priority"].GetType())
+	assert.Equal(t, int64(3), extra["priority"].GetSint64Value())
+	assert.Equal(t, protobuf.Value_DOUBLE, extra["gain"].GetType())
+	assert.Equal(t, "contingency", extra["note"].GetStringValue())
+	assert.True(t, extra["urgent"].GetBooleanValue())
+
+	_, err = (&CommandIssueOptions{Extra: map[string]any{"list": []any{1.0}}}).ExtraValues()
+	assert.Error(t, err)
+}
+
+func TestCommandIssueOptionsCheck(t *testing.T) {
+	command := &mdb.CommandInfo{
+		QualifiedName: pointer("/YSS/SWITCH_ON"),
+		Verifier:      []*mdb.VerifierInfo{{Stage: pointer("Complete")}},
+		BaseCommand: &mdb.CommandInfo{
+			Constraint: []*mdb.TransmissionConstraintInfo{{Expression: pointer("mode == SAFE")}},
+			Verifier:   []*mdb.VerifierInfo{{Stage: pointer("Acceptance")}},
+		},
+	}
+	var none *CommandIssueOptions
+	assert.NoError(t, none.Check(command, nil))
+
+	options := &CommandIssueOptions{
+		DisableTransmissionConstraints: true,
+		DisableVerifiers:               true,
+		Verifiers:                      map[string]VerifierOverride{"Acceptance": {Disable: true}, "Complete": {}},
+		Extra:                          map[string]any{"priority": 3.0},
+	}
+	assert.NoError(t, options.Check(command, []string{"priority"}))
+
+	options.Verifiers["Execution"] = VerifierOverride{Disable: true}
+	options.Extra["unknown"] = true
+	assert.EqualError(t, options.Check(command, []string{"priority"}),
+		"issue options not offered by /YSS/SWITCH_ON: extra attribute unknown, verifier Execution")
+
+	bare := &mdb.CommandInfo{QualifiedName: pointer("/YSS/PING")}
+	assert.EqualError(t, (&CommandIssueOptions{DisableTransmissionConstraints: true, DisableVerifiers: true}).Check(bare, nil),
+		"issue options not offered by /YSS/PING: disableTransmissionConstraints (the command has no transmission constraints), disableVerifiers (the command has no verifiers)")
+}
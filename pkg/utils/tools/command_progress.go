@@ -0,0 +1,178 @@
+package tools
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/commanding"
+)
+
+// Acknowledgments Yamcs sets on every command, in the order they happen.
+var commandAcknowledgments = []string{"Acknowledge_Queued", "Acknowledge_Released", "Acknowledge_Sent"}
+
+// commandCompletion is the stage Yamcs sets once all verifiers have run.
+const commandCompletion = "CommandComplete"
+
+// CommandStage is the state of one acknowledgment or verifier of a command.
+type CommandStage struct {
+	// Name is the prefix of the stage attributes, such as
+	// "Acknowledge_Sent", "Verifier_Complete" or "CommandComplete".
+	Name    string
+	Status  string
+	Time    *time.Time
+	Message string
+}
+
+// CommandProgress gathers the command history updates of one command. Yamcs
+// only sends the attributes that changed in each update.
+type CommandProgress struct {
+	ID      string
+	Command string
+	// Issued is the generation time of the command.
+	Issued time.Time
+
+	stages map[string]*CommandStage
+	// order holds the stages other than the standard acknowledgments and the
+	// completion, in the order they were first reported.
+	order []string
+}
+
+// NewCommandProgress starts following the command with the given ID.
+func NewCommandProgress(id string) *CommandProgress {
+	return &CommandProgress{ID: id, stages: map[string]*CommandStage{}}
+}
+
+// Update merges a command history entry into the progress. Entries of other
+// commands are ignored; Update returns whether entry was merged.
+func (p *CommandProgress) Update(entry *commanding.CommandHistoryEntry) bool {
+	if entry.GetId() != p.ID {
+		return false
+	}
+	if entry.GetCommandName() != "" {
+		p.Command = entry.GetCommandName()
+	}
+	if entry.GenerationTime != nil {
+		p.Issued = entry.GetGenerationTime().AsTime()
+	}
+	for _, attribute := range entry.GetAttr() {
+		name := attribute.GetName()
+		separator := strings.LastIndex(name, "_")
+		if separator <= 0 {
+			continue
+		}
+		stageName, field := name[:separator], name[separator+1:]
+		if !isCommandStage(stageName) {
+			continue
+		}
+		stage := p.stage(stageName)
+		switch field {
+		case "Status":
+			stage.Status = attribute.GetValue().GetStringValue()
+		case "Message":
+			stage.Message = attribute.GetValue().GetStringValue()
+		case "Time":
+			stage.Time = attributeTime(attribute.GetValue())
+		}
+	}
+	return true
+}
+
+func (p *CommandProgress) stage(name string) *CommandStage {
+	stage, ok := p.stages[name]
+	if !ok {
+		stage = &CommandStage{Name: name}
+		p.stages[name] = stage
+		if name != commandCompletion && !slices.Contains(commandAcknowledgments, name) {
+			p.order = append(p.order, name)
+		}
+	}
+	return stage
+}
+
+// isCommandStage tells whether the attribute prefix name is an acknowledgment
+// or a verifier stage.
+func isCommandStage(name string) bool {
+	return strings.HasPrefix(name, "Acknowledge_") || strings.HasPrefix(name, "Verifier_") || name == commandCompletion
+}
+
+// attributeTime reads the time of a stage, given as a timestamp or as an
+// RFC 3339 string depending on the Yamcs version.
+func attributeTime(value *protobuf.Value) *time.Time {
+	if value.TimestampValue != nil {
+		t := time.UnixMilli(value.GetTimestampValue()).UTC()
+		return &t
+	}
+	if t, err := time.Parse(time.RFC3339Nano, value.GetStringValue()); err == nil {
+		return &t
+	}
+	return nil
+}
+
+// Stages returns the stages reported so far: the Queued, Released and Sent
+// acknowledgments, other acknowledgments and verifiers in the order they were
+// reported, and the completion.
+func (p *CommandProgress) Stages() []CommandStage {
+	stages := []CommandStage{}
+	for _, name := range commandAcknowledgments {
+		if stage, ok := p.stages[name]; ok {
+			stages = append(stages, *stage)
+		}
+	}
+	for _, name := range p.order {
+		stages = append(stages, *p.stages[name])
+	}
+	if stage, ok := p.stages[commandCompletion]; ok {
+		stages = append(stages, *stage)
+	}
+	return stages
+}
+
+// Stage returns the stage with the given name, if it was reported.
+func (p *CommandProgress) Stage(name string) (CommandStage, bool) {
+	stage, ok := p.stages[name]
+	if !ok {
+		return CommandStage{}, false
+	}
+	return *stage, true
+}
+
+// Complete tells whether Yamcs reported the final outcome of the command.
+func (p *CommandProgress) Complete() bool {
+	stage, ok := p.stages[commandCompletion]
+	return ok && stage.Status != "" && stage.Status != "PENDING"
+}
+
+// ConvertCommandProgressToFrame converts the progress of a command into a
+// frame with one row per stage. The "complete" custom frame metadata tells
+// whether the command finished, and "timedOut" whether it was given up on
+// before it did.
+func ConvertCommandProgressToFrame(progress *CommandProgress, timedOut bool) *data.Frame {
+	stages := progress.Stages()
+	times := make([]*time.Time, 0, len(stages))
+	names := make([]string, 0, len(stages))
+	statuses := make([]string, 0, len(stages))
+	messages := make([]string, 0, len(stages))
+	for _, stage := range stages {
+		times = append(times, stage.Time)
+		names = append(names, stage.Name)
+		statuses = append(statuses, stage.Status)
+		messages = append(messages, stage.Message)
+	}
+
+	frame := data.NewFrame("command-progress",
+		data.NewField("time", nil, times),
+		data.NewField("stage", nil, names),
+		data.NewField("status", nil, statuses),
+		data.NewField("message", nil, messages),
+	)
+	frame.Meta = &data.FrameMeta{Custom: map[string]any{
+		"id":       progress.ID,
+		"command":  progress.Command,
+		"complete": progress.Complete(),
+		"timedOut": timedOut,
+	}}
+	return frame
+}
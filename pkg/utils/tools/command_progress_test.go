@@ -0,0 +1,62 @@
+package tools
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/commanding"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func commandUpdate(id string, attributes map[string]*protobuf.Value) *commanding.CommandHistoryEntry {
+	entry := &commanding.CommandHistoryEntry{Id: pointer(id)}
+	for name, value := range attributes {
+		entry.Attr = append(entry.Attr, &commanding.CommandHistoryAttribute{Name: pointer(name), Value: value})
+	}
+	return entry
+}
+
+func stringValue(s string) *protobuf.Value {
+	return &protobuf.Value{StringValue: pointer(s)}
+}
+
+func TestCommandProgressMergesUpdates(t *testing.T) {
+	sent := time.Date(2025, 3, 1, 12, 0, 1, 0, time.UTC)
+	progress := NewCommandProgress("cmd-1")
+
+	// Updates arrive out of order and only carry what changed.
+	assert.True(t, progress.Update(commandUpdate("cmd-1", map[string]*protobuf.Value{
+		"Verifier_Complete_Status":  stringValue("PENDING"),
+		"Acknowledge_Sent_Status":   stringValue("OK"),
+		"Acknowledge_Sent_Time":     {TimestampValue: pointer(sent.UnixMilli())},
+		"Acknowledge_Queued_Status": stringValue("OK"),
+		"comment":                   stringValue("ignored"),
+	})))
+	assert.False(t, progress.Update(commandUpdate("cmd-2", map[string]*protobuf.Value{
+		"CommandComplete_Status": stringValue("OK"),
+	})))
+	assert.False(t, progress.Complete())
+
+	progress.Update(commandUpdate("cmd-1", map[string]*protobuf.Value{
+		"Verifier_Complete_Status":  stringValue("NOK"),
+		"Verifier_Complete_Message": stringValue("mode unchanged"),
+		"CommandComplete_Status":    stringValue("NOK"),
+	}))
+	assert.True(t, progress.Complete())
+
+	stages := progress.Stages()
+	names := []string{}
+	for _, stage := range stages {
+		names = append(names, stage.Name)
+	}
+	assert.Equal(t, []string{"Acknowledge_Queued", "Acknowledge_Sent", "Verifier_Complete", "CommandComplete"}, names)
+	require.NotNil(t, stages[1].Time)
+	assert.Equal(t, sent, *stages[1].Time)
+	assert.Equal(t, CommandStage{Name: "Verifier_Complete", Status: "NOK", Message: "mode unchanged"}, stages[2])
+
+	frame := ConvertCommandProgressToFrame(progress, false)
+	assert.Equal(t, 4, frame.Rows())
+	assert.Equal(t, true, frame.Meta.Custom.(map[string]any)["complete"])
+}
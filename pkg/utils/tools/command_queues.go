@@ -0,0 +1,168 @@
+package tools
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/commanding"
+	"google.golang.org/protobuf/proto"
+)
+
+// CommandQueues follows the command queues of a processor. It starts from the
+// queue list and is kept up to date by the queue statistics and events topics:
+// statistics carry the state and counters of a queue, events the commands
+// entering and leaving it.
+type CommandQueues struct {
+	mu      sync.Mutex
+	queues  map[string]*commanding.CommandQueueInfo
+	changed bool
+}
+
+// NewCommandQueues creates an empty set of queues.
+func NewCommandQueues() *CommandQueues {
+	return &CommandQueues{queues: map[string]*commanding.CommandQueueInfo{}}
+}
+
+// Reset replaces every queue by the given list, which is authoritative for the
+// commands pending in each queue.
+func (q *CommandQueues) Reset(list []*commanding.CommandQueueInfo) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.queues = map[string]*commanding.CommandQueueInfo{}
+	for _, info := range list {
+		q.queues[info.GetName()] = proto.Clone(info).(*commanding.CommandQueueInfo)
+	}
+	q.changed = true
+}
+
+// ApplyInfo updates the state and counters of a queue. Statistics do not list
+// the pending commands, so the known ones are kept.
+func (q *CommandQueues) ApplyInfo(info *commanding.CommandQueueInfo) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	updated := proto.Clone(info).(*commanding.CommandQueueInfo)
+	if previous, ok := q.queues[info.GetName()]; ok && len(updated.Entries) == 0 {
+		updated.Entries = previous.Entries
+	}
+	q.queues[info.GetName()] = updated
+	q.changed = true
+}
+
+// ApplyEvent adds, updates or removes a pending command. Events of unknown
+// queues are ignored until the queue is reported.
+func (q *CommandQueues) ApplyEvent(event *commanding.CommandQueueEvent) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	entry := event.GetData()
+	queue, ok := q.queues[entry.GetQueueName()]
+	if !ok {
+		return
+	}
+	index := -1
+	for i, pending := range queue.Entries {
+		if pending.GetId() == entry.GetId() {
+			index = i
+			break
+		}
+	}
+	switch event.GetType() {
+	case commanding.CommandQueueEvent_COMMAND_ADDED, commanding.CommandQueueEvent_COMMAND_UPDATED:
+		if index >= 0 {
+			queue.Entries[index] = entry
+		} else {
+			queue.Entries = append(queue.Entries, entry)
+		}
+	default:
+		if index < 0 {
+			return
+		}
+		queue.Entries = append(queue.Entries[:index], queue.Entries[index+1:]...)
+	}
+	q.changed = true
+}
+
+// Changed returns the queues when they changed since the last call, ordered as
+// Yamcs tries them.
+func (q *CommandQueues) Changed() ([]*commanding.CommandQueueInfo, bool) {
+	q.mu.Lock()
+	changed := q.changed
+	q.changed = false
+	q.mu.Unlock()
+	if !changed {
+		return nil, false
+	}
+	return q.Queues(), true
+}
+
+// Queues returns a copy of the queues, ordered as Yamcs tries them.
+func (q *CommandQueues) Queues() []*commanding.CommandQueueInfo {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	queues := make([]*commanding.CommandQueueInfo, 0, len(q.queues))
+	for _, info := range q.queues {
+		queues = append(queues, proto.Clone(info).(*commanding.CommandQueueInfo))
+	}
+	sort.Slice(queues, func(i, j int) bool {
+		if queues[i].GetOrder() != queues[j].GetOrder() {
+			return queues[i].GetOrder() < queues[j].GetOrder()
+		}
+		return queues[i].GetName() < queues[j].GetName()
+	})
+	return queues
+}
+
+// ConvertCommandQueuesToFrame lays the queues out as a table with one row per
+// pending command. A queue holding no command has a single row with empty
+// command fields, so that every queue and its state is listed.
+func ConvertCommandQueuesToFrame(queues []*commanding.CommandQueueInfo) *data.Frame {
+	var (
+		names, states, ids, commands, users, comments []string
+		orders, pendings, accepted, rejected          []int64
+		generated                                     []*time.Time
+	)
+	addRow := func(info *commanding.CommandQueueInfo, entry *commanding.CommandQueueEntry) {
+		names = append(names, info.GetName())
+		states = append(states, strings.ToLower(info.GetState().String()))
+		orders = append(orders, int64(info.GetOrder()))
+		pendings = append(pendings, int64(len(info.GetEntries())))
+		accepted = append(accepted, int64(info.GetAcceptedCommandsCount()))
+		rejected = append(rejected, int64(info.GetRejectedCommandsCount()))
+		ids = append(ids, entry.GetId())
+		commands = append(commands, entry.GetCommandName())
+		users = append(users, entry.GetUsername())
+		comments = append(comments, entry.GetComment())
+		if entry.GetGenerationTime() != nil {
+			t := entry.GetGenerationTime().AsTime()
+			generated = append(generated, &t)
+		} else {
+			generated = append(generated, nil)
+		}
+	}
+	for _, info := range queues {
+		if len(info.GetEntries()) == 0 {
+			addRow(info, nil)
+		}
+		for _, entry := range info.GetEntries() {
+			addRow(info, entry)
+		}
+	}
+
+	frame := data.NewFrame("command-queues",
+		data.NewField("queue", nil, names),
+		data.NewField("state", nil, states),
+		data.NewField("order", nil, orders),
+		data.NewField("pending", nil, pendings),
+		data.NewField("accepted", nil, accepted),
+		data.NewField("rejected", nil, rejected),
+		data.NewField("id", nil, ids),
+		data.NewField("command", nil, commands),
+		data.NewField("user", nil, users),
+		data.NewField("comment", nil, comments),
+		data.NewField("generated", nil, generated),
+	)
+	frame.Meta = &data.FrameMeta{PreferredVisualization: data.VisTypeTable}
+	return frame
+}
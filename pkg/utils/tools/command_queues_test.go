@@ -0,0 +1,67 @@
+package tools
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/commanding"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func queueEntry(queue, id, command string) *commanding.CommandQueueEntry {
+	return &commanding.CommandQueueEntry{QueueName: pointer(queue), Id: pointer(id), CommandName: pointer(command)}
+}
+
+func TestCommandQueuesFollowsUpdates(t *testing.T) {
+	queues := NewCommandQueues()
+	queues.Reset([]*commanding.CommandQueueInfo{
+		{Name: pointer("ops"), Order: pointer(int32(2)), State: commanding.QueueState_BLOCKED.Enum(),
+			Entries: []*commanding.CommandQueueEntry{queueEntry("ops", "c1", "/YSS/ON")}},
+		{Name: pointer("default"), Order: pointer(int32(3)), State: commanding.QueueState_ENABLED.Enum()},
+	})
+	list, changed := queues.Changed()
+	require.True(t, changed)
+	assert.Equal(t, []string{"ops", "default"}, []string{list[0].GetName(), list[1].GetName()})
+	_, changed = queues.Changed()
+	assert.False(t, changed)
+
+	queues.ApplyEvent(&commanding.CommandQueueEvent{Type: commanding.CommandQueueEvent_COMMAND_ADDED.Enum(), Data: queueEntry("ops", "c2", "/YSS/OFF")})
+	queues.ApplyEvent(&commanding.CommandQueueEvent{Type: commanding.CommandQueueEvent_COMMAND_SENT.Enum(), Data: queueEntry("ops", "c1", "/YSS/ON")})
+	queues.ApplyEvent(&commanding.CommandQueueEvent{Type: commanding.CommandQueueEvent_COMMAND_ADDED.Enum(), Data: queueEntry("unknown", "c3", "/YSS/X")})
+	// Statistics keep the pending commands they do not list.
+	queues.ApplyInfo(&commanding.CommandQueueInfo{Name: pointer("ops"), Order: pointer(int32(2)), State: commanding.QueueState_ENABLED.Enum(), AcceptedCommandsCount: pointer(int32(1))})
+
+	list, changed = queues.Changed()
+	require.True(t, changed)
+	require.Len(t, list, 2)
+	assert.Equal(t, commanding.QueueState_ENABLED, list[0].GetState())
+	require.Len(t, list[0].GetEntries(), 1)
+	assert.Equal(t, "c2", list[0].GetEntries()[0].GetId())
+}
+
+func TestConvertCommandQueuesToFrame(t *testing.T) {
+	generated := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	entry := queueEntry("ops", "c1", "/YSS/ON")
+	entry.Username = pointer("alice")
+	entry.GenerationTime = timestamppb.New(generated)
+
+	frame := ConvertCommandQueuesToFrame([]*commanding.CommandQueueInfo{
+		{Name: pointer("ops"), State: commanding.QueueState_BLOCKED.Enum(), Entries: []*commanding.CommandQueueEntry{entry}},
+		{Name: pointer("default"), State: commanding.QueueState_ENABLED.Enum()},
+	})
+
+	require.Equal(t, 2, frame.Rows())
+	row := func(name string, i int) any {
+		field, _ := frame.FieldByName(name)
+		return field.At(i)
+	}
+	assert.Equal(t, "blocked", row("state", 0))
+	assert.Equal(t, int64(1), row("pending", 0))
+	assert.Equal(t, "/YSS/ON", row("command", 0))
+	assert.Equal(t, &generated, row("generated", 0))
+	assert.Equal(t, "default", row("queue", 1))
+	assert.Equal(t, "", row("command", 1))
+	assert.Nil(t, row("generated", 1))
+}
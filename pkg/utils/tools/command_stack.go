@@ -0,0 +1,446 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf"
+	"gopkg.in/yaml.v3"
+)
+
+// StackStepType is the kind of a command stack step.
+type StackStepType string
+
+const (
+	// StepCommand issues a command.
+	StepCommand StackStepType = "command"
+	// StepWait pauses for a fixed duration.
+	StepWait StackStepType = "wait"
+	// StepVerifier waits until the last command issued reaches a stage.
+	StepVerifier StackStepType = "verifier"
+	// StepCondition waits until parameter conditions hold.
+	StepCondition StackStepType = "condition"
+)
+
+// StackCondition compares the engineering value of a parameter to a value.
+type StackCondition struct {
+	Parameter string `json:"parameter"`
+	Operator  string `json:"operator"` // "eq", "neq", "lt", "lte", "gt" or "gte", or their symbols
+	Value     string `json:"value"`
+}
+
+// StackStep is one step of a command stack.
+type StackStep struct {
+	Type StackStepType `json:"type"`
+
+	// Command steps
+	Command   string         `json:"command,omitempty"`
+	Arguments map[string]any `json:"arguments,omitempty"`
+	Comment   string         `json:"comment,omitempty"`
+
+	// Wait steps, e.g. "5s"
+	Duration string `json:"duration,omitempty"`
+
+	// Verifier steps: the stage to reach, e.g. "Acknowledge_Sent",
+	// "Verifier_Complete" or "CommandComplete"
+	Stage string `json:"stage,omitempty"`
+
+	// Condition steps: all must hold at once
+	Conditions []StackCondition `json:"conditions,omitempty"`
+
+	// Verifier and condition steps fail when they wait longer than Timeout.
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// CommandStack is an ordered list of steps run one after the other.
+type CommandStack struct {
+	Name  string      `json:"name,omitempty"`
+	Steps []StackStep `json:"steps"`
+	// ContinueOnFailure runs the remaining steps after a step fails, rather
+	// than stopping the stack.
+	ContinueOnFailure bool `json:"continueOnFailure,omitempty"`
+}
+
+// Validate checks that every step is complete and its durations parse.
+func (s *CommandStack) Validate() error {
+	if len(s.Steps) == 0 {
+		return fmt.Errorf("the stack has no steps")
+	}
+	for i, step := range s.Steps {
+		if err := step.validate(); err != nil {
+			return fmt.Errorf("step %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+func (step *StackStep) validate() error {
+	if step.Timeout != "" {
+		if _, err := parseStepDuration("timeout", step.Timeout); err != nil {
+			return err
+		}
+	}
+	switch step.Type {
+	case StepCommand:
+		if step.Command == "" {
+			return fmt.Errorf("missing command name")
+		}
+	case StepWait:
+		if _, err := parseStepDuration("duration", step.Duration); err != nil {
+			return err
+		}
+	case StepVerifier:
+		if step.Stage == "" {
+			return fmt.Errorf("missing stage")
+		}
+	case StepCondition:
+		if len(step.Conditions) == 0 {
+			return fmt.Errorf("missing conditions")
+		}
+		for _, condition := range step.Conditions {
+			if condition.Parameter == "" {
+				return fmt.Errorf("missing condition parameter")
+			}
+			if _, ok := conditionOperators[condition.Operator]; !ok {
+				return fmt.Errorf("unknown condition operator %q", condition.Operator)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown step type %q", step.Type)
+	}
+	return nil
+}
+
+// WaitDuration returns the duration of a wait step.
+func (step *StackStep) WaitDuration() time.Duration {
+	duration, _ := parseStepDuration("duration", step.Duration)
+	return duration
+}
+
+// TimeoutDuration returns the timeout of a verifier or condition step, or
+// fallback when the step does not set one.
+func (step *StackStep) TimeoutDuration(fallback time.Duration) time.Duration {
+	if step.Timeout == "" {
+		return fallback
+	}
+	timeout, _ := parseStepDuration("timeout", step.Timeout)
+	return timeout
+}
+
+func parseStepDuration(name, value string) (time.Duration, error) {
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("invalid %s %q", name, value)
+	}
+	return duration, nil
+}
+
+// Describe summarizes a step for progress reports, e.g.
+// "wait for Acknowledge_Sent".
+func (step *StackStep) Describe() string {
+	switch step.Type {
+	case StepCommand:
+		return step.Command
+	case StepWait:
+		return "wait " + step.Duration
+	case StepVerifier:
+		return "wait for " + step.Stage
+	case StepCondition:
+		conditions := make([]string, 0, len(step.Conditions))
+		for _, condition := range step.Conditions {
+			conditions = append(conditions, condition.Parameter+" "+conditionOperators[condition.Operator]+" "+condition.Value)
+		}
+		return "wait until " + strings.Join(conditions, " && ")
+	}
+	return string(step.Type)
+}
+
+var conditionOperators = map[string]string{
+	"eq": "==", "==": "==",
+	"neq": "!=", "!=": "!=",
+	"lt": "<", "<": "<",
+	"lte": "<=", "<=": "<=",
+	"gt": ">", ">": ">",
+	"gte": ">=", ">=": ">=",
+}
+
+// Holds reports whether value satisfies the condition, comparing numbers
+// numerically and labels and strings lexically. A missing value never does.
+func (c StackCondition) Holds(value *protobuf.Value) bool {
+	if value == nil {
+		return false
+	}
+	order, comparable := compareToOperand(value, c.Value)
+	if !comparable {
+		return false
+	}
+	switch conditionOperators[c.Operator] {
+	case "==":
+		return order == 0
+	case "!=":
+		return order != 0
+	case "<":
+		return order < 0
+	case "<=":
+		return order <= 0
+	case ">":
+		return order > 0
+	case ">=":
+		return order >= 0
+	}
+	return false
+}
+
+// ParseCommandStack reads a command stack saved by Yamcs Web: the current
+// format, a JSON or YAML document, or the legacy XML format. Each command is
+// followed by a verifier step for its acceptance stage and a wait step for its
+// delay, as set on the command or the whole stack. Verify steps become a wait
+// for their delay and a condition step; text and check steps, which only
+// inform the operator, are left out.
+func ParseCommandStack(name string, content []byte) (*CommandStack, error) {
+	var stack *CommandStack
+	var err error
+	if trimmed := bytes.TrimSpace(content); bytes.HasPrefix(trimmed, []byte("<")) {
+		stack, err = parseXMLCommandStack(trimmed)
+	} else {
+		stack, err = parseYAMLCommandStack(content)
+	}
+	if err != nil {
+		return nil, err
+	}
+	stack.Name = name
+	if err := stack.Validate(); err != nil {
+		return nil, err
+	}
+	return stack, nil
+}
+
+type yamcsStackAdvancement struct {
+	Acceptance string `yaml:"acceptance"`
+	Wait       *int64 `yaml:"wait"` // milliseconds
+}
+
+type yamcsStackFile struct {
+	Advancement yamcsStackAdvancement `yaml:"advancement"`
+	Steps       []struct {
+		Type      string `yaml:"type"`
+		Name      string `yaml:"name"`
+		Comment   string `yaml:"comment"`
+		Arguments []struct {
+			Name  string `yaml:"name"`
+			Value any    `yaml:"value"`
+		} `yaml:"arguments"`
+		Advancement *yamcsStackAdvancement `yaml:"advancement"`
+		Condition   []struct {
+			Parameter string `yaml:"parameter"`
+			Operator  string `yaml:"operator"`
+			Value     any    `yaml:"value"`
+		} `yaml:"condition"`
+		Delay   int64 `yaml:"delay"`   // milliseconds
+		Timeout int64 `yaml:"timeout"` // milliseconds
+	} `yaml:"steps"`
+}
+
+func parseYAMLCommandStack(content []byte) (*CommandStack, error) {
+	file := yamcsStackFile{}
+	if err := yaml.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("invalid command stack: %w", err)
+	}
+	stack := &CommandStack{Steps: []StackStep{}}
+	for _, step := range file.Steps {
+		switch step.Type {
+		case "command", "":
+			arguments := map[string]any{}
+			for _, argument := range step.Arguments {
+				arguments[argument.Name] = argument.Value
+			}
+			stack.appendCommand(StackStep{Type: StepCommand, Command: step.Name, Arguments: arguments, Comment: step.Comment},
+				file.Advancement, step.Advancement)
+		case "verify":
+			if step.Delay > 0 {
+				stack.Steps = append(stack.Steps, StackStep{Type: StepWait, Duration: milliseconds(step.Delay)})
+			}
+			conditions := []StackCondition{}
+			for _, condition := range step.Condition {
+				conditions = append(conditions, StackCondition{
+					Parameter: condition.Parameter,
+					Operator:  condition.Operator,
+					Value:     fmt.Sprint(condition.Value),
+				})
+			}
+			verify := StackStep{Type: StepCondition, Conditions: conditions}
+			if step.Timeout > 0 {
+				verify.Timeout = milliseconds(step.Timeout)
+			}
+			stack.Steps = append(stack.Steps, verify)
+		}
+	}
+	return stack, nil
+}
+
+type xmlStackFile struct {
+	Commands []struct {
+		QualifiedName string `xml:"qualifiedName,attr"`
+		Comment       string `xml:"comment,attr"`
+		Arguments     []struct {
+			Name  string `xml:"argumentName,attr"`
+			Value string `xml:"argumentValue,attr"`
+		} `xml:"commandArgument"`
+		Advancement *struct {
+			Acceptance string `xml:"acceptance,attr"`
+			Wait       string `xml:"wait,attr"`
+		} `xml:"advancement"`
+	} `xml:"command"`
+}
+
+func parseXMLCommandStack(content []byte) (*CommandStack, error) {
+	file := xmlStackFile{}
+	if err := xml.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("invalid command stack: %w", err)
+	}
+	stack := &CommandStack{Steps: []StackStep{}}
+	for _, command := range file.Commands {
+		arguments := map[string]any{}
+		for _, argument := range command.Arguments {
+			arguments[argument.Name] = argument.Value
+		}
+		var advancement *yamcsStackAdvancement
+		if command.Advancement != nil {
+			advancement = &yamcsStackAdvancement{Acceptance: command.Advancement.Acceptance}
+			if command.Advancement.Wait != "" {
+				wait, err := strconv.ParseInt(command.Advancement.Wait, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid command stack: wait %q of %s", command.Advancement.Wait, command.QualifiedName)
+				}
+				advancement.Wait = &wait
+			}
+		}
+		stack.appendCommand(StackStep{Type: StepCommand, Command: command.QualifiedName, Arguments: arguments, Comment: command.Comment},
+			yamcsStackAdvancement{}, advancement)
+	}
+	return stack, nil
+}
+
+// appendCommand appends a command step followed by the verifier and wait steps
+// of its advancement, which overrides the stack advancement.
+func (s *CommandStack) appendCommand(command StackStep, stackAdvancement yamcsStackAdvancement, advancement *yamcsStackAdvancement) {
+	acceptance, wait := stackAdvancement.Acceptance, stackAdvancement.Wait
+	if advancement != nil {
+		if advancement.Acceptance != "" {
+			acceptance = advancement.Acceptance
+		}
+		if advancement.Wait != nil {
+			wait = advancement.Wait
+		}
+	}
+	s.Steps = append(s.Steps, command)
+	if acceptance != "" {
+		s.Steps = append(s.Steps, StackStep{Type: StepVerifier, Stage: acceptance})
+	}
+	if wait != nil && *wait > 0 {
+		s.Steps = append(s.Steps, StackStep{Type: StepWait, Duration: milliseconds(*wait)})
+	}
+}
+
+func milliseconds(ms int64) string {
+	return (time.Duration(ms) * time.Millisecond).String()
+}
+
+// StackRunState is the state of a command stack run.
+type StackRunState string
+
+const (
+	StackRunning   StackRunState = "running"
+	StackPaused    StackRunState = "paused"
+	StackCompleted StackRunState = "completed"
+	StackFailed    StackRunState = "failed"
+	StackAborted   StackRunState = "aborted"
+)
+
+// Finished tells whether the run is over.
+func (s StackRunState) Finished() bool {
+	return s == StackCompleted || s == StackFailed || s == StackAborted
+}
+
+// StackStepState is the state of one step of a command stack run.
+type StackStepState string
+
+const (
+	StepPending   StackStepState = "pending"
+	StepRunning   StackStepState = "running"
+	StepSucceeded StackStepState = "succeeded"
+	StepFailed    StackStepState = "failed"
+	StepAborted   StackStepState = "aborted"
+)
+
+// StackStepStatus reports the progress of one step.
+type StackStepStatus struct {
+	State    StackStepState `json:"state"`
+	Started  *time.Time     `json:"started,omitempty"`
+	Finished *time.Time     `json:"finished,omitempty"`
+	Message  string         `json:"message,omitempty"`
+	// CommandID identifies the command issued by a command step.
+	CommandID string `json:"commandId,omitempty"`
+}
+
+// StackRunStatus reports the progress of a command stack run.
+type StackRunStatus struct {
+	ID       string            `json:"id"`
+	Endpoint string            `json:"endpoint"`
+	Stack    CommandStack      `json:"stack"`
+	State    StackRunState     `json:"state"`
+	Current  int               `json:"current"` // Index of the step running or next to run
+	Steps    []StackStepStatus `json:"steps"`
+}
+
+// ConvertStackStatusToFrame converts the status of a command stack run into a
+// frame with one row per step. The run ID, state and current step are given in
+// the custom frame metadata.
+func ConvertStackStatusToFrame(status StackRunStatus) *data.Frame {
+	count := len(status.Steps)
+	indexes := make([]int64, 0, count)
+	types := make([]string, 0, count)
+	descriptions := make([]string, 0, count)
+	states := make([]string, 0, count)
+	started := make([]*time.Time, 0, count)
+	finished := make([]*time.Time, 0, count)
+	messages := make([]string, 0, count)
+	commandIDs := make([]string, 0, count)
+	for i, step := range status.Steps {
+		definition := status.Stack.Steps[i]
+		indexes = append(indexes, int64(i+1))
+		types = append(types, string(definition.Type))
+		descriptions = append(descriptions, definition.Describe())
+		states = append(states, string(step.State))
+		started = append(started, step.Started)
+		finished = append(finished, step.Finished)
+		messages = append(messages, step.Message)
+		commandIDs = append(commandIDs, step.CommandID)
+	}
+
+	frame := data.NewFrame("command-stack",
+		data.NewField("step", nil, indexes),
+		data.NewField("type", nil, types),
+		data.NewField("description", nil, descriptions),
+		data.NewField("state", nil, states),
+		data.NewField("started", nil, started),
+		data.NewField("finished", nil, finished),
+		data.NewField("message", nil, messages),
+		data.NewField("commandId", nil, commandIDs),
+	)
+	frame.Meta = &data.FrameMeta{
+		PreferredVisualization: data.VisTypeTable,
+		Custom: map[string]any{
+			"id":      status.ID,
+			"name":    status.Stack.Name,
+			"state":   status.State,
+			"current": status.Current,
+		},
+	}
+	return frame
+}
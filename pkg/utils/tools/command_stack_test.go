@@ -0,0 +1,101 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCommandStack(t *testing.T) {
+	content := `
+advancement:
+  acceptance: Acknowledge_Queued
+  wait: 500
+steps:
+  - type: command
+    name: /YSS/SIMULATOR/SWITCH_VOLTAGE_ON
+    arguments:
+      - name: voltage_num
+        value: 1
+  - type: text
+    text: Check the battery before going on
+  - type: command
+    name: /YSS/SIMULATOR/SWITCH_VOLTAGE_OFF
+    comment: back to safe
+    advancement:
+      acceptance: CommandComplete
+      wait: 0
+  - type: verify
+    delay: 2000
+    timeout: 10000
+    condition:
+      - parameter: /YSS/SIMULATOR/BatteryVoltage1
+        operator: lt
+        value: 5
+`
+	stack, err := ParseCommandStack("voltage.ycs", []byte(content))
+	require.NoError(t, err)
+	assert.Equal(t, &CommandStack{Name: "voltage.ycs", Steps: []StackStep{
+		{Type: StepCommand, Command: "/YSS/SIMULATOR/SWITCH_VOLTAGE_ON", Arguments: map[string]any{"voltage_num": 1}},
+		{Type: StepVerifier, Stage: "Acknowledge_Queued"},
+		{Type: StepWait, Duration: "500ms"},
+		{Type: StepCommand, Command: "/YSS/SIMULATOR/SWITCH_VOLTAGE_OFF", Arguments: map[string]any{}, Comment: "back to safe"},
+		{Type: StepVerifier, Stage: "CommandComplete"},
+		{Type: StepWait, Duration: "2s"},
+		{Type: StepCondition, Timeout: "10s", Conditions: []StackCondition{
+			{Parameter: "/YSS/SIMULATOR/BatteryVoltage1", Operator: "lt", Value: "5"},
+		}},
+	}}, stack)
+}
+
+func TestParseLegacyXMLCommandStack(t *testing.T) {
+	content := `<?xml version="1.0" encoding="UTF-8"?>
+<commandStack>
+  <command qualifiedName="/YSS/SIMULATOR/SWITCH_VOLTAGE_ON" comment="first">
+    <commandArgument argumentName="voltage_num" argumentValue="2"/>
+    <advancement acceptance="Acknowledge_Sent" wait="1500"/>
+  </command>
+  <command qualifiedName="/YSS/SIMULATOR/DUMP_RECORDING"/>
+</commandStack>`
+	stack, err := ParseCommandStack("legacy.xml", []byte(content))
+	require.NoError(t, err)
+	assert.Equal(t, []StackStep{
+		{Type: StepCommand, Command: "/YSS/SIMULATOR/SWITCH_VOLTAGE_ON", Arguments: map[string]any{"voltage_num": "2"}, Comment: "first"},
+		{Type: StepVerifier, Stage: "Acknowledge_Sent"},
+		{Type: StepWait, Duration: "1.5s"},
+		{Type: StepCommand, Command: "/YSS/SIMULATOR/DUMP_RECORDING", Arguments: map[string]any{}},
+	}, stack.Steps)
+
+	_, err = ParseCommandStack("empty.xml", []byte("<commandStack/>"))
+	assert.ErrorContains(t, err, "the stack has no steps")
+}
+
+func TestCommandStackValidate(t *testing.T) {
+	for _, test := range []struct {
+		step StackStep
+		err  string
+	}{
+		{StackStep{Type: StepCommand}, "step 1: missing command name"},
+		{StackStep{Type: StepWait, Duration: "soon"}, `step 1: invalid duration "soon"`},
+		{StackStep{Type: StepVerifier, Stage: "CommandComplete", Timeout: "-1s"}, `step 1: invalid timeout "-1s"`},
+		{StackStep{Type: StepCondition, Conditions: []StackCondition{{Parameter: "/a", Operator: "~"}}}, `step 1: unknown condition operator "~"`},
+		{StackStep{Type: "pray"}, `step 1: unknown step type "pray"`},
+	} {
+		stack := CommandStack{Steps: []StackStep{test.step}}
+		assert.EqualError(t, stack.Validate(), test.err)
+	}
+}
+
+func TestStackConditionHolds(t *testing.T) {
+	voltage := &protobuf.Value{Type: protobuf.Value_DOUBLE.Enum(), DoubleValue: pointer(4.5)}
+	mode := &protobuf.Value{Type: protobuf.Value_ENUMERATED.Enum(), StringValue: pointer("SAFE")}
+
+	assert.True(t, StackCondition{Operator: "lt", Value: "5"}.Holds(voltage))
+	assert.True(t, StackCondition{Operator: ">=", Value: "4.5"}.Holds(voltage))
+	assert.False(t, StackCondition{Operator: "gt", Value: "4.5"}.Holds(voltage))
+	assert.True(t, StackCondition{Operator: "eq", Value: "SAFE"}.Holds(mode))
+	assert.False(t, StackCondition{Operator: "neq", Value: "SAFE"}.Holds(mode))
+	assert.False(t, StackCondition{Operator: "eq", Value: "SAFE"}.Holds(nil))
+}
@@ -0,0 +1,85 @@
+package tools
+
+import (
+	"sort"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/commanding"
+)
+
+// stageRow is one stage of one command in a command stages frame.
+type stageRow struct {
+	command *CommandProgress
+	stage   CommandStage
+	time    time.Time
+}
+
+// CommandProgressFromHistory gathers archived command history entries, which
+// hold every attribute of their command, into the progress of each command.
+func CommandProgressFromHistory(entries []*commanding.CommandHistoryEntry) []*CommandProgress {
+	commands := make([]*CommandProgress, 0, len(entries))
+	for _, entry := range entries {
+		progress := NewCommandProgress(entry.GetId())
+		progress.Update(entry)
+		commands = append(commands, progress)
+	}
+	return commands
+}
+
+// ConvertCommandStagesToFrame lays the stages of commands out in long format,
+// one row per command and stage, ordered by time. The latency of a stage is
+// the time from the issue of the command to the stage, in milliseconds.
+//
+// Stages without a time are left out. When pendingAt is set, a command that
+// did not complete yet gets a PENDING completion row at pendingAt, whose
+// latency is the age of the command, so that commands which never complete
+// can be alerted on.
+func ConvertCommandStagesToFrame(commands []*CommandProgress, pendingAt *time.Time) *data.Frame {
+	rows := []stageRow{}
+	for _, command := range commands {
+		for _, stage := range command.Stages() {
+			if stage.Time != nil {
+				rows = append(rows, stageRow{command: command, stage: stage, time: *stage.Time})
+			}
+		}
+		if pendingAt != nil && !command.Complete() {
+			rows = append(rows, stageRow{command: command, stage: CommandStage{Name: commandCompletion, Status: "PENDING"}, time: *pendingAt})
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].time.Before(rows[j].time) })
+
+	times := make([]time.Time, 0, len(rows))
+	ids := make([]string, 0, len(rows))
+	names := make([]string, 0, len(rows))
+	stages := make([]string, 0, len(rows))
+	statuses := make([]string, 0, len(rows))
+	messages := make([]string, 0, len(rows))
+	latencies := make([]*int64, 0, len(rows))
+	for _, row := range rows {
+		times = append(times, row.time)
+		ids = append(ids, row.command.ID)
+		names = append(names, row.command.Command)
+		stages = append(stages, row.stage.Name)
+		statuses = append(statuses, row.stage.Status)
+		messages = append(messages, row.stage.Message)
+		var latency *int64
+		if !row.command.Issued.IsZero() {
+			ms := row.time.Sub(row.command.Issued).Milliseconds()
+			latency = &ms
+		}
+		latencies = append(latencies, latency)
+	}
+
+	latencyField := data.NewField("latency", nil, latencies)
+	latencyField.Config = &data.FieldConfig{Unit: "ms"}
+	return data.NewFrame("command-stages",
+		data.NewField("time", nil, times),
+		data.NewField("id", nil, ids),
+		data.NewField("command", nil, names),
+		data.NewField("stage", nil, stages),
+		data.NewField("status", nil, statuses),
+		data.NewField("message", nil, messages),
+		latencyField,
+	)
+}
@@ -0,0 +1,63 @@
+package tools
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/commanding"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestConvertCommandStagesToFrame(t *testing.T) {
+	issued := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(ms int64) *protobuf.Value {
+		return &protobuf.Value{TimestampValue: pointer(issued.UnixMilli() + ms)}
+	}
+
+	complete := commandUpdate("cmd-1", map[string]*protobuf.Value{
+		"Acknowledge_Queued_Status": stringValue("OK"),
+		"Acknowledge_Queued_Time":   at(10),
+		"Acknowledge_Sent_Status":   stringValue("OK"),
+		"Acknowledge_Sent_Time":     at(250),
+		"Verifier_Complete_Status":  stringValue("OK"),
+		"Verifier_Complete_Time":    at(1500),
+		"CommandComplete_Status":    stringValue("OK"),
+		"CommandComplete_Time":      at(1500),
+	})
+	complete.CommandName = pointer("/YSS/ON")
+	complete.GenerationTime = timestamppb.New(issued)
+	stuck := commandUpdate("cmd-2", map[string]*protobuf.Value{
+		"Acknowledge_Queued_Status": stringValue("OK"),
+		"Acknowledge_Queued_Time":   at(100),
+		"Verifier_Complete_Status":  stringValue("PENDING"),
+	})
+	stuck.CommandName = pointer("/YSS/OFF")
+	stuck.GenerationTime = timestamppb.New(issued.Add(50 * time.Millisecond))
+
+	now := issued.Add(time.Minute)
+	frame := ConvertCommandStagesToFrame(CommandProgressFromHistory([]*commanding.CommandHistoryEntry{complete, stuck}), &now)
+	require.Equal(t, 6, frame.Rows())
+
+	stage, _ := frame.FieldByName("stage")
+	id, _ := frame.FieldByName("id")
+	status, _ := frame.FieldByName("status")
+	latency, _ := frame.FieldByName("latency")
+	assert.Equal(t, "Acknowledge_Queued", stage.At(0))
+	assert.Equal(t, int64(10), *latency.At(0).(*int64))
+	assert.Equal(t, "cmd-2", id.At(1))
+	assert.Equal(t, int64(50), *latency.At(1).(*int64))
+	assert.Equal(t, "Verifier_Complete", stage.At(3))
+	assert.Equal(t, int64(1500), *latency.At(3).(*int64))
+
+	// The stuck command gets a pending completion at the end, aged one minute.
+	assert.Equal(t, "cmd-2", id.At(5))
+	assert.Equal(t, "CommandComplete", stage.At(5))
+	assert.Equal(t, "PENDING", status.At(5))
+	assert.Equal(t, int64(59950), *latency.At(5).(*int64))
+
+	// Without pendingAt only the reported stages are listed.
+	assert.Equal(t, 5, ConvertCommandStagesToFrame(CommandProgressFromHistory([]*commanding.CommandHistoryEntry{complete, stuck}), nil).Rows())
+}
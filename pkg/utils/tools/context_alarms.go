@@ -0,0 +1,158 @@
+package tools
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/mdb"
+)
+
+// ValueLookup returns the latest engineering value of a parameter, or nil if
+// none has been received.
+type ValueLookup func(parameter string) *protobuf.Value
+
+var comparisonOperators = map[mdb.ComparisonInfo_OperatorType]string{
+	mdb.ComparisonInfo_EQUAL_TO:                 "==",
+	mdb.ComparisonInfo_NOT_EQUAL_TO:             "!=",
+	mdb.ComparisonInfo_GREATER_THAN:             ">",
+	mdb.ComparisonInfo_GREATER_THAN_OR_EQUAL_TO: ">=",
+	mdb.ComparisonInfo_SMALLER_THAN:             "<",
+	mdb.ComparisonInfo_SMALLER_THAN_OR_EQUAL_TO: "<=",
+}
+
+// ContextParameters returns the qualified names of the parameters the context
+// alarms of paramType depend on.
+func ContextParameters(paramType *mdb.ParameterTypeInfo) []string {
+	names := []string{}
+	seen := map[string]bool{}
+	for _, contextAlarm := range paramType.GetContextAlarm() {
+		for _, comparison := range contextAlarm.GetComparison() {
+			name := comparison.GetParameter().GetQualifiedName()
+			if name != "" && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// ActiveAlarm returns the alarm definition applying to paramType given the
+// current values of its context parameters. As in Yamcs, the first context
+// whose comparisons all hold wins; otherwise the default alarm applies and the
+// returned index is -1.
+func ActiveAlarm(paramType *mdb.ParameterTypeInfo, lookup ValueLookup) (int, *mdb.AlarmInfo) {
+	for index, contextAlarm := range paramType.GetContextAlarm() {
+		if ContextHolds(contextAlarm, lookup) {
+			return index, contextAlarm.GetAlarm()
+		}
+	}
+	return -1, paramType.GetDefaultAlarm()
+}
+
+// ContextHolds reports whether every comparison of a context alarm holds. A
+// comparison on a parameter without a known value does not hold.
+func ContextHolds(contextAlarm *mdb.ContextAlarmInfo, lookup ValueLookup) bool {
+	comparisons := contextAlarm.GetComparison()
+	if len(comparisons) == 0 || lookup == nil {
+		return false
+	}
+	for _, comparison := range comparisons {
+		value := lookup(comparison.GetParameter().GetQualifiedName())
+		if value == nil {
+			return false
+		}
+		order, comparable := compareToOperand(value, comparison.GetValue())
+		if !comparable {
+			return false
+		}
+		var holds bool
+		switch comparison.GetOperator() {
+		case mdb.ComparisonInfo_EQUAL_TO:
+			holds = order == 0
+		case mdb.ComparisonInfo_NOT_EQUAL_TO:
+			holds = order != 0
+		case mdb.ComparisonInfo_GREATER_THAN:
+			holds = order > 0
+		case mdb.ComparisonInfo_GREATER_THAN_OR_EQUAL_TO:
+			holds = order >= 0
+		case mdb.ComparisonInfo_SMALLER_THAN:
+			holds = order < 0
+		case mdb.ComparisonInfo_SMALLER_THAN_OR_EQUAL_TO:
+			holds = order <= 0
+		}
+		if !holds {
+			return false
+		}
+	}
+	return true
+}
+
+// DescribeContext renders the condition of a context alarm, e.g.
+// "/YSS/mode == SAFE".
+func DescribeContext(contextAlarm *mdb.ContextAlarmInfo) string {
+	if contextAlarm.GetContext() != "" {
+		return contextAlarm.GetContext()
+	}
+	conditions := []string{}
+	for _, comparison := range contextAlarm.GetComparison() {
+		conditions = append(conditions, comparison.GetParameter().GetQualifiedName()+" "+
+			comparisonOperators[comparison.GetOperator()]+" "+comparison.GetValue())
+	}
+	return strings.Join(conditions, " && ")
+}
+
+// compareToOperand compares a parameter value to the textual operand of an MDB
+// comparison, numerically for numbers and lexically for labels and strings.
+func compareToOperand(value *protobuf.Value, operand string) (int, bool) {
+	switch value.GetType() {
+	case protobuf.Value_FLOAT, protobuf.Value_DOUBLE,
+		protobuf.Value_UINT32, protobuf.Value_SINT32, protobuf.Value_UINT64, protobuf.Value_SINT64:
+		expected, err := strconv.ParseFloat(strings.TrimSpace(operand), 64)
+		if err != nil {
+			return 0, false
+		}
+		actual := numericValue(value)
+		switch {
+		case actual < expected:
+			return -1, true
+		case actual > expected:
+			return 1, true
+		}
+		return 0, true
+	case protobuf.Value_BOOLEAN:
+		expected, err := strconv.ParseBool(strings.TrimSpace(operand))
+		if err != nil {
+			return 0, false
+		}
+		if value.GetBooleanValue() == expected {
+			return 0, true
+		}
+		if value.GetBooleanValue() {
+			return 1, true
+		}
+		return -1, true
+	case protobuf.Value_STRING, protobuf.Value_ENUMERATED:
+		return strings.Compare(value.GetStringValue(), operand), true
+	}
+	return 0, false
+}
+
+func numericValue(value *protobuf.Value) float64 {
+	switch value.GetType() {
+	case protobuf.Value_FLOAT:
+		return float64(value.GetFloatValue())
+	case protobuf.Value_DOUBLE:
+		return value.GetDoubleValue()
+	case protobuf.Value_UINT32:
+		return float64(value.GetUint32Value())
+	case protobuf.Value_SINT32:
+		return float64(value.GetSint32Value())
+	case protobuf.Value_UINT64:
+		return float64(value.GetUint64Value())
+	case protobuf.Value_SINT64:
+		return float64(value.GetSint64Value())
+	}
+	return 0
+}
@@ -0,0 +1,208 @@
+package tools
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/pvalue"
+)
+
+// ExpansionMode selects how aggregate and array values are laid out in a frame.
+type ExpansionMode string
+
+const (
+	// ExpandNone extracts a single member, as designated by the aggregate path.
+	ExpandNone ExpansionMode = ""
+	// ExpandWide gives every leaf member or array element its own field.
+	ExpandWide ExpansionMode = "wide"
+	// ExpandLong puts every leaf in one value field, next to a field naming it.
+	ExpandLong ExpansionMode = "long"
+)
+
+// PathWildcard stands for every index of an array in an aggregate path.
+const PathWildcard = "[*]"
+
+// HasPathWildcard reports whether an aggregate path designates several array elements.
+func HasPathWildcard(aggregatePath string) bool {
+	return strings.Contains(aggregatePath, PathWildcard)
+}
+
+// ExpandValue flattens the part of value designated by aggregatePath into its
+// leaves, calling visit with the path of each leaf, e.g. ".cells[3].voltage".
+// Array wildcards in aggregatePath match every element; whatever lies below the
+// designated part is flattened recursively.
+func ExpandValue(value *protobuf.Value, aggregatePath string, visit func(path string, leaf *protobuf.Value)) {
+	expandPath(value, "", expandSegments(aggregatePath), visit)
+}
+
+func expandSegments(aggregatePath string) []string {
+	wildcards := strings.NewReplacer(PathWildcard, ".*")
+	segments := []string{}
+	for _, part := range strings.Split(wildcards.Replace(strings.TrimPrefix(aggregatePath, ".")), ".") {
+		if part == "*" {
+			segments = append(segments, PathWildcard)
+			continue
+		}
+		segments = append(segments, splitPath(part)...)
+	}
+	return segments
+}
+
+func expandPath(value *protobuf.Value, prefix string, segments []string, visit func(path string, leaf *protobuf.Value)) {
+	if value == nil {
+		return
+	}
+	if len(segments) == 0 {
+		flattenValue(value, prefix, visit)
+		return
+	}
+
+	segment, rest := segments[0], segments[1:]
+	switch {
+	case segment == PathWildcard:
+		for index, element := range value.GetArrayValue() {
+			expandPath(element, prefix+"["+strconv.Itoa(index)+"]", rest, visit)
+		}
+	case strings.HasPrefix(segment, "["):
+		index, err := strconv.Atoi(strings.Trim(segment, "[]"))
+		if elements := value.GetArrayValue(); err == nil && index >= 0 && index < len(elements) {
+			expandPath(elements[index], prefix+segment, rest, visit)
+		}
+	default:
+		aggregate := value.GetAggregateValue()
+		for i, name := range aggregate.GetName() {
+			if strings.EqualFold(name, segment) && i < len(aggregate.GetValue()) {
+				expandPath(aggregate.GetValue()[i], prefix+"."+name, rest, visit)
+				return
+			}
+		}
+	}
+}
+
+func flattenValue(value *protobuf.Value, prefix string, visit func(path string, leaf *protobuf.Value)) {
+	switch value.GetType() {
+	case protobuf.Value_AGGREGATE:
+		aggregate := value.GetAggregateValue()
+		for i, name := range aggregate.GetName() {
+			if i < len(aggregate.GetValue()) {
+				flattenValue(aggregate.GetValue()[i], prefix+"."+name, visit)
+			}
+		}
+	case protobuf.Value_ARRAY:
+		for index, element := range value.GetArrayValue() {
+			flattenValue(element, prefix+"["+strconv.Itoa(index)+"]", visit)
+		}
+	default:
+		visit(prefix, value)
+	}
+}
+
+// leafNumber returns the numeric value of a leaf, if it has one.
+func NumericValue(leaf *protobuf.Value) (float64, bool) {
+	switch leaf.GetType() {
+	case protobuf.Value_FLOAT, protobuf.Value_DOUBLE,
+		protobuf.Value_UINT32, protobuf.Value_SINT32, protobuf.Value_UINT64, protobuf.Value_SINT64:
+		return numericValue(leaf), true
+	}
+	return 0, false
+}
+
+// expandedColumn accumulates the values of one leaf path across a buffer.
+type expandedColumn struct {
+	path    string
+	numbers []*float64
+	strings []*string
+	numeric bool
+}
+
+// ConvertBufferToExpandedFrame converts a parameter value buffer into a frame
+// in which aggregates and arrays are expanded into their leaves. In wide mode
+// each leaf path becomes a field named after parameter and the path, e.g.
+// "/YSS/Battery.cells[3].voltage", leaves missing from a value being null. In
+// long mode the frame has one row per leaf, with a "member" field holding the
+// leaf path (e.g. "cells[3].voltage") and a value field named after parameter.
+func ConvertBufferToExpandedFrame(buffer []*pvalue.ParameterValue, parameter string, aggregatePath string, mode ExpansionMode, realtime bool) *data.Frame {
+	times := make([]time.Time, 0, len(buffer))
+	for _, item := range buffer {
+		if realtime {
+			times = append(times, time.Now())
+		} else {
+			times = append(times, item.GetGenerationTime().AsTime())
+		}
+	}
+
+	if mode == ExpandLong {
+		return convertBufferToLongFrame(buffer, times, parameter, aggregatePath)
+	}
+
+	columns := []*expandedColumn{}
+	byPath := map[string]*expandedColumn{}
+	for row, item := range buffer {
+		ExpandValue(item.GetEngValue(), aggregatePath, func(path string, leaf *protobuf.Value) {
+			column, exists := byPath[path]
+			if !exists {
+				_, numeric := NumericValue(leaf)
+				column = &expandedColumn{
+					path:    path,
+					numeric: numeric,
+					numbers: make([]*float64, len(buffer)),
+					strings: make([]*string, len(buffer)),
+				}
+				byPath[path] = column
+				columns = append(columns, column)
+			}
+			if number, ok := NumericValue(leaf); ok && column.numeric {
+				column.numbers[row] = &number
+			} else {
+				text := StringifyValue(leaf)
+				column.strings[row] = &text
+			}
+		})
+	}
+
+	frame := data.NewFrame("response", data.NewField("time", nil, times))
+	for _, column := range columns {
+		if column.numeric {
+			frame.Fields = append(frame.Fields, data.NewField(parameter+column.path, nil, column.numbers))
+		} else {
+			frame.Fields = append(frame.Fields, data.NewField(parameter+column.path, nil, column.strings))
+		}
+	}
+	return frame
+}
+
+func convertBufferToLongFrame(buffer []*pvalue.ParameterValue, times []time.Time, parameter string, aggregatePath string) *data.Frame {
+	rowTimes := []time.Time{}
+	members := []string{}
+	numbers := []*float64{}
+	texts := []*string{}
+	numeric := true
+
+	for row, item := range buffer {
+		ExpandValue(item.GetEngValue(), aggregatePath, func(path string, leaf *protobuf.Value) {
+			rowTimes = append(rowTimes, times[row])
+			members = append(members, strings.TrimPrefix(path, "."))
+			text := StringifyValue(leaf)
+			texts = append(texts, &text)
+			if number, ok := NumericValue(leaf); ok {
+				numbers = append(numbers, &number)
+			} else {
+				numeric = false
+				numbers = append(numbers, nil)
+			}
+		})
+	}
+
+	valueField := data.NewField(parameter, nil, numbers)
+	if !numeric {
+		valueField = data.NewField(parameter, nil, texts)
+	}
+	return data.NewFrame("response",
+		data.NewField("time", nil, rowTimes),
+		data.NewField("member", nil, members),
+		valueField,
+	)
+}
@@ -0,0 +1,82 @@
+package tools
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/pvalue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func batteryValue(t time.Time, status string, voltages ...float64) *pvalue.ParameterValue {
+	cells := []*protobuf.Value{}
+	for _, voltage := range voltages {
+		cells = append(cells, &protobuf.Value{
+			Type: protobuf.Value_AGGREGATE.Enum(),
+			AggregateValue: &protobuf.AggregateValue{
+				Name:  []string{"voltage"},
+				Value: []*protobuf.Value{{Type: protobuf.Value_DOUBLE.Enum(), DoubleValue: pointer(voltage)}},
+			},
+		})
+	}
+	return &pvalue.ParameterValue{
+		GenerationTime: timestamppb.New(t),
+		EngValue: &protobuf.Value{
+			Type: protobuf.Value_AGGREGATE.Enum(),
+			AggregateValue: &protobuf.AggregateValue{
+				Name: []string{"status", "cells"},
+				Value: []*protobuf.Value{
+					{Type: protobuf.Value_ENUMERATED.Enum(), StringValue: pointer(status)},
+					{Type: protobuf.Value_ARRAY.Enum(), ArrayValue: cells},
+				},
+			},
+		},
+	}
+}
+
+func TestExpandValue(t *testing.T) {
+	value := batteryValue(time.Unix(0, 0), "OK", 3.1, 3.2).GetEngValue()
+	collect := func(aggregatePath string) []string {
+		paths := []string{}
+		ExpandValue(value, aggregatePath, func(path string, leaf *protobuf.Value) {
+			paths = append(paths, path)
+		})
+		return paths
+	}
+
+	assert.Equal(t, []string{".status", ".cells[0].voltage", ".cells[1].voltage"}, collect(""))
+	assert.Equal(t, []string{".cells[0].voltage", ".cells[1].voltage"}, collect("cells[*].voltage"))
+	assert.Equal(t, []string{".cells[1].voltage"}, collect(".cells[1]"))
+	assert.Empty(t, collect("cells[5]"))
+}
+
+func TestConvertBufferToExpandedFrame(t *testing.T) {
+	start := time.Unix(1700000000, 0).UTC()
+	buffer := []*pvalue.ParameterValue{
+		batteryValue(start, "OK", 3.1),
+		batteryValue(start.Add(time.Second), "LOW", 3.0, 2.9),
+	}
+
+	t.Run("Wide", func(t *testing.T) {
+		frame := ConvertBufferToExpandedFrame(buffer, "/YSS/Battery", "", ExpandWide, false)
+		require.Len(t, frame.Fields, 4)
+		assert.Equal(t, "/YSS/Battery.status", frame.Fields[1].Name)
+		assert.Equal(t, "LOW", *frame.Fields[1].At(1).(*string))
+		assert.Equal(t, "/YSS/Battery.cells[1].voltage", frame.Fields[3].Name)
+		assert.Nil(t, frame.Fields[3].At(0))
+		assert.Equal(t, 2.9, *frame.Fields[3].At(1).(*float64))
+	})
+
+	t.Run("Long", func(t *testing.T) {
+		frame := ConvertBufferToExpandedFrame(buffer, "/YSS/Battery", "cells[*].voltage", ExpandLong, false)
+		require.Len(t, frame.Fields, 3)
+		assert.Equal(t, 3, frame.Rows())
+		assert.Equal(t, start.Add(time.Second), frame.Fields[0].At(2))
+		assert.Equal(t, "cells[1].voltage", frame.Fields[1].At(2))
+		assert.Equal(t, "/YSS/Battery", frame.Fields[2].Name)
+		assert.Equal(t, 2.9, *frame.Fields[2].At(2).(*float64))
+	})
+}
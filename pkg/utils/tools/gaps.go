@@ -0,0 +1,259 @@
+package tools
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/archive"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/pvalue"
+)
+
+// ccsdsSequenceCounts is the modulus of CCSDS packet sequence counts.
+const ccsdsSequenceCounts = 1 << 14
+
+// Gap is an interval in which no data was received.
+type Gap struct {
+	Start, End time.Time
+	// Missing is the number of packets lost in the gap, according to the
+	// packet sequence counts, or nil when it is not known.
+	Missing *int64
+}
+
+// Duration returns the length of the gap.
+func (g Gap) Duration() time.Duration {
+	return g.End.Sub(g.Start)
+}
+
+// GapSeries holds the gaps of one source of data: a parameter, an APID or a
+// packet.
+type GapSeries struct {
+	Name string
+	Gaps []Gap
+}
+
+// appendGap appends the gap [from, to), clipped to [start, end), to gaps when
+// it lasts at least minGap.
+func appendGap(gaps []Gap, from, to, start, end time.Time, minGap time.Duration) []Gap {
+	if from.Before(start) {
+		from = start
+	}
+	if to.After(end) {
+		to = end
+	}
+	if !to.After(from) || to.Sub(from) < minGap {
+		return gaps
+	}
+	return append(gaps, Gap{Start: from, End: to})
+}
+
+// RangeGaps returns the gaps of at least minGap between start and end left by
+// value ranges: the spaces between consecutive ranges, ranges holding no value
+// and the edges of the window. Yamcs splits ranges where consecutive values
+// are further apart than the parameter expiration.
+func RangeGaps(ranges *pvalue.Ranges, start, end time.Time, minGap time.Duration) []Gap {
+	gaps := []Gap{}
+	covered := start
+	for _, r := range ranges.GetRange() {
+		if r.GetCount() == 0 && len(r.GetEngValues()) == 0 {
+			continue
+		}
+		from, to := r.GetStart().AsTime(), r.GetStop().AsTime()
+		gaps = appendGap(gaps, covered, from, start, end, minGap)
+		if to.After(covered) {
+			covered = to
+		}
+	}
+	return appendGap(gaps, covered, end, start, end, minGap)
+}
+
+// SampleGaps returns the runs of empty sample buckets lasting at least minGap.
+// A run extends from the start of its first empty bucket to the start of the
+// next non-empty one, or to end.
+func SampleGaps(samples []*pvalue.TimeSeries_Sample, start, end time.Time, minGap time.Duration) []Gap {
+	gaps := []Gap{}
+	var runStart *time.Time
+	for _, sample := range samples {
+		t := sample.GetTime().AsTime()
+		switch {
+		case sample.GetN() == 0 && runStart == nil:
+			runStart = &t
+		case sample.GetN() > 0 && runStart != nil:
+			gaps = appendGap(gaps, *runStart, t, start, end, minGap)
+			runStart = nil
+		}
+	}
+	if runStart != nil {
+		gaps = appendGap(gaps, *runStart, end, start, end, minGap)
+	}
+	return gaps
+}
+
+// IndexGaps returns the gaps of at least minGap between start and end left by
+// the entries of an archive index group, which must be sorted by time. When
+// the entries carry packet sequence counts, each gap between two entries
+// tells how many packets were lost, modulo the 14-bit CCSDS counter.
+func IndexGaps(entries []*archive.IndexEntry, start, end time.Time, minGap time.Duration) ([]Gap, error) {
+	gaps := []Gap{}
+	covered := start
+	var previous *archive.IndexEntry
+	for _, entry := range entries {
+		from, err := time.Parse(time.RFC3339Nano, entry.GetStart())
+		if err != nil {
+			return nil, fmt.Errorf("invalid index entry start %q: %w", entry.GetStart(), err)
+		}
+		to, err := time.Parse(time.RFC3339Nano, entry.GetStop())
+		if err != nil {
+			return nil, fmt.Errorf("invalid index entry stop %q: %w", entry.GetStop(), err)
+		}
+
+		before := len(gaps)
+		gaps = appendGap(gaps, covered, from, start, end, minGap)
+		if len(gaps) > before && previous != nil && previous.SeqStop != nil && entry.SeqStart != nil {
+			missing := ((entry.GetSeqStart()-previous.GetSeqStop()-1)%ccsdsSequenceCounts + ccsdsSequenceCounts) % ccsdsSequenceCounts
+			gaps[len(gaps)-1].Missing = &missing
+		}
+		if to.After(covered) {
+			covered = to
+		}
+		previous = entry
+	}
+	return appendGap(gaps, covered, end, start, end, minGap), nil
+}
+
+// MergeGaps returns the union of several lists of gaps, sorted by start.
+// Overlapping gaps are merged; the number of missing packets is kept when only
+// one of them knows it.
+func MergeGaps(lists ...[]Gap) []Gap {
+	all := []Gap{}
+	for _, list := range lists {
+		all = append(all, list...)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Start.Before(all[j].Start) })
+
+	merged := []Gap{}
+	for _, gap := range all {
+		if n := len(merged); n > 0 && !gap.Start.After(merged[n-1].End) {
+			last := &merged[n-1]
+			if gap.End.After(last.End) {
+				last.End = gap.End
+			}
+			if last.Missing == nil {
+				last.Missing = gap.Missing
+			}
+			continue
+		}
+		merged = append(merged, gap)
+	}
+	return merged
+}
+
+// Completeness returns the share of each interval of [start, end) not covered
+// by gaps, with the start of each interval. A zero interval covers the whole
+// window at once.
+func Completeness(gaps []Gap, start, end time.Time, interval time.Duration) ([]time.Time, []float64) {
+	if interval <= 0 {
+		interval = end.Sub(start)
+	}
+	starts := []time.Time{}
+	shares := []float64{}
+	for from := start; from.Before(end); from = from.Add(interval) {
+		to := from.Add(interval)
+		if to.After(end) {
+			to = end
+		}
+		missing := time.Duration(0)
+		for _, gap := range gaps {
+			overlapStart, overlapEnd := gap.Start, gap.End
+			if overlapStart.Before(from) {
+				overlapStart = from
+			}
+			if overlapEnd.After(to) {
+				overlapEnd = to
+			}
+			if overlapEnd.After(overlapStart) {
+				missing += overlapEnd.Sub(overlapStart)
+			}
+		}
+		starts = append(starts, from)
+		shares = append(shares, 1-float64(missing)/float64(to.Sub(from)))
+	}
+	return starts, shares
+}
+
+// ConvertGapsToFrame converts gap series into a frame with one row per gap,
+// sorted by start. The time, timeEnd and text fields let the frame be used for
+// region annotations.
+func ConvertGapsToFrame(series []GapSeries) *data.Frame {
+	type row struct {
+		source string
+		gap    Gap
+	}
+	rows := []row{}
+	for _, s := range series {
+		for _, gap := range s.Gaps {
+			rows = append(rows, row{s.Name, gap})
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].gap.Start.Before(rows[j].gap.Start) })
+
+	starts := make([]time.Time, 0, len(rows))
+	ends := make([]time.Time, 0, len(rows))
+	durations := make([]int64, 0, len(rows))
+	sources := make([]string, 0, len(rows))
+	missing := make([]*int64, 0, len(rows))
+	texts := make([]string, 0, len(rows))
+	for _, r := range rows {
+		starts = append(starts, r.gap.Start)
+		ends = append(ends, r.gap.End)
+		durations = append(durations, r.gap.Duration().Milliseconds())
+		sources = append(sources, r.source)
+		missing = append(missing, r.gap.Missing)
+		text := fmt.Sprintf("No data from %s for %s", r.source, r.gap.Duration().Round(time.Second))
+		if r.gap.Missing != nil {
+			text += fmt.Sprintf(", %d packets lost", *r.gap.Missing)
+		}
+		texts = append(texts, text)
+	}
+
+	durationField := data.NewField("duration", nil, durations)
+	durationField.Config = &data.FieldConfig{Unit: "ms"}
+	return data.NewFrame("gaps",
+		data.NewField("time", nil, starts),
+		data.NewField("timeEnd", nil, ends),
+		durationField,
+		data.NewField("source", nil, sources),
+		data.NewField("missing", nil, missing),
+		data.NewField("text", nil, texts),
+	)
+}
+
+// ConvertCompletenessToFrame converts gap series into a frame holding the
+// completeness of each series over intervals of [start, end), one field per
+// series. The completeness of each series over the whole window is given in
+// the "completeness" custom frame metadata.
+func ConvertCompletenessToFrame(series []GapSeries, start, end time.Time, interval time.Duration) *data.Frame {
+	frame := data.NewFrame("completeness")
+	overall := map[string]float64{}
+	for i, s := range series {
+		starts, shares := Completeness(s.Gaps, start, end, interval)
+		if i == 0 {
+			frame.Fields = append(frame.Fields, data.NewField("time", nil, starts))
+		}
+		field := data.NewField(s.Name, nil, shares)
+		min, max := data.ConfFloat64(0), data.ConfFloat64(1)
+		field.Config = &data.FieldConfig{Unit: "percentunit", Min: &min, Max: &max}
+		frame.Fields = append(frame.Fields, field)
+
+		_, whole := Completeness(s.Gaps, start, end, 0)
+		if len(whole) > 0 {
+			overall[s.Name] = whole[0]
+		}
+	}
+	if len(series) == 0 {
+		frame.Fields = append(frame.Fields, data.NewField("time", nil, []time.Time{}))
+	}
+	frame.Meta = &data.FrameMeta{Custom: map[string]any{"completeness": overall}}
+	return frame
+}
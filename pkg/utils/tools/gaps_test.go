@@ -0,0 +1,118 @@
+package tools
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/archive"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/pvalue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestRangeGaps(t *testing.T) {
+	start := time.Unix(1700000000, 0).UTC()
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+	ranges := &pvalue.Ranges{Range: []*pvalue.Ranges_Range{
+		stateRange(at(2), at(10), map[string]int32{"SAFE": 8}),
+		stateRange(at(10), at(20), map[string]int32{"NOMINAL": 10}),
+		// A one minute silence, shorter than the minimum gap.
+		stateRange(at(21), at(30), map[string]int32{"NOMINAL": 9}),
+		stateRange(at(45), at(50), map[string]int32{"SAFE": 5}),
+	}}
+
+	gaps := RangeGaps(ranges, start, at(60), 2*time.Minute)
+	assert.Equal(t, []Gap{
+		{Start: at(0), End: at(2)},
+		{Start: at(30), End: at(45)},
+		{Start: at(50), End: at(60)},
+	}, gaps)
+
+	// Without data, the whole window is a gap.
+	assert.Equal(t, []Gap{{Start: at(0), End: at(60)}}, RangeGaps(&pvalue.Ranges{}, start, at(60), 0))
+}
+
+func TestSampleGaps(t *testing.T) {
+	start := time.Unix(1700000000, 0).UTC()
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+	sample := func(minutes int, n int32) *pvalue.TimeSeries_Sample {
+		return &pvalue.TimeSeries_Sample{Time: timestamppb.New(at(minutes)), N: pointer(n)}
+	}
+	samples := []*pvalue.TimeSeries_Sample{
+		sample(0, 3), sample(10, 0), sample(20, 4), sample(30, 0), sample(40, 0), sample(50, 0),
+	}
+
+	assert.Equal(t, []Gap{
+		{Start: at(10), End: at(20)},
+		{Start: at(30), End: at(60)},
+	}, SampleGaps(samples, start, at(60), 0))
+	assert.Equal(t, []Gap{{Start: at(30), End: at(60)}}, SampleGaps(samples, start, at(60), 15*time.Minute))
+}
+
+func TestIndexGapsCountsMissingPackets(t *testing.T) {
+	start := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	entry := func(from, to string, seqStart, seqStop int64) *archive.IndexEntry {
+		return &archive.IndexEntry{
+			Start:    pointer("2025-03-01T12:" + from + "Z"),
+			Stop:     pointer("2025-03-01T12:" + to + "Z"),
+			SeqStart: pointer(seqStart),
+			SeqStop:  pointer(seqStop),
+		}
+	}
+	entries := []*archive.IndexEntry{
+		entry("00:00.000", "10:00.000", 100, 699),
+		entry("12:00.000", "20:00.000", 820, 1299),
+		// The sequence count wraps around.
+		entry("25:00.000", "30:00.000", 3, 302),
+	}
+
+	gaps, err := IndexGaps(entries, start, start.Add(30*time.Minute), time.Minute)
+	require.NoError(t, err)
+	require.Len(t, gaps, 2)
+	assert.Equal(t, start.Add(10*time.Minute), gaps[0].Start)
+	assert.Equal(t, int64(120), *gaps[0].Missing)
+	assert.Equal(t, int64(16384-1300+3), *gaps[1].Missing)
+
+	_, err = IndexGaps([]*archive.IndexEntry{{Start: pointer("yesterday")}}, start, start.Add(time.Hour), 0)
+	assert.ErrorContains(t, err, "invalid index entry start")
+}
+
+func TestMergeGaps(t *testing.T) {
+	start := time.Unix(1700000000, 0).UTC()
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+
+	merged := MergeGaps(
+		[]Gap{{Start: at(0), End: at(5)}, {Start: at(20), End: at(30)}},
+		[]Gap{{Start: at(4), End: at(8)}, {Start: at(40), End: at(41), Missing: pointer(int64(3))}},
+	)
+	assert.Equal(t, []Gap{
+		{Start: at(0), End: at(8)},
+		{Start: at(20), End: at(30)},
+		{Start: at(40), End: at(41), Missing: pointer(int64(3))},
+	}, merged)
+}
+
+func TestConvertCompletenessToFrame(t *testing.T) {
+	start := time.Unix(1700000000, 0).UTC()
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+	series := []GapSeries{
+		{Name: "apid 100", Gaps: []Gap{{Start: at(15), End: at(45)}}},
+		{Name: "apid 200"},
+	}
+
+	frame := ConvertCompletenessToFrame(series, start, at(60), 30*time.Minute)
+	require.Len(t, frame.Fields, 3)
+	require.Equal(t, 2, frame.Rows())
+	assert.Equal(t, at(30), frame.Fields[0].At(1))
+	assert.InDelta(t, 0.5, frame.Fields[1].At(0), 1e-9)
+	assert.InDelta(t, 0.5, frame.Fields[1].At(1), 1e-9)
+	assert.InDelta(t, 1.0, frame.Fields[2].At(0), 1e-9)
+	assert.Equal(t, "percentunit", frame.Fields[1].Config.Unit)
+	assert.Equal(t, map[string]float64{"apid 100": 0.5, "apid 200": 1}, frame.Meta.Custom.(map[string]any)["completeness"])
+
+	gaps := ConvertGapsToFrame(series)
+	require.Equal(t, 1, gaps.Rows())
+	assert.Equal(t, int64(30*time.Minute/time.Millisecond), gaps.Fields[2].At(0))
+	assert.Equal(t, "No data from apid 100 for 30m0s", gaps.Fields[5].At(0))
+}
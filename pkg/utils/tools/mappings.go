@@ -0,0 +1,149 @@
+package tools
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/mdb"
+)
+
+// Colors of boolean states when the MDB does not raise alarms on them.
+const (
+	booleanTrueColor  = "#3AAB58"
+	booleanFalseColor = "#D72638"
+)
+
+// ParameterTypeAt returns the type of the member of paramType designated by an
+// aggregate path such as "position.x", "cells[3].voltage" or "cells[*].voltage".
+// An empty path designates paramType itself. It returns nil if the path does
+// not exist.
+func ParameterTypeAt(paramType *mdb.ParameterTypeInfo, path string) *mdb.ParameterTypeInfo {
+	for _, part := range expandSegments(path) {
+		if paramType == nil {
+			return nil
+		}
+		if strings.HasPrefix(part, "[") {
+			paramType = paramType.GetArrayInfo().GetType()
+			continue
+		}
+		var memberType *mdb.ParameterTypeInfo
+		for _, member := range paramType.GetMember() {
+			if strings.EqualFold(member.GetName(), part) {
+				memberType = member.GetType()
+				break
+			}
+		}
+		paramType = memberType
+	}
+	return paramType
+}
+
+// EnumerationAlarms returns the enumeration alarms of alarmInfo, whichever of
+// the current or deprecated field the server filled.
+func EnumerationAlarms(alarmInfo *mdb.AlarmInfo) []*mdb.EnumerationAlarm {
+	if alarms := alarmInfo.GetEnumerationAlarms(); len(alarms) > 0 {
+		return alarms
+	}
+	return alarmInfo.GetEnumerationAlarm()
+}
+
+// StateAlarmInfo returns the alarm definition that colors the states of
+// paramType: its default alarm, or failing enumeration alarms there, the first
+// context alarm defining some.
+func StateAlarmInfo(paramType *mdb.ParameterTypeInfo) *mdb.AlarmInfo {
+	if len(EnumerationAlarms(paramType.GetDefaultAlarm())) > 0 {
+		return paramType.GetDefaultAlarm()
+	}
+	for _, contextAlarm := range paramType.GetContextAlarm() {
+		if len(EnumerationAlarms(contextAlarm.GetAlarm())) > 0 {
+			return contextAlarm.GetAlarm()
+		}
+	}
+	return paramType.GetDefaultAlarm()
+}
+
+// ConvertParameterTypeToMappings builds Grafana value mappings from the MDB type
+// of a parameter: enumeration labels and boolean state names. States covered by
+// an enumeration alarm take the color of their alarm level, as in
+// AlarmLevelColors. Raw enumeration values are mapped as well, so that numeric
+// series of an enumerated parameter show their labels. It returns nil for types
+// that have no states.
+func ConvertParameterTypeToMappings(paramType *mdb.ParameterTypeInfo, alarmInfo *mdb.AlarmInfo) data.ValueMappings {
+	switch strings.ToLower(paramType.GetEngType()) {
+	case "enumeration":
+		return data.ValueMappings{enumerationMapper(paramType.GetEnumValue(), alarmInfo)}
+	case "boolean":
+		return data.ValueMappings{booleanMapper(paramType)}
+	}
+	return nil
+}
+
+func enumerationMapper(values []*mdb.EnumValue, alarmInfo *mdb.AlarmInfo) data.ValueMapper {
+	levels := map[string]mdb.AlarmLevelType{}
+	for _, alarm := range EnumerationAlarms(alarmInfo) {
+		levels[alarm.GetLabel()] = alarm.GetLevel()
+	}
+	hasAlarms := len(levels) > 0 || alarmInfo.GetDefaultLevel() != mdb.AlarmLevelType_NORMAL
+
+	mapper := data.ValueMapper{}
+	for index, value := range values {
+		label := value.GetLabel()
+		color := HashToRGB(label)
+		if hasAlarms {
+			level, exists := levels[label]
+			if !exists {
+				level = alarmInfo.GetDefaultLevel()
+			}
+			color = alarmLevelColor(level)
+		}
+		result := data.ValueMappingResult{Text: label, Color: color, Index: index}
+		mapper[label] = result
+		mapper[strconv.FormatInt(value.GetValue(), 10)] = result
+	}
+	return mapper
+}
+
+func booleanMapper(paramType *mdb.ParameterTypeInfo) data.ValueMapper {
+	trueText, falseText := "TRUE", "FALSE"
+	if paramType.GetOneStringValue() != "" {
+		trueText = paramType.GetOneStringValue()
+	}
+	if paramType.GetZeroStringValue() != "" {
+		falseText = paramType.GetZeroStringValue()
+	}
+	return data.ValueMapper{
+		"false": {Text: falseText, Color: booleanFalseColor, Index: 0},
+		"true":  {Text: trueText, Color: booleanTrueColor, Index: 1},
+	}
+}
+
+func alarmLevelColor(level mdb.AlarmLevelType) string {
+	if color, exists := AlarmLevelColors[level]; exists {
+		return color
+	}
+	return "gray"
+}
+
+// MergeValueMappings combines value-to-text mappings, entries of overrides
+// taking precedence over those of base. Other kinds of mappings are kept as is.
+func MergeValueMappings(base, overrides data.ValueMappings) data.ValueMappings {
+	merged := data.ValueMapper{}
+	result := data.ValueMappings{}
+	for _, mappings := range []data.ValueMappings{base, overrides} {
+		for _, mapping := range mappings {
+			mapper, ok := mapping.(data.ValueMapper)
+			if !ok {
+				result = append(result, mapping)
+				continue
+			}
+			for value, mappingResult := range mapper {
+				merged[value] = mappingResult
+			}
+		}
+	}
+	if len(merged) > 0 {
+		result = append(data.ValueMappings{merged}, result...)
+	}
+	return result
+}
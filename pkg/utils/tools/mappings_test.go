@@ -0,0 +1,140 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/mdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertParameterTypeToMappings(t *testing.T) {
+	enumType := &mdb.ParameterTypeInfo{
+		EngType: pointer("enumeration"),
+		EnumValue: []*mdb.EnumValue{
+			{Value: pointer(int64(0)), Label: pointer("OFF")},
+			{Value: pointer(int64(1)), Label: pointer("ON")},
+			{Value: pointer(int64(2)), Label: pointer("FAULT")},
+		},
+	}
+	booleanType := &mdb.ParameterTypeInfo{
+		EngType:         pointer("boolean"),
+		OneStringValue:  pointer("OPEN"),
+		ZeroStringValue: pointer("CLOSED"),
+	}
+
+	tests := []struct {
+		name      string
+		paramType *mdb.ParameterTypeInfo
+		alarmInfo *mdb.AlarmInfo
+		want      data.ValueMappings
+	}{
+		{
+			name:      "Float type has no mappings",
+			paramType: &mdb.ParameterTypeInfo{EngType: pointer("float")},
+			want:      nil,
+		},
+		{
+			name:      "Enumeration alarm levels",
+			paramType: enumType,
+			alarmInfo: &mdb.AlarmInfo{
+				EnumerationAlarms: []*mdb.EnumerationAlarm{
+					{Label: pointer("FAULT"), Level: mdb.AlarmLevelType_CRITICAL.Enum()},
+				},
+			},
+			want: data.ValueMappings{data.ValueMapper{
+				"OFF":   {Text: "OFF", Color: "green", Index: 0},
+				"0":     {Text: "OFF", Color: "green", Index: 0},
+				"ON":    {Text: "ON", Color: "green", Index: 1},
+				"1":     {Text: "ON", Color: "green", Index: 1},
+				"FAULT": {Text: "FAULT", Color: "red", Index: 2},
+				"2":     {Text: "FAULT", Color: "red", Index: 2},
+			}},
+		},
+		{
+			name:      "Boolean state names",
+			paramType: booleanType,
+			want: data.ValueMappings{data.ValueMapper{
+				"false": {Text: "CLOSED", Color: "#D72638", Index: 0},
+				"true":  {Text: "OPEN", Color: "#3AAB58", Index: 1},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ConvertParameterTypeToMappings(tt.paramType, tt.alarmInfo))
+		})
+	}
+}
+
+func TestEnumerationWithoutAlarmsUsesLabelColors(t *testing.T) {
+	mappings := ConvertParameterTypeToMappings(&mdb.ParameterTypeInfo{
+		EngType:   pointer("enumeration"),
+		EnumValue: []*mdb.EnumValue{{Value: pointer(int64(3)), Label: pointer("SAFE")}},
+	}, nil)
+
+	require.Len(t, mappings, 1)
+	mapper := mappings[0].(data.ValueMapper)
+	assert.Equal(t, HashToRGB("SAFE"), mapper["SAFE"].Color)
+	assert.Equal(t, "SAFE", mapper["3"].Text)
+}
+
+func TestParameterTypeAt(t *testing.T) {
+	voltage := &mdb.ParameterTypeInfo{EngType: pointer("float")}
+	battery := &mdb.ParameterTypeInfo{
+		EngType: pointer("aggregate"),
+		Member: []*mdb.MemberInfo{{
+			Name: pointer("cells"),
+			Type: &mdb.ParameterTypeInfo{
+				EngType: pointer("array"),
+				ArrayInfo: &mdb.ArrayInfo{Type: &mdb.ParameterTypeInfo{
+					EngType: pointer("aggregate"),
+					Member:  []*mdb.MemberInfo{{Name: pointer("voltage"), Type: voltage}},
+				}},
+			},
+		}},
+	}
+
+	assert.Same(t, battery, ParameterTypeAt(battery, ""))
+	assert.Same(t, voltage, ParameterTypeAt(battery, "cells[2].voltage"))
+	assert.Same(t, voltage, ParameterTypeAt(battery, ".cells[0].voltage"))
+	assert.Nil(t, ParameterTypeAt(battery, "cells[0].current"))
+	assert.Nil(t, ParameterTypeAt(nil, "cells"))
+}
+
+func TestMergeValueMappings(t *testing.T) {
+	base := data.ValueMappings{data.ValueMapper{
+		"ON":  {Color: "#123456"},
+		"OFF": {Color: "#654321"},
+	}}
+	overrides := data.ValueMappings{data.ValueMapper{
+		"ON": {Text: "ON", Color: "red"},
+	}}
+
+	merged := MergeValueMappings(base, overrides)
+	require.Len(t, merged, 1)
+	assert.Equal(t, data.ValueMapper{
+		"ON":  {Text: "ON", Color: "red"},
+		"OFF": {Color: "#654321"},
+	}, merged[0])
+}
+
+func TestStateAlarmInfoFallsBackToContextAlarm(t *testing.T) {
+	contextAlarm := &mdb.AlarmInfo{
+		EnumerationAlarms: []*mdb.EnumerationAlarm{
+			{Label: pointer("FAULT"), Level: mdb.AlarmLevelType_WARNING.Enum()},
+		},
+	}
+	paramType := &mdb.ParameterTypeInfo{
+		DefaultAlarm: &mdb.AlarmInfo{},
+		ContextAlarm: []*mdb.ContextAlarmInfo{
+			{Context: pointer("/mode == 'SAFE'"), Alarm: &mdb.AlarmInfo{}},
+			{Context: pointer("/mode == 'NOMINAL'"), Alarm: contextAlarm},
+		},
+	}
+
+	assert.Same(t, contextAlarm, StateAlarmInfo(paramType))
+	assert.Nil(t, StateAlarmInfo(nil))
+}
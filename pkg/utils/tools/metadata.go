@@ -0,0 +1,188 @@
+package tools
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/mdb"
+)
+
+// ParameterMetadata describes a parameter, or one member of an aggregate
+// parameter, the way panels present it.
+type ParameterMetadata struct {
+	QualifiedName   string            `json:"qualifiedName"`
+	Path            string            `json:"path,omitempty"`
+	DisplayName     string            `json:"displayName"`
+	Description     string            `json:"description,omitempty"`
+	LongDescription string            `json:"longDescription,omitempty"`
+	Aliases         map[string]string `json:"aliases,omitempty"`
+	Type            string            `json:"type,omitempty"`
+	Unit            string            `json:"unit,omitempty"`
+	DataSource      string            `json:"dataSource"`
+	Min             *float64          `json:"min,omitempty"`
+	Max             *float64          `json:"max,omitempty"`
+	Decimals        *uint16           `json:"decimals,omitempty"`
+	Calibration     string            `json:"calibration,omitempty"`
+}
+
+// ConvertParameterInfoToMetadata gathers the metadata of a parameter, or of its
+// member at aggregatePath.
+func ConvertParameterInfoToMetadata(info *mdb.ParameterInfo, aggregatePath string) ParameterMetadata {
+	path := strings.TrimPrefix(aggregatePath, ".")
+	paramType := ParameterTypeAt(info.GetType(), path)
+
+	metadata := ParameterMetadata{
+		QualifiedName:   info.GetQualifiedName(),
+		Path:            path,
+		DisplayName:     displayName(info),
+		Description:     info.GetShortDescription(),
+		LongDescription: info.GetLongDescription(),
+		Type:            paramType.GetEngType(),
+		DataSource:      strings.ToLower(info.GetDataSource().String()),
+		Calibration:     DescribeCalibrator(paramType.GetDataEncoding().GetDefaultCalibrator()),
+	}
+	if path != "" {
+		metadata.DisplayName += "." + path
+		if paramType.GetShortDescription() != "" {
+			metadata.Description = paramType.GetShortDescription()
+		}
+	}
+	if metadata.Description == "" {
+		metadata.Description = metadata.LongDescription
+	}
+	for _, alias := range info.GetAlias() {
+		if metadata.Aliases == nil {
+			metadata.Aliases = map[string]string{}
+		}
+		metadata.Aliases[alias.GetNamespace()] = alias.GetName()
+	}
+	if unitSet := paramType.GetUnitSet(); len(unitSet) > 0 {
+		metadata.Unit = unitSet[0].GetUnit()
+	}
+	metadata.Min, metadata.Max = valueRange(paramType)
+	metadata.Decimals = decimals(paramType)
+	return metadata
+}
+
+// displayName prefers an alias outside the space system hierarchy, which is how
+// operators usually know a parameter, then the parameter's short name.
+func displayName(info *mdb.ParameterInfo) string {
+	for _, alias := range info.GetAlias() {
+		if !strings.HasPrefix(alias.GetNamespace(), "/") && alias.GetName() != "" {
+			return alias.GetName()
+		}
+	}
+	if info.GetName() != "" {
+		return info.GetName()
+	}
+	return info.GetQualifiedName()
+}
+
+// valueRange returns the range of engineering values an integer type can hold.
+// Other types carry no range in the MDB API.
+func valueRange(paramType *mdb.ParameterTypeInfo) (*float64, *float64) {
+	bits := paramType.GetSizeInBits()
+	if paramType.GetEngType() != "integer" || bits <= 0 || bits > 64 {
+		return nil, nil
+	}
+	var min, max float64
+	if paramType.GetSigned() {
+		min, max = -math.Exp2(float64(bits-1)), math.Exp2(float64(bits-1))-1
+	} else {
+		min, max = 0, math.Exp2(float64(bits))-1
+	}
+	return &min, &max
+}
+
+// decimals returns the number of decimals to display: the number format of the
+// type when it has one, none for integers.
+func decimals(paramType *mdb.ParameterTypeInfo) *uint16 {
+	if format := paramType.GetNumberFormat(); format != nil && format.MaximumFractionDigits != nil {
+		digits := uint16(max(format.GetMaximumFractionDigits(), 0))
+		return &digits
+	}
+	if paramType.GetEngType() == "integer" {
+		digits := uint16(0)
+		return &digits
+	}
+	return nil
+}
+
+// DescribeCalibrator renders a calibrator as a formula or a point list.
+func DescribeCalibrator(calibrator *mdb.CalibratorInfo) string {
+	if calibrator == nil {
+		return ""
+	}
+	switch calibrator.GetType() {
+	case mdb.CalibratorInfo_POLYNOMIAL:
+		coefficients := calibrator.GetPolynomialCalibrator().GetCoefficients()
+		if len(coefficients) == 0 {
+			coefficients = calibrator.GetPolynomialCalibrator().GetCoefficient()
+		}
+		terms := []string{}
+		for power, coefficient := range coefficients {
+			if coefficient == 0 {
+				continue
+			}
+			term := strconv.FormatFloat(coefficient, 'g', -1, 64)
+			switch power {
+			case 0:
+			case 1:
+				term += "*x"
+			default:
+				term += "*x^" + strconv.Itoa(power)
+			}
+			terms = append(terms, term)
+		}
+		if len(terms) == 0 {
+			return "polynomial: 0"
+		}
+		return "polynomial: " + strings.Join(terms, " + ")
+	case mdb.CalibratorInfo_SPLINE:
+		points := calibrator.GetSplineCalibrator().GetPoints()
+		if len(points) == 0 {
+			points = calibrator.GetSplineCalibrator().GetPoint()
+		}
+		pairs := []string{}
+		for _, point := range points {
+			pairs = append(pairs, "("+strconv.FormatFloat(point.GetRaw(), 'g', -1, 64)+", "+
+				strconv.FormatFloat(point.GetCalibrated(), 'g', -1, 64)+")")
+		}
+		return "spline: " + strings.Join(pairs, " ")
+	case mdb.CalibratorInfo_JAVA_EXPRESSION:
+		return "expression: " + calibrator.GetJavaExpressionCalibrator().GetFormula()
+	}
+	return strings.ToLower(calibrator.GetType().String())
+}
+
+// ApplyMetadataToField fills the description, display name, range, decimals
+// and data source of a field. parameter is the name the field was built with,
+// e.g. "/YSS/SIMULATOR/BatteryVoltage1" for the value field and
+// "min(/YSS/SIMULATOR/BatteryVoltage1)" for its minimum.
+func ApplyMetadataToField(field *data.Field, parameter string, metadata ParameterMetadata) {
+	if field.Config == nil {
+		field.Config = &data.FieldConfig{}
+	}
+	field.Config.Description = metadata.Description
+	if strings.Contains(field.Name, parameter) {
+		field.Config.DisplayNameFromDS = strings.Replace(field.Name, parameter, metadata.DisplayName, 1)
+	}
+	if metadata.Min != nil {
+		min := data.ConfFloat64(*metadata.Min)
+		field.Config.Min = &min
+	}
+	if metadata.Max != nil {
+		max := data.ConfFloat64(*metadata.Max)
+		field.Config.Max = &max
+	}
+	if metadata.Decimals != nil {
+		decimals := *metadata.Decimals
+		field.Config.Decimals = &decimals
+	}
+	if field.Config.Custom == nil {
+		field.Config.Custom = map[string]interface{}{}
+	}
+	field.Config.Custom["dataSource"] = metadata.DataSource
+}
@@ -0,0 +1,81 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/mdb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertParameterInfoToMetadata(t *testing.T) {
+	info := &mdb.ParameterInfo{
+		Name:             pointer("BatteryVoltage1"),
+		QualifiedName:    pointer("/YSS/SIMULATOR/BatteryVoltage1"),
+		ShortDescription: pointer("Battery 1 voltage"),
+		Alias: []*protobuf.NamedObjectId{
+			{Namespace: pointer("/YSS/SIMULATOR"), Name: pointer("BatteryVoltage1")},
+			{Namespace: pointer("MDB:OPS Name"), Name: pointer("BAT1_V")},
+		},
+		DataSource: mdb.DataSourceType_TELEMETERED.Enum(),
+		Type: &mdb.ParameterTypeInfo{
+			EngType:    pointer("integer"),
+			SizeInBits: pointer(int32(8)),
+			UnitSet:    []*mdb.UnitInfo{{Unit: pointer("V")}},
+			DataEncoding: &mdb.DataEncodingInfo{DefaultCalibrator: &mdb.CalibratorInfo{
+				Type:                 mdb.CalibratorInfo_POLYNOMIAL.Enum(),
+				PolynomialCalibrator: &mdb.PolynomialCalibratorInfo{Coefficients: []float64{0.5, 0.1}},
+			}},
+		},
+	}
+
+	metadata := ConvertParameterInfoToMetadata(info, "")
+	assert.Equal(t, ParameterMetadata{
+		QualifiedName: "/YSS/SIMULATOR/BatteryVoltage1",
+		DisplayName:   "BAT1_V",
+		Description:   "Battery 1 voltage",
+		Aliases:       map[string]string{"/YSS/SIMULATOR": "BatteryVoltage1", "MDB:OPS Name": "BAT1_V"},
+		Type:          "integer",
+		Unit:          "V",
+		DataSource:    "telemetered",
+		Min:           pointer(0.0),
+		Max:           pointer(255.0),
+		Decimals:      pointer(uint16(0)),
+		Calibration:   "polynomial: 0.5 + 0.1*x",
+	}, metadata)
+
+	field := data.NewField("min(/YSS/SIMULATOR/BatteryVoltage1)", nil, []int64{1})
+	ApplyMetadataToField(field, "/YSS/SIMULATOR/BatteryVoltage1", metadata)
+	assert.Equal(t, "min(BAT1_V)", field.Config.DisplayNameFromDS)
+	assert.Equal(t, "Battery 1 voltage", field.Config.Description)
+	assert.Equal(t, data.ConfFloat64(255), *field.Config.Max)
+	assert.Equal(t, "telemetered", field.Config.Custom["dataSource"])
+}
+
+func TestConvertParameterInfoToMetadataMember(t *testing.T) {
+	info := &mdb.ParameterInfo{
+		Name:          pointer("Attitude"),
+		QualifiedName: pointer("/YSS/Attitude"),
+		DataSource:    mdb.DataSourceType_DERIVED.Enum(),
+		Type: &mdb.ParameterTypeInfo{
+			EngType: pointer("aggregate"),
+			Member: []*mdb.MemberInfo{{
+				Name: pointer("q0"),
+				Type: &mdb.ParameterTypeInfo{
+					EngType:          pointer("float"),
+					ShortDescription: pointer("Scalar part"),
+					NumberFormat:     &mdb.NumberFormatTypeInfo{MaximumFractionDigits: pointer(int32(4))},
+				},
+			}},
+		},
+	}
+
+	metadata := ConvertParameterInfoToMetadata(info, ".q0")
+	assert.Equal(t, "Attitude.q0", metadata.DisplayName)
+	assert.Equal(t, "q0", metadata.Path)
+	assert.Equal(t, "Scalar part", metadata.Description)
+	assert.Equal(t, "derived", metadata.DataSource)
+	assert.Equal(t, pointer(uint16(4)), metadata.Decimals)
+	assert.Nil(t, metadata.Min)
+}
@@ -0,0 +1,240 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/activities"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/timeline"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// CommandActivityType is the type of the Yamcs activities issuing a command.
+const CommandActivityType = "COMMAND"
+
+// ScheduledByProperty is the timeline item property naming the Grafana user
+// who scheduled the command.
+const ScheduledByProperty = "scheduledBy"
+
+// CommandActivity builds the definition of an activity issuing a command on a
+// processor.
+func CommandActivity(command string, arguments map[string]any, processor, comment string) (*activities.ActivityDefinitionInfo, error) {
+	if arguments == nil {
+		arguments = map[string]any{}
+	}
+	args, err := structpb.NewStruct(map[string]any{
+		"command":   command,
+		"args":      arguments,
+		"processor": processor,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid command arguments: %w", err)
+	}
+	definition := &activities.ActivityDefinitionInfo{Type: proto.String(CommandActivityType), Args: args}
+	if comment != "" {
+		definition.Comment = proto.String(comment)
+	}
+	return definition, nil
+}
+
+// IsCommandActivity tells whether a timeline item issues a command.
+func IsCommandActivity(item *timeline.TimelineItem) bool {
+	return item.GetType() == timeline.TimelineItemType_ACTIVITY && item.GetActivityDefinition().GetType() == CommandActivityType
+}
+
+// ScheduledCommand is a command activity of the timeline with its latest run,
+// when the run was reported by the activities subscription.
+type ScheduledCommand struct {
+	Item *timeline.TimelineItem
+	Run  *activities.ActivityInfo
+}
+
+// Command returns the name and arguments of the scheduled command.
+func (c ScheduledCommand) Command() (string, map[string]any) {
+	args := c.Item.GetActivityDefinition().GetArgs().AsMap()
+	command, _ := args["command"].(string)
+	arguments, _ := args["args"].(map[string]any)
+	return command, arguments
+}
+
+// Status is the state of the command: "planned", "running", "completed",
+// "cancelled" or "failed". The latest run is more recent than the timeline.
+func (c ScheduledCommand) Status() string {
+	if c.Run != nil {
+		switch c.Run.GetStatus() {
+		case activities.ActivityStatus_RUNNING:
+			return "running"
+		case activities.ActivityStatus_SUCCESSFUL:
+			return "completed"
+		case activities.ActivityStatus_CANCELLED:
+			return "cancelled"
+		case activities.ActivityStatus_FAILED:
+			return "failed"
+		}
+	}
+	switch c.Item.GetStatus() {
+	case timeline.ExecutionStatus_IN_PROGRESS:
+		return "running"
+	case timeline.ExecutionStatus_COMPLETED:
+		return "completed"
+	case timeline.ExecutionStatus_ABORTED:
+		return "cancelled"
+	case timeline.ExecutionStatus_FAILED:
+		return "failed"
+	default:
+		return "planned"
+	}
+}
+
+// ScheduledCommands follows the command activities of a timeline. It starts
+// from the timeline items and is kept up to date by the activities topic.
+// Activities only tell about runs, so a run of an unknown item marks the
+// items as stale until they are listed again.
+type ScheduledCommands struct {
+	mu      sync.Mutex
+	items   map[string]*timeline.TimelineItem
+	runs    map[string]*activities.ActivityInfo
+	changed bool
+	stale   bool
+}
+
+// NewScheduledCommands creates an empty set of scheduled commands.
+func NewScheduledCommands() *ScheduledCommands {
+	return &ScheduledCommands{items: map[string]*timeline.TimelineItem{}, runs: map[string]*activities.ActivityInfo{}}
+}
+
+// Reset replaces the scheduled commands by the command activities among items.
+func (s *ScheduledCommands) Reset(items []*timeline.TimelineItem) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items = map[string]*timeline.TimelineItem{}
+	for _, item := range items {
+		if IsCommandActivity(item) {
+			s.items[item.GetId()] = item
+		}
+	}
+	s.changed, s.stale = true, false
+}
+
+// ApplyActivity records a run of a command activity.
+func (s *ScheduledCommands) ApplyActivity(activity *activities.ActivityInfo) {
+	if activity.GetType() != CommandActivityType {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.runs[activity.GetId()] = activity
+	s.changed = true
+	for _, item := range s.items {
+		for _, run := range item.GetRuns() {
+			if run == activity.GetId() {
+				return
+			}
+		}
+	}
+	s.stale = true
+}
+
+// Stale tells whether a run was reported for an item that is not known yet.
+func (s *ScheduledCommands) Stale() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stale
+}
+
+// Changed returns the scheduled commands when they changed since the last
+// call, ordered by start time.
+func (s *ScheduledCommands) Changed() ([]ScheduledCommand, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.changed {
+		return nil, false
+	}
+	s.changed = false
+	commands := make([]ScheduledCommand, 0, len(s.items))
+	for _, item := range s.items {
+		command := ScheduledCommand{Item: item}
+		if runs := item.GetRuns(); len(runs) > 0 {
+			command.Run = s.runs[runs[len(runs)-1]]
+		}
+		commands = append(commands, command)
+	}
+	SortScheduledCommands(commands)
+	return commands, true
+}
+
+// SortScheduledCommands orders commands by start time.
+func SortScheduledCommands(commands []ScheduledCommand) {
+	sort.SliceStable(commands, func(i, j int) bool {
+		a, b := commands[i].Item.GetStart().AsTime(), commands[j].Item.GetStart().AsTime()
+		if !a.Equal(b) {
+			return a.Before(b)
+		}
+		return commands[i].Item.GetId() < commands[j].Item.GetId()
+	})
+}
+
+// ConvertScheduledCommandsToFrame lays scheduled commands out as a table, one
+// row per command.
+func ConvertScheduledCommandsToFrame(commands []ScheduledCommand) *data.Frame {
+	var (
+		ids, names, arguments, comments, statuses []string
+		relativeTo, users, activityIDs, failures  []string
+		starts                                    []time.Time
+		stops                                     []*time.Time
+	)
+	for _, scheduled := range commands {
+		item := scheduled.Item
+		name, args := scheduled.Command()
+		encoded, _ := json.Marshal(args)
+
+		ids = append(ids, item.GetId())
+		starts = append(starts, item.GetStart().AsTime())
+		names = append(names, name)
+		arguments = append(arguments, string(encoded))
+		comments = append(comments, item.GetActivityDefinition().GetComment())
+		statuses = append(statuses, scheduled.Status())
+		relativeTo = append(relativeTo, item.GetRelativeTime().GetRelto())
+		users = append(users, item.GetProperties()[ScheduledByProperty])
+
+		failure := item.GetFailureReason()
+		var runID string
+		var stop *time.Time
+		if run := scheduled.Run; run != nil {
+			runID = run.GetId()
+			if run.GetFailureReason() != "" {
+				failure = run.GetFailureReason()
+			}
+			if run.GetStop() != nil {
+				t := run.GetStop().AsTime()
+				stop = &t
+			}
+		} else if runs := item.GetRuns(); len(runs) > 0 {
+			runID = runs[len(runs)-1]
+		}
+		activityIDs = append(activityIDs, runID)
+		stops = append(stops, stop)
+		failures = append(failures, failure)
+	}
+
+	frame := data.NewFrame("scheduled-commands",
+		data.NewField("start", nil, starts),
+		data.NewField("id", nil, ids),
+		data.NewField("command", nil, names),
+		data.NewField("arguments", nil, arguments),
+		data.NewField("comment", nil, comments),
+		data.NewField("status", nil, statuses),
+		data.NewField("relativeTo", nil, relativeTo),
+		data.NewField("scheduledBy", nil, users),
+		data.NewField("activity", nil, activityIDs),
+		data.NewField("stop", nil, stops),
+		data.NewField("failure", nil, failures),
+	)
+	frame.Meta = &data.FrameMeta{PreferredVisualization: data.VisTypeTable}
+	return frame
+}
@@ -0,0 +1,72 @@
+package tools
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/activities"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/timeline"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func scheduledCommandItem(t *testing.T, id string, start time.Time, command string, runs ...string) *timeline.TimelineItem {
+	definition, err := CommandActivity(command, map[string]any{"voltage": 12.0}, "realtime", "heater on")
+	require.NoError(t, err)
+	return &timeline.TimelineItem{
+		Id:                 pointer(id),
+		Type:               timeline.TimelineItemType_ACTIVITY.Enum(),
+		Start:              timestamppb.New(start),
+		Status:             timeline.ExecutionStatus_PLANNED.Enum(),
+		ActivityDefinition: definition,
+		Properties:         map[string]string{ScheduledByProperty: "alice"},
+		Runs:               runs,
+	}
+}
+
+func TestScheduledCommands(t *testing.T) {
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	scheduled := NewScheduledCommands()
+	scheduled.Reset([]*timeline.TimelineItem{
+		scheduledCommandItem(t, "b", at.Add(time.Hour), "/YSS/HEATER_OFF"),
+		scheduledCommandItem(t, "a", at, "/YSS/HEATER_ON", "run-1"),
+		{Id: pointer("event"), Type: timeline.TimelineItemType_EVENT.Enum(), Start: timestamppb.New(at)},
+	})
+
+	commands, changed := scheduled.Changed()
+	require.True(t, changed)
+	require.Len(t, commands, 2)
+	assert.Equal(t, "a", commands[0].Item.GetId())
+	name, arguments := commands[0].Command()
+	assert.Equal(t, "/YSS/HEATER_ON", name)
+	assert.Equal(t, map[string]any{"voltage": 12.0}, arguments)
+	assert.Equal(t, "planned", commands[0].Status())
+	_, changed = scheduled.Changed()
+	assert.False(t, changed)
+
+	scheduled.ApplyActivity(&activities.ActivityInfo{Id: pointer("run-1"), Type: pointer(CommandActivityType), Status: activities.ActivityStatus_RUNNING.Enum()})
+	assert.False(t, scheduled.Stale())
+	commands, changed = scheduled.Changed()
+	require.True(t, changed)
+	assert.Equal(t, "running", commands[0].Status())
+
+	scheduled.ApplyActivity(&activities.ActivityInfo{Id: pointer("script"), Type: pointer("SCRIPT")})
+	assert.False(t, scheduled.Stale())
+	scheduled.ApplyActivity(&activities.ActivityInfo{Id: pointer("run-2"), Type: pointer(CommandActivityType)})
+	assert.True(t, scheduled.Stale())
+
+	frame := ConvertScheduledCommandsToFrame(commands)
+	require.Equal(t, 2, frame.Rows())
+	field, _ := frame.FieldByName("command")
+	assert.Equal(t, "/YSS/HEATER_ON", field.At(0))
+	field, _ = frame.FieldByName("arguments")
+	assert.Equal(t, `{"voltage":12}`, field.At(0))
+	field, _ = frame.FieldByName("status")
+	assert.Equal(t, "running", field.At(0))
+	assert.Equal(t, "planned", field.At(1))
+	field, _ = frame.FieldByName("activity")
+	assert.Equal(t, "run-1", field.At(0))
+	field, _ = frame.FieldByName("scheduledBy")
+	assert.Equal(t, "alice", field.At(1))
+}
@@ -0,0 +1,150 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/pvalue"
+)
+
+// rangeState returns the dominant value of a range, the one counted most often
+// when the range merges several values, and a description of all of them.
+func rangeState(r *pvalue.Ranges_Range, aggregatePath string) (string, string) {
+	values := r.GetEngValues()
+	if len(values) == 0 {
+		return "", ""
+	}
+	counts := r.GetCounts()
+	dominant := 0
+	for i := range values {
+		if i < len(counts) && counts[i] > counts[dominant] {
+			dominant = i
+		}
+	}
+	state := fmt.Sprint(extractValue(values[dominant], aggregatePath))
+	if len(values) == 1 {
+		return state, ""
+	}
+
+	described := make([]string, 0, len(values))
+	for i, value := range values {
+		label := fmt.Sprint(extractValue(value, aggregatePath))
+		if i < len(counts) {
+			label += fmt.Sprintf(" (%d)", counts[i])
+		}
+		described = append(described, label)
+	}
+	return state, strings.Join(described, ", ")
+}
+
+// ConvertRangesToStateTimelineFrame converts value ranges into a state timeline:
+// one row per range with its start, end, duration, dominant value, sample count
+// and, when the range merges several values, every value with its count.
+func ConvertRangesToStateTimelineFrame(ranges *pvalue.Ranges, parameter string, aggregatePath string) *data.Frame {
+	starts := []time.Time{}
+	ends := []time.Time{}
+	durations := []int64{}
+	states := []string{}
+	counts := []int64{}
+	others := []string{}
+
+	for _, r := range ranges.GetRange() {
+		if len(r.GetEngValues()) == 0 {
+			continue
+		}
+		state, values := rangeState(r, aggregatePath)
+		start, end := r.GetStart().AsTime(), r.GetStop().AsTime()
+		starts = append(starts, start)
+		ends = append(ends, end)
+		durations = append(durations, end.Sub(start).Milliseconds())
+		states = append(states, state)
+		counts = append(counts, int64(r.GetCount()))
+		others = append(others, values)
+	}
+
+	durationField := data.NewField("duration", nil, durations)
+	durationField.Config = &data.FieldConfig{Unit: "ms"}
+	return data.NewFrame("response",
+		data.NewField("start", nil, starts),
+		data.NewField("end", nil, ends),
+		durationField,
+		data.NewField(parameter, nil, states),
+		data.NewField("count", nil, counts),
+		data.NewField("values", nil, others),
+	)
+}
+
+// ConvertRangesToStateSummaryFrame sums up value ranges per state: the time
+// spent in each state within [start, end), its share of the time covered by
+// ranges and the number of transitions into it. The total number of
+// transitions is given in the "transitions" custom frame metadata.
+func ConvertRangesToStateSummaryFrame(ranges *pvalue.Ranges, parameter string, aggregatePath string, start, end time.Time) *data.Frame {
+	order := []string{}
+	durations := map[string]time.Duration{}
+	entries := map[string]int64{}
+	covered := time.Duration(0)
+	transitions := int64(0)
+	previous := ""
+	started := false
+
+	for _, r := range ranges.GetRange() {
+		if len(r.GetEngValues()) == 0 {
+			continue
+		}
+		state, _ := rangeState(r, aggregatePath)
+		if _, seen := durations[state]; !seen {
+			order = append(order, state)
+			durations[state] = 0
+		}
+		if started && state != previous {
+			entries[state]++
+			transitions++
+		}
+		previous, started = state, true
+
+		from, to := r.GetStart().AsTime(), r.GetStop().AsTime()
+		if from.Before(start) {
+			from = start
+		}
+		if to.After(end) {
+			to = end
+		}
+		if to.After(from) {
+			durations[state] += to.Sub(from)
+			covered += to.Sub(from)
+		}
+	}
+
+	states := make([]string, 0, len(order))
+	totals := make([]int64, 0, len(order))
+	shares := make([]float64, 0, len(order))
+	counts := make([]int64, 0, len(order))
+	for _, state := range order {
+		states = append(states, state)
+		totals = append(totals, durations[state].Milliseconds())
+		share := 0.0
+		if covered > 0 {
+			share = float64(durations[state]) / float64(covered)
+		}
+		shares = append(shares, share)
+		counts = append(counts, entries[state])
+	}
+
+	durationField := data.NewField("duration", nil, totals)
+	durationField.Config = &data.FieldConfig{Unit: "ms"}
+	shareField := data.NewField("share", nil, shares)
+	shareField.Config = &data.FieldConfig{Unit: "percentunit"}
+	frame := data.NewFrame("summary",
+		data.NewField(parameter, nil, states),
+		durationField,
+		shareField,
+		data.NewField("transitions", nil, counts),
+	)
+	frame.Meta = &data.FrameMeta{
+		PreferredVisualization: data.VisTypeTable,
+		Custom:                 map[string]interface{}{"transitions": transitions},
+	}
+	return frame
+}
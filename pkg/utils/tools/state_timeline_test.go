@@ -0,0 +1,52 @@
+package tools
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/pvalue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func stateRange(start, stop time.Time, states map[string]int32) *pvalue.Ranges_Range {
+	r := &pvalue.Ranges_Range{Start: timestamppb.New(start), Stop: timestamppb.New(stop)}
+	total := int32(0)
+	for _, state := range []string{"SAFE", "NOMINAL", "SCIENCE"} {
+		if count, ok := states[state]; ok {
+			r.EngValues = append(r.EngValues, &protobuf.Value{Type: protobuf.Value_ENUMERATED.Enum(), StringValue: pointer(state)})
+			r.Counts = append(r.Counts, count)
+			total += count
+		}
+	}
+	r.Count = pointer(total)
+	return r
+}
+
+func TestConvertRangesToStateFrames(t *testing.T) {
+	start := time.Unix(1700000000, 0).UTC()
+	ranges := &pvalue.Ranges{Range: []*pvalue.Ranges_Range{
+		stateRange(start.Add(-time.Minute), start.Add(time.Minute), map[string]int32{"SAFE": 4}),
+		stateRange(start.Add(time.Minute), start.Add(3*time.Minute), map[string]int32{"SAFE": 1, "NOMINAL": 5}),
+		stateRange(start.Add(3*time.Minute), start.Add(4*time.Minute), map[string]int32{"SAFE": 2}),
+	}}
+
+	timeline := ConvertRangesToStateTimelineFrame(ranges, "/YSS/mode", "")
+	require.Equal(t, 3, timeline.Rows())
+	assert.Equal(t, int64(2*time.Minute/time.Millisecond), timeline.Fields[2].At(1))
+	assert.Equal(t, "NOMINAL", timeline.Fields[3].At(1))
+	assert.Equal(t, int64(6), timeline.Fields[4].At(1))
+	assert.Equal(t, "SAFE (1), NOMINAL (5)", timeline.Fields[5].At(1))
+	assert.Equal(t, "", timeline.Fields[5].At(0))
+
+	summary := ConvertRangesToStateSummaryFrame(ranges, "/YSS/mode", "", start, start.Add(4*time.Minute))
+	require.Equal(t, 2, summary.Rows())
+	assert.Equal(t, "SAFE", summary.Fields[0].At(0))
+	assert.Equal(t, int64(2*time.Minute/time.Millisecond), summary.Fields[1].At(0))
+	assert.Equal(t, 0.5, summary.Fields[2].At(1))
+	assert.Equal(t, int64(1), summary.Fields[3].At(0))
+	assert.Equal(t, int64(1), summary.Fields[3].At(1))
+	assert.Equal(t, int64(2), summary.Meta.Custom.(map[string]interface{})["transitions"])
+}
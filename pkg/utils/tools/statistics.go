@@ -0,0 +1,238 @@
+package tools
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/pvalue"
+)
+
+// Observation is a numeric value of a parameter, standing for Weight values.
+// A raw value is an observation of weight 1; a sample bucket is an observation
+// of its average, weighted by its number of values.
+type Observation struct {
+	Time   time.Time
+	Value  float64
+	Min    float64
+	Max    float64
+	Weight int64
+}
+
+// SamplesToObservations converts sample buckets into observations, skipping
+// empty buckets.
+func SamplesToObservations(samples []*pvalue.TimeSeries_Sample) []Observation {
+	observations := make([]Observation, 0, len(samples))
+	for _, sample := range samples {
+		if sample.GetN() <= 0 {
+			continue
+		}
+		observations = append(observations, Observation{
+			Time:   sample.GetTime().AsTime(),
+			Value:  sample.GetAvg(),
+			Min:    sample.GetMin(),
+			Max:    sample.GetMax(),
+			Weight: int64(sample.GetN()),
+		})
+	}
+	return observations
+}
+
+// ValuesToObservations converts the numeric members at aggregatePath of raw
+// values into observations, skipping values that are not numeric.
+func ValuesToObservations(values []*pvalue.ParameterValue, aggregatePath string) []Observation {
+	observations := make([]Observation, 0, len(values))
+	for _, value := range values {
+		number, ok := NumericValue(memberValue(value.GetEngValue(), aggregatePath))
+		if !ok {
+			continue
+		}
+		observations = append(observations, Observation{
+			Time:   value.GetGenerationTime().AsTime(),
+			Value:  number,
+			Min:    number,
+			Max:    number,
+			Weight: 1,
+		})
+	}
+	return observations
+}
+
+// Statistics summarises a set of observations. Computed from sample buckets,
+// the standard deviation and percentiles only see the spread between bucket
+// averages and are approximate; minimum, maximum, mean and count are exact.
+type Statistics struct {
+	Count       int64
+	Min         float64
+	Max         float64
+	Mean        float64
+	StdDev      float64
+	Percentiles []float64
+}
+
+// ComputeStatistics computes the statistics of observations, including the
+// given percentiles (between 0 and 100).
+func ComputeStatistics(observations []Observation, percentiles []float64) Statistics {
+	stats := Statistics{Min: math.Inf(1), Max: math.Inf(-1)}
+	sum := 0.0
+	for _, o := range observations {
+		stats.Count += o.Weight
+		sum += o.Value * float64(o.Weight)
+		stats.Min = math.Min(stats.Min, o.Min)
+		stats.Max = math.Max(stats.Max, o.Max)
+	}
+	if stats.Count == 0 {
+		nan := math.NaN()
+		stats.Min, stats.Max, stats.Mean, stats.StdDev = nan, nan, nan, nan
+		for range percentiles {
+			stats.Percentiles = append(stats.Percentiles, nan)
+		}
+		return stats
+	}
+
+	stats.Mean = sum / float64(stats.Count)
+	squares := 0.0
+	for _, o := range observations {
+		squares += float64(o.Weight) * (o.Value - stats.Mean) * (o.Value - stats.Mean)
+	}
+	stats.StdDev = math.Sqrt(squares / float64(stats.Count))
+
+	sorted := make([]Observation, len(observations))
+	copy(sorted, observations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Value < sorted[j].Value })
+	for _, p := range percentiles {
+		stats.Percentiles = append(stats.Percentiles, weightedPercentile(sorted, stats.Count, p))
+	}
+	return stats
+}
+
+// weightedPercentile returns the smallest value below which p percent of the
+// total weight lies. sorted must be sorted by value.
+func weightedPercentile(sorted []Observation, total int64, p float64) float64 {
+	rank := math.Ceil(p / 100 * float64(total))
+	cumulated := int64(0)
+	for _, o := range sorted {
+		cumulated += o.Weight
+		if float64(cumulated) >= rank {
+			return o.Value
+		}
+	}
+	return sorted[len(sorted)-1].Value
+}
+
+// StatisticsGroup is a set of observations computed together: the observations
+// of one interval, or those made while a parameter was in one state.
+type StatisticsGroup struct {
+	Start        time.Time
+	State        string
+	Observations []Observation
+}
+
+// GroupByInterval splits observations into consecutive intervals from start.
+// Empty intervals are kept so that the groups form a regular time series.
+func GroupByInterval(observations []Observation, start, end time.Time, interval time.Duration) []StatisticsGroup {
+	groups := []StatisticsGroup{}
+	for t := start; t.Before(end); t = t.Add(interval) {
+		groups = append(groups, StatisticsGroup{Start: t})
+	}
+	for _, o := range observations {
+		index := int(o.Time.Sub(start) / interval)
+		if o.Time.Before(start) || index >= len(groups) {
+			continue
+		}
+		groups[index].Observations = append(groups[index].Observations, o)
+	}
+	return groups
+}
+
+// GroupByState splits observations by the state the parameter described by
+// ranges was in when they were made. Observations outside every range are
+// dropped.
+func GroupByState(observations []Observation, ranges *pvalue.Ranges, aggregatePath string) []StatisticsGroup {
+	groups := []StatisticsGroup{}
+	byState := map[string]int{}
+	list := ranges.GetRange()
+	for _, o := range observations {
+		i := sort.Search(len(list), func(i int) bool { return list[i].GetStop().AsTime().After(o.Time) })
+		if i == len(list) || list[i].GetStart().AsTime().After(o.Time) || len(list[i].GetEngValues()) == 0 {
+			continue
+		}
+		state, _ := rangeState(list[i], aggregatePath)
+		index, exists := byState[state]
+		if !exists {
+			index = len(groups)
+			byState[state] = index
+			groups = append(groups, StatisticsGroup{Start: o.Time, State: state})
+		}
+		groups[index].Observations = append(groups[index].Observations, o)
+	}
+	return groups
+}
+
+// PercentileFieldName names the field holding a percentile, e.g. "p95".
+func PercentileFieldName(p float64) string {
+	return "p" + strconv.FormatFloat(p, 'f', -1, 64)
+}
+
+// ConvertStatisticsToFrame converts statistics groups into a frame with one row
+// per group. groupField names the first field: "time" holds the start of each
+// interval, any other name the state of each group, and an empty name leaves
+// the grouping out, as for a single group covering the whole window.
+func ConvertStatisticsToFrame(groups []StatisticsGroup, parameter string, groupField string, percentiles []float64) *data.Frame {
+	starts := []time.Time{}
+	states := []string{}
+	counts := []int64{}
+	mins := []*float64{}
+	maxs := []*float64{}
+	means := []*float64{}
+	stddevs := []*float64{}
+	quantiles := make([][]*float64, len(percentiles))
+
+	for _, group := range groups {
+		stats := ComputeStatistics(group.Observations, percentiles)
+		starts = append(starts, group.Start)
+		states = append(states, group.State)
+		counts = append(counts, stats.Count)
+		mins = append(mins, finite(stats.Min))
+		maxs = append(maxs, finite(stats.Max))
+		means = append(means, finite(stats.Mean))
+		stddevs = append(stddevs, finite(stats.StdDev))
+		for i, value := range stats.Percentiles {
+			quantiles[i] = append(quantiles[i], finite(value))
+		}
+	}
+
+	frame := data.NewFrame("statistics")
+	switch groupField {
+	case "":
+	case "time":
+		frame.Fields = append(frame.Fields, data.NewField("time", nil, starts))
+	default:
+		frame.Fields = append(frame.Fields, data.NewField(groupField, nil, states))
+	}
+	frame.Fields = append(frame.Fields,
+		data.NewField("count", nil, counts),
+		data.NewField("min("+parameter+")", nil, mins),
+		data.NewField("max("+parameter+")", nil, maxs),
+		data.NewField("mean("+parameter+")", nil, means),
+		data.NewField("stddev("+parameter+")", nil, stddevs),
+	)
+	for i, p := range percentiles {
+		values := quantiles[i]
+		if values == nil {
+			values = []*float64{}
+		}
+		frame.Fields = append(frame.Fields, data.NewField(PercentileFieldName(p)+"("+parameter+")", nil, values))
+	}
+	return frame
+}
+
+// finite returns a pointer to value, or nil if it is not a number.
+func finite(value float64) *float64 {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return nil
+	}
+	return &value
+}
@@ -0,0 +1,71 @@
+package tools
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/pvalue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestComputeStatistics(t *testing.T) {
+	start := time.Unix(1700000000, 0).UTC()
+	samples := []*pvalue.TimeSeries_Sample{
+		{Time: timestamppb.New(start), Avg: pointer(1.0), Min: pointer(0.0), Max: pointer(2.0), N: pointer(int32(3))},
+		{Time: timestamppb.New(start.Add(time.Minute)), N: pointer(int32(0))},
+		{Time: timestamppb.New(start.Add(2 * time.Minute)), Avg: pointer(5.0), Min: pointer(4.0), Max: pointer(9.0), N: pointer(int32(1))},
+	}
+
+	observations := SamplesToObservations(samples)
+	require.Len(t, observations, 2)
+
+	stats := ComputeStatistics(observations, []float64{50, 100})
+	assert.Equal(t, int64(4), stats.Count)
+	assert.Equal(t, 0.0, stats.Min)
+	assert.Equal(t, 9.0, stats.Max)
+	assert.Equal(t, 2.0, stats.Mean)
+	assert.InDelta(t, math.Sqrt(3), stats.StdDev, 1e-9)
+	assert.Equal(t, []float64{1, 5}, stats.Percentiles)
+
+	empty := ComputeStatistics(nil, []float64{95})
+	assert.Equal(t, int64(0), empty.Count)
+	assert.True(t, math.IsNaN(empty.Percentiles[0]))
+}
+
+func TestStatisticsGrouping(t *testing.T) {
+	start := time.Unix(1700000000, 0).UTC()
+	observations := []Observation{
+		{Time: start, Value: 1, Min: 1, Max: 1, Weight: 1},
+		{Time: start.Add(90 * time.Second), Value: 3, Min: 3, Max: 3, Weight: 1},
+		{Time: start.Add(150 * time.Second), Value: 5, Min: 5, Max: 5, Weight: 1},
+	}
+
+	groups := GroupByInterval(observations, start, start.Add(3*time.Minute), time.Minute)
+	require.Len(t, groups, 3)
+	assert.Len(t, groups[0].Observations, 1)
+	assert.Len(t, groups[1].Observations, 1)
+	assert.Len(t, groups[2].Observations, 1)
+
+	frame := ConvertStatisticsToFrame(groups, "/YSS/current", "time", []float64{95})
+	require.Len(t, frame.Fields, 7)
+	assert.Equal(t, "p95(/YSS/current)", frame.Fields[6].Name)
+	assert.Equal(t, 3.0, *frame.Fields[4].At(1).(*float64))
+
+	ranges := &pvalue.Ranges{Range: []*pvalue.Ranges_Range{
+		stateRange(start, start.Add(time.Minute), map[string]int32{"SAFE": 1}),
+		stateRange(start.Add(time.Minute), start.Add(2*time.Minute), map[string]int32{"SCIENCE": 1}),
+		stateRange(start.Add(2*time.Minute), start.Add(3*time.Minute), map[string]int32{"SAFE": 1}),
+	}}
+	groups = GroupByState(observations, ranges, "")
+	require.Len(t, groups, 2)
+	assert.Equal(t, "SAFE", groups[0].State)
+	assert.Len(t, groups[0].Observations, 2)
+
+	frame = ConvertStatisticsToFrame(groups, "/YSS/current", "/YSS/mode", nil)
+	assert.Equal(t, "/YSS/mode", frame.Fields[0].Name)
+	assert.Equal(t, "SCIENCE", frame.Fields[0].At(1))
+	assert.Equal(t, 3.0, *frame.Fields[4].At(0).(*float64))
+}
@@ -8,11 +8,6 @@ import (
 	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/mdb"
 )
 
-type Range struct {
-	Value float64
-	Level mdb.AlarmLevelType
-}
-
 // AlarmLevelColors maps alarm levels to Grafana colors.
 var AlarmLevelColors = map[mdb.AlarmLevelType]string{
 	mdb.AlarmLevelType_NORMAL:   "green",
@@ -23,51 +18,149 @@ var AlarmLevelColors = map[mdb.AlarmLevelType]string{
 	mdb.AlarmLevelType_SEVERE:   "darkred",
 }
 
-// ConvertAlarmInfoToGrafanaThresholds converts an AlarmInfo to Grafana thresholds.
+// AlarmLimit is one band of a static alarm definition: values outside of it
+// raise an alarm of the given level.
+type AlarmLimit struct {
+	Level        string   `json:"level"`
+	Color        string   `json:"color"`
+	MinInclusive *float64 `json:"minInclusive,omitempty"`
+	MinExclusive *float64 `json:"minExclusive,omitempty"`
+	MaxInclusive *float64 `json:"maxInclusive,omitempty"`
+	MaxExclusive *float64 `json:"maxExclusive,omitempty"`
+}
+
+// AlarmLimitSet is the set of limits defined for a parameter in one context, or
+// by default when Context is empty.
+type AlarmLimitSet struct {
+	Context string       `json:"context,omitempty"`
+	Active  bool         `json:"active"`
+	Limits  []AlarmLimit `json:"limits"`
+}
+
+// StaticAlarmRanges returns the static ranges of alarmInfo, whichever of the
+// current or deprecated field the server filled.
+func StaticAlarmRanges(alarmInfo *mdb.AlarmInfo) []*mdb.AlarmRange {
+	if ranges := alarmInfo.GetStaticAlarmRanges(); len(ranges) > 0 {
+		return ranges
+	}
+	return alarmInfo.GetStaticAlarmRange()
+}
+
+// ConvertAlarmInfoToThresholds converts an AlarmInfo to Grafana thresholds.
+//
+// Each static range is the in-limits band of its level, so a value is at the
+// most severe level whose band it falls out of. Grafana thresholds being steps
+// applying from their value upwards, a step is emitted at every bound where
+// that level changes, starting from the level of very low values.
 func ConvertAlarmInfoToThresholds(alarmInfo *mdb.AlarmInfo) []*data.Threshold {
 
 	if alarmInfo == nil {
 		return nil
 	}
 
-	thresholds := []*data.Threshold{}
-	ranges := []Range{}
-
-	// Collect all min/max values from alarm ranges
-	for _, alarmRange := range alarmInfo.StaticAlarmRanges {
-		if alarmRange.MinExclusive != nil {
-			ranges = append(ranges, Range{Value: *alarmRange.MinExclusive, Level: alarmRange.GetLevel()})
-		} else if alarmRange.MinInclusive != nil {
-			ranges = append(ranges, Range{Value: *alarmRange.MinInclusive, Level: alarmRange.GetLevel()})
+	ranges := StaticAlarmRanges(alarmInfo)
+	if len(ranges) == 0 {
+		if alarmInfo.GetDefaultLevel() == mdb.AlarmLevelType_NORMAL {
+			return []*data.Threshold{}
 		}
+		threshold := data.NewThreshold(math.Inf(-1), alarmLevelColor(alarmInfo.GetDefaultLevel()), "")
+		return []*data.Threshold{&threshold}
+	}
 
+	// Steps apply to values greater than or equal to them: an inclusive maximum
+	// or an exclusive minimum only changes the level just above the bound.
+	steps := []float64{}
+	for _, alarmRange := range ranges {
+		if alarmRange.MinInclusive != nil {
+			steps = append(steps, alarmRange.GetMinInclusive())
+		} else if alarmRange.MinExclusive != nil {
+			steps = append(steps, math.Nextafter(alarmRange.GetMinExclusive(), math.Inf(1)))
+		}
 		if alarmRange.MaxExclusive != nil {
-			ranges = append(ranges, Range{Value: *alarmRange.MaxExclusive, Level: alarmRange.GetLevel()})
+			steps = append(steps, alarmRange.GetMaxExclusive())
 		} else if alarmRange.MaxInclusive != nil {
-			ranges = append(ranges, Range{Value: *alarmRange.MaxInclusive, Level: alarmRange.GetLevel()})
+			steps = append(steps, math.Nextafter(alarmRange.GetMaxInclusive(), math.Inf(1)))
 		}
 	}
+	sort.Float64s(steps)
 
-	// Sort thresholds in ascending order
-	sort.Slice(ranges, func(i, j int) bool {
-		return ranges[i].Value < ranges[j].Value
-	})
-
-	// Handle inverse logic: If default level is NOT NORMAL, set the first threshold to -Infinity
-	if alarmInfo.GetDefaultLevel() != mdb.AlarmLevelType_NORMAL {
-		threshold := data.NewThreshold(math.Inf(-1), AlarmLevelColors[alarmInfo.GetDefaultLevel()], "")
+	level := alarmLevelAt(ranges, math.Inf(-1))
+	base := data.NewThreshold(math.Inf(-1), alarmLevelColor(level), "")
+	thresholds := []*data.Threshold{&base}
+	for _, step := range steps {
+		stepLevel := alarmLevelAt(ranges, step)
+		if stepLevel == level {
+			continue
+		}
+		level = stepLevel
+		threshold := data.NewThreshold(step, alarmLevelColor(level), "")
 		thresholds = append(thresholds, &threshold)
 	}
 
-	// Convert to Grafana thresholds
-	for _, r := range ranges {
-		color, exists := AlarmLevelColors[r.Level]
-		if !exists {
-			color = "gray" // Default color for unknown levels
+	return thresholds
+}
+
+// alarmLevelAt returns the most severe level among the ranges value falls out of.
+func alarmLevelAt(ranges []*mdb.AlarmRange, value float64) mdb.AlarmLevelType {
+	level := mdb.AlarmLevelType_NORMAL
+	for _, alarmRange := range ranges {
+		if !inAlarmRange(alarmRange, value) && alarmRange.GetLevel() > level {
+			level = alarmRange.GetLevel()
 		}
-		threshold := data.NewThreshold(r.Value, color, "")
-		thresholds = append(thresholds, &threshold)
 	}
+	return level
+}
 
-	return thresholds
+func inAlarmRange(alarmRange *mdb.AlarmRange, value float64) bool {
+	switch {
+	case alarmRange.MinInclusive != nil && value < alarmRange.GetMinInclusive():
+		return false
+	case alarmRange.MinExclusive != nil && value <= alarmRange.GetMinExclusive():
+		return false
+	case alarmRange.MaxInclusive != nil && value > alarmRange.GetMaxInclusive():
+		return false
+	case alarmRange.MaxExclusive != nil && value >= alarmRange.GetMaxExclusive():
+		return false
+	}
+	return true
+}
+
+// ConvertAlarmInfoToLimits lists the static ranges of an AlarmInfo as they are
+// defined, so that panels can draw lower and upper bands themselves.
+func ConvertAlarmInfoToLimits(alarmInfo *mdb.AlarmInfo) []AlarmLimit {
+	limits := []AlarmLimit{}
+	for _, alarmRange := range StaticAlarmRanges(alarmInfo) {
+		limits = append(limits, AlarmLimit{
+			Level:        alarmRange.GetLevel().String(),
+			Color:        alarmLevelColor(alarmRange.GetLevel()),
+			MinInclusive: alarmRange.MinInclusive,
+			MinExclusive: alarmRange.MinExclusive,
+			MaxInclusive: alarmRange.MaxInclusive,
+			MaxExclusive: alarmRange.MaxExclusive,
+		})
+	}
+	return limits
+}
+
+// ConvertParameterTypeToLimitSets lists the default and context-dependent limit
+// sets of paramType, flagging the one at activeContext (-1 for the default).
+// It returns nil if the type defines no static ranges at all.
+func ConvertParameterTypeToLimitSets(paramType *mdb.ParameterTypeInfo, activeContext int) []AlarmLimitSet {
+	sets := []AlarmLimitSet{}
+	if limits := ConvertAlarmInfoToLimits(paramType.GetDefaultAlarm()); len(limits) > 0 {
+		sets = append(sets, AlarmLimitSet{Active: activeContext < 0, Limits: limits})
+	}
+	for index, contextAlarm := range paramType.GetContextAlarm() {
+		if limits := ConvertAlarmInfoToLimits(contextAlarm.GetAlarm()); len(limits) > 0 {
+			sets = append(sets, AlarmLimitSet{
+				Context: DescribeContext(contextAlarm),
+				Active:  index == activeContext,
+				Limits:  limits,
+			})
+		}
+	}
+	if len(sets) == 0 {
+		return nil
+	}
+	return sets
 }
@@ -0,0 +1,115 @@
+package tools
+
+import (
+	"math"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/mdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func steps(thresholds []*data.Threshold) []data.Threshold {
+	result := []data.Threshold{}
+	for _, threshold := range thresholds {
+		result = append(result, *threshold)
+	}
+	return result
+}
+
+func TestConvertAlarmInfoToThresholds(t *testing.T) {
+	tests := []struct {
+		name      string
+		alarmInfo *mdb.AlarmInfo
+		want      []data.Threshold
+	}{
+		{
+			name:      "No alarm",
+			alarmInfo: nil,
+			want:      []data.Threshold{},
+		},
+		{
+			name: "Nested bands",
+			alarmInfo: &mdb.AlarmInfo{StaticAlarmRanges: []*mdb.AlarmRange{
+				{Level: mdb.AlarmLevelType_WARNING.Enum(), MinInclusive: pointer(10.0), MaxExclusive: pointer(90.0)},
+				{Level: mdb.AlarmLevelType_CRITICAL.Enum(), MinInclusive: pointer(0.0), MaxExclusive: pointer(100.0)},
+			}},
+			want: []data.Threshold{
+				data.NewThreshold(math.Inf(-1), "red", ""),
+				data.NewThreshold(0, "yellow", ""),
+				data.NewThreshold(10, "green", ""),
+				data.NewThreshold(90, "yellow", ""),
+				data.NewThreshold(100, "red", ""),
+			},
+		},
+		{
+			name: "Upper limit only",
+			alarmInfo: &mdb.AlarmInfo{StaticAlarmRanges: []*mdb.AlarmRange{
+				{Level: mdb.AlarmLevelType_WATCH.Enum(), MaxInclusive: pointer(5.0)},
+			}},
+			want: []data.Threshold{
+				data.NewThreshold(math.Inf(-1), "green", ""),
+				data.NewThreshold(math.Nextafter(5, math.Inf(1)), "cyan", ""),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, steps(ConvertAlarmInfoToThresholds(tt.alarmInfo)))
+		})
+	}
+}
+
+func TestActiveAlarm(t *testing.T) {
+	mode := &mdb.ParameterInfo{QualifiedName: pointer("/YSS/mode")}
+	voltage := &mdb.ParameterInfo{QualifiedName: pointer("/YSS/voltage")}
+	safeAlarm := &mdb.AlarmInfo{StaticAlarmRanges: []*mdb.AlarmRange{
+		{Level: mdb.AlarmLevelType_WARNING.Enum(), MaxInclusive: pointer(50.0)},
+	}}
+	lowVoltageAlarm := &mdb.AlarmInfo{}
+	paramType := &mdb.ParameterTypeInfo{
+		DefaultAlarm: &mdb.AlarmInfo{},
+		ContextAlarm: []*mdb.ContextAlarmInfo{
+			{
+				Comparison: []*mdb.ComparisonInfo{{Parameter: mode, Operator: mdb.ComparisonInfo_EQUAL_TO.Enum(), Value: pointer("SAFE")}},
+				Alarm:      safeAlarm,
+			},
+			{
+				Comparison: []*mdb.ComparisonInfo{{Parameter: voltage, Operator: mdb.ComparisonInfo_SMALLER_THAN.Enum(), Value: pointer("24")}},
+				Alarm:      lowVoltageAlarm,
+			},
+		},
+	}
+	lookup := func(values map[string]*protobuf.Value) ValueLookup {
+		return func(parameter string) *protobuf.Value { return values[parameter] }
+	}
+	enumerated := func(label string) *protobuf.Value {
+		return &protobuf.Value{Type: protobuf.Value_ENUMERATED.Enum(), StringValue: pointer(label)}
+	}
+	double := func(value float64) *protobuf.Value {
+		return &protobuf.Value{Type: protobuf.Value_DOUBLE.Enum(), DoubleValue: pointer(value)}
+	}
+
+	assert.Equal(t, []string{"/YSS/mode", "/YSS/voltage"}, ContextParameters(paramType))
+
+	index, alarm := ActiveAlarm(paramType, lookup(nil))
+	assert.Equal(t, -1, index)
+	assert.Same(t, paramType.DefaultAlarm, alarm)
+
+	index, alarm = ActiveAlarm(paramType, lookup(map[string]*protobuf.Value{"/YSS/mode": enumerated("SAFE"), "/YSS/voltage": double(12)}))
+	assert.Equal(t, 0, index)
+	assert.Same(t, safeAlarm, alarm)
+
+	index, alarm = ActiveAlarm(paramType, lookup(map[string]*protobuf.Value{"/YSS/mode": enumerated("NOMINAL"), "/YSS/voltage": double(12)}))
+	assert.Equal(t, 1, index)
+	assert.Same(t, lowVoltageAlarm, alarm)
+
+	sets := ConvertParameterTypeToLimitSets(paramType, 0)
+	require.Len(t, sets, 1)
+	assert.Equal(t, "/YSS/mode == SAFE", sets[0].Context)
+	assert.True(t, sets[0].Active)
+	assert.Equal(t, []AlarmLimit{{Level: "WARNING", Color: "yellow", MaxInclusive: pointer(50.0)}}, sets[0].Limits)
+}
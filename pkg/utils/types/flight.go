@@ -0,0 +1,89 @@
+package types
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// flightCall is a call in progress in a FlightGroup.
+type flightCall[V any] struct {
+	done    chan struct{}
+	value   V
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// FlightGroup coalesces concurrent calls sharing the same key into a single
+// execution whose result is handed to every caller.
+//
+// Unlike a plain singleflight, the shared call does not run with the context of
+// the caller that started it: a caller that gives up only stops waiting, and the
+// call itself is cancelled once no caller is waiting for it anymore.
+type FlightGroup[V any] struct {
+	calls map[string]*flightCall[V]
+	mutex sync.Mutex
+}
+
+// NewFlightGroup creates an empty FlightGroup.
+func NewFlightGroup[V any]() *FlightGroup[V] {
+	return &FlightGroup[V]{
+		calls: make(map[string]*flightCall[V]),
+	}
+}
+
+// Do runs fn once for all concurrent callers using key and returns its result.
+// fn receives a context that keeps the values of the first caller's ctx (such as
+// its trace span) but is only cancelled when every caller has gone away. shared
+// reports whether the result was obtained by another caller's call.
+func (group *FlightGroup[V]) Do(ctx context.Context, key string, fn func(ctx context.Context) (V, error)) (value V, err error, shared bool) {
+	group.mutex.Lock()
+	call, inFlight := group.calls[key]
+	if !inFlight {
+		callCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		call = &flightCall[V]{done: make(chan struct{}), cancel: cancel}
+		group.calls[key] = call
+		go group.run(callCtx, key, call, fn)
+	}
+	call.waiters++
+	group.mutex.Unlock()
+
+	select {
+	case <-call.done:
+		return call.value, call.err, inFlight
+	case <-ctx.Done():
+		group.mutex.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			call.cancel()
+			// Let the next caller start afresh rather than join a cancelled call.
+			if group.calls[key] == call {
+				delete(group.calls, key)
+			}
+		}
+		group.mutex.Unlock()
+		var zeroValue V
+		return zeroValue, ctx.Err(), inFlight
+	}
+}
+
+func (group *FlightGroup[V]) run(ctx context.Context, key string, call *flightCall[V], fn func(ctx context.Context) (V, error)) {
+	defer call.cancel()
+	func() {
+		// A panic must not leave the other callers waiting forever.
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				call.err = fmt.Errorf("shared call %q panicked: %v", key, recovered)
+			}
+		}()
+		call.value, call.err = fn(ctx)
+	}()
+
+	group.mutex.Lock()
+	if group.calls[key] == call {
+		delete(group.calls, key)
+	}
+	group.mutex.Unlock()
+	close(call.done)
+}
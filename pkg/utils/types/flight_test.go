@@ -0,0 +1,119 @@
+package types
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlightGroupCoalescesConcurrentCalls(t *testing.T) {
+	group := NewFlightGroup[int]()
+	release := make(chan struct{})
+	var calls atomic.Int32
+
+	fn := func(ctx context.Context) (int, error) {
+		calls.Add(1)
+		<-release
+		return 42, nil
+	}
+
+	const callers = 10
+	var wg sync.WaitGroup
+	results := make([]int, callers)
+	for i := range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _, _ = group.Do(context.Background(), "key", fn)
+		}()
+	}
+
+	// Wait for every caller to join the call before letting it finish.
+	require.Eventually(t, func() bool {
+		group.mutex.Lock()
+		defer group.mutex.Unlock()
+		call := group.calls["key"]
+		return call != nil && call.waiters == callers
+	}, time.Second, time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls.Load())
+	for _, result := range results {
+		assert.Equal(t, 42, result)
+	}
+}
+
+func TestFlightGroupCallerCancellation(t *testing.T) {
+	group := NewFlightGroup[int]()
+	release := make(chan struct{})
+	sharedCtx := make(chan context.Context, 1)
+
+	fn := func(ctx context.Context) (int, error) {
+		sharedCtx <- ctx
+		select {
+		case <-release:
+			return 7, nil
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+
+	firstCtx, cancelFirst := context.WithCancel(context.Background())
+	firstDone := make(chan error, 1)
+	go func() {
+		_, err, _ := group.Do(firstCtx, "key", fn)
+		firstDone <- err
+	}()
+	callCtx := <-sharedCtx
+
+	secondDone := make(chan int, 1)
+	go func() {
+		value, _, shared := group.Do(context.Background(), "key", fn)
+		assert.True(t, shared)
+		secondDone <- value
+	}()
+	require.Eventually(t, func() bool {
+		group.mutex.Lock()
+		defer group.mutex.Unlock()
+		return group.calls["key"].waiters == 2
+	}, time.Second, time.Millisecond)
+
+	// The first caller leaves: it gets its own error, the shared call goes on.
+	cancelFirst()
+	assert.ErrorIs(t, <-firstDone, context.Canceled)
+	assert.NoError(t, callCtx.Err())
+
+	close(release)
+	assert.Equal(t, 7, <-secondDone)
+}
+
+func TestFlightGroupCancelsAbandonedCall(t *testing.T) {
+	group := NewFlightGroup[int]()
+	sharedCtx := make(chan context.Context, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		group.Do(ctx, "key", func(ctx context.Context) (int, error) {
+			sharedCtx <- ctx
+			<-ctx.Done()
+			return 0, ctx.Err()
+		})
+	}()
+	callCtx := <-sharedCtx
+
+	cancel()
+	<-done
+	select {
+	case <-callCtx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("shared call was not cancelled once every caller left")
+	}
+}
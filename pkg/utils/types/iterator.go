@@ -1,12 +1,15 @@
 package types
 
 import (
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/telemetry"
 	"github.com/jaops-space/grafana-yamcs-jaops/pkg/yamcs/core/http"
 )
 
 // FetchFunction represents a function that fetches data for pagination.
-// It returns a result of type T, a continuation token, and any error encountered.
-type FetchFunction[T any] func() (T, string, error)
+// It receives the HTTP manager the page must be requested through, so that the
+// request is traced as part of the page, and returns a result of type T,
+// a continuation token, and any error encountered.
+type FetchFunction[T any] func(manager *http.HTTPManager) (T, string, error)
 
 // PaginatedRequestIterator handles paginated requests, managing the fetching of results
 // and continuation tokens. It allows iterating through paginated data in a flexible way.
@@ -16,6 +19,7 @@ type PaginatedRequestIterator[T any] struct {
 	apiContext    *http.HTTPManager // Context used for the request.
 	fetchData     FetchFunction[T]  // Function to fetch data.
 	continuation  string            // Token to fetch the next set of results.
+	page          int               // Number of pages fetched so far.
 }
 
 // NewPaginatedRequestIterator initializes a new PaginatedRequestIterator with a context and a fetch function.
@@ -39,20 +43,29 @@ func (iterator *PaginatedRequestIterator[T]) SetQuery(query map[string]string) {
 // Next fetches the next result from the iterator.
 // It applies the query parameters and continuation token, if present.
 func (iterator *PaginatedRequestIterator[T]) Next() (T, error) {
+	ctx, span := telemetry.Start(iterator.apiContext.Context(), "yamcs.page",
+		telemetry.AttributePage.Int(iterator.page),
+	)
+	manager := iterator.apiContext.WithContext(ctx)
+
 	// Set the continuation token if present
 	if iterator.continuation != "" {
-		iterator.apiContext.Query["next"] = iterator.continuation
+		manager.Query["next"] = iterator.continuation
 	}
 
 	// Add the initial query parameters
 	for key, value := range iterator.initialQuery {
-		iterator.apiContext.Query[key] = value
+		manager.Query[key] = value
 	}
 
 	// Fetch data and handle the continuation token
-	result, token, err := iterator.fetchData()
+	result, token, err := iterator.fetchData(manager)
 	iterator.continuation = token
 	iterator.isInitialized = true
+	iterator.page++
+
+	span.SetAttributes(telemetry.AttributeHasNext.Bool(token != ""))
+	telemetry.End(span, err)
 
 	// Reset continuation if error occurred
 	if err != nil {
@@ -0,0 +1,133 @@
+package types
+
+import (
+	"container/list"
+	"sync"
+)
+
+// CacheStats reports the activity of an LRUCache.
+type CacheStats struct {
+	Hits        uint64 `json:"hits"`
+	Misses      uint64 `json:"misses"`
+	Evictions   uint64 `json:"evictions"`
+	Entries     int    `json:"entries"`
+	Bytes       int64  `json:"bytes"`
+	BudgetBytes int64  `json:"budgetBytes"`
+}
+
+// lruEntry is an element of an LRUCache, with the size it was accounted for.
+type lruEntry[K comparable, V any] struct {
+	key   K
+	value V
+	size  int64
+}
+
+// LRUCache is a thread-safe cache bounded by the total size of its values rather
+// than by their number. The least recently used entries are evicted first once
+// the budget is exceeded.
+type LRUCache[K comparable, V any] struct {
+	budget    int64
+	bytes     int64
+	entries   map[K]*list.Element
+	order     *list.List // front is the most recently used entry
+	hits      uint64
+	misses    uint64
+	evictions uint64
+	mutex     sync.Mutex
+}
+
+// NewLRUCache creates an empty cache holding at most budget bytes of values.
+func NewLRUCache[K comparable, V any](budget int64) *LRUCache[K, V] {
+	return &LRUCache[K, V]{
+		budget:  budget,
+		entries: make(map[K]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// Get returns the value stored under key and marks it as recently used.
+func (cache *LRUCache[K, V]) Get(key K) (V, bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	element, exists := cache.entries[key]
+	if !exists {
+		cache.misses++
+		var zeroValue V
+		return zeroValue, false
+	}
+	cache.hits++
+	cache.order.MoveToFront(element)
+	return element.Value.(*lruEntry[K, V]).value, true
+}
+
+// Put stores value under key, accounting for size bytes, and evicts the least
+// recently used entries until the cache fits its budget again. Values larger
+// than the whole budget are not stored.
+func (cache *LRUCache[K, V]) Put(key K, value V, size int64) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if element, exists := cache.entries[key]; exists {
+		cache.removeElement(element)
+	}
+	if size > cache.budget {
+		return
+	}
+
+	element := cache.order.PushFront(&lruEntry[K, V]{key: key, value: value, size: size})
+	cache.entries[key] = element
+	cache.bytes += size
+
+	for cache.bytes > cache.budget {
+		oldest := cache.order.Back()
+		if oldest == nil {
+			break
+		}
+		cache.removeElement(oldest)
+		cache.evictions++
+	}
+}
+
+// Remove deletes the entry stored under key, if any.
+func (cache *LRUCache[K, V]) Remove(key K) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if element, exists := cache.entries[key]; exists {
+		cache.removeElement(element)
+	}
+}
+
+// RemoveIf deletes every entry whose key matches the predicate.
+func (cache *LRUCache[K, V]) RemoveIf(match func(key K) bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	for key, element := range cache.entries {
+		if match(key) {
+			cache.removeElement(element)
+		}
+	}
+}
+
+// Stats returns a snapshot of the cache counters.
+func (cache *LRUCache[K, V]) Stats() CacheStats {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	return CacheStats{
+		Hits:        cache.hits,
+		Misses:      cache.misses,
+		Evictions:   cache.evictions,
+		Entries:     len(cache.entries),
+		Bytes:       cache.bytes,
+		BudgetBytes: cache.budget,
+	}
+}
+
+func (cache *LRUCache[K, V]) removeElement(element *list.Element) {
+	entry := cache.order.Remove(element).(*lruEntry[K, V])
+	delete(cache.entries, entry.key)
+	cache.bytes -= entry.size
+}
@@ -0,0 +1,34 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRUCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewLRUCache[string, int](10)
+
+	cache.Put("a", 1, 4)
+	cache.Put("b", 2, 4)
+	_, _ = cache.Get("a") // b is now the least recently used entry
+	cache.Put("c", 3, 4)
+
+	_, hasA := cache.Get("a")
+	_, hasB := cache.Get("b")
+	_, hasC := cache.Get("c")
+	assert.True(t, hasA)
+	assert.False(t, hasB)
+	assert.True(t, hasC)
+
+	stats := cache.Stats()
+	assert.Equal(t, uint64(3), stats.Hits)
+	assert.Equal(t, uint64(1), stats.Misses)
+	assert.Equal(t, uint64(1), stats.Evictions)
+	assert.Equal(t, int64(8), stats.Bytes)
+
+	// Values larger than the budget are not kept.
+	cache.Put("huge", 4, 11)
+	_, hasHuge := cache.Get("huge")
+	assert.False(t, hasHuge)
+}
@@ -0,0 +1,94 @@
+package client
+
+import (
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/api"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/activities"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// ActivitiesTopic is the WebSocket topic of activity updates.
+const ActivitiesTopic = "activities"
+
+// ActivityListener defines a callback for activities starting, progressing or ending.
+type ActivityListener func(activity *activities.ActivityInfo)
+
+// ActivitySubscription manages a subscription to the activities of an instance.
+type ActivitySubscription struct {
+	subscriptionID int32
+	listener       ActivityListener
+	Instance       string
+	client         *YamcsClient
+}
+
+// CreateActivitySubscription subscribes to the activities of an instance.
+func (client *YamcsClient) CreateActivitySubscription(instance Instance) (*ActivitySubscription, error) {
+	instanceName := instance.GetName()
+	subscription := &ActivitySubscription{
+		client:   client,
+		Instance: instanceName,
+	}
+
+	anyMessage, err := anypb.New(&activities.SubscribeActivitiesRequest{Instance: &instanceName})
+	if err != nil {
+		return nil, err
+	}
+
+	message := &api.ClientMessage{
+		Type:    ActivitiesTopic,
+		Options: anyMessage,
+	}
+
+	_, callID, _, err := client.WebSocket.SendSyncContext(client.Context(), message)
+	if err != nil {
+		return nil, err
+	}
+
+	subscription.subscriptionID = callID
+	client.ActivitySubscriptions[callID] = subscription
+	return subscription, nil
+}
+
+// HandleActivityMessage processes incoming websocket messages for activity updates.
+func (client *YamcsClient) HandleActivityMessage(message *api.ServerMessage) {
+	if message.GetType() != ActivitiesTopic {
+		return
+	}
+
+	subscription, found := client.ActivitySubscriptions[message.GetCall()]
+	if !found {
+		return
+	}
+
+	activity := &activities.ActivityInfo{}
+	if err := message.Data.UnmarshalTo(activity); err != nil {
+		backend.Logger.Debug("Error unmarshalling activity data", "error", err)
+		return
+	}
+	if subscription.listener != nil {
+		subscription.listener(activity)
+	}
+}
+
+// SetListener assigns a listener function for activity updates.
+func (subscription *ActivitySubscription) SetListener(listener ActivityListener) {
+	subscription.listener = listener
+}
+
+// Halt cancels the activity subscription.
+func (subscription *ActivitySubscription) Halt() {
+	delete(subscription.client.ActivitySubscriptions, subscription.subscriptionID)
+
+	cancelRequest := &api.CancelOptions{
+		Call: subscription.subscriptionID,
+	}
+
+	anyMessage, _ := anypb.New(cancelRequest)
+
+	message := &api.ClientMessage{
+		Type:    "cancel",
+		Options: anyMessage,
+	}
+
+	subscription.client.WebSocket.SendSync(message)
+}
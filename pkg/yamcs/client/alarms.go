@@ -8,6 +8,7 @@ import (
 	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/api"
 	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/alarms"
 	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/types"
+	corehttp "github.com/jaops-space/grafana-yamcs-jaops/pkg/yamcs/core/http"
 	"google.golang.org/protobuf/types/known/anypb"
 )
 
@@ -18,9 +19,9 @@ func (c *YamcsClient) ListAlarms(instance, name string) *types.PaginatedRequestI
 
 // fetchAlarms fetches a list of alarms from the Yamcs API.
 func (c *YamcsClient) fetchAlarms(instance, name string) types.FetchFunction[[]*alarms.AlarmData] {
-	return func() ([]*alarms.AlarmData, string, error) {
+	return func(manager *corehttp.HTTPManager) ([]*alarms.AlarmData, string, error) {
 		response := &alarms.ListAlarmsResponse{}
-		if err := c.HTTP.GetProto(fmt.Sprintf("/archive/%s/alarms/%s", instance, name), response); err != nil {
+		if err := manager.GetProto(fmt.Sprintf("/archive/%s/alarms/%s", instance, name), response); err != nil {
 			return nil, "", err
 		}
 		return response.Alarms, response.GetContinuationToken(), nil
@@ -28,12 +29,16 @@ func (c *YamcsClient) fetchAlarms(instance, name string) types.FetchFunction[[]*
 }
 
 // ListProcessorAlarms retrieves currently active alarms for a processor.
+// Concurrent calls for the same processor share a single request.
 func (c *YamcsClient) ListProcessorAlarms(instance Instance, processor Processor) ([]*alarms.AlarmData, error) {
-	response := &alarms.ListProcessorAlarmsResponse{}
-	if err := c.HTTP.GetProto(fmt.Sprintf("/processors/%s/%s/alarms", instance.GetName(), processor.GetName()), response); err != nil {
-		return nil, err
-	}
-	return response.Alarms, nil
+	path := fmt.Sprintf("/processors/%s/%s/alarms", instance.GetName(), processor.GetName())
+	return coalesce(c, "GET "+path, func(c *YamcsClient) ([]*alarms.AlarmData, error) {
+		response := &alarms.ListProcessorAlarmsResponse{}
+		if err := c.HTTP.GetProto(path, response); err != nil {
+			return nil, err
+		}
+		return response.Alarms, nil
+	})
 }
 
 // AcknowledgeAlarm acknowledges an alarm.
@@ -130,7 +135,7 @@ func (c *YamcsClient) newAlarmSubscription(instance Instance, processor Processo
 		Options: anyMessage,
 	}
 
-	_, callID, _, err := c.WebSocket.SendSync(message)
+	_, callID, _, err := c.WebSocket.SendSyncContext(c.Context(), message)
 	if err != nil {
 		return nil, err
 	}
@@ -224,7 +229,7 @@ func (c *YamcsClient) newGlobalAlarmStatusSubscription(instance Instance, proces
 		Options: anyMessage,
 	}
 
-	_, callID, _, err := c.WebSocket.SendSync(message)
+	_, callID, _, err := c.WebSocket.SendSyncContext(c.Context(), message)
 	if err != nil {
 		return nil, err
 	}
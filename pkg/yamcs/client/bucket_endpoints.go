@@ -0,0 +1,18 @@
+package client
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// GetObject returns the content of an object stored in a Yamcs bucket. The
+// object name may contain slashes.
+func (c *YamcsClient) GetObject(bucket, object string) ([]byte, error) {
+	segments := strings.Split(object, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	path := fmt.Sprintf("%s/buckets/%s/objects/%s", c.HTTP.APIRoot, url.PathEscape(bucket), strings.Join(segments, "/"))
+	return c.HTTP.SendRequest("GET", path, nil)
+}
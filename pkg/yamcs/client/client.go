@@ -1,6 +1,7 @@
 package client
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 
@@ -51,9 +52,15 @@ type YamcsClient struct {
 	TimeSubscriptions              map[int32]*TimeSubscription
 	LinkSubscriptions              map[int32]*LinkSubscription
 	ProcessorSubscriptions         map[int32]*ProcessorSubscription
+	MdbChangeSubscriptions         map[int32]*MdbChangeSubscription
+	QueueSubscriptions             map[int32]*QueueSubscription
+	ActivitySubscriptions          map[int32]*ActivitySubscription
 
 	// Sample Point Count for Sample endpoints
 	SamplePointCount *types.Optional[int]
+
+	// Identical concurrent lookups in flight, shared with WithContext clones
+	flights *types.FlightGroup[any]
 }
 
 // NewYamcsClient constructs a new YamcsClient.
@@ -79,7 +86,11 @@ func NewYamcsClient(
 		TimeSubscriptions:              make(map[int32]*TimeSubscription),
 		LinkSubscriptions:              make(map[int32]*LinkSubscription),
 		ProcessorSubscriptions:         make(map[int32]*ProcessorSubscription),
+		MdbChangeSubscriptions:         make(map[int32]*MdbChangeSubscription),
+		QueueSubscriptions:             make(map[int32]*QueueSubscription),
+		ActivitySubscriptions:          make(map[int32]*ActivitySubscription),
 		SamplePointCount:               types.OptionalOfNil[int](),
+		flights:                        types.NewFlightGroup[any](),
 	}
 
 	// WebSocket URL based on whether TLS is enabled
@@ -109,6 +120,9 @@ func NewYamcsClient(
 	client.WebSocket.AddListener(ws.TimeListenerID, client.HandleTimeMessage)
 	client.WebSocket.AddListener(ws.LinksListenerID, client.HandleLinkMessage)
 	client.WebSocket.AddListener(ws.ProcessorListenerID, client.HandleProcessorMessage)
+	client.WebSocket.AddListener(ws.MdbChangesListenerID, client.HandleMdbChangeMessage)
+	client.WebSocket.AddListener(ws.QueuesListenerID, client.HandleQueueMessage)
+	client.WebSocket.AddListener(ws.ActivitiesListenerID, client.HandleActivityMessage)
 
 	// Handle WebSocket disconnections
 	client.WebSocket.SetDisconnectHandler(func() {
@@ -118,6 +132,26 @@ func NewYamcsClient(
 	return client, nil
 }
 
+// WithContext returns a shallow copy of the client whose HTTP requests and
+// WebSocket calls are bound to ctx, so that they are traced as children of the
+// caller's span and abort when the caller goes away. Subscriptions and the
+// WebSocket connection are shared with the original client.
+func (client *YamcsClient) WithContext(ctx context.Context) *YamcsClient {
+	clone := *client
+	clone.HTTP = client.HTTP.WithContext(ctx)
+	// The sample point count is request state: give the clone its own copy.
+	clone.SamplePointCount = types.OptionalOfNil[int]()
+	if client.SamplePointCount.IsPresent() {
+		clone.SamplePointCount.Set(client.SamplePointCount.Get())
+	}
+	return &clone
+}
+
+// Context returns the context the client is bound to.
+func (client *YamcsClient) Context() context.Context {
+	return client.HTTP.Context()
+}
+
 func (client *YamcsClient) EstablishWebSocketConnection() error {
 	if client.IsWebSocketConnected() {
 		return nil
@@ -186,4 +220,7 @@ func (client *YamcsClient) clearAllSubscriptions() {
 	client.TimeSubscriptions = make(map[int32]*TimeSubscription)
 	client.LinkSubscriptions = make(map[int32]*LinkSubscription)
 	client.ProcessorSubscriptions = make(map[int32]*ProcessorSubscription)
+	client.MdbChangeSubscriptions = make(map[int32]*MdbChangeSubscription)
+	client.QueueSubscriptions = make(map[int32]*QueueSubscription)
+	client.ActivitySubscriptions = make(map[int32]*ActivitySubscription)
 }
@@ -1,12 +1,17 @@
 package client
 
 import (
+	"context"
+	"errors"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/instances"
 	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/yamcsManagement"
 	corehttp "github.com/jaops-space/grafana-yamcs-jaops/pkg/yamcs/core/http"
 )
@@ -83,3 +88,233 @@ func TestClient(t *testing.T) {
 	}
 
 }
+
+// errorTransport answers every request with a Yamcs exception body.
+type errorTransport struct {
+	status      int
+	contentType string
+	body        string
+}
+
+func (m *errorTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	header := make(http.Header)
+	header.Set("Content-Type", m.contentType)
+	return &http.Response{
+		StatusCode: m.status,
+		Body:       io.NopCloser(strings.NewReader(m.body)),
+		Header:     header,
+		Request:    req,
+	}, nil
+}
+
+func TestClientYamcsError(t *testing.T) {
+	tests := []struct {
+		name        string
+		transport   *errorTransport
+		wantType    string
+		wantMessage string
+		downstream  bool
+	}{
+		{
+			name: "json exception",
+			transport: &errorTransport{
+				status:      404,
+				contentType: "application/json",
+				body:        `{"code":404,"type":"NotFoundException","msg":"No such parameter"}`,
+			},
+			wantType:    "NotFoundException",
+			wantMessage: "No such parameter",
+			downstream:  true,
+		},
+		{
+			name: "plain text from a proxy",
+			transport: &errorTransport{
+				status:      502,
+				contentType: "text/html",
+				body:        "  Bad Gateway\n",
+			},
+			wantMessage: "Bad Gateway",
+			downstream:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewYamcsClient(
+				"yamcs-error",
+				corehttp.GetNoTLSConfiguration(),
+				&corehttp.NoCredentials{},
+				OptionSetProtocol(false),
+			)
+			if err != nil {
+				t.Fatalf("Failed to create client: %v", err)
+			}
+			client.HTTP.Client.Transport = tt.transport
+			client.HTTP.Retry = corehttp.RetryPolicy{}
+
+			_, err = client.GetInstanceByName("someinstance")
+			yamcsErr, ok := corehttp.AsYamcsError(err)
+			if !ok {
+				t.Fatalf("Expected a YamcsError, got %v", err)
+			}
+			if yamcsErr.StatusCode != tt.transport.status {
+				t.Errorf("StatusCode = %d, want %d", yamcsErr.StatusCode, tt.transport.status)
+			}
+			if yamcsErr.Type != tt.wantType {
+				t.Errorf("Type = %q, want %q", yamcsErr.Type, tt.wantType)
+			}
+			if yamcsErr.Message != tt.wantMessage {
+				t.Errorf("Message = %q, want %q", yamcsErr.Message, tt.wantMessage)
+			}
+			if backend.IsDownstreamError(err) != tt.downstream {
+				t.Errorf("IsDownstreamError = %v, want %v", !tt.downstream, tt.downstream)
+			}
+		})
+	}
+}
+
+// flakyTransport fails the first requests with a 503, then answers like mockTransport.
+type flakyTransport struct {
+	failures int
+	calls    int
+}
+
+func (m *flakyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	m.calls++
+	if m.calls <= m.failures {
+		header := make(http.Header)
+		header.Set("Retry-After", "0")
+		return &http.Response{
+			StatusCode: 503,
+			Body:       io.NopCloser(strings.NewReader("Service Unavailable")),
+			Header:     header,
+			Request:    req,
+		}, nil
+	}
+	return (&mockTransport{}).RoundTrip(req)
+}
+
+func TestClientRetryAndCircuitBreaker(t *testing.T) {
+	client, err := NewYamcsClient(
+		"yamcs-flaky",
+		corehttp.GetNoTLSConfiguration(),
+		&corehttp.NoCredentials{},
+		OptionSetProtocol(false),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	client.HTTP.Retry.InitialBackoff = time.Millisecond
+	client.HTTP.Retry.MaxBackoff = time.Millisecond
+
+	// A transient 503 is retried transparently.
+	transport := &flakyTransport{failures: 2}
+	client.HTTP.Client.Transport = transport
+	if _, err := client.GetInstanceByName("someinstance"); err != nil {
+		t.Fatalf("Expected retries to succeed, got %v", err)
+	}
+	if transport.calls != 3 {
+		t.Errorf("calls = %d, want 3", transport.calls)
+	}
+
+	// A POST is never retried.
+	transport = &flakyTransport{failures: 1}
+	client.HTTP.Client.Transport = transport
+	if err := client.HTTP.PostProto("/something", nil, nil); err == nil {
+		t.Fatalf("Expected POST to fail")
+	}
+	if transport.calls != 1 {
+		t.Errorf("calls = %d, want 1", transport.calls)
+	}
+
+	// Repeated failures open the circuit and further calls do not reach the host.
+	transport = &flakyTransport{failures: 100}
+	client.HTTP.Client.Transport = transport
+	for i := 0; i < 3; i++ {
+		client.GetInstanceByName("someinstance")
+	}
+	if state := client.HTTP.Breaker.Status().State; state != corehttp.BreakerOpen {
+		t.Fatalf("Breaker state = %s, want %s", state, corehttp.BreakerOpen)
+	}
+	calls := transport.calls
+	_, err = client.GetInstanceByName("someinstance")
+	var openErr *corehttp.CircuitOpenError
+	if !errors.As(err, &openErr) {
+		t.Fatalf("Expected a CircuitOpenError, got %v", err)
+	}
+	if transport.calls != calls {
+		t.Errorf("Host was contacted while the circuit was open")
+	}
+}
+
+// pagingTransport answers each request with the next page body and records the
+// query of every request.
+type pagingTransport struct {
+	pages   []string
+	queries []url.Values
+}
+
+func (m *pagingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	m.queries = append(m.queries, req.URL.Query())
+	body := m.pages[len(m.queries)-1]
+	header := make(http.Header)
+	header.Set("Content-Type", "application/json")
+	return &http.Response{
+		StatusCode: 200,
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Header:     header,
+		Request:    req,
+	}, nil
+}
+
+func TestListCommandsHistoryTimeWindow(t *testing.T) {
+	client, err := NewYamcsClient(
+		"yamcs-paging",
+		corehttp.GetNoTLSConfiguration(),
+		&corehttp.NoCredentials{},
+		OptionSetProtocol(false),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	transport := &pagingTransport{pages: []string{
+		`{"commands":[{"id":"cmd-1"}],"continuationToken":"page-2"}`,
+		`{"commands":[{"id":"cmd-2"}]}`,
+	}}
+	client.HTTP.Client.Transport = transport
+
+	instance := &instances.YamcsInstance{}
+	instance.Name = new(string)
+	*instance.Name = "someinstance"
+	start := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+
+	// Queries page through a context-bound copy of the client, as the plugin does.
+	iterator := client.WithContext(context.Background()).ListCommandsHistory(instance, start, end)
+	count := 0
+	for iterator.HasNext() {
+		commands, err := iterator.Next()
+		if err != nil {
+			t.Fatalf("Failed to list command history: %v", err)
+		}
+		count += len(commands)
+	}
+	if count != 2 {
+		t.Fatalf("count = %d, want 2", count)
+	}
+
+	if len(transport.queries) != 2 {
+		t.Fatalf("requests = %d, want 2", len(transport.queries))
+	}
+	for page, query := range transport.queries {
+		if got := query.Get("start"); got != "2025-03-01T12:00:00Z" {
+			t.Errorf("page %d start = %q", page, got)
+		}
+		if got := query.Get("stop"); got != "2025-03-01T13:00:00Z" {
+			t.Errorf("page %d stop = %q", page, got)
+		}
+	}
+	if got := transport.queries[1].Get("next"); got != "page-2" {
+		t.Errorf("page 1 next = %q, want page-2", got)
+	}
+}
@@ -0,0 +1,20 @@
+package client
+
+import (
+	"context"
+)
+
+// coalesce runs fetch once for all concurrent callers of the same key on this
+// client (or any of its WithContext clones) and hands each of them the result.
+// A caller whose context is cancelled stops waiting without cancelling the call
+// for the others. The result is shared and must not be modified.
+func coalesce[V any](client *YamcsClient, key string, fetch func(client *YamcsClient) (V, error)) (V, error) {
+	value, err, _ := client.flights.Do(client.Context(), key, func(ctx context.Context) (any, error) {
+		return fetch(client.WithContext(ctx))
+	})
+	if err != nil {
+		var zeroValue V
+		return zeroValue, err
+	}
+	return value.(V), nil
+}
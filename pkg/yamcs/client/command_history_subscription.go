@@ -17,6 +17,7 @@ type CommandHistorySubscription struct {
 	activeSubscriptions types.Set[string]
 	commandListener     CommandHistoryListener
 	Instance            string
+	Processor           string
 	client              *YamcsClient
 }
 
@@ -36,6 +37,7 @@ func (client *YamcsClient) newCommandHistorySubscription(instance, processor str
 	subscription := &CommandHistorySubscription{
 		client:              client,
 		Instance:            instance,
+		Processor:           processor,
 		activeSubscriptions: types.Set[string]{},
 	}
 
@@ -56,7 +58,7 @@ func (client *YamcsClient) newCommandHistorySubscription(instance, processor str
 		Options: anyMessage,
 	}
 
-	_, callID, _, err := client.WebSocket.SendSync(message)
+	_, callID, _, err := client.WebSocket.SendSyncContext(client.Context(), message)
 	if err != nil {
 		return nil, err
 	}
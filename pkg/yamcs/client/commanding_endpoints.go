@@ -8,6 +8,7 @@ import (
 	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/commanding"
 	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/mdb"
 	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/types"
+	corehttp "github.com/jaops-space/grafana-yamcs-jaops/pkg/yamcs/core/http"
 	"google.golang.org/protobuf/types/known/structpb"
 )
 
@@ -26,11 +27,28 @@ func (c *YamcsClient) IssueCommandWithOptions(instance Instance, processor Proce
 	return c.issueCommand(instance, processor, commandName, args, comment, &origin, &sequenceNumber, dryRun, nil, nil, nil, nil, extra)
 }
 
+// DryRunCommand validates a command and encodes it without releasing it. The
+// response holds the generated binary.
+func (c *YamcsClient) DryRunCommand(instance Instance, processor Processor, commandName string, args map[string]any, comment string) (*commanding.IssueCommandResponse, error) {
+	return c.issueCommand(instance, processor, commandName, args, comment, nil, nil, true, nil, nil, nil, nil, nil)
+}
+
 // IssueCommandWithElevatedPrivileges sends a command with all options, including those requiring elevated privileges.
 func (c *YamcsClient) IssueCommandWithElevatedPrivileges(instance Instance, processor Processor, commandName string, args map[string]any, origin string, sequenceNumber int32, dryRun bool, comment string, stream string, disableTransmissionConstraints, disableVerifiers bool, verifierConfig map[string]*commanding.VerifierConfig, extra map[string]*protobuf.Value) (*commanding.IssueCommandResponse, error) {
 	return c.issueCommand(instance, processor, commandName, args, comment, &origin, &sequenceNumber, dryRun, &stream, &disableTransmissionConstraints, &disableVerifiers, verifierConfig, extra)
 }
 
+// IssueCommandWithOverrides sends a command with contingency options: bypassed
+// transmission constraints, verifier overrides, another TC stream or extra
+// attributes. An empty stream keeps the default one.
+func (c *YamcsClient) IssueCommandWithOverrides(instance Instance, processor Processor, commandName string, args map[string]any, comment string, stream string, disableTransmissionConstraints, disableVerifiers bool, verifierConfig map[string]*commanding.VerifierConfig, extra map[string]*protobuf.Value) (*commanding.IssueCommandResponse, error) {
+	var streamName *string
+	if stream != "" {
+		streamName = &stream
+	}
+	return c.issueCommand(instance, processor, commandName, args, comment, nil, nil, false, streamName, &disableTransmissionConstraints, &disableVerifiers, verifierConfig, extra)
+}
+
 // issueCommand handles command execution with optional parameters.
 func (c *YamcsClient) issueCommand(instance Instance, processor Processor, commandName string, args map[string]any, comment string, origin *string, sequenceNumber *int32, dryRun bool, stream *string, disableTransmissionConstraints, disableVerifiers *bool, verifierConfig map[string]*commanding.VerifierConfig, extra map[string]*protobuf.Value) (*commanding.IssueCommandResponse, error) {
 	url := fmt.Sprintf("/processors/%s/%s/commands/%s", instance.GetName(), processor.GetName(), commandName)
@@ -74,14 +92,15 @@ func (c *YamcsClient) GetCommand(instance, id string) (*commanding.CommandHistor
 
 // ListCommandsHistory returns an iterator over command history entries.
 func (c *YamcsClient) ListCommandsHistory(instance Instance, start, end time.Time) *types.PaginatedRequestIterator[[]*commanding.CommandHistoryEntry] {
-	return types.NewPaginatedRequestIterator(c.HTTP, c.getCommandsHistoryFetcher(instance.GetName(), start, end))
+	iterator := types.NewPaginatedRequestIterator(c.HTTP, c.getCommandsHistoryFetcher(instance.GetName()))
+	iterator.SetQuery(timeQuery(start, end))
+	return iterator
 }
 
-func (c *YamcsClient) getCommandsHistoryFetcher(instance string, startTime, endTime time.Time) types.FetchFunction[[]*commanding.CommandHistoryEntry] {
-	return func() ([]*commanding.CommandHistoryEntry, string, error) {
+func (c *YamcsClient) getCommandsHistoryFetcher(instance string) types.FetchFunction[[]*commanding.CommandHistoryEntry] {
+	return func(manager *corehttp.HTTPManager) ([]*commanding.CommandHistoryEntry, string, error) {
 		response := &commanding.ListCommandsResponse{}
-		c.setTime(startTime, endTime)
-		if err := c.HTTP.GetProto(fmt.Sprintf("/archive/%s/commands", instance), response); err != nil {
+		if err := manager.GetProto(fmt.Sprintf("/archive/%s/commands", instance), response); err != nil {
 			return nil, "", err
 		}
 		return response.Commands, response.GetContinuationToken(), nil
@@ -114,9 +133,9 @@ func (c *YamcsClient) SearchCommandInfo(instance Instance, query string) *types.
 }
 
 func (c *YamcsClient) getCommandInfoFetcher(instance string) types.FetchFunction[[]CommandInfo] {
-	return func() ([]CommandInfo, string, error) {
+	return func(manager *corehttp.HTTPManager) ([]CommandInfo, string, error) {
 		response := &mdb.ListCommandsResponse{}
-		if err := c.HTTP.GetProto(fmt.Sprintf("/mdb/%s/commands", instance), response); err != nil {
+		if err := manager.GetProto(fmt.Sprintf("/mdb/%s/commands", instance), response); err != nil {
 			return nil, "", err
 		}
 		return response.GetCommands(), response.GetContinuationToken(), nil
@@ -124,11 +143,14 @@ func (c *YamcsClient) getCommandInfoFetcher(instance string) types.FetchFunction
 }
 
 // GetCommandInfo retrieves metadata for a specific command.
+// Concurrent lookups of the same command share a single request.
 func (c *YamcsClient) GetCommandInfo(instance Instance, command string) (CommandInfo, error) {
 	url := fmt.Sprintf("/mdb/%s/commands/%s", instance.GetName(), command)
-	info := &mdb.CommandInfo{}
-	if err := c.HTTP.GetProto(url, info); err != nil {
-		return nil, err
-	}
-	return info, nil
+	return coalesce(c, "GET "+url, func(c *YamcsClient) (CommandInfo, error) {
+		info := &mdb.CommandInfo{}
+		if err := c.HTTP.GetProto(url, info); err != nil {
+			return nil, err
+		}
+		return info, nil
+	})
 }
@@ -6,6 +6,7 @@ import (
 
 	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/events"
 	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/types"
+	corehttp "github.com/jaops-space/grafana-yamcs-jaops/pkg/yamcs/core/http"
 )
 
 // ListEvents returns a paginated iterator for fetching events from a specified instance.
@@ -25,9 +26,9 @@ func (c *YamcsClient) ListEventsWithinTimeRange(instance Instance, startTime, en
 
 // fetchEventBatch retrieves a batch of events from the given instance.
 func (c *YamcsClient) fetchEventBatch(instance string) types.FetchFunction[[]*events.Event] {
-	return func() ([]*events.Event, string, error) {
+	return func(manager *corehttp.HTTPManager) ([]*events.Event, string, error) {
 		response := &events.ListEventsResponse{}
-		err := c.HTTP.GetProto(fmt.Sprintf("/archive/%s/events", instance), response)
+		err := manager.GetProto(fmt.Sprintf("/archive/%s/events", instance), response)
 		if err != nil {
 			return nil, "", err
 		}
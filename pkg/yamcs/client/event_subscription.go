@@ -57,7 +57,7 @@ func (client *YamcsClient) newEventSubscription(instance string) (*EventSubscrip
 		Options: anyMessage,
 	}
 
-	_, callID, _, err := client.WebSocket.SendSync(message)
+	_, callID, _, err := client.WebSocket.SendSyncContext(client.Context(), message)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,41 @@
+package client
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/archive"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/types"
+	corehttp "github.com/jaops-space/grafana-yamcs-jaops/pkg/yamcs/core/http"
+)
+
+// Archive indexes listed by ListArchiveIndex.
+const (
+	// CompletenessIndex indexes CCSDS packets per APID, with their sequence counts.
+	CompletenessIndex = "completeness-index"
+	// PacketIndex indexes telemetry packets per packet name.
+	PacketIndex = "packet-index"
+)
+
+// ListArchiveIndex returns an iterator over the groups of an archive index
+// between start and end. Consecutive entries closer than mergeTime are merged,
+// unless mergeTime is zero, which keeps the default of the index.
+func (client *YamcsClient) ListArchiveIndex(instance Instance, index string, start, end time.Time, mergeTime time.Duration) *types.PaginatedRequestIterator[[]*archive.IndexGroup] {
+	iterator := types.NewPaginatedRequestIterator(client.HTTP, client.getArchiveIndexFetcher(instance.GetName(), index))
+	iterator.SetQuery(timeQuery(start, end))
+	if mergeTime > 0 {
+		iterator.SetQuery(map[string]string{"mergeTime": strconv.FormatInt(mergeTime.Milliseconds(), 10)})
+	}
+	return iterator
+}
+
+func (client *YamcsClient) getArchiveIndexFetcher(instance string, index string) types.FetchFunction[[]*archive.IndexGroup] {
+	return func(manager *corehttp.HTTPManager) ([]*archive.IndexGroup, string, error) {
+		response := &archive.IndexResponse{}
+		if err := manager.GetProto(fmt.Sprintf("/archive/%s/%s", instance, index), response); err != nil {
+			return nil, "", err
+		}
+		return response.GetGroup(), response.GetContinuationToken(), nil
+	}
+}
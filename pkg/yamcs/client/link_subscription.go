@@ -50,7 +50,7 @@ func (client *YamcsClient) newLinkSubscription(instance string) (*LinkSubscripti
 		Options: anyMessage,
 	}
 
-	_, callID, _, err := client.WebSocket.SendSync(message)
+	_, callID, _, err := client.WebSocket.SendSyncContext(client.Context(), message)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,96 @@
+package client
+
+import (
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/api"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/processing"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// MdbChangeListener defines a callback for incoming MDB overrides.
+type MdbChangeListener func(change *processing.MdbOverrideInfo)
+
+// MdbChangeSubscription manages a subscription to the MDB changes of a processor,
+// such as alarm or calibration overrides.
+type MdbChangeSubscription struct {
+	subscriptionID int32
+	listener       MdbChangeListener
+	Instance       string
+	Processor      string
+	client         *YamcsClient
+}
+
+// CreateMdbChangeSubscription creates a new MDB changes subscription.
+func (client *YamcsClient) CreateMdbChangeSubscription(instance Instance, processor Processor) (*MdbChangeSubscription, error) {
+	subscription := &MdbChangeSubscription{
+		client:    client,
+		Instance:  instance.GetName(),
+		Processor: processor.GetName(),
+	}
+
+	subscribeRequest := &processing.SubscribeMdbChangesRequest{
+		Instance:  &subscription.Instance,
+		Processor: &subscription.Processor,
+	}
+
+	anyMessage, err := anypb.New(subscribeRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	message := &api.ClientMessage{
+		Type:    "mdb-changes",
+		Options: anyMessage,
+	}
+
+	_, callID, _, err := client.WebSocket.SendSyncContext(client.Context(), message)
+	if err != nil {
+		return nil, err
+	}
+
+	subscription.subscriptionID = callID
+	client.MdbChangeSubscriptions[subscription.subscriptionID] = subscription
+	return subscription, nil
+}
+
+// HandleMdbChangeMessage processes incoming websocket messages for MDB changes.
+func (client *YamcsClient) HandleMdbChangeMessage(message *api.ServerMessage) {
+	if message.GetType() != "mdb-changes" {
+		return
+	}
+
+	change := &processing.MdbOverrideInfo{}
+	if err := message.Data.UnmarshalTo(change); err != nil {
+		backend.Logger.Debug("Error unmarshalling MDB change data", "error", err)
+		return
+	}
+
+	callID := message.GetCall()
+	subscription, found := client.MdbChangeSubscriptions[callID]
+	if found && subscription.listener != nil {
+		subscription.listener(change)
+	}
+}
+
+// SetListener assigns an MDB change listener to the subscription.
+func (subscription *MdbChangeSubscription) SetListener(listener MdbChangeListener) {
+	subscription.listener = listener
+}
+
+// Halt cancels the MDB changes subscription.
+func (subscription *MdbChangeSubscription) Halt() {
+	delete(subscription.client.MdbChangeSubscriptions, subscription.subscriptionID)
+
+	cancelRequest := &api.CancelOptions{
+		Call: subscription.subscriptionID,
+	}
+
+	anyMessage, _ := anypb.New(cancelRequest)
+
+	message := &api.ClientMessage{
+		Type:    "cancel",
+		Options: anyMessage,
+	}
+
+	subscription.client.WebSocket.SendSync(message)
+}
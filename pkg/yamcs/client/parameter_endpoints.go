@@ -8,13 +8,14 @@ import (
 	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/mdb"
 	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/pvalue"
 	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/types"
+	corehttp "github.com/jaops-space/grafana-yamcs-jaops/pkg/yamcs/core/http"
 )
 
 // getParametersFetchMethod returns a fetch function for paginated parameter results.
 func (client *YamcsClient) getParametersFetchMethod(instance string) types.FetchFunction[[]Parameter] {
-	return func() ([]Parameter, string, error) {
+	return func(manager *corehttp.HTTPManager) ([]Parameter, string, error) {
 		response := &mdb.ListParametersResponse{}
-		err := client.HTTP.GetProto(fmt.Sprintf("/mdb/%s/parameters", instance), response)
+		err := manager.GetProto(fmt.Sprintf("/mdb/%s/parameters", instance), response)
 		if err != nil {
 			return nil, "", err
 		}
@@ -42,13 +43,17 @@ func (client *YamcsClient) SearchParameters(instance Instance, query string) *ty
 }
 
 // GetParameter retrieves a specific parameter's info for an instance.
+// Concurrent lookups of the same parameter share a single request.
 func (client *YamcsClient) GetParameter(instance Instance, parameter string) (Parameter, error) {
-	response := &mdb.ParameterInfo{}
-	err := client.HTTP.GetProto(fmt.Sprintf("/mdb/%s/parameters/%s", instance.GetName(), parameter), response)
-	if err != nil {
-		return nil, err
-	}
-	return response, nil
+	path := fmt.Sprintf("/mdb/%s/parameters/%s", instance.GetName(), parameter)
+	return coalesce(client, "GET "+path, func(client *YamcsClient) (Parameter, error) {
+		response := &mdb.ParameterInfo{}
+		err := client.HTTP.GetProto(path, response)
+		if err != nil {
+			return nil, err
+		}
+		return response, nil
+	})
 }
 
 // GetParameterRanges retrieves the ranges of a specific parameter in a given instance.
@@ -81,11 +86,20 @@ func (client *YamcsClient) ListParameterHistory(instance Instance, parameter Par
 	return iterator
 }
 
+// ListParameterHistoryByName retrieves the raw values of a parameter between
+// start and end, newest first. A parameter name may designate an aggregate or
+// array parameter as a whole.
+func (client *YamcsClient) ListParameterHistoryByName(instance Instance, parameter string, start, end time.Time) *types.PaginatedRequestIterator[[]*pvalue.ParameterValue] {
+	iterator := types.NewPaginatedRequestIterator(client.HTTP, client.getParameterHistoryFetchMethod(instance.GetName(), parameter))
+	iterator.SetQuery(timeQuery(start, end))
+	return iterator
+}
+
 // getParameterHistoryFetchMethod returns a fetch function for paginated parameter history results.
 func (client *YamcsClient) getParameterHistoryFetchMethod(instance string, parameter string) types.FetchFunction[[]*pvalue.ParameterValue] {
-	return func() ([]*pvalue.ParameterValue, string, error) {
+	return func(manager *corehttp.HTTPManager) ([]*pvalue.ParameterValue, string, error) {
 		response := &archive.ListParameterHistoryResponse{}
-		err := client.HTTP.GetProto(fmt.Sprintf("/archive/%s/parameters/%s", instance, parameter), response)
+		err := manager.GetProto(fmt.Sprintf("/archive/%s/parameters/%s", instance, parameter), response)
 		if err != nil {
 			return nil, "", err
 		}
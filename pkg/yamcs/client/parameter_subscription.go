@@ -59,7 +59,7 @@ func NewParameterSubscription(client *YamcsClient, instanceName, processorName s
 		Type:    "parameters",
 		Options: anyMessage,
 	}
-	_, callID, _, err := client.WebSocket.SendSync(message)
+	_, callID, _, err := client.WebSocket.SendSyncContext(client.Context(), message)
 	if err != nil {
 		return nil, err
 	}
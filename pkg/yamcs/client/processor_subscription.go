@@ -64,7 +64,7 @@ func (client *YamcsClient) newProcessorSubscription(instance string, processor s
 		Options: anyMessage,
 	}
 
-	_, callID, _, err := client.WebSocket.SendSync(message)
+	_, callID, _, err := client.WebSocket.SendSyncContext(client.Context(), message)
 	if err != nil {
 		return nil, err
 	}
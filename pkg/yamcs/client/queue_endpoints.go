@@ -0,0 +1,68 @@
+package client
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/commanding"
+	"google.golang.org/protobuf/proto"
+)
+
+// queuePath returns the API path of a command queue of a processor.
+func queuePath(instance Instance, processor Processor, queue string) string {
+	return fmt.Sprintf("/processors/%s/%s/queues/%s", instance.GetName(), processor.GetName(), url.PathEscape(queue))
+}
+
+// ListQueues retrieves the command queues of a processor with their pending commands.
+func (c *YamcsClient) ListQueues(instance Instance, processor Processor) ([]*commanding.CommandQueueInfo, error) {
+	response := &commanding.ListQueuesResponse{}
+	err := c.HTTP.GetProto(fmt.Sprintf("/processors/%s/%s/queues", instance.GetName(), processor.GetName()), response)
+	if err != nil {
+		return nil, err
+	}
+	return response.GetQueues(), nil
+}
+
+// GetQueue retrieves a command queue of a processor.
+func (c *YamcsClient) GetQueue(instance Instance, processor Processor, queue string) (*commanding.CommandQueueInfo, error) {
+	response := &commanding.CommandQueueInfo{}
+	if err := c.HTTP.GetProto(queuePath(instance, processor, queue), response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// EnableQueue lets a command queue release the commands it holds and the new ones.
+func (c *YamcsClient) EnableQueue(instance Instance, processor Processor, queue string) (*commanding.CommandQueueInfo, error) {
+	return c.setQueueState(instance, processor, queue, "enable", &commanding.EnableQueueRequest{})
+}
+
+// DisableQueue makes a command queue reject the commands it holds and the new ones.
+func (c *YamcsClient) DisableQueue(instance Instance, processor Processor, queue string) (*commanding.CommandQueueInfo, error) {
+	return c.setQueueState(instance, processor, queue, "disable", &commanding.DisableQueueRequest{})
+}
+
+// BlockQueue makes a command queue hold new commands until they are accepted or rejected.
+func (c *YamcsClient) BlockQueue(instance Instance, processor Processor, queue string) (*commanding.CommandQueueInfo, error) {
+	return c.setQueueState(instance, processor, queue, "block", &commanding.BlockQueueRequest{})
+}
+
+func (c *YamcsClient) setQueueState(instance Instance, processor Processor, queue, verb string, request proto.Message) (*commanding.CommandQueueInfo, error) {
+	response := &commanding.CommandQueueInfo{}
+	if err := c.HTTP.PostProto(queuePath(instance, processor, queue)+":"+verb, request, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// AcceptQueuedCommand releases a command held in a queue.
+func (c *YamcsClient) AcceptQueuedCommand(instance Instance, processor Processor, queue, commandID string) error {
+	path := queuePath(instance, processor, queue) + "/entries/" + url.PathEscape(commandID) + ":accept"
+	return c.HTTP.PostProto(path, &commanding.AcceptCommandRequest{}, nil)
+}
+
+// RejectQueuedCommand rejects a command held in a queue.
+func (c *YamcsClient) RejectQueuedCommand(instance Instance, processor Processor, queue, commandID string) error {
+	path := queuePath(instance, processor, queue) + "/entries/" + url.PathEscape(commandID) + ":reject"
+	return c.HTTP.PostProto(path, &commanding.RejectCommandRequest{}, nil)
+}
@@ -0,0 +1,128 @@
+package client
+
+import (
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/api"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/commanding"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// Topics of the command queue subscriptions.
+const (
+	QueueStatisticsTopic = "queue-stats"
+	QueueEventsTopic     = "queue-events"
+)
+
+// QueueStatisticsListener defines a callback for queue state and counter updates.
+type QueueStatisticsListener func(info *commanding.CommandQueueInfo)
+
+// QueueEventListener defines a callback for commands added to or leaving a queue.
+type QueueEventListener func(event *commanding.CommandQueueEvent)
+
+// QueueSubscription manages a subscription to the command queues of a processor,
+// on either the statistics or the events topic.
+type QueueSubscription struct {
+	subscriptionID int32
+	Topic          string
+	Instance       string
+	Processor      string
+	statsListener  QueueStatisticsListener
+	eventListener  QueueEventListener
+	client         *YamcsClient
+}
+
+// CreateQueueSubscription subscribes to a command queue topic of a processor.
+func (client *YamcsClient) CreateQueueSubscription(instance Instance, processor Processor, topic string) (*QueueSubscription, error) {
+	instanceName, processorName := instance.GetName(), processor.GetName()
+	subscription := &QueueSubscription{
+		client:    client,
+		Topic:     topic,
+		Instance:  instanceName,
+		Processor: processorName,
+	}
+
+	var request proto.Message = &commanding.SubscribeQueueEventsRequest{Instance: &instanceName, Processor: &processorName}
+	if topic == QueueStatisticsTopic {
+		request = &commanding.SubscribeQueueStatisticsRequest{Instance: &instanceName, Processor: &processorName}
+	}
+	anyMessage, err := anypb.New(request)
+	if err != nil {
+		return nil, err
+	}
+
+	message := &api.ClientMessage{
+		Type:    topic,
+		Options: anyMessage,
+	}
+
+	_, callID, _, err := client.WebSocket.SendSyncContext(client.Context(), message)
+	if err != nil {
+		return nil, err
+	}
+
+	subscription.subscriptionID = callID
+	client.QueueSubscriptions[callID] = subscription
+	return subscription, nil
+}
+
+// HandleQueueMessage processes incoming websocket messages for command queue updates.
+func (client *YamcsClient) HandleQueueMessage(message *api.ServerMessage) {
+	if message.GetType() != QueueStatisticsTopic && message.GetType() != QueueEventsTopic {
+		return
+	}
+
+	subscription, found := client.QueueSubscriptions[message.GetCall()]
+	if !found {
+		return
+	}
+
+	if message.GetType() == QueueStatisticsTopic {
+		info := &commanding.CommandQueueInfo{}
+		if err := message.Data.UnmarshalTo(info); err != nil {
+			backend.Logger.Debug("Error unmarshalling queue statistics data", "error", err)
+			return
+		}
+		if subscription.statsListener != nil {
+			subscription.statsListener(info)
+		}
+		return
+	}
+
+	event := &commanding.CommandQueueEvent{}
+	if err := message.Data.UnmarshalTo(event); err != nil {
+		backend.Logger.Debug("Error unmarshalling queue event data", "error", err)
+		return
+	}
+	if subscription.eventListener != nil {
+		subscription.eventListener(event)
+	}
+}
+
+// SetStatisticsListener assigns the listener of a statistics subscription.
+func (subscription *QueueSubscription) SetStatisticsListener(listener QueueStatisticsListener) {
+	subscription.statsListener = listener
+}
+
+// SetEventListener assigns the listener of an events subscription.
+func (subscription *QueueSubscription) SetEventListener(listener QueueEventListener) {
+	subscription.eventListener = listener
+}
+
+// Halt cancels the queue subscription.
+func (subscription *QueueSubscription) Halt() {
+	delete(subscription.client.QueueSubscriptions, subscription.subscriptionID)
+
+	cancelRequest := &api.CancelOptions{
+		Call: subscription.subscriptionID,
+	}
+
+	anyMessage, _ := anypb.New(cancelRequest)
+
+	message := &api.ClientMessage{
+		Type:    "cancel",
+		Options: anyMessage,
+	}
+
+	subscription.client.WebSocket.SendSync(message)
+}
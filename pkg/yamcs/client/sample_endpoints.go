@@ -20,8 +20,18 @@ func (client *YamcsClient) ClearSamplePointCount() {
 
 // setTime sets the start and end times in the HTTP query parameters.
 func (client *YamcsClient) setTime(start time.Time, end time.Time) {
-	client.HTTP.Query["start"] = start.Format(time.RFC3339)
-	client.HTTP.Query["stop"] = end.Format(time.RFC3339)
+	for key, value := range timeQuery(start, end) {
+		client.HTTP.Query[key] = value
+	}
+}
+
+// timeQuery returns the query parameters selecting the [start, end) window.
+// Paginated requests pass them to their iterator, which applies them to every page.
+func timeQuery(start time.Time, end time.Time) map[string]string {
+	return map[string]string{
+		"start": start.Format(time.RFC3339),
+		"stop":  end.Format(time.RFC3339),
+	}
 }
 
 // setTimeAndSampleCount sets both the time range and the sample point count in the HTTP query parameters.
@@ -0,0 +1,15 @@
+package client
+
+import (
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/server"
+)
+
+// GetServerInfo retrieves general information about the Yamcs server, such as
+// its version and the command options it accepts.
+func (c *YamcsClient) GetServerInfo() (*server.GetServerInfoResponse, error) {
+	response := &server.GetServerInfoResponse{}
+	if err := c.HTTP.GetProto("", response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
@@ -58,7 +58,7 @@ func NewTimeSubscription(client *YamcsClient, instance string, processor string)
 		Options: anyMessage, // Attach the Any message containing the subscription request
 	}
 
-	_, callID, _, err := client.WebSocket.SendSync(message)
+	_, callID, _, err := client.WebSocket.SendSyncContext(client.Context(), message)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,77 @@
+package client
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/activities"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/protobuf/timeline"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/types"
+	corehttp "github.com/jaops-space/grafana-yamcs-jaops/pkg/yamcs/core/http"
+)
+
+// timelineItemPath returns the API path of a timeline item. Items are kept in
+// the default "rdb" source, the only one Yamcs schedules activities from.
+func timelineItemPath(instance Instance, id string) string {
+	return fmt.Sprintf("/timeline/%s/items/%s", instance.GetName(), url.PathEscape(id))
+}
+
+// ListTimelineItems returns an iterator over the timeline items overlapping
+// the time range, with their activity definitions.
+func (c *YamcsClient) ListTimelineItems(instance Instance, start, end time.Time) *types.PaginatedRequestIterator[[]*timeline.TimelineItem] {
+	instanceName := instance.GetName()
+	iterator := types.NewPaginatedRequestIterator(c.HTTP, func(manager *corehttp.HTTPManager) ([]*timeline.TimelineItem, string, error) {
+		response := &timeline.ListItemsResponse{}
+		if err := manager.GetProto(fmt.Sprintf("/timeline/%s/items", instanceName), response); err != nil {
+			return nil, "", err
+		}
+		return response.GetItems(), response.GetContinuationToken(), nil
+	})
+	query := timeQuery(start, end)
+	query["details"] = "true"
+	iterator.SetQuery(query)
+	return iterator
+}
+
+// GetTimelineItem retrieves a timeline item.
+func (c *YamcsClient) GetTimelineItem(instance Instance, id string) (*timeline.TimelineItem, error) {
+	item := &timeline.TimelineItem{}
+	if err := c.HTTP.GetProto(timelineItemPath(instance, id), item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// CreateTimelineItem adds an item to the timeline.
+func (c *YamcsClient) CreateTimelineItem(instance Instance, request *timeline.CreateItemRequest) (*timeline.TimelineItem, error) {
+	item := &timeline.TimelineItem{}
+	if err := c.HTTP.PostProto(fmt.Sprintf("/timeline/%s/items", instance.GetName()), request, item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// UpdateTimelineItem changes the timing, tags or status of a timeline item.
+func (c *YamcsClient) UpdateTimelineItem(instance Instance, id string, request *timeline.UpdateItemRequest) (*timeline.TimelineItem, error) {
+	item := &timeline.TimelineItem{}
+	if err := c.HTTP.PutProto(timelineItemPath(instance, id), request, item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// DeleteTimelineItem removes an item from the timeline.
+func (c *YamcsClient) DeleteTimelineItem(instance Instance, id string) error {
+	return c.HTTP.DeleteProto(timelineItemPath(instance, id), &timeline.DeleteItemRequest{}, nil)
+}
+
+// CancelActivity stops a running activity.
+func (c *YamcsClient) CancelActivity(instance Instance, id string) (*activities.ActivityInfo, error) {
+	activity := &activities.ActivityInfo{}
+	path := fmt.Sprintf("/activities/%s/activities/%s:cancel", instance.GetName(), url.PathEscape(id))
+	if err := c.HTTP.PostProto(path, &activities.CancelActivityRequest{}, activity); err != nil {
+		return nil, err
+	}
+	return activity, nil
+}
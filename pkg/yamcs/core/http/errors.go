@@ -0,0 +1,119 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/api"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxRawErrorLength bounds how much of a non-Yamcs error body (e.g. an HTML page
+// from a reverse proxy) is kept as the error message.
+const maxRawErrorLength = 512
+
+// YamcsError is a non-2xx response from Yamcs, decoded from the
+// api.ExceptionMessage the server sends in the response body.
+type YamcsError struct {
+	// StatusCode is the HTTP status of the response.
+	StatusCode int
+	// Code is the code reported in the exception body, usually equal to StatusCode.
+	Code int32
+	// Type is the Yamcs exception type, e.g. "NotFoundException".
+	Type string
+	// Message is the human readable message, e.g. "No such parameter".
+	Message string
+	// Method and Path identify the request that failed.
+	Method string
+	Path   string
+	// RetryAfter is the pause requested by the server through the Retry-After header, if any.
+	RetryAfter time.Duration
+}
+
+func (e *YamcsError) Error() string {
+	message := e.Message
+	if message == "" {
+		message = http.StatusText(e.StatusCode)
+	}
+	if e.Type != "" {
+		return fmt.Sprintf("Yamcs %s %s failed (%d %s): %s", e.Method, e.Path, e.StatusCode, e.Type, message)
+	}
+	return fmt.Sprintf("Yamcs %s %s failed (%d): %s", e.Method, e.Path, e.StatusCode, message)
+}
+
+// Source classifies the error for Grafana: most failures are caused by Yamcs or
+// by what the user asked for, a few mean the plugin built a bad request.
+func (e *YamcsError) Source() backend.ErrorSource {
+	return backend.ErrorSourceFromHTTPStatus(e.StatusCode)
+}
+
+// AsYamcsError returns the YamcsError wrapped in err, if any.
+func AsYamcsError(err error) (*YamcsError, bool) {
+	var yamcsErr *YamcsError
+	if errors.As(err, &yamcsErr) {
+		return yamcsErr, true
+	}
+	return nil, false
+}
+
+// jsonException mirrors api.ExceptionMessage as Yamcs serializes it in JSON.
+// It is decoded with encoding/json so that an unknown detail type does not
+// prevent reading the message.
+type jsonException struct {
+	Code int32  `json:"code"`
+	Type string `json:"type"`
+	Msg  string `json:"msg"`
+}
+
+// decodeErrorResponse builds a YamcsError from a non-2xx response. The body is
+// decoded according to its Content-Type rather than the negotiated protocol,
+// since errors raised in front of Yamcs (proxies, auth gateways) are usually
+// plain text or HTML.
+func decodeErrorResponse(resp *http.Response, body []byte) *YamcsError {
+	yamcsErr := &YamcsError{
+		StatusCode: resp.StatusCode,
+		Code:       int32(resp.StatusCode),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+	if resp.Request != nil {
+		yamcsErr.Method = resp.Request.Method
+		yamcsErr.Path = resp.Request.URL.Path
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/protobuf", "application/x-protobuf":
+		exc := &api.ExceptionMessage{}
+		if err := proto.Unmarshal(body, exc); err == nil && (exc.GetMsg() != "" || exc.GetType() != "") {
+			applyException(yamcsErr, exc.GetCode(), exc.GetType(), exc.GetMsg())
+			return yamcsErr
+		}
+	case "application/json":
+		exc := jsonException{}
+		if err := json.Unmarshal(body, &exc); err == nil && (exc.Msg != "" || exc.Type != "") {
+			applyException(yamcsErr, exc.Code, exc.Type, exc.Msg)
+			return yamcsErr
+		}
+	}
+
+	raw := strings.TrimSpace(string(body))
+	if len(raw) > maxRawErrorLength {
+		raw = raw[:maxRawErrorLength] + "..."
+	}
+	yamcsErr.Message = raw
+	return yamcsErr
+}
+
+func applyException(yamcsErr *YamcsError, code int32, excType string, message string) {
+	if code != 0 {
+		yamcsErr.Code = code
+	}
+	yamcsErr.Type = excType
+	yamcsErr.Message = message
+}
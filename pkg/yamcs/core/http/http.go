@@ -2,14 +2,20 @@ package http
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"maps"
 	"net/http"
+	neturl "net/url"
 	"strings"
+	"time"
 
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
-	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/exception"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/telemetry"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // HTTPManager represents a connection to a Yamcs server
@@ -25,6 +31,11 @@ type HTTPManager struct {
 	OnTokenUpdate func(Credentials)
 
 	RefreshStop chan struct{} // Channel to stop the refresh ticker
+
+	Retry   RetryPolicy     // Retry policy applied to idempotent requests
+	Breaker *CircuitBreaker // Circuit breaker shared by all managers of the same host
+
+	ctx context.Context // Context requests are bound to, see WithContext
 }
 
 // NewHTTPManager initializes a new Yamcs HTTPManager.
@@ -34,16 +45,10 @@ type HTTPManager struct {
 func NewHTTPManager(address string, tlsConfig TLS, credentials Credentials, userAgent string, keepAlive bool, protobuf bool, existingClient *http.Client) (*HTTPManager, error) {
 	address = strings.TrimSuffix(address, "/")
 
-	var url, authRoot, apiRoot string
-
 	// Determine the scheme based on TLS configuration
-	scheme := "http"
-	if tlsConfig.Enabled {
-		scheme = "https"
-	}
-	url = fmt.Sprintf("%s://%s", scheme, address)
-	authRoot = fmt.Sprintf("%s://%s/auth", scheme, address)
-	apiRoot = fmt.Sprintf("%s://%s/api", scheme, address)
+	url := BaseURL(address, tlsConfig)
+	authRoot := url + "/auth"
+	apiRoot := url + "/api"
 
 	opts := httpclient.Options{}
 	if tlsConfig.Enabled {
@@ -66,6 +71,8 @@ func NewHTTPManager(address string, tlsConfig TLS, credentials Credentials, user
 		Query:         make(map[string]string),
 		Credentials:   credentials,
 		UsingProtobuf: protobuf,
+		Retry:         DefaultRetryPolicy,
+		Breaker:       BreakerFor(url),
 	}
 
 	if protobuf {
@@ -91,18 +98,106 @@ func NewHTTPManager(address string, tlsConfig TLS, credentials Credentials, user
 	return manager, nil
 }
 
-// SendRequest sends an HTTP request and automatically applies credentials
-func (m *HTTPManager) SendRequest(method string, url string, body []byte) ([]byte, error) {
+// WithContext returns a shallow copy of the manager bound to ctx. Requests sent
+// through the copy are traced as children of the span carried by ctx and are
+// aborted when ctx is cancelled. Headers and credentials are shared with the
+// original manager; pending query parameters are copied so that concurrent
+// callers do not see each other's parameters.
+func (m *HTTPManager) WithContext(ctx context.Context) *HTTPManager {
+	clone := *m
+	clone.ctx = ctx
+	clone.Query = maps.Clone(m.Query)
+	if clone.Query == nil {
+		clone.Query = make(map[string]string)
+	}
+	return &clone
+}
+
+// Context returns the context the manager is bound to, or context.Background()
+// if none was set.
+func (m *HTTPManager) Context() context.Context {
+	if m.ctx == nil {
+		return context.Background()
+	}
+	return m.ctx
+}
+
+// SendRequest sends an HTTP request and automatically applies credentials.
+// Idempotent requests are retried on transient failures according to the
+// manager's RetryPolicy, and no request is sent while the host's circuit
+// breaker is open.
+func (m *HTTPManager) SendRequest(method string, url string, body []byte) (respBody []byte, err error) {
+	// Query parameters apply to this request only, including its retries.
+	query := m.Query
+	m.Query = make(map[string]string)
+
+	ctx, span := telemetry.Start(m.Context(), "yamcs.http "+method,
+		telemetry.AttributeHTTPMethod.String(method),
+		telemetry.AttributeHTTPPath.String(urlPath(url)),
+	)
+	defer func() { telemetry.End(span, err) }()
+
+	attempts := 1
+	if isIdempotentMethod(method) && m.Retry.MaxAttempts > 1 {
+		attempts = m.Retry.MaxAttempts
+	}
+
+	for attempt := 1; ; attempt++ {
+		if err := m.Breaker.Allow(); err != nil {
+			return nil, backend.DownstreamError(err)
+		}
+
+		var status int
+		respBody, status, err = m.doRequest(ctx, method, url, query, body)
+		if ctx.Err() != nil {
+			// The caller went away; this says nothing about the host.
+			m.Breaker.Release()
+		} else {
+			m.Breaker.Record(err)
+		}
+		if status != 0 {
+			span.SetAttributes(telemetry.AttributeHTTPStatus.Int(status))
+		}
+
+		if err == nil || attempt >= attempts || ctx.Err() != nil || !isRetryable(err) {
+			return respBody, err
+		}
+
+		var retryAfter time.Duration
+		if yamcsErr, ok := AsYamcsError(err); ok {
+			retryAfter = yamcsErr.RetryAfter
+		}
+		if retryAfter > m.Retry.MaxRetryAfter {
+			return respBody, err
+		}
+		wait := m.Retry.backoff(attempt, retryAfter)
+		span.AddEvent("retry", trace.WithAttributes(
+			telemetry.AttributeAttempt.Int(attempt),
+			telemetry.AttributeBackoff.String(wait.String()),
+		))
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return respBody, err
+		case <-timer.C:
+		}
+	}
+}
+
+// doRequest performs a single attempt of SendRequest and returns the response
+// status, or 0 if no response was received.
+func (m *HTTPManager) doRequest(ctx context.Context, method string, url string, query map[string]string, body []byte) ([]byte, int, error) {
 	if m.Credentials != nil && m.Credentials.IsExpired() {
 		if err := m.Credentials.Refresh(m); err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 	}
 
-	reader := bytes.NewReader(body)
-	req, err := http.NewRequest(method, url, reader)
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	req.Close = true
@@ -114,35 +209,44 @@ func (m *HTTPManager) SendRequest(method string, url string, body []byte) ([]byt
 
 	// Apply query parameters
 	q := req.URL.Query()
-	for k, v := range m.Query {
+	for k, v := range query {
 		q.Set(k, v)
 	}
 	req.URL.RawQuery = q.Encode()
-	m.Query = make(map[string]string)
 
 	// Apply credentials
 	if m.Credentials != nil {
 		if err := m.Credentials.BeforeRequest(req); err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 	}
 
 	resp, err := m.Client.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer resp.Body.Close()
 
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, resp.StatusCode, err
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		return respBody, exception.New(fmt.Sprintf("Status code was %d", resp.StatusCode), "HTTP_STATUS_NOT_OK")
+		yamcsErr := decodeErrorResponse(resp, respBody)
+		return respBody, resp.StatusCode, backend.NewErrorWithSource(yamcsErr, yamcsErr.Source())
 	}
 
-	return respBody, nil
+	return respBody, resp.StatusCode, nil
+}
+
+// urlPath returns the path of rawURL for tracing, or rawURL itself if it cannot be parsed.
+func urlPath(rawURL string) string {
+	parsed, err := neturl.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return parsed.Path
 }
 
 // SendJSONRequest sends a JSON HTTP request
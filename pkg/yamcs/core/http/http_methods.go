@@ -3,8 +3,6 @@ package http
 import (
 	"fmt"
 
-	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/api"
-	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/exception"
 	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
 )
@@ -21,16 +19,10 @@ func (httpManager *HTTPManager) ProtoRequest(method, path string, body proto.Mes
 		return err
 	}
 
-	// Send the request and capture the response
+	// Send the request and capture the response. Error responses are already
+	// decoded into a YamcsError by SendRequest.
 	response, err := httpManager.SendRequest(method, url, marshalledBody)
-	if err != nil && response != nil {
-		exc := &api.ExceptionMessage{}
-		err := unmarshalResponse(response, exc, httpManager.UsingProtobuf)
-		if err != nil {
-			return exception.Wrap("Error unmarshalling error after HTTP error", "HTTP_API_ERROR", err)
-		}
-		return exception.Wrap(fmt.Sprintf("Error in %s call to \"%s\", type: %s, message: %s\n", method, path, exc.GetType(), exc.GetMsg()), "HTTP_API_ERROR", err)
-	} else if err != nil {
+	if err != nil {
 		return err
 	}
 
@@ -0,0 +1,289 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RetryPolicy controls how idempotent requests are retried after a transient failure.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one. Values
+	// below 2 disable retries.
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry; it doubles on every attempt.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the exponential backoff.
+	MaxBackoff time.Duration
+	// MaxRetryAfter is the longest Retry-After the client is willing to honor. A
+	// server asking for a longer pause fails the request immediately instead.
+	MaxRetryAfter time.Duration
+}
+
+// DefaultRetryPolicy rides out a Yamcs restart or a proxy hiccup without holding
+// a panel query for more than a few seconds.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 250 * time.Millisecond,
+	MaxBackoff:     4 * time.Second,
+	MaxRetryAfter:  10 * time.Second,
+}
+
+// backoff returns the wait before the given retry (1 for the first retry). The
+// server's Retry-After wins over the computed backoff when present.
+func (p RetryPolicy) backoff(retry int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		return retryAfter
+	}
+	wait := p.InitialBackoff << (retry - 1)
+	if wait <= 0 || wait > p.MaxBackoff {
+		wait = p.MaxBackoff
+	}
+	// Up to 20% jitter so panels refreshing together do not retry in lockstep.
+	return wait - time.Duration(rand.Int64N(int64(wait)/5+1))
+}
+
+// isIdempotentMethod reports whether a request with this method can safely be sent twice.
+func isIdempotentMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead
+}
+
+// isRetryable reports whether err is a transient failure worth retrying.
+func isRetryable(err error) bool {
+	// A deadline counts as transient: the HTTP client's own timeout is reported as
+	// context.DeadlineExceeded too. The caller's own deadline is checked by SendRequest.
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var openErr *CircuitOpenError
+	if errors.As(err, &openErr) {
+		return false
+	}
+	if yamcsErr, ok := AsYamcsError(err); ok {
+		switch yamcsErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	// Anything else failed before a response was received: refused connection,
+	// reset, DNS failure.
+	return true
+}
+
+// isHostFailure reports whether err means the host itself is unhealthy, as opposed
+// to a request Yamcs answered with an error.
+func isHostFailure(err error) bool {
+	if yamcsErr, ok := AsYamcsError(err); ok {
+		switch yamcsErr.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	return isRetryable(err)
+}
+
+// parseRetryAfter reads a Retry-After header given either in seconds or as an HTTP date.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := date.Sub(now); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
+
+// BreakerState is the state of a CircuitBreaker.
+type BreakerState string
+
+const (
+	// BreakerClosed lets every request through.
+	BreakerClosed BreakerState = "closed"
+	// BreakerOpen rejects requests until the cooldown has elapsed.
+	BreakerOpen BreakerState = "open"
+	// BreakerHalfOpen lets a single probe request through to test the host.
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+// BreakerStatus is a snapshot of a CircuitBreaker, suitable for health reports.
+type BreakerStatus struct {
+	State               BreakerState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutiveFailures"`
+	LastError           string       `json:"lastError,omitempty"`
+	OpenedAt            *time.Time   `json:"openedAt,omitempty"`
+	RetryAt             *time.Time   `json:"retryAt,omitempty"`
+}
+
+// CircuitOpenError is returned without contacting the host while its breaker is open.
+type CircuitOpenError struct {
+	Host    string
+	RetryAt time.Time
+	Cause   string
+}
+
+func (e *CircuitOpenError) Error() string {
+	message := fmt.Sprintf("Yamcs host %s is unavailable, requests are suspended until %s", e.Host, e.RetryAt.Format(time.RFC3339))
+	if e.Cause != "" {
+		message += " (last error: " + e.Cause + ")"
+	}
+	return message
+}
+
+// CircuitBreaker stops requests to a host after repeated failures, so that a dead
+// Yamcs server is not hammered by every panel refresh. After the cooldown a single
+// probe is let through; its outcome closes or re-opens the circuit.
+type CircuitBreaker struct {
+	// Host identifies the breaker in errors and logs.
+	Host string
+	// FailureThreshold is the number of consecutive host failures that opens the circuit.
+	FailureThreshold int
+	// Cooldown is how long the circuit stays open before probing again.
+	Cooldown time.Duration
+
+	mu        sync.Mutex
+	state     BreakerState
+	failures  int
+	lastError string
+	openedAt  time.Time
+	probing   bool
+
+	now func() time.Time
+}
+
+// NewCircuitBreaker creates a closed breaker with the default threshold and cooldown.
+func NewCircuitBreaker(host string) *CircuitBreaker {
+	return &CircuitBreaker{
+		Host:             host,
+		FailureThreshold: 5,
+		Cooldown:         30 * time.Second,
+		state:            BreakerClosed,
+		now:              time.Now,
+	}
+}
+
+// Allow returns a CircuitOpenError if the request must not be sent.
+func (b *CircuitBreaker) Allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		retryAt := b.openedAt.Add(b.Cooldown)
+		if b.now().Before(retryAt) {
+			return &CircuitOpenError{Host: b.Host, RetryAt: retryAt, Cause: b.lastError}
+		}
+		b.state = BreakerHalfOpen
+		b.probing = true
+		return nil
+	case BreakerHalfOpen:
+		if b.probing {
+			return &CircuitOpenError{Host: b.Host, RetryAt: b.now().Add(b.Cooldown), Cause: b.lastError}
+		}
+		b.probing = true
+		return nil
+	}
+	return nil
+}
+
+// Record updates the breaker with the outcome of a request it allowed.
+func (b *CircuitBreaker) Record(err error) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if err == nil || !isHostFailure(err) {
+		b.state = BreakerClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	b.lastError = err.Error()
+	if b.state == BreakerHalfOpen || b.failures >= b.FailureThreshold {
+		b.state = BreakerOpen
+		b.openedAt = b.now()
+	}
+}
+
+// Release gives up a probe slot taken by Allow without recording an outcome, e.g.
+// when the caller cancelled the request.
+func (b *CircuitBreaker) Release() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	b.probing = false
+	b.mu.Unlock()
+}
+
+// Status returns a snapshot of the breaker.
+func (b *CircuitBreaker) Status() BreakerStatus {
+	if b == nil {
+		return BreakerStatus{State: BreakerClosed}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := BreakerStatus{
+		State:               b.state,
+		ConsecutiveFailures: b.failures,
+		LastError:           b.lastError,
+	}
+	if b.state != BreakerClosed {
+		openedAt := b.openedAt
+		retryAt := openedAt.Add(b.Cooldown)
+		status.OpenedAt = &openedAt
+		status.RetryAt = &retryAt
+	}
+	return status
+}
+
+var (
+	breakers      = make(map[string]*CircuitBreaker)
+	breakersMutex sync.Mutex
+)
+
+// BreakerFor returns the circuit breaker shared by every manager talking to baseURL,
+// so that health checks and live endpoints see the same host state.
+func BreakerFor(baseURL string) *CircuitBreaker {
+	breakersMutex.Lock()
+	defer breakersMutex.Unlock()
+
+	breaker, exists := breakers[baseURL]
+	if !exists {
+		breaker = NewCircuitBreaker(baseURL)
+		breakers[baseURL] = breaker
+	}
+	return breaker
+}
+
+// BaseURL returns the URL of a Yamcs server given its address as configured.
+func BaseURL(address string, tlsConfig TLS) string {
+	scheme := "http"
+	if tlsConfig.Enabled {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s", scheme, strings.TrimSuffix(address, "/"))
+}
@@ -11,4 +11,7 @@ const (
 	TimeListenerID           ListenerID = "TIME_LISTENER"
 	LinksListenerID          ListenerID = "LINKS_LISTENER"
 	ProcessorListenerID      ListenerID = "PROCESSOR_LISTENER"
+	MdbChangesListenerID     ListenerID = "MDB_CHANGES_LISTENER"
+	QueuesListenerID         ListenerID = "QUEUES_LISTENER"
+	ActivitiesListenerID     ListenerID = "ACTIVITIES_LISTENER"
 )
@@ -1,6 +1,7 @@
 package ws
 
 import (
+	"context"
 	"net/http"
 	"sync"
 	"sync/atomic"
@@ -10,6 +11,7 @@ import (
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/jaops-space/grafana-yamcs-jaops/api/yamcs/api"
 	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/exception"
+	"github.com/jaops-space/grafana-yamcs-jaops/pkg/utils/telemetry"
 	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
 
@@ -167,6 +169,20 @@ func (websocketHandler *WebSocketHandler) ForceDisconnect() {
 }
 
 func (websocketHandler *WebSocketHandler) SendSync(message *api.ClientMessage) (*api.Reply, int32, int32, error) {
+	return websocketHandler.SendSyncContext(context.Background(), message)
+}
+
+// SendSyncContext sends a message and waits for the matching reply, tracing the
+// round-trip as a child of the span carried by ctx.
+func (websocketHandler *WebSocketHandler) SendSyncContext(ctx context.Context, message *api.ClientMessage) (reply *api.Reply, call int32, seq int32, err error) {
+	_, span := telemetry.Start(ctx, "yamcs.ws.SendSync",
+		telemetry.AttributeMessage.String(message.GetType()),
+	)
+	defer func() {
+		span.SetAttributes(telemetry.AttributeCall.Int(int(call)))
+		telemetry.End(span, err)
+	}()
+
 	websocketHandler.mutex.Lock()
 	message.Id = websocketHandler.currentPacketID
 	currentID := websocketHandler.currentPacketID
@@ -174,7 +190,6 @@ func (websocketHandler *WebSocketHandler) SendSync(message *api.ClientMessage) (
 	websocketHandler.mutex.Unlock()
 
 	var data []byte
-	var err error
 	if websocketHandler.useProtobuf {
 		data, err = proto.Marshal(message)
 	} else {
@@ -193,8 +208,6 @@ func (websocketHandler *WebSocketHandler) SendSync(message *api.ClientMessage) (
 	websocketHandler.mutex.Unlock()
 
 	done := make(chan struct{})
-	var reply *api.Reply
-	var call, seq int32
 
 	websocketHandler.mutex.Lock()
 	websocketHandler.messageCallbacks[currentID] = func(returnedCall int32, returnedSeq int32, returnedReply *api.Reply) {
@@ -211,6 +224,11 @@ func (websocketHandler *WebSocketHandler) SendSync(message *api.ClientMessage) (
 		delete(websocketHandler.messageCallbacks, currentID)
 		websocketHandler.mutex.Unlock()
 		return reply, call, seq, nil
+	case <-ctx.Done():
+		websocketHandler.mutex.Lock()
+		delete(websocketHandler.messageCallbacks, currentID)
+		websocketHandler.mutex.Unlock()
+		return nil, 0, 0, ctx.Err()
 	case <-time.After(10 * time.Second):
 		websocketHandler.mutex.Lock()
 		delete(websocketHandler.messageCallbacks, currentID)
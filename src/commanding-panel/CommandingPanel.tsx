@@ -1,9 +1,10 @@
-import React, { useState } from 'react';
+import React, { useCallback, useState } from 'react';
 import { CommandForms } from './types';
 import { useLocationService } from '@grafana/runtime';
 import { ButtonGroupPreview } from './components/ButtonGroupPreview';
 import { CommandButton } from './components/CommandButton';
 import { CommandCard } from './components/CommandCard';
+import { CommandProgress } from './components/CommandProgress';
 import { LayoutEditor } from './components/LayoutEditor';
 import { VariableRuntime } from './components/VariableRuntime';
 import { useCommandInfos } from './hooks/useCommandInfos';
@@ -11,7 +12,7 @@ import { useCommandSubmit } from './hooks/useCommandSubmit';
 import { useDatasource } from './hooks/useDatasource';
 import { useDualButtonStates } from './hooks/useDualButtonStates';
 import { useDualCommandInfos } from './hooks/useDualCommandInfos';
-import { CommandErrors, CommandingPanelProps } from './types';
+import { CommandErrors, CommandingPanelProps, IssuedCommands } from './types';
 import { getCommandKey } from './utils/commandKeys';
 import { getEditorCardsStyle, getRuntimeButtonWrapperStyle, getRuntimeLayoutStyle } from './utils/layout';
 import { setArgumentError, validateCommandArgument } from './utils/validation';
@@ -37,6 +38,12 @@ export default function CommandingPanel({ variableMode = false, ...props }: Comm
     const [formState, setFormState] = useState<CommandForms>(options.commandForms || {});
     const [errors, setErrors] = useState<CommandErrors>({});
     const [loading, setLoading] = useState(false);
+    const [issued, setIssued] = useState<IssuedCommands>({});
+    const handleIssued = useCallback(
+        (commandKey: string, id: string, endpoint: string) =>
+            setIssued((prev) => ({ ...prev, [commandKey]: { id, endpoint } })),
+        []
+    );
     const { dualButtonStates, updateDualButtonStates } = useDualButtonStates(props.id, options, onOptionsChange);
 
     const hasGroupPreview = editing && commandInfos.length > 1;
@@ -95,6 +102,7 @@ export default function CommandingPanel({ variableMode = false, ...props }: Comm
         dualCommandInfos,
         dualButtonStates,
         updateDualButtonStates,
+        onIssued: handleIssued,
     });
 
     const renderRuntimeButton = (commandInfo: any, index: number, preview = false) => {
@@ -151,6 +159,7 @@ export default function CommandingPanel({ variableMode = false, ...props }: Comm
                         return (
                             <div key={commandKey} style={getRuntimeButtonWrapperStyle(options)}>
                                 {renderRuntimeButton(commandInfo, index)}
+                                {!variableMode && <CommandProgress datasource={datasource} issued={issued[commandKey]} />}
                             </div>
                         );
                     }
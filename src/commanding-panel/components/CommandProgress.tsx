@@ -0,0 +1,68 @@
+import React from 'react';
+import { Icon, Tooltip, useTheme2 } from '@grafana/ui';
+import { DataSourceWithBackend } from '@grafana/runtime';
+import { useCommandProgress } from '../hooks/useCommandProgress';
+import { CommandStage, IssuedCommand } from '../types';
+
+function stageLabel(stage: CommandStage): string {
+    if (stage.name === 'CommandComplete') {
+        return 'completed';
+    }
+    return stage.name.replace(/^(Acknowledge|Verifier)_/, '').toLowerCase();
+}
+
+function stageTooltip(stage: CommandStage): string {
+    const time = stage.time ? new Date(stage.time).toISOString() : undefined;
+    return [stage.status, time, stage.message].filter(Boolean).join(' · ');
+}
+
+/**
+ * Shows the acknowledgments and verifiers of the last command issued from a button,
+ * e.g. "queued → released → sent → completed".
+ */
+export function CommandProgress(props: { datasource: DataSourceWithBackend | null; issued?: IssuedCommand }) {
+    const theme = useTheme2();
+    const progress = useCommandProgress(props.datasource, props.issued);
+    if (!progress || progress.stages.length === 0) {
+        return null;
+    }
+
+    const color = (status: string) => {
+        switch (status) {
+            case 'OK':
+                return theme.colors.success.text;
+            case 'NOK':
+            case 'TIMEOUT':
+            case 'CANCELLED':
+                return theme.colors.error.text;
+            default:
+                return theme.colors.text.secondary;
+        }
+    };
+
+    return (
+        <div
+            style={{
+                display: 'flex',
+                flexWrap: 'wrap',
+                alignItems: 'center',
+                gap: 4,
+                fontSize: theme.typography.bodySmall.fontSize,
+            }}
+        >
+            {progress.stages.map((stage, index) => (
+                <React.Fragment key={stage.name}>
+                    {index > 0 && <span style={{ color: theme.colors.text.disabled }}>→</span>}
+                    <Tooltip content={stageTooltip(stage)}>
+                        <span style={{ color: color(stage.status) }}>{stageLabel(stage)}</span>
+                    </Tooltip>
+                </React.Fragment>
+            ))}
+            {progress.timedOut && !progress.complete && (
+                <Tooltip content="Stopped following the command before it completed">
+                    <Icon name="clock-nine" style={{ color: theme.colors.warning.text }} />
+                </Tooltip>
+            )}
+        </div>
+    );
+}
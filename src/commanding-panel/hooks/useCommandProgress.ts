@@ -0,0 +1,64 @@
+import { useEffect, useState } from 'react';
+import { DataFrame, LiveChannelScope, StreamingFrameAction } from '@grafana/data';
+import { DataSourceWithBackend, getGrafanaLiveSrv } from '@grafana/runtime';
+import { CommandProgress, CommandStage, IssuedCommand } from '../types';
+
+function toProgress(frame: DataFrame): CommandProgress {
+    const field = (name: string) => frame.fields.find((f) => f.name === name);
+    const times = field('time');
+    const names = field('stage');
+    const statuses = field('status');
+    const messages = field('message');
+    const stages: CommandStage[] = [];
+    for (let i = 0; i < frame.length; i++) {
+        stages.push({
+            name: names?.values[i],
+            status: statuses?.values[i],
+            time: times?.values[i] ?? undefined,
+            message: messages?.values[i] || undefined,
+        });
+    }
+    const custom = frame.meta?.custom ?? {};
+    return { stages, complete: Boolean(custom.complete), timedOut: Boolean(custom.timedOut) };
+}
+
+/**
+ * Follows an issued command through its acknowledgments and verifiers until it completes
+ * or the backend gives up on it.
+ */
+export function useCommandProgress(datasource: DataSourceWithBackend | null, issued?: IssuedCommand) {
+    const [progress, setProgress] = useState<CommandProgress | undefined>(undefined);
+
+    useEffect(() => {
+        setProgress(undefined);
+        if (!datasource || !issued?.id) {
+            return;
+        }
+
+        const subscription = getGrafanaLiveSrv()
+            .getDataStream({
+                buffer: { maxLength: 100, action: StreamingFrameAction.Replace },
+                addr: {
+                    scope: LiveChannelScope.DataSource,
+                    stream: datasource.uid,
+                    path: `req/${issued.endpoint}-command-${issued.id.replace(/[^\w.-]/g, '')}`,
+                    data: {
+                        refId: 'progress',
+                        type: 'command-progress',
+                        endpoint: issued.endpoint,
+                        tracking: { id: issued.id },
+                    },
+                },
+            })
+            .subscribe((response) => {
+                const frame = response.data?.[0] as DataFrame | undefined;
+                if (frame) {
+                    setProgress(toProgress(frame));
+                }
+            });
+
+        return () => subscription.unsubscribe();
+    }, [datasource, issued?.id, issued?.endpoint]);
+
+    return progress;
+}
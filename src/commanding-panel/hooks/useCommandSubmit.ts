@@ -16,6 +16,7 @@ export function useCommandSubmit(params: {
     dualCommandInfos: DualCommandInfos;
     dualButtonStates: DualButtonStates;
     updateDualButtonStates: (newStates: DualButtonStates) => void;
+    onIssued?: (commandKey: string, id: string, endpoint: string) => void;
 }) {
     const {
         datasource,
@@ -26,6 +27,7 @@ export function useCommandSubmit(params: {
         dualCommandInfos,
         dualButtonStates,
         updateDualButtonStates,
+        onIssued,
     } = params;
     const appEvents = getAppEvents();
 
@@ -104,8 +106,18 @@ export function useCommandSubmit(params: {
                     arguments: resolveArguments(argumentsToUse, activeCommandInfo, scopedVars),
                     comment: getTemplateSrv().replace(commentToUse || '', scopedVars),
                 })
-                .then(() => {
+                .then((response: any) => {
                     setLoading(false);
+                    if (response?.approval) {
+                        appEvents.publish({
+                            type: AppEvents.alertWarning.name,
+                            payload: [`Command ${commandNameToUse} is waiting for confirmation by a second user`],
+                        });
+                        return;
+                    }
+                    if (response?.id) {
+                        onIssued?.(commandKey, response.id, endpoint);
+                    }
                     if (commandData?.isDualButton) {
                         updateDualButtonStates({ ...dualButtonStates, [commandKey]: isOffCommand ? 'off' : 'on' });
                     }
@@ -122,6 +134,7 @@ export function useCommandSubmit(params: {
             dualButtonStates,
             dualCommandInfos,
             formState,
+            onIssued,
             scopedVars,
             setLoading,
             updateDualButtonStates,
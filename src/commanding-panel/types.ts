@@ -16,6 +16,42 @@ export type DualButtonStates = Record<string, 'on' | 'off'>;
 
 export type CommandErrors = Record<string, Record<string, string>>;
 
+export type CommandArgumentError = {
+    argument?: string;
+    code: 'MISSING' | 'UNKNOWN' | 'TYPE' | 'RANGE' | 'ENUM' | 'LENGTH' | 'REJECTED';
+    message: string;
+};
+
+// Command sent from a button, keyed by command key.
+export type IssuedCommand = {
+    id: string;
+    endpoint: string;
+};
+
+export type IssuedCommands = Record<string, IssuedCommand>;
+
+export type CommandStage = {
+    name: string; // e.g. Acknowledge_Sent, Verifier_Complete or CommandComplete
+    status: string; // e.g. OK, NOK, PENDING or TIMEOUT
+    time?: number;
+    message?: string;
+};
+
+export type CommandProgress = {
+    stages: CommandStage[];
+    complete: boolean;
+    timedOut: boolean;
+};
+
+// Response of the endpoint/{endpoint}/command/validate resource.
+export type CommandValidationResult = {
+    valid: boolean;
+    arguments: Record<string, any>;
+    errors: CommandArgumentError[];
+    binary?: string;
+    unprocessedBinary?: string;
+};
+
 export interface CommandingPanelProps extends PanelProps<PanelOptions> {
     variableMode?: boolean;
 }
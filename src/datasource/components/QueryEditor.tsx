@@ -92,7 +92,8 @@ export function QueryEditor(props: QueryProps) {
     const endpointOptions: Array<ComboboxOption<string>> = Object.entries(endpoints).map(([id, endpoint]) => {
         const online = (endpoint as any).online as boolean;
         const desc = (endpoint as any).description;
-        const statusLabel = online ? '🟢 Online' : '🔴 Offline';
+        const circuitOpen = (endpoint as any).circuit?.state === 'open';
+        const statusLabel = circuitOpen ? '🟠 Suspended after repeated failures' : online ? '🟢 Online' : '🔴 Offline';
         return {
             label: (endpoint as any).name || `#${id}`,
             description: desc ? `${desc} — ${statusLabel}` : statusLabel,
@@ -37,6 +37,41 @@ export const QueryOptions: Array<SelectableValue<QueryType>> = [
         category: QueryCategory.PARAMETER,
         additionalFields: false,
     },
+    {
+        label: 'State Timeline',
+        description: "List a discrete parameter's states over time with their durations and sample counts.",
+        value: QueryType.STATE_TIMELINE,
+        category: QueryCategory.PARAMETER,
+        additionalFields: false,
+    },
+    {
+        label: 'State Summary',
+        description: 'Total time spent in each state of a discrete parameter and its number of transitions.',
+        value: QueryType.STATE_SUMMARY,
+        category: QueryCategory.PARAMETER,
+        additionalFields: false,
+    },
+    {
+        label: 'Statistics',
+        description: 'Compute min, max, mean, standard deviation and percentiles of a numerical parameter over the time range.',
+        value: QueryType.STATISTICS,
+        category: QueryCategory.PARAMETER,
+        additionalFields: false,
+    },
+    {
+        label: 'Gaps',
+        description: 'List the intervals without data of a parameter, APID or packet, usable as annotations.',
+        value: QueryType.GAPS,
+        category: QueryCategory.PARAMETER,
+        additionalFields: false,
+    },
+    {
+        label: 'Completeness',
+        description: 'Share of time covered by data of a parameter, APID or packet over the time range.',
+        value: QueryType.COMPLETENESS,
+        category: QueryCategory.PARAMETER,
+        additionalFields: false,
+    },
     {
         label: 'Time',
         description: 'Display current Yamcs time.',
@@ -51,6 +86,13 @@ export const QueryOptions: Array<SelectableValue<QueryType>> = [
         category: QueryCategory.TIMELINE,
         additionalFields: false,
     },
+    {
+        label: 'Audit',
+        description: 'List the commands, alarm and link operations taken through this datasource, usable as annotations.',
+        value: QueryType.AUDIT,
+        category: QueryCategory.TIMELINE,
+        additionalFields: false,
+    },
     {
         label: 'Image',
         description: 'Visualize images.',
@@ -72,6 +114,20 @@ export const QueryOptions: Array<SelectableValue<QueryType>> = [
         category: QueryCategory.COMMANDING,
         additionalFields: false,
     },
+    {
+        label: 'Command Queues',
+        description: 'Monitor command queues and the commands waiting in each in real-time.',
+        value: QueryType.COMMAND_QUEUES,
+        category: QueryCategory.COMMANDING,
+        additionalFields: false,
+    },
+    {
+        label: 'Scheduled Commands',
+        description: 'List upcoming and executed commands scheduled on the Yamcs timeline, with their status.',
+        value: QueryType.SCHEDULED_COMMANDS,
+        category: QueryCategory.COMMANDING,
+        additionalFields: false,
+    },
     {
         label: 'Alarms',
         description: 'Monitor and manage active alarms in real-time.',
@@ -67,10 +67,20 @@ export class DataSource extends DataSourceWithBackend<Query, Configuration> {
                     pathName = 'subscriptions';
                 } else if (query.type === QueryType.COMMAND_HISTORY) {
                     pathName = 'commands';
+                } else if (query.type === QueryType.COMMAND_PROGRESS) {
+                    pathName = `command-${(query.tracking?.id ?? '').replace(/[^\w.-]/g, '')}`;
+                } else if (query.type === QueryType.COMMAND_STACK) {
+                    pathName = `stack-${query.stack?.run}`;
                 } else if (query.type === QueryType.ALARMS) {
                     pathName = 'alarms';
+                } else if (query.type === QueryType.COMMAND_QUEUES) {
+                    pathName = 'queues';
+                } else if (query.type === QueryType.SCHEDULED_COMMANDS) {
+                    pathName = 'schedule';
                 } else if (query.type === QueryType.LINKS) {
                     pathName = 'links';
+                } else if (query.type === QueryType.AUDIT) {
+                    pathName = 'audit';
                 }
 
                 let action = StreamingFrameAction.Append;
@@ -78,7 +88,11 @@ export class DataSource extends DataSourceWithBackend<Query, Configuration> {
                     query.type === QueryType.DEMANDS ||
                     query.type === QueryType.SUBSCRIPTIONS ||
                     query.type === QueryType.ALARMS ||
-                    query.type === QueryType.LINKS
+                    query.type === QueryType.LINKS ||
+                    query.type === QueryType.COMMAND_PROGRESS ||
+                    query.type === QueryType.COMMAND_STACK ||
+                    query.type === QueryType.COMMAND_QUEUES ||
+                    query.type === QueryType.SCHEDULED_COMMANDS
                 ) {
                     action = StreamingFrameAction.Replace;
                 }
@@ -15,6 +15,11 @@ export interface Query extends DataQuery {
     customVariableString: boolean;
     endpointVariable: string;
     frontendShiftedTime?: boolean;
+    // Lays aggregates and arrays out as one field per member or as member/value rows.
+    expand?: ExpansionMode;
+    // Derived parameter computed from other parameters, e.g. "BUS_V * BUS_I".
+    expression?: string;
+    unit?: string; // Overrides the unit propagated from the inputs
 
     // YAMCS parameter filter configuration
     yamcsFilter?: {
@@ -23,6 +28,61 @@ export interface Query extends DataQuery {
         operator: 'equals'; // Currently only equality
         value: string; // Expected value (e.g., "1")
     };
+
+    // Binary value decoding for image and single value queries
+    binary?: {
+        width?: number; // Width of raw sensor frames, in pixels
+        height?: number; // Height of raw sensor frames, in pixels
+        pixelFormat?: PixelFormat;
+        history?: number; // Number of latest images to return
+    };
+
+    // Statistics query configuration
+    statistics?: {
+        exact?: boolean; // Compute from raw values rather than sample buckets
+        percentiles?: number[]; // Percentiles to compute, e.g. 95
+        interval?: string; // Group into fixed intervals, e.g. "1h"
+        groupBy?: string; // Group by the state of another parameter
+    };
+
+    // Gaps and completeness query configuration
+    gaps?: {
+        source?: GapsSource;
+        minGap?: string; // Shortest gap reported, e.g. "30s"
+        maxGap?: string; // Longest silence that is not a gap, defaults to the parameter expiration
+        interval?: string; // Completeness interval, e.g. "1h"
+        stream?: string; // Index groups to keep by namespace, e.g. a stream name
+        name?: string; // Index groups to keep by name, e.g. an APID or packet name
+    };
+
+    // Command progress query configuration
+    tracking?: {
+        id: string; // Command ID returned by the command issue resource
+        timeout?: string; // Stop following the command after this long, e.g. "5m"
+    };
+
+    // Command stack query configuration
+    stack?: {
+        run: string; // Run ID returned by the stack run resource
+    };
+
+    // Audit query configuration
+    audit?: {
+        action?: string; // Action or action family, e.g. "command.issue" or "alarm"
+        user?: string; // Grafana user who took the action
+        outcome?: 'success' | 'failure' | 'denied' | 'pending';
+        overrides?: boolean; // Only commands sent with contingency issue options
+    };
+
+    // Command history query configuration
+    history?: {
+        layout?: 'commands' | 'stages'; // One row per command (default) or per command stage, with its latency
+        names?: string[]; // Command name patterns, as in command policies
+        user?: string; // Yamcs user who issued the command
+        origin?: string; // Origin of the command
+        queue?: string; // Queue the command went through
+        status?: 'success' | 'failure' | 'pending'; // Completion state of the command
+    };
 }
 
 /**
@@ -32,6 +92,11 @@ export enum QueryType {
     PLOT = 'plot',
     SINGLE = 'single',
     DISCRETE = 'discrete',
+    STATE_TIMELINE = 'state-timeline',
+    STATE_SUMMARY = 'state-summary',
+    STATISTICS = 'statistics',
+    GAPS = 'gaps',
+    COMPLETENESS = 'completeness',
     EVENTS = 'events',
     TIME = 'time',
     IMAGE = 'image',
@@ -41,6 +106,11 @@ export enum QueryType {
 
     COMMANDING = 'commanding',
     COMMAND_HISTORY = 'command-history',
+    COMMAND_PROGRESS = 'command-progress',
+    COMMAND_STACK = 'command-stack',
+    COMMAND_QUEUES = 'command-queues',
+    SCHEDULED_COMMANDS = 'scheduled-commands',
+    AUDIT = 'audit',
     ALARMS = 'alarms',
     LINKS = 'links',
 }
@@ -50,6 +120,13 @@ export enum QueryType {
  */
 export type QueryField = 'max' | 'min';
 
+export type ExpansionMode = 'wide' | 'long';
+
+export type PixelFormat = 'gray8' | 'gray16' | 'rgb24' | 'rgba32';
+
+// Parameter history, CCSDS completeness index (per APID) or packet index (per packet name).
+export type GapsSource = 'parameter' | 'apid' | 'packet';
+
 /**
  * Default values for a query.
  */
@@ -90,13 +167,65 @@ export interface Configuration extends DataSourceJsonData {
             host: string;
             instance: string;
             processor?: string;
+            derived?: DerivedParameterConfiguration[];
+            commanding?: CommandPolicyConfiguration;
         }
     >;
 
+    /**
+     * Audit log of operator actions. Enabled with defaults when omitted.
+     */
+    audit?: {
+        disabled?: boolean;
+        directory?: string; // Defaults to the Grafana data path
+        retentionDays?: number; // Defaults to 90
+        readerRole?: 'Viewer' | 'Editor' | 'Admin'; // Least role allowed to query the log, defaults to Editor
+    };
+
+    /**
+     * Historical query cache settings. The cache is enabled with defaults when omitted.
+     */
+    cache?: {
+        disabled?: boolean;
+        maxMemoryMB?: number;
+        settleSeconds?: number;
+    };
+
     bufferMaxLength: number;
     debugMode: boolean;
 }
 
+/**
+ * Virtual parameter computed by the backend from other parameters of an endpoint.
+ */
+export interface DerivedParameterConfiguration {
+    name: string;
+    expression: string;
+    unit?: string;
+    inputs?: Record<string, string>; // Expression variables mapped to Yamcs parameters
+}
+
+/**
+ * Who may send which commands through an endpoint, enforced by the backend.
+ */
+export interface CommandPolicyConfiguration {
+    rules: CommandRuleConfiguration[]; // Tried in order, the first match decides
+    defaultDeny?: boolean;
+    approvalWindowSeconds?: number; // Defaults to 300
+}
+
+export interface CommandRuleConfiguration {
+    pattern: string; // e.g. "/YSS/SIMULATOR/**" or "SWITCH_*"
+    deny?: boolean;
+    minRole?: 'Viewer' | 'Editor' | 'Admin';
+    teams?: string[];
+    confirm?: boolean; // Needs a second user to confirm
+    overrides?: boolean; // Allows the contingency issue options
+}
+
+// Secure field of the Grafana service account token used to read team members.
+export const GRAFANA_TOKEN_KEY = 'grafanaToken';
+
 export interface SecureConfiguration {
     [key: string]: string;
 }
@@ -115,9 +244,37 @@ export const DefaultSecureConfiguration = {} satisfies SecureConfiguration;
  */
 export type Optional<T> = T | null | undefined;
 
+export interface BreakerStatus {
+    state: 'closed' | 'open' | 'half-open';
+    consecutiveFailures: number;
+    lastError?: string;
+    openedAt?: string;
+    retryAt?: string;
+}
+
 export interface ItemStatus {
     status: 'ok' | 'warning' | 'error';
     message: string;
+    circuit?: BreakerStatus;
+}
+
+/**
+ * Parameter metadata, as returned by `endpoint/{id}/parameter/info`
+ */
+export interface ParameterMetadata {
+    qualifiedName: string;
+    path?: string;
+    displayName: string;
+    description?: string;
+    longDescription?: string;
+    aliases?: Record<string, string>;
+    type?: string;
+    unit?: string;
+    dataSource: string;
+    min?: number;
+    max?: number;
+    decimals?: number;
+    calibration?: string;
 }
 
 /**
@@ -150,3 +307,57 @@ export type Hosts = Configuration['hosts'];
 export type Endpoint = ValueOf<Endpoints>;
 export type Host = ValueOf<Hosts>;
 export type IndexedEndpoint = ValueOf<Configuration['endpoints']> & { index: string };
+
+// Step of a command stack run by the endpoint/{endpoint}/stack/run resource.
+export interface CommandStackStep {
+    type: 'command' | 'wait' | 'verifier' | 'condition';
+    command?: string;
+    arguments?: Record<string, any>;
+    comment?: string;
+    duration?: string; // Wait steps, e.g. "5s"
+    stage?: string; // Verifier steps, e.g. "Acknowledge_Sent" or "CommandComplete"
+    conditions?: Array<{ parameter: string; operator: 'eq' | 'neq' | 'lt' | 'lte' | 'gt' | 'gte'; value: string }>;
+    timeout?: string; // Verifier and condition steps, e.g. "30s"
+}
+
+export interface CommandStack {
+    name?: string;
+    steps: CommandStackStep[];
+    continueOnFailure?: boolean;
+}
+
+// Command waiting for a second user, returned by endpoint/{endpoint}/command/issue
+// with status 202 and listed by endpoint/{endpoint}/command/approvals.
+// Contingency issue options of endpoint/{endpoint}/command/issue. They must be
+// allowed by the command policy and require a justification comment.
+export interface CommandIssueOptions {
+    disableTransmissionConstraints?: boolean;
+    disableVerifiers?: boolean;
+    verifiers?: Record<string, { disable?: boolean; timeToStartChecking?: number; timeToStopChecking?: number }>;
+    stream?: string; // e.g. a backup TC stream
+    extra?: Record<string, string | boolean | number>;
+}
+
+// Command to schedule through endpoint/{endpoint}/schedule/command, at an
+// absolute time or relative to another timeline item such as a pass.
+export interface ScheduledCommandRequest {
+    name: string;
+    arguments?: Record<string, any>;
+    comment?: string;
+    start?: string; // RFC 3339
+    relativeTo?: string; // Timeline item ID, e.g. an AOS event
+    offset?: string; // Go duration after the item start, e.g. "5m"
+    tags?: string[];
+}
+
+export interface CommandApproval {
+    id: string;
+    endpoint: string;
+    command: string;
+    arguments: Record<string, any>;
+    options?: CommandIssueOptions;
+    comment?: string;
+    requestedBy: string;
+    requested: string;
+    expires: string;
+}